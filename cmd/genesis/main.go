@@ -2,39 +2,32 @@ package main
 
 import (
 	"github.com/alecthomas/kong"
-	"github.com/voidshard/genesis/pkg/genesis"
-	"github.com/voidshard/genesis/pkg/types"
+	"github.com/voidshard/genesis"
+	"github.com/voidshard/genesis/internal/server"
 )
 
-const desc = ``
+const desc = `Create & edit worlds`
 
-func main() {
-	cli := &genesis.Options{}
-	kong.Parse(cli, kong.Name("genesis"), kong.Description(desc))
+type serveCmd struct {
+	Addr string `default:":8080" help:"address to listen on"`
+}
 
-	gen, err := genesis.New(cli)
+func (c *serveCmd) Run(opts *genesis.Options) error {
+	gen, err := genesis.New(opts)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer gen.Close()
+	return server.New(gen).ListenAndServe(c.Addr)
+}
 
-	p := types.NewProject()
-	p.Name = "my-project"
-
-	err = gen.CreateProject(p)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println("project created", p.ID, p.Name)
+var cli struct {
+	genesis.Options `embed:""`
 
-	err = gen.SetDefaultProject(p.ID)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println("set project", p.ID)
+	Serve serveCmd `cmd:"" help:"serve the HTTP API"`
+}
 
-	found, err := gen.Projects([]string{p.ID})
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println("projects", found)
+func main() {
+	ctx := kong.Parse(&cli, kong.Name("genesis"), kong.Description(desc))
+	ctx.FatalIfErrorf(ctx.Run(&cli.Options))
 }
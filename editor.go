@@ -2,11 +2,18 @@ package genesis
 
 import (
 	"log"
+	"path/filepath"
+	"sync"
 
+	"github.com/voidshard/genesis/internal/blob"
 	"github.com/voidshard/genesis/internal/config"
 	"github.com/voidshard/genesis/internal/database"
 	"github.com/voidshard/genesis/internal/geography"
+	"github.com/voidshard/genesis/internal/jobs"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/render"
 	"github.com/voidshard/genesis/internal/search"
+	"github.com/voidshard/genesis/internal/tiles"
 )
 
 // Check Editor implements GenesisEditor
@@ -14,12 +21,20 @@ var _ GenesisEditor = new(Editor)
 
 // Editor is our top level struct
 type Editor struct {
-	cfg *config.Config
-	db  database.Database
-	sb  search.Search
+	cfg   *config.Config
+	db    database.Database
+	sb    search.Search
+	store blob.Blob
 
 	Geo     *geography.Settings
 	geoEdit *geography.Editor
+	jobs    *jobs.Runner
+
+	tiles     *tiles.Cache
+	ranges    map[string]*render.Ranges
+	rangeLock sync.Mutex
+
+	unhook func() // stops us hearing about saved canvases
 }
 
 //
@@ -41,13 +56,53 @@ func New(opts *Options) (*Editor, error) {
 		return nil, err
 	}
 
+	store, err := blob.New(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	runner, err := jobs.New(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// tiles are only a cache, so they're kept on local disk unless we're in memory
+	var tileStore blob.Blob = blob.NewMemory()
+	if cfg.Storage.Driver != config.StorageDriverMemory {
+		tileStore, err = blob.NewFilesystem(filepath.Join(cfg.Gen.Root, "tiles"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	gs := geography.DefaultSettings()
 
-	return &Editor{
+	e := &Editor{
 		cfg:     cfg,
 		db:      db,
 		sb:      sb,
+		store:   store,
 		Geo:     gs,
-		geoEdit: geography.New(cfg, db, gs),
-	}, nil
+		geoEdit: geography.New(cfg, db, store, gs),
+		jobs:    runner,
+		tiles:   tiles.New(tileStore),
+		ranges:  map[string]*render.Ranges{},
+	}
+	e.unhook = paint.OnSave(store, e.canvasChanged)
+
+	// finish (or throw away) canvas writes we were part way through when we last stopped
+	err = paint.Recover(store, cfg.Gen.Root)
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Close releases the editor, it must not be used afterwards
+func (e *Editor) Close() error {
+	e.unhook()
+	e.jobs.Close() // jobs save their final state, so this goes before the DB
+	return e.db.Close()
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	"github.com/voidshard/genesis"
 )
 
@@ -10,13 +12,14 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	ctx := context.Background()
 
 	p, err := gen.Project("my-project")
 	if err != nil {
 		panic(err)
 	}
 
-	err = gen.NextEpoch(p.ID)
+	err = gen.NextEpoch(ctx, p.ID)
 	if err != nil {
 		panic(err)
 	}
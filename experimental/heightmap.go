@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/png"
@@ -10,6 +11,7 @@ import (
 	"sort"
 
 	"github.com/voidshard/genesis"
+	"github.com/voidshard/genesis/internal/blob"
 	"github.com/voidshard/genesis/internal/paint"
 	"github.com/voidshard/genesis/internal/voronoi"
 	"github.com/voidshard/genesis/pkg/types"
@@ -34,6 +36,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	ctx := context.Background()
 
 	p, err := gen.Project("my-project")
 	if err != nil {
@@ -42,30 +45,34 @@ func main() {
 	fmt.Println(p.WorldWidth, p.WorldHeight)
 
 	log.Println("Cleaning previous data")
-	pnt := paint.New(cli.Root, p.WorldWidth, p.WorldHeight)
+	store, err := blob.NewFilesystem(cli.Root)
+	if err != nil {
+		panic(err)
+	}
+	pnt := paint.New(store, cli.Root, p.WorldWidth, p.WorldHeight)
 	for _, t := range []string{"mountains", "ravines", "rivers", "sea", "noise-perlin", "noise-voronoi", "rain"} {
 		pnt.Delete(p.Canvas(t))
 	}
 
-	voro := voronoi.New(cli.Root, p.WorldWidth, p.WorldHeight)
+	voro := voronoi.New(store, p.WorldWidth, p.WorldHeight)
 	voro.Delete(p.VoronoiDiagram())
 	log.Println("Regenerating")
 
-	err = gen.CreateTectonics(p.ID, perinNoiseVal, voronoiPoints)
+	err = gen.CreateTectonics(ctx, p.ID, perinNoiseVal, voronoiPoints)
 	if err != nil {
 		panic(err)
 	}
 	log.Println("Tectonics created")
 
 	for i := 0; i < numMountains; i++ {
-		_, _, err = gen.AddMountainRange(p.ID, "mountains-of-madness", nil, 1)
+		_, _, err = gen.AddMountainRange(ctx, p.ID, "mountains-of-madness", nil, 1)
 		if err != nil {
 			panic(err)
 		}
 		log.Println("Added mountain range")
 	}
 	for i := 0; i < numVolcs; i++ {
-		_, _, err = gen.AddVolanoes(p.ID, 5, nil)
+		_, _, err = gen.AddVolanoes(ctx, p.ID, 5, nil)
 		if err != nil {
 			panic(err)
 		}
@@ -73,7 +80,7 @@ func main() {
 	}
 
 	for i := 0; i < numRavines; i++ {
-		_, err = gen.AddRavine(p.ID, "ravine", nil, .2)
+		_, err = gen.AddRavine(ctx, p.ID, "ravine", nil, .2)
 		if err != nil {
 			panic(err)
 		}
@@ -82,16 +89,16 @@ func main() {
 
 	log.Println("\tSmoothing ...")
 	for i := 0; i < smoothPasses; i++ {
-		err = gen.SmoothTerrain(p.ID, uint32(rand.Intn(2)+rand.Intn(2)+2))
+		err = gen.SmoothTerrain(ctx, p.ID, uint32(rand.Intn(2)+rand.Intn(2)+2))
 	}
 
 	log.Println("Flattening edges ...")
-	err = gen.FlattenOutside(p.ID, image.Rect(5, 5, p.WorldWidth-5, p.WorldHeight-5))
+	err = gen.FlattenOutside(ctx, p.ID, image.Rect(5, 5, p.WorldWidth-5, p.WorldHeight-5))
 	if err != nil {
 		panic(err)
 	}
 
-	hm, err := gen.HeightMap(p.ID, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	hm, err := gen.HeightMap(ctx, p.ID, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
 	if err != nil {
 		panic(err)
 	}
@@ -103,7 +110,7 @@ func main() {
 	}
 
 	log.Println("Discovering sea")
-	_, land, err := gen.SeaMap(p.ID, sealevel, 100, 100, 6)
+	_, land, err := gen.SeaMap(ctx, p.ID, sealevel, 100, 100, 6)
 	if err != nil {
 		panic(err)
 	}
@@ -116,15 +123,15 @@ func main() {
 	}
 
 	log.Println("Raining ..")
-	_, err = gen.Rain(p.ID, 3, nil)
+	_, err = gen.Rain(ctx, p.ID, 3, nil)
 	if err != nil {
 		panic(err)
 	}
-	_, err = gen.Rain(p.ID, 1, types.ClockwiseHeadings(gen.Geo.RainfallPrevailingWinds...))
+	_, err = gen.Rain(ctx, p.ID, 1, types.ClockwiseHeadings(gen.Geo.RainfallPrevailingWinds...))
 	if err != nil {
 		panic(err)
 	}
-	_, err = gen.Rain(p.ID, 1, types.CounterClockwiseHeadings(gen.Geo.RainfallPrevailingWinds...))
+	_, err = gen.Rain(ctx, p.ID, 1, types.CounterClockwiseHeadings(gen.Geo.RainfallPrevailingWinds...))
 	if err != nil {
 		panic(err)
 	}
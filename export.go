@@ -0,0 +1,123 @@
+package genesis
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"github.com/voidshard/genesis/internal/export"
+	"github.com/voidshard/genesis/internal/vector"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// Layer returns `area` of a single layer of the current epoch as 16 bit values.
+func (e *Editor) Layer(proj string, layer types.Layer, area image.Rectangle) (*image.Gray16, error) {
+	p, err := e.Project(proj)
+	if err != nil {
+		return nil, err
+	}
+	return e.geoEdit.Layer(p.ID, layer, area.Intersect(image.Rect(0, 0, p.WorldWidth, p.WorldHeight)))
+}
+
+// Export writes `area` of a layer of the current epoch to `path` in the given format.
+func (e *Editor) Export(proj string, layer types.Layer, format types.ExportFormat, area image.Rectangle, path string) error {
+	bands, err := e.exportBands(proj, layer, format, area)
+	if err != nil {
+		return err
+	}
+	return export.WriteFile(path, format, nil, bands...)
+}
+
+// ExportTiles is Export but `area` is cut into tiles (at most size x size pixels) each
+// of which is written to it's own file in `dir`.
+//
+// Tiles are named <layer>_<column>_<row>.<ext> and carry their world offset in formats
+// that support it (GeoTIFF).
+func (e *Editor) ExportTiles(proj string, layer types.Layer, format types.ExportFormat, area image.Rectangle, size int, dir string) ([]string, error) {
+	bands, err := e.exportBands(proj, layer, format, area)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(dir, 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	written := []string{}
+	for _, tile := range export.Tiles(area, size) {
+		col := (tile.Min.X - area.Min.X) / size
+		row := (tile.Min.Y - area.Min.Y) / size
+		path := filepath.Join(dir, fmt.Sprintf("%s_%d_%d%s", layer, col, row, format.Extension()))
+
+		err = export.WriteFile(path, format, nil, export.SubBands(tile, bands...)...)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+
+	return written, nil
+}
+
+// exportBands returns the band(s) required to write out `layer`
+func (e *Editor) exportBands(proj string, layer types.Layer, format types.ExportFormat, area image.Rectangle) ([]*image.Gray16, error) {
+	p, err := e.Project(proj)
+	if err != nil {
+		return nil, err
+	}
+	area = area.Intersect(image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+
+	layers := []types.Layer{layer}
+	if layer == types.LayerAll {
+		if format != types.ExportMultiBandTIFF {
+			return nil, fmt.Errorf("%w layer %s requires format %s", export.ErrBandCount, layer, types.ExportMultiBandTIFF)
+		}
+		layers = types.Layers()
+	}
+
+	bands := make([]*image.Gray16, len(layers))
+	for i, l := range layers {
+		bands[i], err = e.geoEdit.Layer(p.ID, l, area)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bands, nil
+}
+
+// ExportVector writes vector features (coastlines, mountain ranges, volcanoes ..) of the
+// current epoch to `path` as either GeoJSON or SVG.
+func (e *Editor) ExportVector(proj string, format types.ExportFormat, path string) error {
+	p, err := e.Project(proj)
+	if err != nil {
+		return err
+	}
+
+	features, err := e.geoEdit.Features(p.ID)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case types.ExportGeoJSON:
+		err = vector.WriteGeoJSON(f, features)
+	case types.ExportSVG:
+		err = vector.WriteSVG(f, p.WorldWidth, p.WorldHeight, features)
+	default:
+		err = fmt.Errorf("%w %s", export.ErrUnknownFormat, format)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
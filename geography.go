@@ -1,6 +1,8 @@
 package genesis
 
 import (
+	"context"
+	"fmt"
 	"image"
 
 	"github.com/voidshard/genesis/pkg/types"
@@ -8,70 +10,262 @@ import (
 
 // Tectonics divides the map into regions - used by following
 // functions that pick out paths between points.
-func (e *Editor) CreateTectonics(proj string, noise float64, points int) error {
-	return e.geoEdit.CreateTectonics(proj, noise, points)
+func (e *Editor) CreateTectonics(ctx context.Context, proj string, noise float64, points int) error {
+	return e.journal(ctx, proj, "tectonics", &tectonicsOp{Noise: noise, Points: points}, nil)
+}
+
+// ImportHeightmap is used in place of CreateTectonics to start from an existing
+// heightmap
+func (e *Editor) ImportHeightmap(ctx context.Context, proj string, im image.Image, opts *types.ImportOptions) error {
+	op, err := newImportOp(false, im, opts)
+	if err != nil {
+		return err
+	}
+	return e.journal(ctx, proj, "import-heightmap", op, nil)
+}
+
+// ImportSketch is used in place of CreateTectonics to start from a rough drawing of
+// land, sea & mountains
+func (e *Editor) ImportSketch(ctx context.Context, proj string, im image.Image, opts *types.ImportOptions) error {
+	op, err := newImportOp(true, im, opts)
+	if err != nil {
+		return err
+	}
+	return e.journal(ctx, proj, "import-sketch", op, nil)
 }
 
 //
-func (e *Editor) Rain(proj string, stormMult float64, prevailingWinds []types.Heading) (image.Image, error) {
-	return e.geoEdit.Rain(proj, stormMult, prevailingWinds)
+func (e *Editor) Bathymetry(ctx context.Context, proj string) (image.Image, error) {
+	var out image.Image
+	err := e.journal(ctx, proj, "bathymetry", &bathymetryOp{}, func(ctx context.Context) (err error) {
+		out, err = e.geoEdit.Bathymetry(ctx, proj)
+		return err
+	})
+	return out, err
 }
 
 //
-func (e *Editor) Rivers(proj string, threshold int) (image.Image, error) {
-	return e.geoEdit.Rivers(proj, threshold)
+func (e *Editor) Wind(ctx context.Context, proj string) (image.Image, error) {
+	var out image.Image
+	err := e.journal(ctx, proj, "wind", &windOp{}, func(ctx context.Context) (err error) {
+		out, err = e.geoEdit.Wind(ctx, proj)
+		return err
+	})
+	return out, err
 }
 
 //
-func (e *Editor) NextEpoch(proj string) error {
-	return e.geoEdit.NextEpoch(proj)
+func (e *Editor) Rain(ctx context.Context, proj string, stormMult float64, prevailingWinds []types.Heading) (image.Image, error) {
+	var out image.Image
+	err := e.journal(ctx, proj, "rain", &rainOp{StormMult: stormMult, Winds: prevailingWinds}, func(ctx context.Context) (err error) {
+		out, err = e.geoEdit.Rain(ctx, proj, stormMult, prevailingWinds)
+		return err
+	})
+	return out, err
+}
+
+//
+func (e *Editor) Seasons(ctx context.Context, proj string, seasons int, stormMult float64) (image.Image, error) {
+	var out image.Image
+	err := e.journal(ctx, proj, "seasons", &seasonsOp{Seasons: seasons, StormMult: stormMult}, func(ctx context.Context) (err error) {
+		out, err = e.geoEdit.Seasons(ctx, proj, seasons, stormMult)
+		return err
+	})
+	return out, err
+}
+
+//
+func (e *Editor) Cryosphere(ctx context.Context, proj string) (image.Image, error) {
+	var out image.Image
+	err := e.journal(ctx, proj, "cryosphere", &cryosphereOp{}, func(ctx context.Context) (err error) {
+		out, err = e.geoEdit.Cryosphere(ctx, proj)
+		return err
+	})
+	return out, err
+}
+
+//
+func (e *Editor) Rivers(ctx context.Context, proj string, threshold int) (image.Image, error) {
+	var out image.Image
+	err := e.journal(ctx, proj, "rivers", &riversOp{Threshold: threshold}, func(ctx context.Context) (err error) {
+		out, err = e.geoEdit.Rivers(ctx, proj, threshold)
+		return err
+	})
+	return out, err
+}
+
+//
+func (e *Editor) NextEpoch(ctx context.Context, proj string) error {
+	return e.journal(ctx, proj, "epoch", &epochOp{}, nil)
+}
+
+// Rescale makes a new project (named `{name}-{width}x{height}`) holding the terrain of
+// the current epoch of `proj` at a new size. Features are redrawn rather than stretched,
+// derived steps (eg. SeaMap) need calling again.
+func (e *Editor) Rescale(ctx context.Context, proj string, width, height int) (*types.Project, error) {
+	src, err := e.Project(proj)
+	if err != nil {
+		return nil, err
+	}
+
+	if width < minWorldSize {
+		width = minWorldSize
+	}
+	if height < minWorldSize {
+		height = minWorldSize
+	}
+
+	name := fmt.Sprintf("%s-%dx%d", src.Name, width, height)
+	dst := &types.Project{Name: name, Seed: src.Seed, WorldWidth: width, WorldHeight: height, Wrap: src.Wrap, Projection: src.Projection}
+	err = e.CreateProject(dst) // ErrExists if we've rescaled to this size before
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.geoEdit.Rescale(ctx, src.ID, dst.ID)
+	if err != nil {
+		e.DeleteProject(dst.ID)
+		return nil, err
+	}
+	return dst, nil
 }
 
 // A mountain range follows some path, placing high ridges and mountains
 // randomly along the path
 // Implies
 // - CreateTectonics
-func (e *Editor) AddMountainRange(proj, tag string, s *types.PathSpec, scale float64) ([]image.Point, []image.Point, error) {
-	return e.geoEdit.AddMountainRange(proj, tag, s, scale)
+func (e *Editor) AddMountainRange(ctx context.Context, proj, tag string, s *types.PathSpec, scale float64) ([]image.Point, []image.Point, error) {
+	var peaks, ridges []image.Point
+	err := e.journal(ctx, proj, "mountains", &mountainsOp{Tag: tag, Spec: s, Scale: scale}, func(ctx context.Context) (err error) {
+		peaks, ridges, err = e.geoEdit.AddMountainRange(ctx, proj, tag, s, scale)
+		return err
+	})
+	return peaks, ridges, err
 }
 
 // Similar to mountain range we place volcanoes around a rough path
 // (eg. a fault line) but much less frequently than mountains.
 // Implies
 // - CreateTectonics
-func (e *Editor) AddVolanoes(proj string, count int, s *types.PathSpec) ([]image.Point, []image.Point, error) {
-	return e.geoEdit.AddVolanoes(proj, count, s)
+func (e *Editor) AddVolanoes(ctx context.Context, proj string, count int, s *types.PathSpec) ([]image.Point, []image.Point, error) {
+	var volcanoes, path []image.Point
+	err := e.journal(ctx, proj, "volcanoes", &volcanoesOp{Count: count, Spec: s}, func(ctx context.Context) (err error) {
+		volcanoes, path, err = e.geoEdit.AddVolanoes(ctx, proj, count, s)
+		return err
+	})
+	return volcanoes, path, err
 }
 
 // A ravine follows a path, adding steep sheer cliff walls
 // Implies
 // - CreateTectonics
-func (e *Editor) AddRavine(proj, tag string, s *types.PathSpec, forkChance float64) ([]image.Point, error) {
-	return e.geoEdit.AddRavine(proj, tag, s, forkChance)
+func (e *Editor) AddRavine(ctx context.Context, proj, tag string, s *types.PathSpec, forkChance float64) (*types.PathTree, error) {
+	var tree *types.PathTree
+	err := e.journal(ctx, proj, "ravines", &ravineOp{Tag: tag, Spec: s, ForkChance: forkChance}, func(ctx context.Context) (err error) {
+		tree, err = e.geoEdit.AddRavine(ctx, proj, tag, s, forkChance)
+		return err
+	})
+	return tree, err
 }
 
 // SmoothTerrain applies a smoothing brush to mountains / volcanoes
-func (e *Editor) SmoothTerrain(proj string, radius uint32) error {
-	return e.geoEdit.SmoothTerrain(proj, radius)
+func (e *Editor) SmoothTerrain(ctx context.Context, proj string, radius uint32) error {
+	return e.journal(ctx, proj, "smooth", &smoothOp{Radius: radius}, nil)
 }
 
 // FlattenOutside terrain (eg.outside the rect) at the very edge(s) of the map down to 0
-func (e *Editor) FlattenOutside(proj string, r image.Rectangle) error {
-	return e.geoEdit.FlattenOutside(proj, r)
+func (e *Editor) FlattenOutside(ctx context.Context, proj string, r image.Rectangle) error {
+	return e.journal(ctx, proj, "flatten", &flattenOp{Area: r}, nil)
+}
+
+// AddArchipelago raises a chain of islands along a path. They appear on the next SeaMap.
+// Implies
+// - CreateTectonics
+func (e *Editor) AddArchipelago(ctx context.Context, proj, tag string, s *types.PathSpec, islands int) ([]image.Point, error) {
+	var centres []image.Point
+	err := e.journal(ctx, proj, "archipelago", &archipelagoOp{Tag: tag, Spec: s, Islands: islands}, func(ctx context.Context) (err error) {
+		centres, err = e.geoEdit.AddArchipelago(ctx, proj, tag, s, islands)
+		return err
+	})
+	return centres, err
+}
+
+// AddBay cuts away land around a point. It appears on the next SeaMap.
+func (e *Editor) AddBay(ctx context.Context, proj string, centre image.Point, radius int) error {
+	return e.journal(ctx, proj, "bay", &bayOp{Centre: centre, Radius: radius}, nil)
+}
+
+// CarveFjords cuts inlets into the coast of a landmass. They appear on the next SeaMap.
+// Implies
+// - SeaMap
+func (e *Editor) CarveFjords(ctx context.Context, proj, landmassID string, depth int) ([][]image.Point, error) {
+	var fjords [][]image.Point
+	err := e.journal(ctx, proj, "fjords", &fjordsOp{Landmass: landmassID, Depth: depth}, func(ctx context.Context) (err error) {
+		fjords, err = e.geoEdit.CarveFjords(ctx, proj, landmassID, depth)
+		return err
+	})
+	return fjords, err
+}
+
+// RaiseArea raises terrain under the brush by `amount`
+func (e *Editor) RaiseArea(ctx context.Context, proj string, b *types.Brush, amount uint8) error {
+	op := newEditOp("raise", b)
+	op.Amount = amount
+	return e.journal(ctx, proj, op.Kind, op, nil)
+}
+
+// LowerArea lowers terrain under the brush by `amount`
+func (e *Editor) LowerArea(ctx context.Context, proj string, b *types.Brush, amount uint8) error {
+	op := newEditOp("lower", b)
+	op.Amount = amount
+	return e.journal(ctx, proj, op.Kind, op, nil)
+}
+
+// FlattenTo sets terrain under the brush to `height`
+func (e *Editor) FlattenTo(ctx context.Context, proj string, b *types.Brush, height uint8) error {
+	op := newEditOp("flatten-to", b)
+	op.Height = height
+	return e.journal(ctx, proj, op.Kind, op, nil)
+}
+
+// SmoothArea smooths terrain under the brush
+func (e *Editor) SmoothArea(ctx context.Context, proj string, b *types.Brush, radius uint32) error {
+	op := newEditOp("smooth-area", b)
+	op.SmoothBy = radius
+	return e.journal(ctx, proj, op.Kind, op, nil)
+}
+
+// PaintNoise adds perlin noise to terrain under the brush
+func (e *Editor) PaintNoise(ctx context.Context, proj string, b *types.Brush, scale float64, amount uint8) error {
+	op := newEditOp("noise", b)
+	op.NoiseScale, op.Amount = scale, amount
+	return e.journal(ctx, proj, op.Kind, op, nil)
+}
+
+// UndoEdit reverts the last hand edit to terrain
+func (e *Editor) UndoEdit(ctx context.Context, proj string) error {
+	return e.journal(ctx, proj, "undo", &undoOp{}, nil)
 }
 
 // SeaMap figures out where there should be sea.
 // Implies
 // - AddMountainRange
 // - AddVolanoes
-func (e *Editor) SeaMap(proj string, sealevel uint8, equatorWidth, articWidth, seaCurrents int) (image.Image, []*types.Landmass, error) {
-	return e.geoEdit.SeaMap(proj, sealevel, equatorWidth, articWidth, seaCurrents)
+func (e *Editor) SeaMap(ctx context.Context, proj string, sealevel uint8, equatorWidth, articWidth, seaCurrents int) (image.Image, []*types.Landmass, error) {
+	var sea image.Image
+	var land []*types.Landmass
+	op := &seaOp{SeaLevel: sealevel, EquatorWidth: equatorWidth, ArcticWidth: articWidth, Currents: seaCurrents}
+	err := e.journal(ctx, proj, "sea", op, func(ctx context.Context) (err error) {
+		sea, land, err = e.geoEdit.SeaMap(ctx, proj, sealevel, equatorWidth, articWidth, seaCurrents)
+		return err
+	})
+	return sea, land, err
 }
 
 // HeightMap generates an amalgamated height map using all of the previously
 // called function(s) output.
 // Implies
 // - Anything that modifies terrain height .. obviously
-func (e *Editor) HeightMap(proj string, area image.Rectangle) (image.Image, error) {
-	return e.geoEdit.HeightMap(proj, area)
+func (e *Editor) HeightMap(ctx context.Context, proj string, area image.Rectangle) (image.Image, error) {
+	return e.geoEdit.HeightMap(ctx, proj, area)
 }
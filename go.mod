@@ -12,10 +12,10 @@ require (
 	github.com/stretchr/testify v1.7.2
 	github.com/voidshard/voronoi v0.0.5
 	github.com/wlevene/ini v0.1.5
+	golang.org/x/image v0.0.0-20220617043117-41969df76e82
 )
 
 require (
-	github.com/RyanCarrier/dijkstra v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fsnotify/fsnotify v1.4.9 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
@@ -24,7 +24,6 @@ require (
 	github.com/unixpickle/essentials v1.3.0 // indirect
 	github.com/unixpickle/model3d v0.3.4 // indirect
 	github.com/unixpickle/splaytree v0.0.0-20160517015709-ba216b293df0 // indirect
-	golang.org/x/image v0.0.0-20220617043117-41969df76e82 // indirect
 	golang.org/x/sys v0.0.0-20220405052023-b1e9470b6e64 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -1,6 +1,7 @@
 package genesis
 
 import (
+	"context"
 	"image"
 
 	"github.com/voidshard/genesis/pkg/types"
@@ -9,8 +10,15 @@ import (
 type GenesisEditor interface {
 	projectEditor
 	geographyEditor
+	exportEditor
+	renderEditor
+	jobEditor
+	journalEditor
 	raceEditor
 	civilizationEditor
+
+	// Close releases the editor, it must not be used afterwards
+	Close() error
 }
 
 type projectEditor interface {
@@ -25,12 +33,23 @@ type projectEditor interface {
 
 	// Project returns a project by ID (sugar for 'Projects')
 	Project(key string) (*types.Project, error)
+
+	// DeleteProject removes a project and everything belonging to it
+	DeleteProject(key string) error
 }
 
 type geographyEditorInit interface {
 	// Tectonics divides the map into regions - used by following
 	// functions that pick out paths between points.
-	CreateTectonics(proj string, noise float64, points int) error
+	CreateTectonics(ctx context.Context, proj string, noise float64, points int) error
+
+	// ImportHeightmap is used in place of CreateTectonics to start from an existing
+	// (8 or 16 bit) heightmap, resized to fit the world.
+	ImportHeightmap(ctx context.Context, proj string, im image.Image, opts *types.ImportOptions) error
+
+	// ImportSketch is used in place of CreateTectonics to start from a rough drawing of
+	// land, sea & mountains (see types.SketchLand etc), resized to fit the world.
+	ImportSketch(ctx context.Context, proj string, im image.Image, opts *types.ImportOptions) error
 }
 
 type geographyEditorTerrain interface {
@@ -39,21 +58,57 @@ type geographyEditorTerrain interface {
 
 	// A mountain range follows some path, placing high ridges and mountains
 	// randomly along the path
-	AddMountainRange(proj, tag string, s *types.PathSpec, scale float64) ([]image.Point, []image.Point, error)
+	AddMountainRange(ctx context.Context, proj, tag string, s *types.PathSpec, scale float64) ([]image.Point, []image.Point, error)
 
 	// Similar to mountain range we place volcanoes around a rough path
 	// (eg. a fault line) but much less frequently than mountains.
-	AddVolanoes(proj string, count int, s *types.PathSpec) ([]image.Point, []image.Point, error)
+	AddVolanoes(ctx context.Context, proj string, count int, s *types.PathSpec) ([]image.Point, []image.Point, error)
 
-	// A ravine follows a path, adding steep sheer cliff walls
-	AddRavine(proj, tag string, s *types.PathSpec, forkChance float64) ([]image.Point, error)
+	// A ravine follows a path, adding steep sheer cliff walls. At each point along the
+	// way it may fork (with forkChance) into smaller branches, which can fork in turn.
+	AddRavine(ctx context.Context, proj, tag string, s *types.PathSpec, forkChance float64) (*types.PathTree, error)
 
 	// SmoothTerrain applies a smoothing brush to mountains / volcanoes
-	SmoothTerrain(proj string, radius uint32) error
+	SmoothTerrain(ctx context.Context, proj string, radius uint32) error
 
 	// FlattenOutside terrain (eg.outside the rect) at the very edge(s) of the map down to 0
 	// Ie. if you wished to force the edges to be sea .. this would be how
-	FlattenOutside(proj string, r image.Rectangle) error
+	FlattenOutside(ctx context.Context, proj string, r image.Rectangle) error
+
+	// AddArchipelago raises a chain of islands along a path (that avoids land, if we have
+	// a sea map). Returns the centre of each island.
+	AddArchipelago(ctx context.Context, proj, tag string, s *types.PathSpec, islands int) ([]image.Point, error)
+
+	// AddBay cuts away land around a point, making a bay on the coast (or a lake inland)
+	AddBay(ctx context.Context, proj string, centre image.Point, radius int) error
+
+	// CarveFjords cuts narrow inlets up to `depth` pixels into the coast of a landmass
+	// (from the last SeaMap). Returns the path of each fjord.
+	CarveFjords(ctx context.Context, proj, landmassID string, depth int) ([][]image.Point, error)
+}
+
+type geographyEditorManual interface {
+	// Hand edits to terrain (of the current epoch) under a brush (polygon, circle
+	// or mask). Edits are kept apart from generated terrain so they survive re-running
+	// generators, and can be undone (most recent first).
+
+	// RaiseArea raises terrain under the brush by `amount`
+	RaiseArea(ctx context.Context, proj string, b *types.Brush, amount uint8) error
+
+	// LowerArea lowers terrain under the brush by `amount`
+	LowerArea(ctx context.Context, proj string, b *types.Brush, amount uint8) error
+
+	// FlattenTo sets terrain under the brush to `height`
+	FlattenTo(ctx context.Context, proj string, b *types.Brush, height uint8) error
+
+	// SmoothArea smooths terrain under the brush
+	SmoothArea(ctx context.Context, proj string, b *types.Brush, radius uint32) error
+
+	// PaintNoise adds perlin noise of up to +/- `amount` to terrain under the brush
+	PaintNoise(ctx context.Context, proj string, b *types.Brush, scale float64, amount uint8) error
+
+	// UndoEdit reverts the last of the above edits
+	UndoEdit(ctx context.Context, proj string) error
 }
 
 type geographyEditorDerived interface {
@@ -64,23 +119,56 @@ type geographyEditorDerived interface {
 	// SeaMap figures out where there should be sea, sea temperatures (including currents).
 	// We also this this time to figure out where land is (ie. not sea ..) and the size / location
 	// of each landmass.
-	SeaMap(proj string, sealevel uint8, equatorWidth, arcticWidth, seaCurrents int) (image.Image, []*types.Landmass, error)
+	SeaMap(ctx context.Context, proj string, sealevel uint8, equatorWidth, arcticWidth, seaCurrents int) (image.Image, []*types.Landmass, error)
 
 	// HeightMap generates an amalgamated height map
-	HeightMap(proj string, area image.Rectangle) (image.Image, error)
+	HeightMap(ctx context.Context, proj string, area image.Rectangle) (image.Image, error)
+
+	// Wind works out prevailing winds from latitude (Hadley, Ferrel & polar cells turned
+	// by the spin of the world) & the lie of the land.
+	// Implies
+	// - HeightMap
+	Wind(ctx context.Context, proj string) (image.Image, error)
+
+	// Bathymetry works out how deep the sea is; shelves off the coast, trenches by
+	// volcanoes & ridges in the open ocean. The depth goes into HeightMap.
+	// Implies
+	// - SeaMap
+	Bathymetry(ctx context.Context, proj string) (image.Image, error)
+
+	// Rain determines rainfall & rainshadows. Storms follow the wind (which is worked
+	// out first if it hasn't been) unless prevailingWinds are given, in which case the
+	// world is split into bands of latitude each blowing one way.
+	// Implies
+	// - SeaMap
+	// - Wind
+	Rain(ctx context.Context, proj string, stormMult float64, prevailingWinds []types.Heading) (image.Image, error)
 
-	// Rain determines rainfall & rainshadows.
+	// Seasons works out wind, temperature & rain for each of `seasons` times of the
+	// year, as the sun (& with it the wind) moves north & south. Each season is kept
+	// (see types.Layer.Seasonal), rain & temperature become the yearly average & we
+	// work out how much they change over the year & where there are monsoons.
 	// Implies
 	// - SeaMap
-	Rain(proj string, stormMult float64, prevailingWinds []types.Heading) (image.Image, error)
+	Seasons(ctx context.Context, proj string, seasons int, stormMult float64) (image.Image, error)
+
+	// Cryosphere works out sea ice, ice caps & snow above the snowline from temperature,
+	// & glaciers flowing down from snowy mountains. Glaciers cut troughs into the
+	// terrain that are kept between epochs.
+	// Implies
+	// - SeaMap
+	// - Seasons (if called, otherwise summer temperatures are worked out)
+	Cryosphere(ctx context.Context, proj string) (image.Image, error)
 
 	// Rivers determines where rivers should go based on rainfall.
 	// Ie. Water flows downward & collects before returning to the sea.
 	// Implies
 	// - Rain
-	Rivers(proj string, threshold int) (image.Image, error)
+	Rivers(ctx context.Context, proj string, threshold int) (image.Image, error)
 }
 
+// Geography steps accept a context; cancelling it stops the step. When run as a
+// job (see SubmitJob) their progress is recorded on the job.
 type geographyEditor interface {
 	// functions to call when setting up an initial worldspace (once)
 	geographyEditorInit
@@ -88,6 +176,9 @@ type geographyEditor interface {
 	// functions that alter terrain of the current epoch (per epoch, before 'Derived'
 	geographyEditorTerrain
 
+	// hand edits to terrain of the current epoch (also before 'Derived')
+	geographyEditorManual
+
 	// functions that use information from previous steps (init / terrain) and should
 	// be (re)called after changes to them.
 	geographyEditorDerived
@@ -104,7 +195,86 @@ type geographyEditor interface {
 	// - since we're assuming the caller will add new mountains / volcanoes / ravines
 	//   derived stuff like sea, rain, heightmap(s) will need re-calculation (that is,
 	//   we don't copy derived information we expect will be outdated immediately)
-	NextEpoch(proj string) error
+	NextEpoch(ctx context.Context, proj string) error
+
+	// Rescale makes a new project (named `{name}-{width}x{height}`) from the current
+	// epoch of this one at a new size, eg. to add detail to a world prototyped small.
+	// - the graph (weights & tags) is scaled & mountains, volcanoes, ravines are redrawn
+	//   along their paths at the new size (rather than stretching pixels)
+	// - noise & hand edits are resampled
+	// - derived steps (sea, rain, rivers) need re-calculation
+	Rescale(ctx context.Context, proj string, width, height int) (*types.Project, error)
+}
+
+type exportEditor interface {
+	// Layer returns `area` of a single layer (eg. height, rain) of the current epoch
+	// as 16 bit values.
+	Layer(proj string, layer types.Layer, area image.Rectangle) (*image.Gray16, error)
+
+	// Export writes `area` of a layer to `path` in the given format (eg. 16 bit PNG,
+	// RAW R16, GeoTIFF, EXR). The multi-band TIFF format accepts types.LayerAll which bundles
+	// height, rain, sea temperature, landmass, wind, climate, ice & depth into one file.
+	Export(proj string, layer types.Layer, format types.ExportFormat, area image.Rectangle, path string) error
+
+	// ExportTiles is Export but splits `area` into engine sized tiles (at most size x size)
+	// written into `dir`. Returns the paths of the written files.
+	ExportTiles(proj string, layer types.Layer, format types.ExportFormat, area image.Rectangle, size int, dir string) ([]string, error)
+
+	// ExportVector writes coastlines (implies SeaMap), mountain ranges, ravines, volcanoes,
+	// glaciers (implies Cryosphere) and their names as vector data in either GeoJSON or
+	// SVG format.
+	ExportVector(proj string, format types.ExportFormat, path string) error
+
+	// Snapshot writes every project (db rows, canvases & graphs) into `dir` laid out as a
+	// root folder, so it can be opened later with Options{Root: dir}. Mostly for keeping
+	// worlds made InMemory.
+	Snapshot(dir string) error
+}
+
+type renderEditor interface {
+	// Render composites the heightmap (tinted & hillshaded), sea depth & temperature,
+	// rainfall and named features into a map in the given style (eg. atlas, parchment,
+	// satellite) with a scale bar & compass.
+	Render(proj, style string, area image.Rectangle) (image.Image, error)
+
+	// RenderPNG is Render but writes the result to `path` as a PNG
+	RenderPNG(proj, style string, area image.Rectangle, path string) error
+
+	// Tile returns XYZ map tile z/x/y (PNG encoded) of either a layer (eg. height, rain) or
+	// a render style (eg. atlas) from the given epoch. Tiles are cached until the epoch's
+	// canvases change.
+	Tile(proj string, epoch int, layer string, z, x, y int) ([]byte, error)
+}
+
+type jobEditor interface {
+	// SubmitJob runs some function in the background (eg. a geography step) with a
+	// context that records progress onto the job & is cancelled by CancelJob.
+	// Jobs run one at a time in the order they're submitted & are kept in the DB.
+	SubmitJob(proj, kind string, fn JobFunc) (*types.Job, error)
+
+	// Job returns a job by ID
+	Job(id string) (*types.Job, error)
+
+	// ListJobs iterates over jobs, newest first (optionally of only one project)
+	ListJobs(proj, tkn string) ([]*types.Job, string, error)
+
+	// CancelJob cancels a queued or running job
+	CancelJob(id string) (*types.Job, error)
+
+	// WatchJob streams updates (status, progress) of a job until it finishes
+	WatchJob(id string) (<-chan *types.Job, func(), error)
+}
+
+type journalEditor interface {
+	// ListOperations iterates over a project's journal (oldest first); every geography
+	// call that changed the world, with it's parameters & the seed it used.
+	ListOperations(proj, tkn string) ([]*types.Operation, string, error)
+
+	// Replay rebuilds the world from scratch by remaking operations in it's journal up to
+	// & including `upTo` (0 being an empty world). Replaying to an earlier operation
+	// undoes those after it, which can be redone by replaying to them again - at least
+	// until a new operation is made.
+	Replay(ctx context.Context, proj string, upTo int) error
 }
 
 type raceEditor interface {
@@ -0,0 +1,113 @@
+package blob
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/voidshard/genesis/internal/config"
+)
+
+var (
+	// ErrNotFound is returned if there is no blob with some key
+	ErrNotFound = fmt.Errorf("blob not found")
+
+	// ErrUnknownDriver is returned if we're configured with a storage driver we don't know
+	ErrUnknownDriver = fmt.Errorf("unknown storage driver")
+)
+
+// Blob stores data by key. Keys are "/" separated paths (eg. "some-canvas/0-0.png"),
+// a key can't be both a blob & a prefix of other blobs.
+type Blob interface {
+	// Get the blob stored at key, ErrNotFound if there isn't one
+	Get(key string) ([]byte, error)
+
+	// Put data at key, replacing anything already there
+	Put(key string, data []byte) error
+
+	// Delete the blob at key & everything under key/ (noop if there's nothing)
+	Delete(key string) error
+
+	// List keys of all blobs that begin with prefix
+	List(prefix string) ([]string, error)
+
+	// Move the blob at `from` & everything under from/ to `to`, replacing anything
+	// that's there. Storage that can't rename might copy & leave `from` in place, so
+	// callers should Delete `from` once they're done. Noop if `from` doesn't exist.
+	Move(from, to string) error
+}
+
+// Local is implemented by storage that keeps blobs as files on the local disk, so
+// callers can work on them in place
+type Local interface {
+	Blob
+
+	// Path returns where the given key is on disk
+	Path(key string) string
+}
+
+// New returns storage as configured
+func New(opts config.Storage) (Blob, error) {
+	switch opts.Driver {
+	case config.StorageDriverFilesystem:
+		return NewFilesystem(opts.Location)
+	case config.StorageDriverMemory:
+		return NewMemory(), nil
+	case config.StorageDriverS3:
+		return NewS3(&opts)
+	}
+	return nil, fmt.Errorf("%w %s", ErrUnknownDriver, opts.Driver)
+}
+
+// Prefix returns storage where all keys are under `prefix/` of the given storage
+func Prefix(in Blob, prefix string) Blob {
+	p := &prefixBlob{in: in, prefix: strings.Trim(prefix, "/") + "/"}
+	l, ok := in.(Local)
+	if ok {
+		return &localPrefixBlob{prefixBlob: p, local: l}
+	}
+	return p
+}
+
+type prefixBlob struct {
+	in     Blob
+	prefix string
+}
+
+type localPrefixBlob struct {
+	*prefixBlob
+	local Local
+}
+
+func (p *prefixBlob) Get(key string) ([]byte, error) {
+	return p.in.Get(p.prefix + key)
+}
+
+func (p *prefixBlob) Put(key string, data []byte) error {
+	return p.in.Put(p.prefix+key, data)
+}
+
+func (p *prefixBlob) Delete(key string) error {
+	return p.in.Delete(p.prefix + key)
+}
+
+func (p *prefixBlob) List(prefix string) ([]string, error) {
+	found, err := p.in.List(p.prefix + prefix)
+	for i, k := range found {
+		found[i] = strings.TrimPrefix(k, p.prefix)
+	}
+	return found, err
+}
+
+func (p *prefixBlob) Move(from, to string) error {
+	return p.in.Move(p.prefix+from, p.prefix+to)
+}
+
+func (l *localPrefixBlob) Path(key string) string {
+	return l.local.Path(path.Join(l.prefix, key))
+}
+
+// under returns if key is `prefix` or some key under prefix/
+func under(key, prefix string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
@@ -0,0 +1,128 @@
+package blob
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Filesystem keeps blobs as files under some root folder
+type Filesystem struct {
+	root string
+}
+
+// NewFilesystem returns storage rooted at the given folder
+func NewFilesystem(root string) (*Filesystem, error) {
+	return &Filesystem{root: root}, os.MkdirAll(root, 0750)
+}
+
+// Path returns where the given key is on disk
+func (f *Filesystem) Path(key string) string {
+	return filepath.Join(f.root, filepath.FromSlash(key))
+}
+
+// Get the blob stored at key
+func (f *Filesystem) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(f.Path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put data at key. Data is written to a temp file & renamed into place once it's
+// on disk, so a blob is never half written.
+func (f *Filesystem) Put(key string, data []byte) error {
+	dst := f.Path(key)
+	err := os.MkdirAll(filepath.Dir(dst), 0750)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	cerr := tmp.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// Delete the blob at key & everything under it
+func (f *Filesystem) Delete(key string) error {
+	return os.RemoveAll(f.Path(key))
+}
+
+// List keys of all blobs that begin with prefix
+func (f *Filesystem) List(prefix string) ([]string, error) {
+	// only walk the folder the prefix is in
+	dir := f.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = f.Path(prefix[:i])
+	}
+
+	found := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(f.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if path != dir && !strings.HasPrefix(key, prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir // nothing in here can match
+			}
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+		if strings.HasPrefix(key, prefix) {
+			found = append(found, key)
+		}
+		return nil
+	})
+	return found, err
+}
+
+// Move renames `from` to `to`
+func (f *Filesystem) Move(from, to string) error {
+	src := f.Path(from)
+	_, err := os.Stat(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	dst := f.Path(to)
+	err = os.RemoveAll(dst)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(dst), 0750)
+	if err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
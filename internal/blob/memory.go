@@ -0,0 +1,93 @@
+package blob
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory keeps blobs in memory (handy for tests)
+type Memory struct {
+	data map[string][]byte
+	lock sync.RWMutex
+}
+
+// NewMemory returns empty in memory storage
+func NewMemory() *Memory {
+	return &Memory{data: map[string][]byte{}}
+}
+
+// Get the blob stored at key
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	data, ok := m.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, data...), nil
+}
+
+// Put data at key
+func (m *Memory) Put(key string, data []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.data[key] = append([]byte{}, data...)
+	return nil
+}
+
+// Delete the blob at key & everything under it
+func (m *Memory) Delete(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for k := range m.data {
+		if under(k, key) {
+			delete(m.data, k)
+		}
+	}
+	return nil
+}
+
+// List keys of all blobs that begin with prefix
+func (m *Memory) List(prefix string) ([]string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	found := []string{}
+	for k := range m.data {
+		if strings.HasPrefix(k, prefix) {
+			found = append(found, k)
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// Move re-keys `from` to `to`
+func (m *Memory) Move(from, to string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	moving := map[string][]byte{}
+	for k, v := range m.data {
+		if under(k, from) {
+			moving[to+strings.TrimPrefix(k, from)] = v
+		}
+	}
+	if len(moving) == 0 {
+		return nil
+	}
+
+	for k := range m.data {
+		if under(k, from) || under(k, to) {
+			delete(m.data, k)
+		}
+	}
+	for k, v := range moving {
+		m.data[k] = v
+	}
+	return nil
+}
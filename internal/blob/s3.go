@@ -0,0 +1,377 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/voidshard/genesis/internal/config"
+)
+
+const (
+	// defaultRegion is used if no region is configured (most S3 alikes don't care)
+	defaultRegion = "us-east-1"
+
+	// sigAlgorithm is the AWS signature (v4) we use
+	sigAlgorithm = "AWS4-HMAC-SHA256"
+
+	// amzDate is the time format used in signed requests
+	amzDate = "20060102T150405Z"
+)
+
+var (
+	// ErrInvalidConfig is returned if storage is missing required settings
+	ErrInvalidConfig = fmt.Errorf("invalid storage config")
+
+	// ErrRequest is returned if S3 replies with an unexpected status
+	ErrRequest = fmt.Errorf("storage request failed")
+)
+
+// S3 keeps blobs in a bucket of an S3 compatible service (AWS, MinIO etc). We use
+// path style URLs (eg. http://host:port/bucket/key) since that's what everyone supports.
+type S3 struct {
+	endpoint *url.URL
+	bucket   string
+	region   string
+
+	accessKey string
+	secretKey string
+
+	client *http.Client
+	now    func() time.Time
+}
+
+// listBucketResult is the reply to a ListObjectsV2 request
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// NewS3 returns storage backed by an S3 bucket. Keys not given in the config are
+// read from the usual AWS env vars.
+func NewS3(opts *config.Storage) (*S3, error) {
+	if opts.Location == "" || opts.Bucket == "" {
+		return nil, fmt.Errorf("%w s3 requires location & bucket", ErrInvalidConfig)
+	}
+	u, err := url.Parse(opts.Location)
+	if err != nil {
+		return nil, fmt.Errorf("%w %v", ErrInvalidConfig, err)
+	}
+
+	s := &S3{
+		endpoint:  u,
+		bucket:    opts.Bucket,
+		region:    opts.Region,
+		accessKey: opts.AccessKey,
+		secretKey: opts.SecretKey,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+		now:       time.Now,
+	}
+	if s.region == "" {
+		s.region = defaultRegion
+	}
+	if s.accessKey == "" {
+		s.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if s.secretKey == "" {
+		s.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+// Get the blob stored at key
+func (s *S3) Get(key string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.failed(resp, key)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Put data at key
+func (s *S3) Put(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, key, nil, nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.failed(resp, key)
+	}
+	return nil
+}
+
+// Delete the blob at key & everything under it
+func (s *S3) Delete(key string) error {
+	found, err := s.List(key)
+	if err != nil {
+		return err
+	}
+	for _, k := range found {
+		if !under(k, key) {
+			continue
+		}
+		err = s.deleteOne(k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List keys of all blobs that begin with prefix
+func (s *S3) List(prefix string) ([]string, error) {
+	found := []string{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = s.failed(resp, prefix)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		result := &listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			found = append(found, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Strings(found)
+	return found, nil
+}
+
+// Move copies `from` to `to` (S3 can't rename), `from` is left in place
+func (s *S3) Move(from, to string) error {
+	found, err := s.List(from)
+	if err != nil {
+		return err
+	}
+	copies := map[string]string{} // dst -> src
+	for _, k := range found {
+		if under(k, from) {
+			copies[to+strings.TrimPrefix(k, from)] = k
+		}
+	}
+	if len(copies) == 0 {
+		return nil
+	}
+
+	// remove anything at the destination we're not about to overwrite
+	existing, err := s.List(to)
+	if err != nil {
+		return err
+	}
+	for _, k := range existing {
+		_, ok := copies[k]
+		if !under(k, to) || ok {
+			continue
+		}
+		err = s.deleteOne(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	for dst, src := range copies {
+		err = s.copyOne(src, dst)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteOne deletes a single object
+func (s *S3) deleteOne(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s.failed(resp, key)
+	}
+	return nil
+}
+
+// copyOne copies a single object server side
+func (s *S3) copyOne(src, dst string) error {
+	hdr := http.Header{}
+	hdr.Set("x-amz-copy-source", "/"+s.bucket+"/"+uriEncode(src, false))
+
+	resp, err := s.do(http.MethodPut, dst, nil, hdr, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.failed(resp, dst)
+	}
+	return nil
+}
+
+// do sends a signed request for the given object key (or the bucket if key is "")
+func (s *S3) do(method, key string, query url.Values, hdr http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + "/" + s.bucket
+	u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + "/" + uriEncode(s.bucket, false)
+	if key != "" {
+		u.Path += "/" + key
+		u.RawPath += "/" + uriEncode(key, false)
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range hdr {
+		req.Header[k] = vs
+	}
+
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), s.now().UTC())
+
+	return s.client.Do(req)
+}
+
+// failed builds an error from an unexpected reply
+func (s *S3) failed(resp *http.Response, key string) error {
+	msg, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("%w %s %s: %s %s", ErrRequest, resp.Request.Method, key, resp.Status, msg)
+}
+
+// sign adds AWS signature v4 headers to a request. We sign the host & all headers
+// already set on the request.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	stamp := now.Format(amzDate)
+	day := stamp[:8]
+
+	req.Header.Set("x-amz-date", stamp)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, vs := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(vs, ","))
+	}
+	names := []string{}
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	canonHeaders := ""
+	for _, k := range names {
+		canonHeaders += k + ":" + headers[k] + "\n"
+	}
+	signed := strings.Join(names, ";")
+
+	canonRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders,
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{day, s.region, "s3", "aws4_request"}, "/")
+	reqHash := sha256.Sum256([]byte(canonRequest))
+	toSign := strings.Join([]string{sigAlgorithm, stamp, scope, hex.EncodeToString(reqHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigAlgorithm, s.accessKey, scope, signed, sig,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query params sorted by name, as AWS wants them
+func canonicalQuery(query url.Values) string {
+	names := []string{}
+	for k := range query {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	parts := []string{}
+	for _, k := range names {
+		vs := append([]string{}, query[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode escapes everything but unreserved characters (& optionally "/")
+func uriEncode(in string, encodeSlash bool) string {
+	buf := &strings.Builder{}
+	for _, b := range []byte(in) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,166 @@
+package brush
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+var (
+	// ErrInvalidBrush is returned if a brush has no (or too many) shapes
+	ErrInvalidBrush = fmt.Errorf("brush must have exactly one of polygon, radius or mask")
+)
+
+// Mask returns how strongly (0-255) the brush applies to each pixel within `bounds`.
+// The mask covers only the area the brush touches (which may be empty).
+func Mask(b *types.Brush, bounds image.Rectangle) (*image.Gray, error) {
+	shapes := 0
+	if len(b.Polygon) > 0 {
+		shapes++
+	}
+	if b.Radius > 0 {
+		shapes++
+	}
+	if b.Mask != nil {
+		shapes++
+	}
+	if shapes != 1 {
+		return nil, ErrInvalidBrush
+	}
+
+	switch {
+	case len(b.Polygon) > 0:
+		return polygon(b.Polygon, b.Falloff, bounds)
+	case b.Radius > 0:
+		return circle(b.Centre, b.Radius, b.Falloff, bounds), nil
+	}
+	return mask(b.Mask, b.Falloff, bounds), nil
+}
+
+// strength returns how strong the brush is `dist` pixels in from the edge
+func strength(dist float64, falloff int) uint8 {
+	if dist < 0 {
+		return 0
+	}
+	if falloff <= 0 || dist >= float64(falloff) {
+		return 255
+	}
+	return uint8(255 * dist / float64(falloff))
+}
+
+func circle(centre image.Point, radius, falloff int, bounds image.Rectangle) *image.Gray {
+	area := image.Rect(centre.X-radius, centre.Y-radius, centre.X+radius+1, centre.Y+radius+1).Intersect(bounds)
+	out := image.NewGray(area)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			d := math.Hypot(float64(x-centre.X), float64(y-centre.Y))
+			out.Pix[out.PixOffset(x, y)] = strength(float64(radius)-d, falloff)
+		}
+	}
+	return out
+}
+
+func polygon(pts []image.Point, falloff int, bounds image.Rectangle) (*image.Gray, error) {
+	if len(pts) < 3 {
+		return nil, fmt.Errorf("%w: polygon needs at least 3 points", ErrInvalidBrush)
+	}
+
+	area := image.Rectangle{Min: pts[0], Max: pts[0]}
+	for _, p := range pts[1:] {
+		area = area.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+	}
+	area = area.Intersect(bounds)
+
+	out := image.NewGray(area)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if !inside(pts, x, y) {
+				continue
+			}
+			d := math.Inf(1)
+			if falloff > 0 {
+				for i := range pts {
+					d = math.Min(d, toSegment(x, y, pts[i], pts[(i+1)%len(pts)]))
+				}
+			}
+			out.Pix[out.PixOffset(x, y)] = strength(d, falloff)
+		}
+	}
+	return out, nil
+}
+
+func mask(in image.Image, falloff int, bounds image.Rectangle) *image.Gray {
+	area := in.Bounds().Intersect(bounds)
+	out := image.NewGray(area)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			r, _, _, _ := in.At(x, y).RGBA()
+			out.Pix[out.PixOffset(x, y)] = uint8(r >> 8)
+		}
+	}
+	if falloff > 0 {
+		blur(out, falloff)
+	}
+	return out
+}
+
+// inside returns if x,y is within the polygon (even-odd rule)
+func inside(pts []image.Point, x, y int) bool {
+	in := false
+	px, py := float64(x), float64(y)
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		a, b := pts[i], pts[j]
+		if (float64(a.Y) > py) != (float64(b.Y) > py) {
+			cross := float64(b.X-a.X)*(py-float64(a.Y))/float64(b.Y-a.Y) + float64(a.X)
+			if px < cross {
+				in = !in
+			}
+		}
+	}
+	return in
+}
+
+// toSegment returns the distance from x,y to the line segment a-b
+func toSegment(x, y int, a, b image.Point) float64 {
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	px, py := float64(x-a.X), float64(y-a.Y)
+
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, (px*dx+py*dy)/l))
+	}
+	return math.Hypot(px-t*dx, py-t*dy)
+}
+
+// blur softens the edges of a mask with a box blur of the given radius
+func blur(im *image.Gray, radius int) {
+	area := im.Bounds()
+	tmp := make([]uint8, len(im.Pix))
+
+	pass := func(src, dst []uint8, horizontal bool) {
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				sum, n := 0, 0
+				for k := -radius; k <= radius; k++ {
+					sx, sy := x, y
+					if horizontal {
+						sx += k
+					} else {
+						sy += k
+					}
+					if !(image.Point{sx, sy}).In(area) {
+						continue
+					}
+					sum += int(src[im.PixOffset(sx, sy)])
+					n++
+				}
+				dst[im.PixOffset(x, y)] = uint8(sum / n)
+			}
+		}
+	}
+
+	pass(im.Pix, tmp, true)
+	pass(tmp, im.Pix, false)
+}
@@ -0,0 +1,88 @@
+package brush
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func TestMask(t *testing.T) {
+	world := image.Rect(0, 0, 100, 100)
+
+	square := image.NewGray(image.Rect(40, 40, 60, 60))
+	for i := range square.Pix {
+		square.Pix[i] = 255
+	}
+
+	cases := map[string]struct {
+		Brush  *types.Brush
+		Area   image.Rectangle
+		Values map[image.Point]uint8
+		Err    error
+	}{
+		"circle": {
+			Brush:  &types.Brush{Centre: image.Pt(50, 50), Radius: 10},
+			Area:   image.Rect(40, 40, 61, 61),
+			Values: map[image.Point]uint8{{50, 50}: 255, {59, 50}: 255, {58, 58}: 0, {40, 40}: 0},
+		},
+		"circle falloff": {
+			Brush:  &types.Brush{Centre: image.Pt(50, 50), Radius: 10, Falloff: 10},
+			Area:   image.Rect(40, 40, 61, 61),
+			Values: map[image.Point]uint8{{50, 50}: 255, {55, 50}: 127, {60, 50}: 0},
+		},
+		"circle clipped": {
+			Brush:  &types.Brush{Centre: image.Pt(0, 0), Radius: 10},
+			Area:   image.Rect(0, 0, 11, 11),
+			Values: map[image.Point]uint8{{0, 0}: 255},
+		},
+		"polygon": {
+			Brush:  &types.Brush{Polygon: []image.Point{{10, 10}, {30, 10}, {10, 30}}},
+			Area:   image.Rect(10, 10, 31, 31),
+			Values: map[image.Point]uint8{{12, 12}: 255, {28, 28}: 0},
+		},
+		"polygon falloff": {
+			Brush:  &types.Brush{Polygon: []image.Point{{10, 10}, {50, 10}, {50, 50}, {10, 50}}, Falloff: 10},
+			Area:   image.Rect(10, 10, 51, 51),
+			Values: map[image.Point]uint8{{30, 30}: 255, {15, 30}: 127, {30, 12}: 51},
+		},
+		"mask": {
+			Brush:  &types.Brush{Mask: square},
+			Area:   square.Bounds(),
+			Values: map[image.Point]uint8{{40, 40}: 255, {59, 59}: 255},
+		},
+		"mask falloff": {
+			Brush:  &types.Brush{Mask: square, Falloff: 2},
+			Area:   square.Bounds(),
+			Values: map[image.Point]uint8{{50, 50}: 255},
+		},
+		"nothing": {
+			Brush: &types.Brush{},
+			Err:   ErrInvalidBrush,
+		},
+		"too much": {
+			Brush: &types.Brush{Radius: 3, Mask: square},
+			Err:   ErrInvalidBrush,
+		},
+		"line": {
+			Brush: &types.Brush{Polygon: []image.Point{{1, 1}, {2, 2}}},
+			Err:   ErrInvalidBrush,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := Mask(tt.Brush, world)
+			assert.ErrorIs(t, err, tt.Err)
+			if tt.Err != nil {
+				return
+			}
+			assert.Equal(t, tt.Area, m.Bounds())
+			for p, v := range tt.Values {
+				assert.Equal(t, v, m.GrayAt(p.X, p.Y).Y, p)
+			}
+		})
+	}
+}
@@ -26,6 +26,13 @@ const (
 	SearchDriverBleve = "bleve"
 
 	DatabaseDriverSQLite = "sqlite3"
+
+	// DatabaseNameMemory is the sqlite db name that keeps everything in memory
+	DatabaseNameMemory = ":memory:"
+
+	StorageDriverFilesystem = "filesystem"
+	StorageDriverMemory     = "memory"
+	StorageDriverS3         = "s3"
 )
 
 var (
@@ -42,6 +49,9 @@ name=genesis.sqlite
 driver=bleve
 name=genesis.bleve
 
+[storage]
+driver=filesystem
+
 [geography]
 
 [civilization]
@@ -57,6 +67,8 @@ type Config struct {
 
 	Search Search `ini:"search"`
 
+	Storage Storage `ini:"storage"`
+
 	Geography    Geography    `ini:"geography"`
 	Civilization Civilization `ini:"civilization"`
 }
@@ -73,6 +85,18 @@ type Search struct {
 	Location string `ini:"location"` // host:port or folder
 }
 
+// Storage is where we keep canvases & graphs
+type Storage struct {
+	Driver   string `ini:"driver"`
+	Location string `ini:"location"` // folder or S3 endpoint (eg. http://localhost:9000)
+
+	// S3 only
+	Bucket    string `ini:"bucket"`
+	Region    string `ini:"region"`
+	AccessKey string `ini:"access_key"`
+	SecretKey string `ini:"secret_key"`
+}
+
 type Geography struct {
 }
 
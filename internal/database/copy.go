@@ -0,0 +1,123 @@
+package database
+
+import (
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// copyBatch is how many rows Copy writes at once
+const copyBatch = 200
+
+// Copy writes all projects (& their landmasses, jobs & journals) in `src` to `dst`
+func Copy(dst, src Database) error {
+	projects := []*types.Project{}
+	landmasses := []*types.Landmass{}
+	jobs := []*types.Job{}
+	operations := []*types.Operation{}
+	heads := map[string]int{}
+
+	tkn := ""
+	for {
+		found, next, err := src.ListProjects(tkn)
+		if err != nil {
+			return err
+		}
+		projects = append(projects, found...)
+		if next == "" {
+			break
+		}
+		tkn = next
+	}
+
+	for _, p := range projects {
+		tkn = ""
+		for {
+			found, next, err := src.ListLandmasses(p.ID, tkn)
+			if err != nil {
+				return err
+			}
+			landmasses = append(landmasses, found...)
+			if next == "" {
+				break
+			}
+			tkn = next
+		}
+
+		tkn = ""
+		for {
+			found, next, err := src.ListJobs(p.ID, tkn)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, found...)
+			if next == "" {
+				break
+			}
+			tkn = next
+		}
+
+		tkn = ""
+		for {
+			found, next, err := src.ListOperations(p.ID, tkn)
+			if err != nil {
+				return err
+			}
+			operations = append(operations, found...)
+			if next == "" {
+				break
+			}
+			tkn = next
+		}
+
+		head, err := src.JournalHead(p.ID)
+		if err != nil {
+			return err
+		}
+		heads[p.ID] = head
+	}
+
+	tx, err := dst.Begin()
+	if err != nil {
+		return err
+	}
+
+	// rows are written in batches, both to skip empty writes & stay under sqlite's
+	// limit on query params
+	for i := 0; i < len(projects); i += copyBatch {
+		err = tx.SetProjects(projects[i:min(i+copyBatch, len(projects))])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for i := 0; i < len(landmasses); i += copyBatch {
+		err = tx.SetLandmasses(landmasses[i:min(i+copyBatch, len(landmasses))])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for i := 0; i < len(jobs); i += copyBatch {
+		err = tx.SetJobs(jobs[i:min(i+copyBatch, len(jobs))])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	for i := 0; i < len(operations); i += copyBatch {
+		err = tx.SetOperations(operations[i:min(i+copyBatch, len(operations))])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for id, head := range heads {
+		err = tx.SetJournalHead(id, head)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
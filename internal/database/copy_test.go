@@ -0,0 +1,117 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/config"
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func testSqlite(t *testing.T, name string) Database {
+	db, err := NewSqlite3(&config.Database{Driver: config.DatabaseDriverSQLite, Location: t.TempDir(), Name: name})
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCopy(t *testing.T) {
+	src := testSqlite(t, "src.sqlite")
+	dst := testSqlite(t, "dst.sqlite")
+
+	created := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	a, b := dbutils.NewID("a"), dbutils.NewID("b")
+
+	projects := []*types.Project{
+		{ID: a, Name: "first", Epoch: 2, Seed: 7, WorldWidth: 100, WorldHeight: 50},
+		{ID: b, Name: "second", WorldWidth: 20, WorldHeight: 20, Wrap: true},
+	}
+	landmasses := []*types.Landmass{
+		{ProjectID: a, ID: dbutils.NewID(a, "land"), Epoch: 2, Size: 40, ColorR: 1, FirstX: 3, FirstY: 4},
+	}
+	jobs := []*types.Job{
+		{ID: dbutils.NewID(a, "job"), ProjectID: a, Kind: "mountains", Status: types.JobSucceeded, Progress: 1, Created: created},
+	}
+
+	// more than a batch so we write in several goes
+	operations := []*types.Operation{}
+	for i := 1; i <= copyBatch+10; i++ {
+		operations = append(operations, &types.Operation{
+			ID:        dbutils.NewID(a, i),
+			ProjectID: a,
+			Seq:       i,
+			Epoch:     2,
+			Kind:      "mountains",
+			Params:    json.RawMessage(`{}`),
+			Seed:      int64(i),
+			Created:   created,
+		})
+	}
+
+	tx, err := src.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.SetProjects(projects))
+	assert.Nil(t, tx.SetLandmasses(landmasses))
+	assert.Nil(t, tx.SetJobs(jobs))
+	assert.Nil(t, tx.SetOperations(operations))
+	assert.Nil(t, tx.SetJournalHead(a, copyBatch))
+	assert.Nil(t, tx.Commit())
+
+	assert.Nil(t, Copy(dst, src))
+
+	found, err := dst.Projects([]string{a, b})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, projects, found)
+
+	lands, _, err := dst.ListLandmasses(a, "")
+	assert.Nil(t, err)
+	assert.Equal(t, landmasses, lands)
+
+	js, _, err := dst.ListJobs(a, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(js))
+	assert.Equal(t, jobs[0].ID, js[0].ID)
+	assert.Equal(t, types.JobSucceeded, js[0].Status)
+	assert.True(t, created.Equal(js[0].Created))
+
+	ops := []*types.Operation{}
+	tkn := ""
+	for {
+		found, next, err := dst.ListOperations(a, tkn)
+		assert.Nil(t, err)
+		ops = append(ops, found...)
+		if next == "" {
+			break
+		}
+		tkn = next
+	}
+	assert.Equal(t, len(operations), len(ops))
+	for i, op := range ops {
+		assert.Equal(t, operations[i].ID, op.ID)
+		assert.Equal(t, operations[i].Seq, op.Seq)
+		assert.Equal(t, operations[i].Seed, op.Seed)
+	}
+
+	head, err := dst.JournalHead(a)
+	assert.Nil(t, err)
+	assert.Equal(t, copyBatch, head)
+
+	head, err = dst.JournalHead(b)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, head)
+}
+
+func TestCopyEmpty(t *testing.T) {
+	src := testSqlite(t, "src.sqlite")
+	dst := testSqlite(t, "dst.sqlite")
+
+	assert.Nil(t, Copy(dst, src))
+
+	found, _, err := dst.ListProjects("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(found))
+}
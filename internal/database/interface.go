@@ -34,6 +34,13 @@ type Transaction interface {
 type Iterate interface {
 	ListProjects(token string) ([]*types.Project, string, error)
 	ListLandmasses(projectID string, token string) ([]*types.Landmass, string, error)
+
+	// ListJobs iterates over jobs, newest first. If projectID is given only
+	// jobs of that project are returned.
+	ListJobs(projectID string, token string) ([]*types.Job, string, error)
+
+	// ListOperations iterates over a project's journal, in order (oldest first)
+	ListOperations(projectID string, token string) ([]*types.Operation, string, error)
 }
 
 // Read allows one to look up items by their IDs
@@ -41,14 +48,24 @@ type Read interface {
 	Projects([]string) ([]*types.Project, error)
 	Meta(string) (string, int, error)
 	Landmasses([]string) ([]*types.Landmass, error)
+	Jobs([]string) ([]*types.Job, error)
+
+	// JournalHead returns the Seq of the last operation in effect on the project
+	// (later operations have been undone & may be replayed), 0 if there are none.
+	JournalHead(projectID string) (int, error)
 }
 
 // Write updates the database, only usable in a Transaction
 type Write interface {
 	SetProjects([]*types.Project) error
+	DeleteProject(id string) error
 	SetMeta(id, str_value string, int_value int) error
 	SetLandmasses([]*types.Landmass) error
 	DeleteLandmassesByProjectEpoch(id string, e int) error
+	SetJobs([]*types.Job) error
+	SetOperations([]*types.Operation) error
+	DeleteOperationsAfter(projectID string, seq int) error
+	SetJournalHead(projectID string, seq int) error
 }
 
 // New returns a new database from a config
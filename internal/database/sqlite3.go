@@ -16,7 +16,7 @@ const (
 
 	// currentSchemaVersion of the db schema. Should be updated
 	// when we update the tables so we can handle migrations
-	currentSchemaVersion = 1
+	currentSchemaVersion = 4
 )
 
 var (
@@ -32,7 +32,9 @@ var (
 	epoch INTEGER NOT NULL DEFAULT 0,
 	seed INTEGER NOT NULL DEFAULT 0,
 	world_width INTEGER NOT NULL,
-	world_height INTEGER NOT NULL
+	world_height INTEGER NOT NULL,
+	wrap BOOLEAN NOT NULL DEFAULT 0,
+	projection TEXT NOT NULL DEFAULT 'flat'
     );`, TableProjects)
 
 	createLandmasses = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
@@ -47,7 +49,42 @@ var (
 	first_y INTEGER NOT NULL DEFAULT 0
     );`, TableLandmasses)
 
-	indexes = []string{}
+	createJobs = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(255) PRIMARY KEY,
+	project_id VARCHAR(255) NOT NULL,
+	kind VARCHAR(255) NOT NULL DEFAULT "",
+	status VARCHAR(255) NOT NULL DEFAULT "",
+	error TEXT NOT NULL DEFAULT "",
+	progress REAL NOT NULL DEFAULT 0,
+	message TEXT NOT NULL DEFAULT "",
+	result BLOB NOT NULL DEFAULT x'',
+	created DATETIME NOT NULL,
+	started DATETIME,
+	finished DATETIME
+    );`, TableJobs)
+
+	createOperations = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(255) PRIMARY KEY,
+	project_id VARCHAR(255) NOT NULL,
+	seq INTEGER NOT NULL,
+	epoch INTEGER NOT NULL DEFAULT 0,
+	kind VARCHAR(255) NOT NULL DEFAULT "",
+	params BLOB NOT NULL DEFAULT x'',
+	seed INTEGER NOT NULL DEFAULT 0,
+	created DATETIME NOT NULL,
+	UNIQUE (project_id, seq)
+    );`, TableOperations)
+
+	// migrations bring tables made by an older schema up to date, by the version
+	// they upgrade to (new tables are simply created)
+	migrations = map[int][]string{
+		3: {fmt.Sprintf(`ALTER TABLE %s ADD COLUMN wrap BOOLEAN NOT NULL DEFAULT 0;`, TableProjects)},
+		4: {fmt.Sprintf(`ALTER TABLE %s ADD COLUMN projection TEXT NOT NULL DEFAULT 'flat';`, TableProjects)},
+	}
+
+	indexes = []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_project_created ON %s (project_id, created);`, TableJobs, TableJobs),
+	}
 )
 
 // Sqlite represents a DB connection to sqlite
@@ -58,10 +95,19 @@ type Sqlite struct {
 // NewSqlite3 opens a SQLite DB file.
 // We also attempt to create / update tables to make it ready for use.
 func NewSqlite3(cfg *config.Database) (*Sqlite, error) {
-	db, err := sqlx.Connect("sqlite3", filepath.Join(cfg.Location, cfg.Name))
+	dsn := filepath.Join(cfg.Location, cfg.Name)
+	if cfg.Name == config.DatabaseNameMemory {
+		dsn = cfg.Name
+	}
+
+	db, err := sqlx.Connect("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Name == config.DatabaseNameMemory {
+		// each connection to :memory: gets it's own (empty) db, so we must only have one
+		db.SetMaxOpenConns(1)
+	}
 	me := &Sqlite{&sqlDB{conn: db}}
 	return me, me.setupDatabase()
 }
@@ -76,6 +122,11 @@ func (s *Sqlite) setupDatabase() error {
 		return err
 	}
 
+	err = s.migrate()
+	if err != nil {
+		return err
+	}
+
 	txn, err := s.Begin()
 	if err != nil {
 		return err
@@ -94,7 +145,7 @@ func (s *Sqlite) setupDatabase() error {
 // We'll try to press on despite errors.
 func (s *Sqlite) createTables() error {
 	var final error
-	todo := []string{createMeta, createProjects, createLandmasses}
+	todo := []string{createMeta, createProjects, createLandmasses, createJobs, createOperations}
 	todo = append(todo, indexes...)
 	for _, ddl := range todo {
 		_, err := s.conn.Exec(ddl)
@@ -108,3 +159,23 @@ func (s *Sqlite) createTables() error {
 	}
 	return final
 }
+
+// migrate runs migrations for every schema version after the one the db was made with
+func (s *Sqlite) migrate() error {
+	_, version, err := s.Meta(metaSchemaVersion)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil // a new db, createTables made everything as it is now
+	}
+	for v := version + 1; v <= currentSchemaVersion; v++ {
+		for _, ddl := range migrations[v] {
+			_, err = s.conn.Exec(ddl)
+			if err != nil {
+				return fmt.Errorf("migrating to schema version %d: %w", v, err)
+			}
+		}
+	}
+	return nil
+}
@@ -16,6 +16,8 @@ const (
 	TableMeta       = "meta"
 	TableProjects   = "projects"
 	TableLandmasses = "landmasses"
+	TableJobs       = "jobs"
+	TableOperations = "operations"
 	chunksize       = 6000
 )
 
@@ -52,6 +54,26 @@ func (s *sqlDB) ListLandmasses(projectID string, token string) ([]*types.Landmas
 	return listLandmasses(s.conn, projectID, token)
 }
 
+// Jobs fetches jobs outside of a transaction
+func (s *sqlDB) Jobs(ids []string) ([]*types.Job, error) {
+	return jobs(s.conn, ids)
+}
+
+// ListJobs iterates over jobs (optionally of one project) with some token
+func (s *sqlDB) ListJobs(projectID string, token string) ([]*types.Job, string, error) {
+	return listJobs(s.conn, projectID, token)
+}
+
+// ListOperations iterates over a project's journal with some token
+func (s *sqlDB) ListOperations(projectID string, token string) ([]*types.Operation, string, error) {
+	return listOperations(s.conn, projectID, token)
+}
+
+// JournalHead returns the last operation in effect on a project, outside of a transaction
+func (s *sqlDB) JournalHead(projectID string) (int, error) {
+	return journalHead(s.conn, projectID)
+}
+
 // Close connection to DB
 func (s *sqlDB) Close() error {
 	return s.conn.Close()
@@ -102,6 +124,11 @@ func (t *sqlTx) SetProjects(in []*types.Project) error {
 	return setProjects(t.tx, in)
 }
 
+// DeleteProject removes a project & everything belonging to it inside transaction
+func (t *sqlTx) DeleteProject(id string) error {
+	return deleteProject(t.tx, id)
+}
+
 // SetLandmasses writes landmasses (insert or update) inside transaction
 func (t *sqlTx) SetLandmasses(in []*types.Landmass) error {
 	return setLandmasses(t.tx, in)
@@ -117,6 +144,36 @@ func (t *sqlTx) DeleteLandmassesByProjectEpoch(projectID string, e int) error {
 	return deleteLandmassesByProjectEpoch(t.tx, projectID, e)
 }
 
+// Jobs reads jobs inside transaction
+func (t *sqlTx) Jobs(ids []string) ([]*types.Job, error) {
+	return jobs(t.tx, ids)
+}
+
+// SetJobs writes jobs (insert or update) inside transaction
+func (t *sqlTx) SetJobs(in []*types.Job) error {
+	return setJobs(t.tx, in)
+}
+
+// JournalHead returns the last operation in effect on a project inside transaction
+func (t *sqlTx) JournalHead(projectID string) (int, error) {
+	return journalHead(t.tx, projectID)
+}
+
+// SetJournalHead sets the last operation in effect on a project inside transaction
+func (t *sqlTx) SetJournalHead(projectID string, seq int) error {
+	return setJournalHead(t.tx, projectID, seq)
+}
+
+// SetOperations writes journal entries (insert or update) inside transaction
+func (t *sqlTx) SetOperations(in []*types.Operation) error {
+	return setOperations(t.tx, in)
+}
+
+// DeleteOperationsAfter removes journal entries of a project after `seq` inside transaction
+func (t *sqlTx) DeleteOperationsAfter(projectID string, seq int) error {
+	return deleteOperationsAfter(t.tx, projectID, seq)
+}
+
 // sqlOperator is something that can perform an sql operation read/write
 // We do this so we can have some lower level funcs that perform the query logic regardless
 // of whether we are in a transaction or not.
@@ -160,15 +217,19 @@ func listLandmasses(op sqlOperator, projectID, tkn string) ([]*types.Landmass, s
 		return nil, "", err
 	}
 
+	if !dbutils.IsValidID(projectID) {
+		return nil, "", fmt.Errorf("project id %s is invalid", projectID)
+	}
+
 	query := fmt.Sprintf(
-		"SELECT * FROM %s ORDER BY id LIMIT %d OFFSET %d;",
+		"SELECT * FROM %s WHERE project_id=$1 ORDER BY id LIMIT %d OFFSET %d;",
 		TableLandmasses,
 		itr.Limit,
 		itr.Offset,
 	)
 
 	result := []*types.Landmass{}
-	err = op.Select(&result, query)
+	err = op.Select(&result, query, projectID)
 
 	if err != nil {
 		return nil, tkn, err
@@ -257,13 +318,15 @@ func setProjects(op sqlOperator, in []*types.Project) error {
 	}
 
 	qstr := fmt.Sprintf(
-		`INSERT INTO %s (id, name, epoch, seed, world_width, world_height)
-		VALUES (:id, :name, :epoch, :seed, :world_width, :world_height) 
+		`INSERT INTO %s (id, name, epoch, seed, world_width, world_height, wrap, projection)
+		VALUES (:id, :name, :epoch, :seed, :world_width, :world_height, :wrap, :projection) 
 		ON CONFLICT (id) DO UPDATE SET
 		    epoch=EXCLUDED.epoch,
 		    seed=EXCLUDED.seed,
 		    world_width=EXCLUDED.world_width,
-		    world_height=EXCLUDED.world_height
+		    world_height=EXCLUDED.world_height,
+		    wrap=EXCLUDED.wrap,
+		    projection=EXCLUDED.projection
 		;`,
 		TableProjects,
 	)
@@ -271,6 +334,26 @@ func setProjects(op sqlOperator, in []*types.Project) error {
 	return err
 }
 
+// deleteProject base level func to remove a project & it's rows in other tables
+func deleteProject(op sqlOperator, id string) error {
+	if !dbutils.IsValidID(id) {
+		return fmt.Errorf("project id %s is invalid", id)
+	}
+	for _, q := range []string{
+		fmt.Sprintf(`DELETE FROM %s WHERE project_id=:id;`, TableLandmasses),
+		fmt.Sprintf(`DELETE FROM %s WHERE project_id=:id;`, TableJobs),
+		fmt.Sprintf(`DELETE FROM %s WHERE project_id=:id;`, TableOperations),
+		fmt.Sprintf(`DELETE FROM %s WHERE id=:journal;`, TableMeta),
+		fmt.Sprintf(`DELETE FROM %s WHERE id=:id;`, TableProjects),
+	} {
+		_, err := op.NamedExec(q, map[string]interface{}{"id": id, "journal": journalKey(id)})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // landmasses base level func to query landmasses
 func landmasses(op sqlOperator, ids []string) ([]*types.Landmass, error) {
 	wstr, args := queryByIds(ids)
@@ -301,9 +384,10 @@ func setLandmasses(op sqlOperator, in []*types.Landmass) error {
 	}
 
 	qstr := fmt.Sprintf(
-		`INSERT INTO %s (project_id, id, size, color_r, color_g, color_b, first_x, first_y)
-		VALUES (:project_id, :id, :size, :color_r, :color_g, :color_b, :first_x, :first_y) 
+		`INSERT INTO %s (project_id, id, epoch, size, color_r, color_g, color_b, first_x, first_y)
+		VALUES (:project_id, :id, :epoch, :size, :color_r, :color_g, :color_b, :first_x, :first_y) 
 		ON CONFLICT (id) DO UPDATE SET
+		    epoch=EXCLUDED.epoch,
 		    size=EXCLUDED.size,
 		    color_r=EXCLUDED.color_r,
 		    color_g=EXCLUDED.color_g,
@@ -328,6 +412,183 @@ func deleteLandmassesByProjectEpoch(op sqlOperator, projectID string, e int) err
 	return err
 }
 
+// listJobs iterates over jobs, newest first, optionally only those of the given project
+func listJobs(op sqlOperator, projectID, tkn string) ([]*types.Job, string, error) {
+	itr, err := dbutils.ParseIterToken(tkn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	where := ""
+	args := []interface{}{}
+	if projectID != "" {
+		if !dbutils.IsValidID(projectID) {
+			return nil, "", fmt.Errorf("project id %s is invalid", projectID)
+		}
+		where = "WHERE project_id=$1"
+		args = append(args, projectID)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT * FROM %s %s ORDER BY created DESC, id LIMIT %d OFFSET %d;",
+		TableJobs,
+		where,
+		itr.Limit,
+		itr.Offset,
+	)
+
+	result := []*types.Job{}
+	err = op.Select(&result, query, args...)
+
+	if err != nil {
+		return nil, tkn, err
+	} else if len(result) < itr.Limit {
+		return result, "", nil
+	} else {
+		itr.Offset += itr.Limit
+		return result, itr.String(), nil
+	}
+}
+
+// jobs base level func to query jobs
+func jobs(op sqlOperator, ids []string) ([]*types.Job, error) {
+	wstr, args := queryByIds(ids)
+	if args == nil {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(
+		"SELECT * FROM %s %s LIMIT %d;",
+		TableJobs,
+		wstr,
+		len(ids),
+	)
+
+	result := []*types.Job{}
+	return result, op.Select(&result, query, args...)
+}
+
+// setJobs writes jobs (insert or update)
+func setJobs(op sqlOperator, in []*types.Job) error {
+	for _, j := range in {
+		if !dbutils.IsValidID(j.ID) {
+			return fmt.Errorf("job id %s is invalid", j.ID)
+		}
+		if !dbutils.IsValidID(j.ProjectID) {
+			return fmt.Errorf("job project id %s is invalid", j.ProjectID)
+		}
+	}
+
+	qstr := fmt.Sprintf(
+		`INSERT INTO %s (id, project_id, kind, status, error, progress, message, result, created, started, finished)
+		VALUES (:id, :project_id, :kind, :status, :error, :progress, :message, COALESCE(:result, x''), :created, :started, :finished) 
+		ON CONFLICT (id) DO UPDATE SET
+		    status=EXCLUDED.status,
+		    error=EXCLUDED.error,
+		    progress=EXCLUDED.progress,
+		    message=EXCLUDED.message,
+		    result=EXCLUDED.result,
+		    started=EXCLUDED.started,
+		    finished=EXCLUDED.finished
+		;`,
+		TableJobs,
+	)
+	_, err := op.NamedExec(qstr, in)
+	return err
+}
+
+// listOperations iterates over a project's journal in order
+func listOperations(op sqlOperator, projectID, tkn string) ([]*types.Operation, string, error) {
+	itr, err := dbutils.ParseIterToken(tkn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !dbutils.IsValidID(projectID) {
+		return nil, "", fmt.Errorf("project id %s is invalid", projectID)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT * FROM %s WHERE project_id=$1 ORDER BY seq LIMIT %d OFFSET %d;",
+		TableOperations,
+		itr.Limit,
+		itr.Offset,
+	)
+
+	result := []*types.Operation{}
+	err = op.Select(&result, query, projectID)
+
+	if err != nil {
+		return nil, tkn, err
+	} else if len(result) < itr.Limit {
+		return result, "", nil
+	} else {
+		itr.Offset += itr.Limit
+		return result, itr.String(), nil
+	}
+}
+
+// setOperations writes journal entries (insert or update)
+func setOperations(op sqlOperator, in []*types.Operation) error {
+	for _, o := range in {
+		if !dbutils.IsValidID(o.ID) {
+			return fmt.Errorf("operation id %s is invalid", o.ID)
+		}
+		if !dbutils.IsValidID(o.ProjectID) {
+			return fmt.Errorf("operation project id %s is invalid", o.ProjectID)
+		}
+	}
+
+	qstr := fmt.Sprintf(
+		`INSERT INTO %s (id, project_id, seq, epoch, kind, params, seed, created)
+		VALUES (:id, :project_id, :seq, :epoch, :kind, COALESCE(:params, x''), :seed, :created)
+		ON CONFLICT (id) DO UPDATE SET
+		    seq=EXCLUDED.seq,
+		    epoch=EXCLUDED.epoch,
+		    kind=EXCLUDED.kind,
+		    params=EXCLUDED.params,
+		    seed=EXCLUDED.seed
+		;`,
+		TableOperations,
+	)
+	_, err := op.NamedExec(qstr, in)
+	return err
+}
+
+// deleteOperationsAfter removes journal entries of a project after `seq`
+func deleteOperationsAfter(op sqlOperator, projectID string, seq int) error {
+	if !dbutils.IsValidID(projectID) {
+		return fmt.Errorf("project id %s is invalid", projectID)
+	}
+	_, err := op.NamedExec(
+		fmt.Sprintf(`DELETE FROM %s WHERE project_id=:id AND seq>:seq;`, TableOperations),
+		map[string]interface{}{"id": projectID, "seq": seq},
+	)
+	return err
+}
+
+// journalKey is the meta key holding the journal head of a project
+func journalKey(projectID string) string {
+	return fmt.Sprintf("journal-%s", projectID)
+}
+
+// journalHead returns the last operation in effect on a project
+func journalHead(op sqlOperator, projectID string) (int, error) {
+	if !dbutils.IsValidID(projectID) {
+		return 0, fmt.Errorf("project id %s is invalid", projectID)
+	}
+	_, seq, err := meta(op, journalKey(projectID))
+	return seq, err
+}
+
+// setJournalHead sets the last operation in effect on a project
+func setJournalHead(op sqlOperator, projectID string, seq int) error {
+	if !dbutils.IsValidID(projectID) {
+		return fmt.Errorf("project id %s is invalid", projectID)
+	}
+	return setMeta(op, journalKey(projectID), "", seq)
+}
+
 func queryByIds(ids []string) (string, []interface{}) {
 	if ids == nil || len(ids) == 0 {
 		return "", nil
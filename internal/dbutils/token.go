@@ -44,10 +44,13 @@ func ParseIterToken(in string) (*IterToken, error) {
 	}
 
 	bits := strings.SplitN(in, ",", 2)
+	if len(bits) != 2 {
+		return nil, fmt.Errorf("%w %s", ErrInvalidToken, in)
+	}
 
 	limit, err := strconv.Atoi(bits[0])
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w %v", ErrInvalidToken, err)
 	}
 	if limit < 1 {
 		return nil, fmt.Errorf("%w found limit %d", ErrInvalidToken, limit)
@@ -58,7 +61,7 @@ func ParseIterToken(in string) (*IterToken, error) {
 
 	offset, err := strconv.Atoi(bits[1])
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w %v", ErrInvalidToken, err)
 	}
 	if offset < 0 {
 		return nil, fmt.Errorf("%w found offset %d", ErrInvalidToken, offset)
@@ -4,8 +4,7 @@ import (
 	"fmt"
 	"image"
 	"math/rand"
-
-	"github.com/RyanCarrier/dijkstra"
+	"sort"
 )
 
 var (
@@ -15,16 +14,31 @@ var (
 
 // graph specifically breaks out the dijkstra / weights part of the fun.
 //
-// Since our dijkstra impl only allows one set of wieghts we actually have
-// a set of internal dijkstra graphs, each with the weights particular to it.
-// Ie. the same graph but whose weights differ.
+// Each tag has it's own set of weights over the same vertices & edges. The weight
+// of a vertex is the cost of stepping on to it (see Route).
 type Graph struct {
 	verts      []image.Point
 	neighbours [][]int          // vert index -> list of neighbouring verts
 	weights    map[string][]int // tag -> vert index -> weight
 
 	pointLookup map[image.Point]int
-	dij         map[string]*dijkstra.Graph
+
+	wrap   int // width of the world if the east & west edges meet (see Wrap)
+	sphere int // height of the world if it's a globe (see Sphere)
+}
+
+// Wrap tells us vertices are on a world `width` wide whose east & west edges meet,
+// so distances are measured the short way around. Edges that cross from one side
+// to the other are expected to be given as normal edges.
+func (g *Graph) Wrap(width int) {
+	g.wrap = width
+}
+
+// Sphere tells us vertices are on a globe drawn equirectangular (see globe), so
+// distances are measured along great circles. It implies Wrap.
+func (g *Graph) Sphere(width, height int) {
+	g.wrap = width
+	g.sphere = height
 }
 
 //
@@ -39,43 +53,41 @@ func (g *Graph) SetWeights(in map[string][]int) error {
 	}
 	weights := map[string][]int{}
 	for tag, given := range in {
-		dij, ok := g.dij[tag]
-		if !ok {
+		if _, ok := g.weights[tag]; !ok {
 			return fmt.Errorf("%w given tag %s", ErrInvalidTag, tag)
 		}
+		if len(given) != len(g.verts) {
+			return fmt.Errorf("%w tag %s has %d weights, expected %d", ErrInvalidTag, tag, len(given), len(g.verts))
+		}
 
 		for pid, w := range given {
 			if w < 0 {
-				w = 0
-			}
-			neighbours := g.neighbours[pid]
-			for _, nid := range neighbours {
-				dij.AddArc(nid, pid, int64(w)) // overwrites
+				given[pid] = 0
 			}
 		}
 
 		weights[tag] = given
 	}
+	for tag, values := range g.weights {
+		if _, ok := weights[tag]; !ok {
+			weights[tag] = values // not given, keep what we have
+		}
+	}
 	g.weights = weights
 	return nil
 }
 
-// New makes a new graph with no weights.
-// Since we initialise internal graphs, we need the names of the graphs (weights) up front.
+// New makes a new graph with all weights set to the default.
+// The names of the weights must be given up front.
 func New(defaultWeight int, weightNames []string, verts []image.Point, edges [][2]image.Point) (*Graph, error) {
-	dij := map[string]*dijkstra.Graph{}
 	weights := map[string][]int{}
 	for _, tag := range weightNames {
-		dij[tag] = dijkstra.NewGraph()
 		weights[tag] = make([]int, len(verts))
 	}
 
 	pl := map[image.Point]int{}
 	for i, p := range verts {
 		pl[p] = i
-		for _, g := range dij {
-			g.AddVertex(i)
-		}
 	}
 
 	ns := make([][]int, len(verts))
@@ -105,12 +117,9 @@ func New(defaultWeight int, weightNames []string, verts []image.Point, edges [][
 		}
 		ns[id1] = neighbours
 
-		for tag, g := range dij {
-			values, _ := weights[tag]
+		for _, values := range weights {
 			values[id0] = defaultWeight
 			values[id1] = defaultWeight
-			g.AddArc(id0, id1, int64(defaultWeight))
-			g.AddArc(id1, id0, int64(defaultWeight))
 		}
 	}
 
@@ -119,12 +128,11 @@ func New(defaultWeight int, weightNames []string, verts []image.Point, edges [][
 		pointLookup: pl,
 		neighbours:  ns,
 		weights:     weights,
-		dij:         dij,
 	}, nil
 }
 
-func (g *Graph) RandomPoint() image.Point {
-	return g.verts[rand.Intn(len(g.verts))]
+func (g *Graph) RandomPoint(rng *rand.Rand) image.Point {
+	return g.verts[rng.Intn(len(g.verts))]
 }
 
 func (g *Graph) IncrWeightsOutside(area image.Rectangle, delta map[string]int) error {
@@ -144,22 +152,15 @@ func (g *Graph) IncrWeights(pts []image.Point, delta map[string]int) error {
 			return fmt.Errorf("%w %v", ErrPointNotFound, p)
 		}
 
-		neighbours := g.neighbours[pid]
-
 		for tag, dw := range delta {
-			dij, ok := g.dij[tag]
+			weights, ok := g.weights[tag]
 			if !ok {
 				return fmt.Errorf("%w given tag %s", ErrInvalidTag, tag)
 			}
-			weights, _ := g.weights[tag]
 			weights[pid] = weights[pid] + dw
 			if weights[pid] < 0 {
 				weights[pid] = 0
 			}
-
-			for _, nid := range neighbours {
-				dij.AddArc(nid, pid, int64(weights[pid])) // overwrites
-			}
 		}
 	}
 	return nil
@@ -191,32 +192,12 @@ func (g *Graph) Neighbours(in []image.Point) ([]image.Point, error) {
 	for p := range found {
 		result = append(result, p)
 	}
+	sort.Slice(result, func(i, j int) bool { // so callers see points in the same order each time
+		if result[i].Y != result[j].Y {
+			return result[i].Y < result[j].Y
+		}
+		return result[i].X < result[j].X
+	})
 
 	return result, nil
 }
-
-func (g *Graph) Shortest(tag string, a, b image.Point) ([]image.Point, error) {
-	ai, ok := g.pointLookup[a]
-	if !ok {
-		return nil, fmt.Errorf("%w %v", ErrPointNotFound, a)
-	}
-	bi, ok := g.pointLookup[b]
-	if !ok {
-		return nil, fmt.Errorf("%w %v", ErrPointNotFound, b)
-	}
-
-	dij, ok := g.dij[tag]
-	if !ok {
-		return nil, fmt.Errorf("%w given tag %s", ErrInvalidTag, tag)
-	}
-
-	best, err := dij.Shortest(ai, bi)
-	if err != nil {
-		return nil, err
-	}
-	path := make([]image.Point, len(best.Path))
-	for i, id := range best.Path {
-		path[i] = g.verts[id]
-	}
-	return path, nil
-}
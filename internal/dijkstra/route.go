@@ -0,0 +1,231 @@
+package dijkstra
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/voidshard/genesis/internal/globe"
+)
+
+var (
+	ErrNoPath     = fmt.Errorf("no path between points")
+	ErrNoWeights  = fmt.Errorf("route must give at least one weight")
+	ErrAvoidedEnd = fmt.Errorf("route start / end / waypoint is in an avoided area")
+)
+
+// Route describes how we want to get from a to b
+type Route struct {
+	// Weights to use and how much each counts towards the cost of a step.
+	// Eg. {"mountains": 0.7, "rivers": 0.3}
+	Weights map[string]float64
+
+	// Via are points the path must pass through (in order)
+	Via []image.Point
+
+	// Avoid are areas the path must not enter
+	Avoid []image.Rectangle
+}
+
+// Shortest finds the path with the least weight for the given tag
+func (g *Graph) Shortest(tag string, a, b image.Point) ([]image.Point, error) {
+	return g.Route(&Route{Weights: map[string]float64{tag: 1}}, a, b)
+}
+
+// Route finds the least cost path from a to b (via any waypoints) given the route.
+func (g *Graph) Route(r *Route, a, b image.Point) ([]image.Point, error) {
+	cost, err := g.blend(r.Weights)
+	if err != nil {
+		return nil, err
+	}
+
+	stops := []int{}
+	for _, p := range append(append([]image.Point{a}, r.Via...), b) {
+		pid, ok := g.pointLookup[p]
+		if !ok {
+			return nil, fmt.Errorf("%w %v", ErrPointNotFound, p)
+		}
+		if avoided(r.Avoid, p) {
+			return nil, fmt.Errorf("%w %v", ErrAvoidedEnd, p)
+		}
+		stops = append(stops, pid)
+	}
+
+	blocked := make([]bool, len(g.verts))
+	for i, v := range g.verts {
+		blocked[i] = avoided(r.Avoid, v)
+	}
+
+	scale := g.heuristicScale(cost)
+
+	path := []int{stops[0]}
+	for i := 1; i < len(stops); i++ {
+		leg, _, err := g.astar(cost, blocked, scale, stops[i-1], stops[i])
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, leg[1:]...) // leg starts where the last one ended
+	}
+
+	result := make([]image.Point, len(path))
+	for i, id := range path {
+		result[i] = g.verts[id]
+	}
+	return result, nil
+}
+
+// blend returns the cost of entering each vertex given weights & how much each counts
+func (g *Graph) blend(weights map[string]float64) ([]float64, error) {
+	if len(weights) == 0 {
+		return nil, ErrNoWeights
+	}
+
+	cost := make([]float64, len(g.verts))
+	for tag, factor := range weights {
+		values, ok := g.weights[tag]
+		if !ok {
+			return nil, fmt.Errorf("%w given tag %s", ErrInvalidTag, tag)
+		}
+		for i, w := range values {
+			cost[i] += float64(w) * factor
+		}
+	}
+	for i := range cost {
+		if cost[i] < 0 {
+			cost[i] = 0
+		}
+	}
+	return cost, nil
+}
+
+// heuristicScale returns the most we can multiply the straight line distance to
+// the goal by & still never guess higher than the real cost (so A* finds the same
+// path as dijkstra would).
+//
+// A step into v costs cost[v] and is at most as long as v's longest edge.
+// Vertices that cost nothing are ignored, otherwise one would make the scale 0 (& A*
+// no better than dijkstra). A path running over free vertices may then cost a little
+// more than the very cheapest.
+func (g *Graph) heuristicScale(cost []float64) float64 {
+	scale := math.Inf(1)
+	for v, ns := range g.neighbours {
+		if cost[v] <= 0 {
+			continue
+		}
+		longest := 0.0
+		for _, n := range ns {
+			longest = math.Max(longest, g.dist(g.verts[v], g.verts[n]))
+		}
+		if longest > 0 {
+			scale = math.Min(scale, cost[v]/longest)
+		}
+	}
+	if math.IsInf(scale, 1) {
+		return 0
+	}
+	return scale
+}
+
+// astar returns the least cost path from a to b (inclusive) & how many vertices it
+// visited to find it
+func (g *Graph) astar(cost []float64, blocked []bool, scale float64, a, b int) ([]int, int, error) {
+	if a == b {
+		return []int{a}, 0, nil
+	}
+
+	goal := g.verts[b]
+
+	best := make([]float64, len(g.verts))
+	for i := range best {
+		best[i] = math.Inf(1)
+	}
+	from := make([]int, len(g.verts))
+	done := make([]bool, len(g.verts))
+
+	best[a] = 0
+	from[a] = -1
+	open := &queue{{vert: a, priority: scale * g.dist(g.verts[a], goal)}}
+	visited := 0
+
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(item).vert
+		if done[cur] {
+			continue // we found a cheaper way here already
+		}
+		done[cur] = true
+		visited++
+
+		if cur == b {
+			path := []int{}
+			for v := b; v != -1; v = from[v] {
+				path = append(path, v)
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path, visited, nil
+		}
+
+		for _, n := range g.neighbours[cur] {
+			if done[n] || blocked[n] {
+				continue
+			}
+			c := best[cur] + cost[n]
+			if c < best[n] {
+				best[n] = c
+				from[n] = cur
+				heap.Push(open, item{vert: n, priority: c + scale*g.dist(g.verts[n], goal)})
+			}
+		}
+	}
+
+	return nil, visited, fmt.Errorf("%w %v -> %v", ErrNoPath, g.verts[a], goal)
+}
+
+// avoided returns if p is inside any of the given areas
+func avoided(areas []image.Rectangle, p image.Point) bool {
+	for _, a := range areas {
+		if p.In(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// dist is the straight line distance between two points (around the world, if it wraps)
+func (g *Graph) dist(a, b image.Point) float64 {
+	if g.sphere > 0 {
+		return globe.Distance(a, b, g.wrap, g.sphere)
+	}
+	dx := math.Abs(float64(a.X - b.X))
+	if g.wrap > 0 && dx > float64(g.wrap)/2 {
+		dx = float64(g.wrap) - dx
+	}
+	return math.Hypot(dx, float64(a.Y-b.Y))
+}
+
+// item is a vertex we've yet to visit
+type item struct {
+	vert     int
+	priority float64
+}
+
+// queue is a min heap of items (by priority)
+type queue []item
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+
+func (q queue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(item)) }
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
@@ -0,0 +1,157 @@
+package dijkstra
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// grid returns a size x size grid graph, points 10 apart
+func grid(t *testing.T, size int) *Graph {
+	verts := []image.Point{}
+	edges := [][2]image.Point{}
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			p := image.Pt(x*10, y*10)
+			verts = append(verts, p)
+			if x > 0 {
+				edges = append(edges, [2]image.Point{p, image.Pt((x-1)*10, y*10)})
+			}
+			if y > 0 {
+				edges = append(edges, [2]image.Point{p, image.Pt(x*10, (y-1)*10)})
+			}
+		}
+	}
+	g, err := New(1, []string{"a", "b"}, verts, edges)
+	assert.Nil(t, err)
+	return g
+}
+
+// cheapest returns the cost of the best path from a to b by relaxing every edge
+// until nothing changes (slow but obviously right)
+func cheapest(g *Graph, cost []float64, a, b int) float64 {
+	best := make([]float64, len(g.verts))
+	for i := range best {
+		best[i] = 1e18
+	}
+	best[a] = 0
+	for changed := true; changed; {
+		changed = false
+		for v, ns := range g.neighbours {
+			for _, n := range ns {
+				if best[v]+cost[n] < best[n] {
+					best[n] = best[v] + cost[n]
+					changed = true
+				}
+			}
+		}
+	}
+	return best[b]
+}
+
+func pathCost(t *testing.T, g *Graph, cost []float64, path []image.Point) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		pid := g.pointLookup[path[i]]
+		assert.Contains(t, g.neighbours[g.pointLookup[path[i-1]]], pid)
+		total += cost[pid]
+	}
+	return total
+}
+
+func TestRoute(t *testing.T) {
+	g := grid(t, 12)
+
+	rng := rand.New(rand.NewSource(3))
+	for _, v := range g.verts {
+		assert.Nil(t, g.IncrWeights([]image.Point{v}, map[string]int{"a": rng.Intn(20), "b": rng.Intn(5)}))
+	}
+
+	blend := map[string]float64{"a": 0.7, "b": 0.3}
+	cost, err := g.blend(blend)
+	assert.Nil(t, err)
+
+	for i := 0; i < 20; i++ {
+		a, b := rng.Intn(len(g.verts)), rng.Intn(len(g.verts))
+		path, err := g.Route(&Route{Weights: blend}, g.verts[a], g.verts[b])
+		assert.Nil(t, err)
+		assert.Equal(t, g.verts[a], path[0])
+		assert.Equal(t, g.verts[b], path[len(path)-1])
+		assert.InDelta(t, cheapest(g, cost, a, b), pathCost(t, g, cost, path), 0.0001)
+	}
+
+	via := []image.Point{{110, 0}, {0, 110}}
+	path, err := g.Route(&Route{Weights: blend, Via: via}, image.Pt(0, 0), image.Pt(110, 110))
+	assert.Nil(t, err)
+	assert.Contains(t, path, via[0])
+	assert.Contains(t, path, via[1])
+
+	// wall off the middle, leaving a gap at the top
+	wall := image.Rect(50, 10, 61, 200)
+	path, err = g.Route(&Route{Weights: blend, Avoid: []image.Rectangle{wall}}, image.Pt(0, 100), image.Pt(110, 100))
+	assert.Nil(t, err)
+	for _, p := range path {
+		assert.False(t, p.In(wall))
+	}
+	assert.Contains(t, path, image.Pt(50, 0))
+
+	_, err = g.Route(&Route{Weights: blend, Avoid: []image.Rectangle{image.Rect(50, 0, 61, 200)}}, image.Pt(0, 100), image.Pt(110, 100))
+	assert.ErrorIs(t, err, ErrNoPath)
+
+	_, err = g.Route(&Route{Weights: blend, Avoid: []image.Rectangle{wall}}, image.Pt(50, 50), image.Pt(110, 100))
+	assert.ErrorIs(t, err, ErrAvoidedEnd)
+
+	_, err = g.Route(&Route{}, image.Pt(0, 0), image.Pt(10, 10))
+	assert.ErrorIs(t, err, ErrNoWeights)
+
+	_, err = g.Shortest("nope", image.Pt(0, 0), image.Pt(10, 10))
+	assert.ErrorIs(t, err, ErrInvalidTag)
+}
+
+func TestRouteExpandsFewerThanDijkstra(t *testing.T) {
+	g := grid(t, 30)
+
+	// some vertices are free, as the sea is for mountains
+	rng := rand.New(rand.NewSource(5))
+	for i, v := range g.verts {
+		w := 1 + rng.Intn(20)
+		if i%17 == 0 {
+			w = 0
+		}
+		assert.Nil(t, g.IncrWeights([]image.Point{v}, map[string]int{"a": w}))
+	}
+	cost, err := g.blend(map[string]float64{"a": 1})
+	assert.Nil(t, err)
+	blocked := make([]bool, len(g.verts))
+
+	scale := g.heuristicScale(cost)
+	assert.Greater(t, scale, 0.0)
+
+	astar, plain := 0, 0
+	for i := 0; i < 20; i++ {
+		a, b := rng.Intn(len(g.verts)), rng.Intn(len(g.verts))
+
+		path, visited, err := g.astar(cost, blocked, scale, a, b)
+		assert.Nil(t, err)
+		astar += visited
+
+		best, visited, err := g.astar(cost, blocked, 0, a, b) // no heuristic is dijkstra
+		assert.Nil(t, err)
+		plain += visited
+
+		assert.Equal(t, b, path[len(path)-1])
+		bestCost := 0.0
+		for _, v := range best[1:] {
+			bestCost += cost[v]
+		}
+		assert.InDelta(t, cheapest(g, cost, a, b), bestCost, 0.0001)
+		pcost := 0.0
+		for _, v := range path[1:] {
+			pcost += cost[v]
+		}
+		assert.GreaterOrEqual(t, pcost, bestCost-0.0001)
+	}
+	assert.Less(t, astar, plain)
+}
@@ -0,0 +1,149 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+var (
+	// ErrUnknownFormat means we don't know how to write the format
+	ErrUnknownFormat = fmt.Errorf("unknown export format")
+
+	// ErrBandCount is returned if we're given the wrong number of bands for the format
+	ErrBandCount = fmt.Errorf("invalid number of bands for format")
+
+	// ErrBandMismatch is returned if bands do not all cover the same area
+	ErrBandMismatch = fmt.Errorf("bands have differing bounds")
+)
+
+// GeoRef describes where an image sits in world space.
+//
+// Our worlds have no real coordinate system, so by default one world pixel is one
+// unit & the origin is the top left of the world. Engines & GIS tools use this to
+// stitch tiles back together.
+type GeoRef struct {
+	// Origin is the world pixel of the top left corner of the image
+	Origin image.Point
+
+	// PixelScale is the size of one pixel in world units (1 if not set)
+	PixelScale float64
+}
+
+// WriteFile writes bands out to the given path, creating / truncating it.
+func WriteFile(path string, format types.ExportFormat, geo *GeoRef, bands ...*image.Gray16) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	buf := bufio.NewWriter(f)
+	err = Write(buf, format, geo, bands...)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = buf.Flush()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Write outputs bands in the given format.
+// All formats except ExportMultiBandTIFF accept exactly one band.
+func Write(w io.Writer, format types.ExportFormat, geo *GeoRef, bands ...*image.Gray16) error {
+	if len(bands) == 0 {
+		return fmt.Errorf("%w no bands given", ErrBandCount)
+	}
+	bnds := bands[0].Bounds()
+	for _, b := range bands[1:] {
+		if !b.Bounds().Eq(bnds) {
+			return fmt.Errorf("%w %v vs %v", ErrBandMismatch, bnds, b.Bounds())
+		}
+	}
+
+	if geo == nil {
+		geo = &GeoRef{Origin: bnds.Min, PixelScale: 1}
+	}
+
+	switch format {
+	case types.ExportPNG16:
+		if len(bands) != 1 {
+			return fmt.Errorf("%w %s expects 1 got %d", ErrBandCount, format, len(bands))
+		}
+		return png.Encode(w, bands[0])
+	case types.ExportRawR16:
+		if len(bands) != 1 {
+			return fmt.Errorf("%w %s expects 1 got %d", ErrBandCount, format, len(bands))
+		}
+		return writeR16(w, bands[0])
+	case types.ExportGeoTIFF:
+		if len(bands) != 1 {
+			return fmt.Errorf("%w %s expects 1 got %d", ErrBandCount, format, len(bands))
+		}
+		return writeTIFF(w, geo, bands)
+	case types.ExportMultiBandTIFF:
+		return writeTIFF(w, geo, bands)
+	case types.ExportEXR:
+		if len(bands) != 1 {
+			return fmt.Errorf("%w %s expects 1 got %d", ErrBandCount, format, len(bands))
+		}
+		return writeEXR(w, bands[0])
+	}
+
+	return fmt.Errorf("%w %s", ErrUnknownFormat, format)
+}
+
+// Tiles splits `area` into tiles of at most size x size pixels, row by row.
+// Tiles along the right & bottom edges may be smaller.
+func Tiles(area image.Rectangle, size int) []image.Rectangle {
+	if size <= 0 {
+		return []image.Rectangle{area}
+	}
+
+	tiles := []image.Rectangle{}
+	for y := area.Min.Y; y < area.Max.Y; y += size {
+		for x := area.Min.X; x < area.Max.X; x += size {
+			tiles = append(tiles, image.Rect(x, y, x+size, y+size).Intersect(area))
+		}
+	}
+
+	return tiles
+}
+
+// SubBands returns the given area of each band (sharing memory with the originals)
+func SubBands(area image.Rectangle, bands ...*image.Gray16) []*image.Gray16 {
+	result := make([]*image.Gray16, len(bands))
+	for i, b := range bands {
+		result[i] = b.SubImage(area).(*image.Gray16)
+	}
+	return result
+}
+
+// writeR16 writes raw little endian uint16 values, row by row
+func writeR16(w io.Writer, im *image.Gray16) error {
+	bnds := im.Bounds()
+	row := make([]byte, bnds.Dx()*2)
+	for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+		for x := bnds.Min.X; x < bnds.Max.X; x++ {
+			v := im.Gray16At(x, y).Y
+			i := (x - bnds.Min.X) * 2
+			row[i] = uint8(v)
+			row[i+1] = uint8(v >> 8)
+		}
+		_, err := w.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
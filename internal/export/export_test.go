@@ -0,0 +1,197 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/tiff"
+
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func testBand(w, h int) *image.Gray16 {
+	im := image.NewGray16(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			im.SetGray16(x, y, color.Gray16{Y: uint16(y*w*100 + x*7)})
+		}
+	}
+	return im
+}
+
+func TestTiles(t *testing.T) {
+	cases := []struct {
+		Area  image.Rectangle
+		Size  int
+		Count int
+		Last  image.Rectangle
+	}{
+		{image.Rect(0, 0, 100, 100), 50, 4, image.Rect(50, 50, 100, 100)},
+		{image.Rect(0, 0, 100, 60), 50, 4, image.Rect(50, 50, 100, 60)},
+		{image.Rect(10, 10, 20, 20), 0, 1, image.Rect(10, 10, 20, 20)},
+	}
+
+	for _, tt := range cases {
+		result := Tiles(tt.Area, tt.Size)
+		assert.Equal(t, tt.Count, len(result))
+		assert.Equal(t, tt.Last, result[len(result)-1])
+	}
+}
+
+func TestWriteR16(t *testing.T) {
+	im := testBand(3, 2)
+	buf := bytes.NewBuffer(nil)
+
+	err := Write(buf, types.ExportRawR16, nil, im)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3*2*2, buf.Len())
+	data := buf.Bytes()
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			i := (y*3 + x) * 2
+			assert.Equal(t, im.Gray16At(x, y).Y, binary.LittleEndian.Uint16(data[i:]))
+		}
+	}
+}
+
+func TestWriteGeoTIFF(t *testing.T) {
+	im := testBand(70, 40)
+	buf := bytes.NewBuffer(nil)
+
+	err := Write(buf, types.ExportGeoTIFF, &GeoRef{Origin: image.Pt(500, 100)}, im)
+	assert.Nil(t, err)
+
+	out, err := tiff.Decode(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, im.Bounds(), out.Bounds())
+	for _, p := range []image.Point{{0, 0}, {69, 0}, {13, 27}, {69, 39}} {
+		assert.Equal(t, im.At(p.X, p.Y), out.At(p.X, p.Y))
+	}
+}
+
+// readTIFF reads the samples of a single image, uncompressed, 16 bit TIFF (as we
+// write them) as one band per sample
+func readTIFF(t *testing.T, data []byte) []*image.Gray16 {
+	le := binary.LittleEndian
+	assert.Equal(t, "II", string(data[:2]))
+	ifd := data[le.Uint32(data[4:]):]
+
+	values := func(typ uint16, count uint32, field []byte) []uint32 {
+		size := map[uint16]uint32{tiffShort: 2, tiffLong: 4}[typ]
+		if size*count > 4 {
+			field = data[le.Uint32(field):]
+		}
+		out := make([]uint32, count)
+		for i := range out {
+			if typ == tiffShort {
+				out[i] = uint32(le.Uint16(field[i*2:]))
+			} else {
+				out[i] = le.Uint32(field[i*4:])
+			}
+		}
+		return out
+	}
+
+	tags := map[uint16][]uint32{}
+	for i := 0; i < int(le.Uint16(ifd)); i++ {
+		e := ifd[2+i*12:]
+		typ := le.Uint16(e[2:])
+		if typ == tiffShort || typ == tiffLong {
+			tags[le.Uint16(e)] = values(typ, le.Uint32(e[4:]), e[8:12])
+		}
+	}
+
+	width, height := int(tags[tagImageWidth][0]), int(tags[tagImageLength][0])
+	spp := int(tags[tagSamplesPerPixel][0])
+	for _, bits := range tags[tagBitsPerSample] {
+		assert.Equal(t, uint32(16), bits)
+	}
+	assert.Equal(t, spp, len(tags[tagBitsPerSample]))
+	assert.Equal(t, []uint32{1}, tags[tagCompression])
+
+	pix := []byte{}
+	for i, off := range tags[tagStripOffsets] {
+		pix = append(pix, data[off:off+tags[tagStripByteCounts][i]]...)
+	}
+	assert.Equal(t, width*height*spp*2, len(pix))
+
+	bands := make([]*image.Gray16, spp)
+	for b := range bands {
+		bands[b] = image.NewGray16(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				i := ((y*width+x)*spp + b) * 2
+				bands[b].SetGray16(x, y, color.Gray16{Y: le.Uint16(pix[i:])})
+			}
+		}
+	}
+	return bands
+}
+
+func TestWriteMultiBandTIFF(t *testing.T) {
+	// enough rows that we need more than one strip
+	in := []*image.Gray16{testBand(300, 200), testBand(300, 200), testBand(300, 200), testBand(300, 200)}
+	for i, b := range in[1:] {
+		for j := range b.Pix {
+			b.Pix[j] ^= uint8(37 * (i + 1))
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+
+	err := Write(buf, types.ExportMultiBandTIFF, nil, in...)
+	assert.Nil(t, err)
+
+	out := readTIFF(t, buf.Bytes())
+	assert.Equal(t, len(in), len(out))
+	for i := range in {
+		assert.Equal(t, in[i].Pix, out[i].Pix, "band %d", i)
+	}
+}
+
+func TestWriteEXR(t *testing.T) {
+	im := testBand(5, 3).SubImage(image.Rect(1, 1, 5, 3)).(*image.Gray16)
+	buf := bytes.NewBuffer(nil)
+
+	err := Write(buf, types.ExportEXR, nil, im)
+	assert.Nil(t, err)
+
+	le := binary.LittleEndian
+	data := buf.Bytes()
+	assert.Equal(t, uint32(exrMagic), le.Uint32(data))
+	assert.Equal(t, uint32(exrVersion), le.Uint32(data[4:]))
+
+	// skip the header (attributes end with an empty name)
+	i := 8
+	for data[i] != 0 {
+		i += bytes.IndexByte(data[i:], 0) + 1 // name
+		i += bytes.IndexByte(data[i:], 0) + 1 // type
+		i += 4 + int(le.Uint32(data[i:]))
+	}
+	i++
+
+	for y := 0; y < 2; y++ {
+		chunk := data[le.Uint64(data[i+y*8:]):]
+		assert.Equal(t, uint32(y), le.Uint32(chunk))
+		assert.Equal(t, uint32(4*4), le.Uint32(chunk[4:]))
+		for x := 0; x < 4; x++ {
+			v := math.Float32frombits(le.Uint32(chunk[8+x*4:]))
+			assert.Equal(t, im.Gray16At(1+x, 1+y).Y, uint16(math.Round(float64(v)*math.MaxUint16)))
+		}
+	}
+}
+
+func TestWriteBandErrors(t *testing.T) {
+	a := testBand(10, 10)
+	b := testBand(10, 11)
+
+	assert.ErrorIs(t, Write(bytes.NewBuffer(nil), types.ExportPNG16, nil, a, a), ErrBandCount)
+	assert.ErrorIs(t, Write(bytes.NewBuffer(nil), types.ExportEXR, nil, a, a), ErrBandCount)
+	assert.ErrorIs(t, Write(bytes.NewBuffer(nil), types.ExportMultiBandTIFF, nil, a, b), ErrBandMismatch)
+	assert.ErrorIs(t, Write(bytes.NewBuffer(nil), "jpeg", nil, a), ErrUnknownFormat)
+}
@@ -0,0 +1,106 @@
+package export
+
+/*
+Minimal OpenEXR writer; enough for engines (eg. Unity, Unreal) & image tools to read
+a heightmap as floats.
+
+We write a single part, uncompressed, scanline image with one 32 bit float channel
+"Y" holding each value scaled to 0-1 (a 16 bit value fits a float exactly).
+*/
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+)
+
+const (
+	exrMagic   = 20000630
+	exrVersion = 2
+
+	exrPixelFloat = 2
+)
+
+// exrAttr is a header attribute: name, type & little endian encoded value
+type exrAttr struct {
+	name string
+	typ  string
+	data []byte
+}
+
+func floats(in ...float32) []byte {
+	out := make([]byte, len(in)*4)
+	for i, v := range in {
+		binary.LittleEndian.PutUint32(out[i*4:], math.Float32bits(v))
+	}
+	return out
+}
+
+func ints(in ...int32) []byte {
+	out := make([]byte, len(in)*4)
+	for i, v := range in {
+		binary.LittleEndian.PutUint32(out[i*4:], uint32(v))
+	}
+	return out
+}
+
+// writeEXR writes a band as an OpenEXR image
+func writeEXR(w io.Writer, im *image.Gray16) error {
+	bnds := im.Bounds()
+	width, height := bnds.Dx(), bnds.Dy()
+	window := ints(0, 0, int32(width-1), int32(height-1))
+
+	// name, pixel type, pLinear + reserved, x & y sampling; then the end of the list
+	channels := append([]byte("Y\x00"), ints(exrPixelFloat, 0, 1, 1)...)
+	channels = append(channels, 0)
+
+	// attributes must be in alphabetical order
+	attrs := []*exrAttr{
+		{"channels", "chlist", channels},
+		{"compression", "compression", []byte{0}}, // none
+		{"dataWindow", "box2i", window},
+		{"displayWindow", "box2i", window},
+		{"lineOrder", "lineOrder", []byte{0}}, // increasing y
+		{"pixelAspectRatio", "float", floats(1)},
+		{"screenWindowCenter", "v2f", floats(0, 0)},
+		{"screenWindowWidth", "float", floats(1)},
+	}
+
+	header := ints(exrMagic, exrVersion)
+	for _, a := range attrs {
+		header = append(header, a.name+"\x00"+a.typ+"\x00"...)
+		header = append(header, ints(int32(len(a.data)))...)
+		header = append(header, a.data...)
+	}
+	header = append(header, 0) // end of header
+
+	// each row is a chunk: y, size of pixel data, pixel data
+	rowBytes := width * 4
+	chunk := 8 + rowBytes
+	offsets := make([]byte, height*8)
+	start := len(header) + len(offsets)
+	for y := 0; y < height; y++ {
+		binary.LittleEndian.PutUint64(offsets[y*8:], uint64(start+y*chunk))
+	}
+
+	_, err := w.Write(append(header, offsets...))
+	if err != nil {
+		return err
+	}
+
+	row := make([]byte, chunk)
+	for y := 0; y < height; y++ {
+		copy(row, ints(int32(y), int32(rowBytes)))
+		for x := 0; x < width; x++ {
+			v := float32(im.Gray16At(bnds.Min.X+x, bnds.Min.Y+y).Y) / math.MaxUint16
+			binary.LittleEndian.PutUint32(row[8+x*4:], math.Float32bits(v))
+		}
+		_, err = w.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package export
+
+/*
+Minimal baseline TIFF writer with enough GeoTIFF tags that GIS tools & terrain
+importers can place tiles relative to each other.
+
+We write uncompressed, chunky (interleaved) 16 bit unsigned samples in strips.
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	tiffShort  = 3
+	tiffLong   = 4
+	tiffDouble = 12
+
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagPhotometric     = 262
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagPlanarConfig    = 284
+	tagExtraSamples    = 338
+	tagSampleFormat    = 339
+	tagModelPixelScale = 33550
+	tagModelTiepoint   = 33922
+	tagGeoKeyDirectory = 34735
+
+	// geo keys
+	keyModelType      = 1024
+	keyRasterType     = 1025
+	keyProjectedCS    = 3072
+	modelProjected    = 1
+	rasterPixelIsArea = 1
+	userDefined       = 32767
+
+	// target size of each strip of pixel data
+	stripTarget = 64 * 1024
+
+	tiffHeaderSize = 8
+)
+
+// ErrTooLarge is returned if the image cannot fit in a (non Big) TIFF
+var ErrTooLarge = fmt.Errorf("image too large for tiff")
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte // little endian encoded values
+}
+
+func shorts(in ...uint16) []byte {
+	out := make([]byte, len(in)*2)
+	for i, v := range in {
+		binary.LittleEndian.PutUint16(out[i*2:], v)
+	}
+	return out
+}
+
+func longs(in ...uint32) []byte {
+	out := make([]byte, len(in)*4)
+	for i, v := range in {
+		binary.LittleEndian.PutUint32(out[i*4:], v)
+	}
+	return out
+}
+
+func doubles(in ...float64) []byte {
+	out := make([]byte, len(in)*8)
+	for i, v := range in {
+		binary.LittleEndian.PutUint64(out[i*8:], math.Float64bits(v))
+	}
+	return out
+}
+
+// writeTIFF writes all bands interleaved into one image
+func writeTIFF(w io.Writer, geo *GeoRef, bands []*image.Gray16) error {
+	bnds := bands[0].Bounds()
+	width, height := bnds.Dx(), bnds.Dy()
+	spp := len(bands)
+
+	rowBytes := width * spp * 2
+	rowsPerStrip := stripTarget / rowBytes
+	if rowsPerStrip < 1 {
+		rowsPerStrip = 1
+	} else if rowsPerStrip > height {
+		rowsPerStrip = height
+	}
+	strips := (height + rowsPerStrip - 1) / rowsPerStrip
+
+	dataSize := int64(rowBytes) * int64(height)
+	if dataSize+tiffHeaderSize >= math.MaxUint32/2 {
+		return fmt.Errorf("%w %dx%d with %d bands", ErrTooLarge, width, height, spp)
+	}
+
+	offsets := make([]uint32, strips)
+	counts := make([]uint32, strips)
+	for i := 0; i < strips; i++ {
+		rows := rowsPerStrip
+		if (i+1)*rowsPerStrip > height {
+			rows = height - i*rowsPerStrip
+		}
+		offsets[i] = uint32(tiffHeaderSize + i*rowsPerStrip*rowBytes)
+		counts[i] = uint32(rows * rowBytes)
+	}
+
+	bits := make([]uint16, spp)
+	format := make([]uint16, spp)
+	for i := range bits {
+		bits[i] = 16
+		format[i] = 1 // unsigned int
+	}
+
+	scale := geo.PixelScale
+	if scale <= 0 {
+		scale = 1
+	}
+
+	entries := []*ifdEntry{
+		{tagImageWidth, tiffLong, 1, longs(uint32(width))},
+		{tagImageLength, tiffLong, 1, longs(uint32(height))},
+		{tagBitsPerSample, tiffShort, uint32(spp), shorts(bits...)},
+		{tagCompression, tiffShort, 1, shorts(1)},
+		{tagPhotometric, tiffShort, 1, shorts(1)}, // black is zero
+		{tagStripOffsets, tiffLong, uint32(strips), longs(offsets...)},
+		{tagSamplesPerPixel, tiffShort, 1, shorts(uint16(spp))},
+		{tagRowsPerStrip, tiffLong, 1, longs(uint32(rowsPerStrip))},
+		{tagStripByteCounts, tiffLong, uint32(strips), longs(counts...)},
+		{tagPlanarConfig, tiffShort, 1, shorts(1)}, // chunky
+		{tagSampleFormat, tiffShort, uint32(spp), shorts(format...)},
+		{tagModelPixelScale, tiffDouble, 3, doubles(scale, scale, 0)},
+		{tagModelTiepoint, tiffDouble, 6, doubles(
+			0, 0, 0,
+			float64(geo.Origin.X)*scale, float64(geo.Origin.Y)*scale, 0,
+		)},
+		{tagGeoKeyDirectory, tiffShort, 16, shorts(
+			1, 1, 0, 3, // version, revision, minor, number of keys
+			keyModelType, 0, 1, modelProjected,
+			keyRasterType, 0, 1, rasterPixelIsArea,
+			keyProjectedCS, 0, 1, userDefined,
+		)},
+	}
+	if spp > 1 {
+		extra := make([]uint16, spp-1) // 0 => unspecified data
+		entries = append(entries, &ifdEntry{tagExtraSamples, tiffShort, uint32(spp - 1), shorts(extra...)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	// lay out the file: header, pixels, IFD, then values too large to fit in the IFD
+	ifdOffset := uint32(tiffHeaderSize + dataSize)
+	overflow := ifdOffset + uint32(2+12*len(entries)+4)
+
+	ifd := shorts(uint16(len(entries)))
+	extra := []byte{}
+	for _, e := range entries {
+		ifd = append(ifd, shorts(e.tag, e.typ)...)
+		ifd = append(ifd, longs(e.count)...)
+		if len(e.data) <= 4 {
+			value := make([]byte, 4)
+			copy(value, e.data)
+			ifd = append(ifd, value...)
+			continue
+		}
+		ifd = append(ifd, longs(overflow+uint32(len(extra)))...)
+		extra = append(extra, e.data...)
+		if len(extra)%2 == 1 { // values must start on a word boundary
+			extra = append(extra, 0)
+		}
+	}
+	ifd = append(ifd, longs(0)...) // no next IFD
+
+	header := append([]byte("II"), shorts(42)...)
+	header = append(header, longs(ifdOffset)...)
+	_, err := w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	row := make([]byte, rowBytes)
+	for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+		i := 0
+		for x := bnds.Min.X; x < bnds.Max.X; x++ {
+			for _, b := range bands {
+				binary.LittleEndian.PutUint16(row[i:], b.Gray16At(x, y).Y)
+				i += 2
+			}
+		}
+		_, err = w.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Write(ifd)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(extra)
+	return err
+}
@@ -0,0 +1,276 @@
+package geography
+
+import (
+	"container/heap"
+	"context"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/voidshard/genesis/internal/globe"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// trenchDirections is how many ways we look from a volcano for the open sea
+const trenchDirections = 16
+
+// Bathymetry works out how deep the sea is. Continental shelves run out from the coast
+// & fall away to the ocean floor, trenches run along the seaward side of volcanoes
+// (where one plate dives under another) & ridges rise up in the open ocean, far from
+// any coast.
+//
+// Depth is saved as a canvas (0 being land or sea level, 255 the deepest) that HeightMap
+// takes away (see HeightMapDepthWeight). Implies SeaMap.
+func (e *Editor) Bathymetry(ctx context.Context, proj string) (image.Image, error) {
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	voro := voronoi.New(e.store, p.WorldWidth, p.WorldHeight)
+	graph, err := e.cachedGraph(voro, p.VoronoiDiagram())
+	if err != nil {
+		return nil, err
+	}
+
+	sea, err := pnt.Canvas(p.Canvas(tagSea))
+	if err != nil {
+		return nil, err
+	}
+	seamap, err := paint.Image(sea)
+	if err != nil {
+		return nil, err
+	}
+	water := func(x, y int) bool {
+		return isSea(seamap, x, y)
+	}
+
+	err = progress.Report(ctx, "bathymetry", 0, 4, "stages")
+	if err != nil {
+		return nil, err
+	}
+	coast := distanceField(p, water, func(x, y int) bool {
+		return !water(x, y)
+	})
+
+	stage, err := pnt.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Rollback() // noop once committed
+
+	// trenches
+	err = progress.Report(ctx, "bathymetry", 1, 4, "stages")
+	if err != nil {
+		return nil, err
+	}
+	trenches, err := stage.NewCanvas(p.Canvas(tagDepth + "-trench")) // never saved
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range graph.TagNames() {
+		if graph.TagKind(name) != tagVolcanoes {
+			continue
+		}
+		volcanoes, _ := graph.FromTag(name)
+		for _, v := range volcanoes {
+			at, ok := e.trenchAt(p, coast, v)
+			if !ok {
+				continue
+			}
+			// along the coast, across the way to the sea
+			angle := math.Atan2(float64(at.Y-v.Y), float64(at.X-v.X))*180/math.Pi + 90
+			err = wrapped(p, trenches).Ellipse(
+				at,
+				e.set.VolcanoRangeWidth,
+				maxInt(1, e.set.BathymetryTrenchWidth/2),
+				int(angle),
+				e.set.BathymetryTrenchDepth,
+				paint.Convex,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// ridges follow the line furthest from the coasts either side
+	err = progress.Report(ctx, "bathymetry", 2, 4, "stages")
+	if err != nil {
+		return nil, err
+	}
+	ridges := distanceField(p, water, func(x, y int) bool {
+		return e.onRidge(p, coast, x, y)
+	})
+
+	err = progress.Report(ctx, "bathymetry", 3, 4, "stages")
+	if err != nil {
+		return nil, err
+	}
+	trenchIm, err := paint.Image(trenches)
+	if err != nil {
+		return nil, err
+	}
+	shelves := perlin(random(ctx).Int63(), p, e.set.BathymetryShelfNoise, true)
+	im := image.NewRGBA(image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			if !water(x, y) {
+				im.SetRGBA(x, y, color.RGBA{A: 255})
+				continue
+			}
+			i := y*p.WorldWidth + x
+
+			// shelves are 50-150% of the usual width
+			shelf := e.set.BathymetryShelfWidth * (0.5 + float64(shelves.GrayAt(x, y).Y)/255)
+			depth := e.shelfDepth(coast[i], shelf)
+
+			depth += float64(grey(trenchIm, x, y))
+			if ridges[i] < e.set.BathymetryRidgeWidth {
+				depth -= e.set.BathymetryRidgeHeight * (1 - ridges[i]/e.set.BathymetryRidgeWidth)
+			}
+
+			// ridges rise no higher than the shelves
+			depth = math.Max(depth, math.Min(e.shelfDepth(coast[i], shelf), e.set.BathymetryShelfDepth))
+			v := forceUint8(int(math.Round(depth)))
+			im.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	cnv, err := stage.NewCanvasFromImage(p.Canvas(tagDepth), im)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	err = stage.Save(cnv)
+	if err != nil {
+		return nil, err
+	}
+	err = stage.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	progress.Report(ctx, "bathymetry", 4, 4, "stages")
+	return im, nil
+}
+
+// shelfDepth returns how deep the sea is `dist` from the coast, on a shelf `shelf` wide
+// before the ocean floor (ignoring trenches & ridges)
+func (e *Editor) shelfDepth(dist, shelf float64) float64 {
+	if dist <= shelf {
+		return e.set.BathymetryShelfDepth * dist / math.Max(1, shelf)
+	}
+	slope := math.Min(1, (dist-shelf)/math.Max(1, e.set.BathymetrySlopeWidth))
+	slope = slope * slope * (3 - 2*slope) // steep in the middle, flattening out at the bottom
+	return e.set.BathymetryShelfDepth + (e.set.BathymetryAbyssDepth-e.set.BathymetryShelfDepth)*slope
+}
+
+// trenchAt finds where a trench goes next to volcano `v`; the nearest open sea (past the
+// shelf) within VolcanoRangeWidth * 2
+func (e *Editor) trenchAt(p *types.Project, coast []float64, v image.Point) (image.Point, bool) {
+	reach := e.set.VolcanoRangeWidth * 2
+	for step := 1; step <= reach; step++ {
+		for k := 0; k < trenchDirections; k++ {
+			theta := 2 * math.Pi * float64(k) / trenchDirections
+			x, ok := neighbourX(p, v.X+int(math.Round(math.Cos(theta)*float64(step))))
+			y := v.Y + int(math.Round(math.Sin(theta)*float64(step)))
+			if !ok || y < 0 || y >= p.WorldHeight {
+				continue
+			}
+			if coast[y*p.WorldWidth+x] >= e.set.BathymetryShelfWidth {
+				return image.Pt(x, y), true
+			}
+		}
+	}
+	return v, false
+}
+
+// onRidge returns if (x, y) is at least BathymetryRidgeDistance from the coast & further
+// than it's neighbours either side (across or down)
+func (e *Editor) onRidge(p *types.Project, coast []float64, x, y int) bool {
+	d := coast[y*p.WorldWidth+x]
+	if d < e.set.BathymetryRidgeDistance || math.IsInf(d, 1) {
+		return false
+	}
+	at := func(x, y int) float64 {
+		x, ok := neighbourX(p, x)
+		if !ok || y < 0 || y >= p.WorldHeight {
+			return math.Inf(-1)
+		}
+		return coast[y*p.WorldWidth+x]
+	}
+	return (d >= at(x-1, y) && d >= at(x+1, y)) || (d >= at(x, y-1) && d >= at(x, y+1))
+}
+
+// distanceField returns how far (pixels) each pixel is from the nearest `source` going
+// only over pixels where `over` is true (by y * width + x). Unreachable pixels are +Inf.
+func distanceField(p *types.Project, over, source func(x, y int) bool) []float64 {
+	dist := make([]float64, p.WorldWidth*p.WorldHeight)
+	queue := &pixelQueue{}
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			i := y*p.WorldWidth + x
+			dist[i] = math.Inf(1)
+			if source(x, y) {
+				dist[i] = 0
+				heap.Push(queue, pixelDist{image.Pt(x, y), 0})
+			}
+		}
+	}
+
+	for queue.Len() > 0 {
+		next := heap.Pop(queue).(pixelDist)
+		at := next.at
+		if next.dist > dist[at.Y*p.WorldWidth+at.X] {
+			continue // found a shorter way here already
+		}
+
+		// on a globe going east / west is shorter nearer the poles
+		across := 1.0
+		if p.Sphere() {
+			across = 1 / globe.Stretch(at.Y, p.WorldHeight)
+		}
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				x, ok := neighbourX(p, at.X+dx)
+				y := at.Y + dy
+				if (dx == 0 && dy == 0) || !ok || y < 0 || y >= p.WorldHeight || !over(x, y) {
+					continue
+				}
+				d := next.dist + math.Hypot(float64(dx)*across, float64(dy))
+				if d < dist[y*p.WorldWidth+x] {
+					dist[y*p.WorldWidth+x] = d
+					heap.Push(queue, pixelDist{image.Pt(x, y), d})
+				}
+			}
+		}
+	}
+	return dist
+}
+
+// pixelDist is a pixel & how far it is from something
+type pixelDist struct {
+	at   image.Point
+	dist float64
+}
+
+// pixelQueue is a priority queue (see container/heap) of pixels, nearest first
+type pixelQueue []pixelDist
+
+func (q pixelQueue) Len() int            { return len(q) }
+func (q pixelQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q pixelQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pixelQueue) Push(x interface{}) { *q = append(*q, x.(pixelDist)) }
+func (q *pixelQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	out := old[n-1]
+	*q = old[:n-1]
+	return out
+}
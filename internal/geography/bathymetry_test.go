@@ -0,0 +1,124 @@
+package geography
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func TestDistanceField(t *testing.T) {
+	flat := &types.Project{WorldWidth: 10, WorldHeight: 5}
+	cylinder := &types.Project{WorldWidth: 10, WorldHeight: 5, Wrap: true}
+	everywhere := func(x, y int) bool { return true }
+	west := func(x, y int) bool { return x == 0 }
+
+	dist := distanceField(flat, everywhere, west)
+	assert.Equal(t, 0.0, dist[2*10+0])
+	assert.Equal(t, 4.0, dist[2*10+4])
+	assert.Equal(t, 9.0, dist[2*10+9])
+
+	// around the back of the world
+	dist = distanceField(cylinder, everywhere, west)
+	assert.Equal(t, 1.0, dist[2*10+9])
+
+	// nothing gets past a wall
+	dist = distanceField(flat, func(x, y int) bool { return x != 5 }, west)
+	assert.Equal(t, 4.0, dist[2*10+4])
+	assert.True(t, math.IsInf(dist[2*10+7], 1))
+}
+
+func TestShelfDepth(t *testing.T) {
+	e := &Editor{set: DefaultSettings()}
+	shelf := e.set.BathymetryShelfWidth
+
+	assert.Equal(t, 0.0, e.shelfDepth(0, shelf))
+	assert.Equal(t, e.set.BathymetryShelfDepth, e.shelfDepth(shelf, shelf))
+	assert.Equal(t, e.set.BathymetryAbyssDepth, e.shelfDepth(shelf+e.set.BathymetrySlopeWidth, shelf))
+	assert.Equal(t, e.set.BathymetryAbyssDepth, e.shelfDepth(shelf*100, shelf))
+
+	last := -1.0
+	for d := 0.0; d < shelf+e.set.BathymetrySlopeWidth; d++ {
+		depth := e.shelfDepth(d, shelf)
+		assert.Greater(t, depth, last, d)
+		last = depth
+	}
+}
+
+func TestTrenchAt(t *testing.T) {
+	e := &Editor{set: DefaultSettings()}
+	p := &types.Project{WorldWidth: 200, WorldHeight: 50}
+
+	// the coast is along the west edge, with the sea getting further from it going east
+	coast := make([]float64, p.WorldWidth*p.WorldHeight)
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			coast[y*p.WorldWidth+x] = float64(x)
+		}
+	}
+
+	at, ok := e.trenchAt(p, coast, image.Pt(10, 25))
+	assert.True(t, ok)
+	assert.Equal(t, image.Pt(int(e.set.BathymetryShelfWidth), 25), at)
+
+	// no open sea in reach
+	for i := range coast {
+		coast[i] = 0
+	}
+	_, ok = e.trenchAt(p, coast, image.Pt(10, 25))
+	assert.False(t, ok)
+}
+
+func TestBathymetry(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+
+	// land on the east & west edges with an ocean between them
+	sketch := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			c := types.SketchSea
+			if x < 5 || x >= 25 {
+				c = types.SketchLand
+			}
+			sketch.Set(x, y, c)
+		}
+	}
+	assert.Nil(t, e.ImportSketch(ctx, p.ID, sketch, &types.ImportOptions{Points: 60}))
+	seamap, _, err := e.SeaMap(ctx, p.ID, (e.set.SketchSeaHeight+e.set.SketchLandHeight)/2, 30, 30, 2)
+	assert.Nil(t, err)
+	before := heights(t, ctx, e, p)
+
+	im, err := e.Bathymetry(ctx, p.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, p.WorldWidth, im.Bounds().Dx())
+
+	// land has no depth, the sea does
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			if !isSea(seamap, x, y) {
+				assert.Equal(t, uint8(0), grey(im, x, y), x, y)
+			}
+		}
+	}
+
+	// shallow shelves by the coast fall away to the ocean floor
+	near, far := grey(im, 55, 100), grey(im, 110, 100)
+	assert.True(t, isSea(seamap, 55, 100))
+	assert.LessOrEqual(t, float64(near), e.set.BathymetryShelfDepth)
+	assert.Greater(t, far, near)
+
+	// with a ridge rising in the middle of the ocean, between the deepest parts of it
+	deepest := 0
+	for x := 0; x < p.WorldWidth; x++ {
+		deepest = maxInt(deepest, int(grey(im, x, 100)))
+	}
+	assert.Less(t, int(grey(im, 150, 100)), deepest)
+
+	// the sea is deeper on the heightmap, but the land is as it was
+	after := heights(t, ctx, e, p)
+	assert.Less(t, grey(after, 110, 100), grey(before, 110, 100))
+	assert.Equal(t, grey(before, 10, 100), grey(after, 10, 100))
+}
@@ -19,7 +19,7 @@ type Grid struct {
 	width  int
 	height int
 	size   int
-	wrap   bool
+	wrap   bool // the leftmost & rightmost grids are neighbours (the world wraps)
 
 	gridWidth  int
 	gridHeight int
@@ -90,22 +90,15 @@ func (g *Grid) indexAboveBelow(i int) (int, int) {
 		return -1, -1
 	}
 
+	// worlds only wrap east / west, the poles are always edges
 	above := i - g.gridWidth
 	if above < 0 {
-		if g.wrap {
-			above = g.gridWidth*(g.gridHeight-1) + i
-		} else {
-			above = -1
-		}
+		above = -1
 	}
 
 	below := i + g.gridWidth
 	if below >= g.gridWidth*g.gridHeight {
-		if g.wrap {
-			below = g.gridWidth * (g.gridHeight - 1)
-		} else {
-			below = -1
-		}
+		below = -1
 	}
 
 	return above, below
@@ -0,0 +1,217 @@
+package geography
+
+import (
+	"context"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// cancelAt returns a context that's cancelled part way through `step`, once it
+// reports being at least `frac` done
+func cancelAt(ctx context.Context, step string, frac float64) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	return progress.WithReporter(ctx, func(ev *progress.Event) {
+		if ev.Step == step && ev.Done > 0 && ev.Done < ev.Total && ev.Fraction() >= frac {
+			cancel()
+		}
+	})
+}
+
+// stored returns everything the editor has saved
+func stored(t *testing.T, e *Editor) map[string][]byte {
+	keys, err := e.store.List("")
+	assert.Nil(t, err)
+	out := map[string][]byte{}
+	for _, k := range keys {
+		data, err := e.store.Get(k)
+		assert.Nil(t, err)
+		out[k] = data
+	}
+	return out
+}
+
+// assertGraphSaved checks the editor's cached graph (if any) is the one on disk
+func assertGraphSaved(t *testing.T, e *Editor, p *types.Project) {
+	if e.graph == nil {
+		return
+	}
+	saved, err := voronoi.New(e.store, p.WorldWidth, p.WorldHeight).Graph(e.graph.Name())
+	assert.Nil(t, err)
+	want, err := saved.Marshal()
+	assert.Nil(t, err)
+	got, err := e.graph.Marshal()
+	assert.Nil(t, err)
+	assert.Equal(t, want, got, "cached graph was changed")
+}
+
+func TestCancel(t *testing.T) {
+	empty := func(t *testing.T) (context.Context, *Editor, *types.Project) { return testEditor(t, nil) }
+	world := func(t *testing.T) (context.Context, *Editor, *types.Project) { return testWorld(t, nil) }
+	from, to := image.Pt(30, 100), image.Pt(270, 110)
+	spec := &types.PathSpec{From: &from, To: &to, MaxDist: 400}
+
+	cases := []struct {
+		Name  string
+		World func(t *testing.T) (context.Context, *Editor, *types.Project)
+		Step  string
+		Run   func(ctx context.Context, e *Editor, p *types.Project) error
+	}{
+		{
+			"tectonics",
+			empty,
+			"tectonics",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				return e.CreateTectonics(ctx, p.ID, 0.5, 60)
+			},
+		},
+		{
+			"import",
+			empty,
+			"import",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				in := slope(p.WorldWidth/2, p.WorldHeight/2, 0, math.MaxUint16)
+				return e.ImportHeightmap(ctx, p.ID, in, &types.ImportOptions{Points: 60})
+			},
+		},
+		{
+			"mountains",
+			world,
+			"mountains",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				_, _, err := e.AddMountainRange(ctx, p.ID, "range", spec, 1)
+				return err
+			},
+		},
+		{
+			"sea",
+			world,
+			"sea",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				_, _, err := e.SeaMap(ctx, p.ID, 110, 30, 30, 2)
+				return err
+			},
+		},
+		{
+			"bathymetry",
+			world,
+			"bathymetry",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				_, err := e.Bathymetry(ctx, p.ID)
+				return err
+			},
+		},
+		{
+			"wind",
+			world,
+			"wind",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				_, err := e.Wind(ctx, p.ID)
+				return err
+			},
+		},
+		{
+			"rain-wind-storms",
+			world,
+			"rain",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				_, err := e.Rain(ctx, p.ID, 1, nil)
+				return err
+			},
+		},
+		{
+			"rain-band-storms",
+			world,
+			"rain",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				_, err := e.Rain(ctx, p.ID, 1, []types.Heading{types.EAST, types.WEST})
+				return err
+			},
+		},
+		{
+			"seasons",
+			world,
+			"seasons",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				_, err := e.Seasons(ctx, p.ID, 4, 1)
+				return err
+			},
+		},
+		{
+			"cryosphere",
+			glacierWorld,
+			"cryosphere",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				_, err := e.Cryosphere(ctx, p.ID)
+				return err
+			},
+		},
+		{
+			"rescale",
+			world,
+			"rescale",
+			func(ctx context.Context, e *Editor, p *types.Project) error {
+				dst := &types.Project{ID: dbutils.NewID("bigger"), Name: "bigger", WorldWidth: p.WorldWidth * 2, WorldHeight: p.WorldHeight * 2}
+				tx, err := e.db.Begin()
+				if err != nil {
+					return err
+				}
+				err = tx.SetProjects([]*types.Project{dst})
+				if err != nil {
+					tx.Rollback()
+					return err
+				}
+				err = tx.Commit()
+				if err != nil {
+					return err
+				}
+				return e.Rescale(ctx, p.ID, dst.ID)
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx, e, p := tt.World(t)
+			before := stored(t, e)
+
+			err := tt.Run(cancelAt(ctx, tt.Step, 0.3), e, p)
+			assert.ErrorIs(t, err, context.Canceled)
+
+			// nothing is saved part way & nothing cached is left half changed
+			assert.Equal(t, before, stored(t, e))
+			assertGraphSaved(t, e, p)
+		})
+	}
+}
+
+func TestCancelDropsCachedState(t *testing.T) {
+	ctx, e, p := testWorld(t, nil)
+	heights(t, ctx, e, p)
+	assert.Greater(t, len(e.hmap), 0)
+
+	// mountains are tagged & weighted on the (cached) graph as they're placed, so that
+	// has to be thrown away if we stop part way
+	from, to := image.Pt(30, 100), image.Pt(270, 110)
+	_, _, err := e.AddMountainRange(cancelAt(ctx, "mountains", 0.3), p.ID, "range", &types.PathSpec{From: &from, To: &to, MaxDist: 400}, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, e.graph)
+	assert.Equal(t, 0, len(e.hmap))
+
+	graph, err := e.cachedGraph(voronoi.New(e.store, p.WorldWidth, p.WorldHeight), p.VoronoiDiagram())
+	assert.Nil(t, err)
+	for _, name := range graph.TagNames() {
+		assert.NotEqual(t, tagMountains, graph.TagKind(name), name)
+	}
+
+	// & the next step is none the wiser
+	_, _, err = e.AddMountainRange(ctx, p.ID, "range", &types.PathSpec{From: &from, To: &to, MaxDist: 400}, 1)
+	assert.Nil(t, err)
+}
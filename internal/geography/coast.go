@@ -0,0 +1,309 @@
+package geography
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+var (
+	// ErrLandmassNotFound is returned if we're given a landmass that doesn't exist
+	// (in the current epoch)
+	ErrLandmassNotFound = fmt.Errorf("landmass not found")
+)
+
+// AddArchipelago places a chain of islands along a path. The islands are raised out of
+// the sea on the next call to SeaMap.
+func (e *Editor) AddArchipelago(ctx context.Context, proj, tag string, s *types.PathSpec, islands int) ([]image.Point, error) {
+	op, err := e.newGraphOp(ctx, proj, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// sea weights make paths keep off of land (if we've a sea map yet)
+	path, err := op.path(tagSea)
+	if err != nil {
+		return nil, err
+	}
+
+	stage, err := op.pnt.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Rollback() // noop once committed
+
+	cnv, err := stage.Canvas(op.p.Canvas(tagCoastLand))
+	if err != nil {
+		return nil, err
+	}
+
+	// spread islands evenly(ish) along the path
+	along := []image.Point{}
+	line := unwrapped(op.p, path)
+	for j := 1; j < len(line); j++ {
+		along = append(along, voronoi.PointsBetween(line[j-1], line[j])...)
+	}
+	if islands < 1 || len(along) < 1 {
+		return nil, fmt.Errorf("%w no room for islands", ErrNoPath)
+	}
+	step := float64(len(along)) / float64(islands)
+
+	centres := []image.Point{}
+	for i := 0; i < islands; i++ {
+		at := along[int(step*float64(i)+op.rng.Float64()*step)%len(along)]
+		size := e.set.IslandSize.RollWith(op.rng)
+		centre := pointNear(op.rng, at, 0, size/2)
+
+		// a main island with a few smaller bumps, so they're not all perfect ovals
+		draw := wrapped(op.p, cnv)
+		err = draw.Ellipse(centre, size, size/2+op.rng.Intn(size/2+1), op.rng.Intn(180), 0.8+op.rng.Float64()/5, paint.Convex)
+		if err != nil {
+			return nil, err
+		}
+		for k := op.rng.Intn(3); k > 0; k-- {
+			small := size/2 + 1
+			err = draw.Ellipse(pointNear(op.rng, centre, size/3, size), small, small/2+op.rng.Intn(small/2+1), op.rng.Intn(180), 0.6+op.rng.Float64()/5, paint.Convex)
+			if err != nil {
+				return nil, err
+			}
+		}
+		centres = append(centres, onWorld(op.p, centre))
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// keep other sea paths off the islands
+	err = op.graph.IncrWeights(path, map[string]int{tagSea: e.set.GraphSeaWeight})
+	if err != nil {
+		return nil, err
+	}
+	op.graph.TagAs(tagArchipelago, featureTag(op.graph, tagArchipelago, tag), path)
+
+	err = stage.Save(cnv)
+	if err != nil {
+		return nil, err
+	}
+	err = stagedVoronoi(op.p, stage).Save(op.graph)
+	if err != nil {
+		return nil, err
+	}
+	return centres, stage.Commit()
+}
+
+// AddBay scoops out a bay (or lake, if it's inland) around the centre. The bay is
+// flooded on the next call to SeaMap.
+func (e *Editor) AddBay(ctx context.Context, proj string, centre image.Point, radius int) error {
+	p, err := e.project(proj)
+	if err != nil {
+		return err
+	}
+	if radius < 1 {
+		return fmt.Errorf("bay radius must be > 0, got %d", radius)
+	}
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	stage, err := pnt.Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
+
+	cnv, err := stage.Canvas(p.Canvas(tagCoastSea))
+	if err != nil {
+		return err
+	}
+
+	// a bay isn't perfectly round
+	rng := random(ctx)
+	err = wrapped(p, cnv).Ellipse(centre, radius, radius-rng.Intn(radius/3+1), rng.Intn(180), 1, paint.Convex)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	err = stage.Save(cnv)
+	if err != nil {
+		return err
+	}
+	return stage.Commit()
+}
+
+// CarveFjords cuts narrow inlets up to `depth` pixels into the coast of a landmass found
+// by the last SeaMap. Inlets are flooded by the next call to SeaMap.
+func (e *Editor) CarveFjords(ctx context.Context, proj, landmassID string, depth int) ([][]image.Point, error) {
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := e.db.Landmasses([]string{landmassID})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) != 1 || found[0].ProjectID != p.ID || found[0].Epoch != p.Epoch {
+		return nil, fmt.Errorf("%w %s", ErrLandmassNotFound, landmassID)
+	}
+	lm := found[0]
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	sea, err := pnt.Canvas(p.Canvas(tagSea))
+	if err != nil {
+		return nil, err
+	}
+	seamap, err := paint.Image(sea)
+	if err != nil {
+		return nil, err
+	}
+
+	coast, err := landmassCoast(ctx, seamap, lm)
+	if err != nil {
+		return nil, err
+	}
+	if len(coast) < 1 {
+		return nil, fmt.Errorf("%w %s has no coast", ErrLandmassNotFound, landmassID)
+	}
+
+	stage, err := pnt.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Rollback() // noop once committed
+
+	cnv, err := stage.Canvas(p.Canvas(tagCoastSea))
+	if err != nil {
+		return nil, err
+	}
+
+	rng := random(ctx)
+	fjords := [][]image.Point{}
+	for i := rng.Intn(e.set.FjordSpacing); i < len(coast); i += e.set.FjordSpacing + rng.Intn(e.set.FjordSpacing) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		fjord := fjordPath(rng, seamap, coast[i], depth)
+		if len(fjord) < 2 {
+			continue
+		}
+		err = cnv.Channel(fjord, e.set.FjordWidth.RollWith(rng), 0.8+rng.Float64()/5, paint.Convex)
+		if err != nil {
+			return nil, err
+		}
+		fjords = append(fjords, fjord)
+	}
+
+	err = stage.Save(cnv)
+	if err != nil {
+		return nil, err
+	}
+	return fjords, stage.Commit()
+}
+
+// landmassCoast returns land pixels of the landmass that touch the sea, in the order
+// we walked over them.
+func landmassCoast(ctx context.Context, seamap image.Image, lm *types.Landmass) ([]image.Point, error) {
+	bnds := seamap.Bounds()
+	want := combineUint16(uint8(lm.ColorR), uint8(lm.ColorG))
+	isLand := func(x, y int) bool {
+		num, ok := landAt(seamap, x, y)
+		return ok && num == want
+	}
+
+	coast := []image.Point{}
+	for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		for x := bnds.Min.X; x < bnds.Max.X; x++ {
+			if !isLand(x, y) {
+				continue
+			}
+			for _, d := range fourWay {
+				n := image.Pt(x+d.X, y+d.Y)
+				if n.In(bnds) && isSea(seamap, n.X, n.Y) {
+					coast = append(coast, image.Pt(x, y))
+					break
+				}
+			}
+		}
+	}
+
+	// roughly order by angle around the middle, so spacing along the slice is
+	// spacing along the coast
+	mid := image.Pt(0, 0)
+	for _, c := range coast {
+		mid = mid.Add(c)
+	}
+	if len(coast) > 0 {
+		mid = mid.Div(len(coast))
+	}
+	sort.Slice(coast, func(i, j int) bool {
+		return math.Atan2(float64(coast[i].Y-mid.Y), float64(coast[i].X-mid.X)) <
+			math.Atan2(float64(coast[j].Y-mid.Y), float64(coast[j].X-mid.X))
+	})
+
+	return coast, nil
+}
+
+// fjordPath wanders inland from `start` (away from the sea) for up to `depth` pixels
+func fjordPath(rng *rand.Rand, seamap image.Image, start image.Point, depth int) []image.Point {
+	bnds := seamap.Bounds()
+
+	// head away from the sea around us
+	dx, dy := 0.0, 0.0
+	for _, d := range fourWay {
+		n := start.Add(d)
+		if n.In(bnds) && isSea(seamap, n.X, n.Y) {
+			dx -= float64(d.X)
+			dy -= float64(d.Y)
+		}
+	}
+	norm := math.Hypot(dx, dy)
+	if norm == 0 {
+		return nil
+	}
+	dx, dy = dx/norm, dy/norm
+
+	path := []image.Point{start}
+	x, y := float64(start.X), float64(start.Y)
+	segment := float64(depth) / fjordSegments
+	for i := 0; i < fjordSegments; i++ {
+		// wiggle a bit as we go
+		turn := (rng.Float64() - 0.5) * math.Pi / 3
+		dx, dy = dx*math.Cos(turn)-dy*math.Sin(turn), dx*math.Sin(turn)+dy*math.Cos(turn)
+
+		x += dx * segment
+		y += dy * segment
+		next := image.Pt(int(x), int(y))
+		if !next.In(bnds) || isSea(seamap, next.X, next.Y) {
+			break // we've come out the other side
+		}
+		path = append(path, next)
+	}
+	return path
+}
+
+// isSea returns if some pixel of the sea map is sea
+func isSea(seamap image.Image, x, y int) bool {
+	_, ok := landAt(seamap, x, y)
+	return !ok
+}
+
+const (
+	// fjordSegments is how many straight lines make up a fjord
+	fjordSegments = 6
+)
+
+var fourWay = []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
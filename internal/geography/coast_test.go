@@ -0,0 +1,80 @@
+package geography
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func TestAddBay(t *testing.T) {
+	ctx, e, p := testWorld(t, nil)
+	centre := image.Pt(p.WorldWidth/2, p.WorldHeight/2)
+	before := heights(t, ctx, e, p)
+
+	assert.Nil(t, e.AddBay(ctx, p.ID, centre, 20))
+
+	after := heights(t, ctx, e, p)
+	assert.Less(t, grey(after, centre.X, centre.Y), grey(before, centre.X, centre.Y))
+
+	// nothing changes well away from the bay
+	assert.Equal(t, grey(before, 5, 5), grey(after, 5, 5))
+
+	assert.NotNil(t, e.AddBay(ctx, p.ID, centre, 0))
+}
+
+func TestAddArchipelago(t *testing.T) {
+	ctx, e, p := testWorld(t, nil)
+	before := heights(t, ctx, e, p)
+
+	islands, err := e.AddArchipelago(ctx, p.ID, "", &types.PathSpec{
+		From:    &image.Point{X: 30, Y: 30},
+		To:      &image.Point{X: 270, Y: 170},
+		MaxDist: 1000,
+	}, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(islands))
+
+	after := heights(t, ctx, e, p)
+	for _, c := range islands {
+		assert.True(t, c.In(after.Bounds()), c)
+		assert.Greater(t, grey(after, c.X, c.Y), grey(before, c.X, c.Y), c)
+	}
+
+	graph, err := e.cachedGraph(voronoi.New(e.store, p.WorldWidth, p.WorldHeight), p.VoronoiDiagram())
+	assert.Nil(t, err)
+	_, ok := graph.FromTag(tagArchipelago + "/0")
+	assert.True(t, ok)
+}
+
+func TestCarveFjords(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 60))
+	_, lms, err := e.SeaMap(ctx, p.ID, 100, 30, 30, 2)
+	assert.Nil(t, err)
+
+	_, err = e.CarveFjords(ctx, p.ID, "nope", 30)
+	assert.ErrorIs(t, err, ErrLandmassNotFound)
+
+	biggest := lms[0]
+	for _, lm := range lms {
+		if lm.Size > biggest.Size {
+			biggest = lm
+		}
+	}
+	before := heights(t, ctx, e, p)
+
+	fjords, err := e.CarveFjords(ctx, p.ID, biggest.ID, 30)
+	assert.Nil(t, err)
+	assert.Greater(t, len(fjords), 0)
+
+	after := heights(t, ctx, e, p)
+	for _, f := range fjords {
+		assert.GreaterOrEqual(t, len(f), 2)
+		end := f[len(f)-1]
+		assert.Less(t, grey(after, end.X, end.Y), grey(before, end.X, end.Y), end)
+	}
+}
@@ -0,0 +1,347 @@
+package geography
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/voidshard/genesis/internal/blob"
+	"github.com/voidshard/genesis/internal/brush"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+const (
+	// manual edits are kept apart from generated terrain, so re-running a generator
+	// doesn't lose them. Raised & lowered heights are added / taken from the heightmap
+	// as is (ie. weight 1 & -1).
+	tagEditRaise = "edit-raise"
+	tagEditLower = "edit-lower"
+
+	// undo entries are kept in storage as `{project}-{epoch}-undo-{n}.edit`
+	tagEditUndo = "undo"
+	editUndoExt = ".edit"
+)
+
+var (
+	// ErrNothingToUndo is returned by UndoEdit when there are no edits left to undo
+	ErrNothingToUndo = fmt.Errorf("no edits to undo")
+)
+
+// editUndo holds the edit canvases (in area) as they were before an edit
+type editUndo struct {
+	Area  image.Rectangle `json:"area"`
+	Raise []uint8         `json:"raise"`
+	Lower []uint8         `json:"lower"`
+}
+
+// RaiseArea raises the terrain under the brush by `amount`
+func (e *Editor) RaiseArea(ctx context.Context, proj string, b *types.Brush, amount uint8) error {
+	return e.edit(ctx, proj, b, func(p *types.Project, pnt paint.Painter, area image.Rectangle) (func(x, y int) float64, error) {
+		return func(x, y int) float64 { return float64(amount) }, nil
+	})
+}
+
+// LowerArea lowers the terrain under the brush by `amount`
+func (e *Editor) LowerArea(ctx context.Context, proj string, b *types.Brush, amount uint8) error {
+	return e.edit(ctx, proj, b, func(p *types.Project, pnt paint.Painter, area image.Rectangle) (func(x, y int) float64, error) {
+		return func(x, y int) float64 { return -1 * float64(amount) }, nil
+	})
+}
+
+// FlattenTo sets the terrain under the brush to `height`
+func (e *Editor) FlattenTo(ctx context.Context, proj string, b *types.Brush, height uint8) error {
+	return e.edit(ctx, proj, b, func(p *types.Project, pnt paint.Painter, area image.Rectangle) (func(x, y int) float64, error) {
+		current, err := e.currentHeight(ctx, p, pnt, area)
+		if err != nil {
+			return nil, err
+		}
+		return func(x, y int) float64 {
+			return float64(height) - float64(grey(current, x, y))
+		}, nil
+	})
+}
+
+// SmoothArea smooths the terrain under the brush
+func (e *Editor) SmoothArea(ctx context.Context, proj string, b *types.Brush, radius uint32) error {
+	return e.edit(ctx, proj, b, func(p *types.Project, pnt paint.Painter, area image.Rectangle) (func(x, y int) float64, error) {
+		// include a border, so the edges are smoothed with what's around them
+		border := int(radius)
+		current, err := e.currentHeight(ctx, p, pnt, area.Inset(-border).Intersect(image.Rect(0, 0, p.WorldWidth, p.WorldHeight)))
+		if err != nil {
+			return nil, err
+		}
+		smooth, err := paint.SmoothImage(current, radius)
+		if err != nil {
+			return nil, err
+		}
+		return func(x, y int) float64 {
+			return float64(grey(smooth, x, y)) - float64(grey(current, x, y))
+		}, nil
+	})
+}
+
+// PaintNoise adds perlin noise (of up to +/- `amount`) to the terrain under the brush
+func (e *Editor) PaintNoise(ctx context.Context, proj string, b *types.Brush, scale float64, amount uint8) error {
+	return e.edit(ctx, proj, b, func(p *types.Project, pnt paint.Painter, area image.Rectangle) (func(x, y int) float64, error) {
+		noise := paint.NewPerlin(random(ctx).Int63(), area.Dx(), area.Dy(), scale, false)
+		return func(x, y int) float64 {
+			v := float64(noise.GrayAt(x-area.Min.X, y-area.Min.Y).Y)
+			return (v - 128) / 128 * float64(amount)
+		}, nil
+	})
+}
+
+// UndoEdit reverts the last manual edit (of the current epoch)
+func (e *Editor) UndoEdit(ctx context.Context, proj string) error {
+	p, err := e.project(proj)
+	if err != nil {
+		return err
+	}
+
+	keys, err := e.undoKeys(p)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return ErrNothingToUndo
+	}
+	last := keys[len(keys)-1]
+
+	data, err := e.store.Get(last)
+	if err != nil {
+		return err
+	}
+	undo := &editUndo{}
+	err = json.Unmarshal(data, undo)
+	if err != nil {
+		return err
+	}
+	if len(undo.Raise) != undo.Area.Dx()*undo.Area.Dy() || len(undo.Lower) != len(undo.Raise) {
+		return fmt.Errorf("undo entry %s is corrupt", last)
+	}
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	stage, err := pnt.Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
+
+	raise, err := stage.Canvas(p.Canvas(tagEditRaise))
+	if err != nil {
+		return err
+	}
+	lower, err := stage.Canvas(p.Canvas(tagEditLower))
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	for y := undo.Area.Min.Y; y < undo.Area.Max.Y; y++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		for x := undo.Area.Min.X; x < undo.Area.Max.X; x++ {
+			err = setEdit(raise, lower, x, y, undo.Raise[i], undo.Lower[i])
+			if err != nil {
+				return err
+			}
+			i++
+		}
+	}
+
+	for _, cnv := range []paint.Canvas{raise, lower} {
+		err = stage.Save(cnv)
+		if err != nil {
+			return err
+		}
+	}
+	err = stage.Commit()
+	if err != nil {
+		return err
+	}
+
+	e.hmap = map[hmapKey]image.Image{}
+	return e.store.Delete(last)
+}
+
+// edit applies some change in height (from `delta`) to the area under the brush,
+// scaled by how strong the brush is at each point. The change is recorded so it can
+// be undone.
+func (e *Editor) edit(
+	ctx context.Context,
+	proj string,
+	b *types.Brush,
+	delta func(p *types.Project, pnt paint.Painter, area image.Rectangle) (func(x, y int) float64, error),
+) error {
+	p, err := e.project(proj)
+	if err != nil {
+		return err
+	}
+
+	mask, err := brush.Mask(b, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	if err != nil {
+		return err
+	}
+	area := mask.Bounds()
+	if area.Empty() {
+		return nil // nothing to do
+	}
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	change, err := delta(p, pnt, area)
+	if err != nil {
+		return err
+	}
+
+	stage, err := pnt.Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
+
+	raise, err := stage.Canvas(p.Canvas(tagEditRaise))
+	if err != nil {
+		return err
+	}
+	lower, err := stage.Canvas(p.Canvas(tagEditLower))
+	if err != nil {
+		return err
+	}
+
+	undo := &editUndo{
+		Area:  area,
+		Raise: make([]uint8, 0, area.Dx()*area.Dy()),
+		Lower: make([]uint8, 0, area.Dx()*area.Dy()),
+	}
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		for x := area.Min.X; x < area.Max.X; x++ {
+			r, err := raise.R(x, y)
+			if err != nil {
+				return err
+			}
+			l, err := lower.R(x, y)
+			if err != nil {
+				return err
+			}
+			undo.Raise = append(undo.Raise, r)
+			undo.Lower = append(undo.Lower, l)
+
+			m := float64(mask.GrayAt(x, y).Y) / 255
+			if m == 0 {
+				continue
+			}
+
+			// raise & lower hold the net change, only one of which is set
+			net := math.Round(float64(r) - float64(l) + change(x, y)*m)
+			if net >= 0 {
+				err = setEdit(raise, lower, x, y, forceUint8(int(net)), 0)
+			} else {
+				err = setEdit(raise, lower, x, y, 0, forceUint8(int(-net)))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, cnv := range []paint.Canvas{raise, lower} {
+		err = stage.Save(cnv)
+		if err != nil {
+			return err
+		}
+	}
+	err = stage.Commit()
+	if err != nil {
+		return err
+	}
+
+	e.hmap = map[hmapKey]image.Image{}
+	return e.pushUndo(p, undo)
+}
+
+// currentHeight returns the (unsmoothed) heightmap of `area`
+func (e *Editor) currentHeight(ctx context.Context, p *types.Project, pnt paint.Painter, area image.Rectangle) (image.Image, error) {
+	weights, err := e.heightMapWeights(p, pnt)
+	if err != nil {
+		return nil, err
+	}
+	return pnt.Merge(ctx, area, weights)
+}
+
+// pushUndo records an undo entry, dropping the oldest entries past our limit
+func (e *Editor) pushUndo(p *types.Project, undo *editUndo) error {
+	data, err := json.Marshal(undo)
+	if err != nil {
+		return err
+	}
+
+	keys, err := e.undoKeys(p)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	if len(keys) > 0 {
+		_, err = fmt.Sscanf(keys[len(keys)-1][len(p.Canvas(tagEditUndo))+1:], "%d", &n)
+		if err != nil {
+			return err
+		}
+		n++
+	}
+
+	err = e.store.Put(fmt.Sprintf("%s-%09d%s", p.Canvas(tagEditUndo), n, editUndoExt), data)
+	if err != nil {
+		return err
+	}
+
+	for len(keys)+1 > e.set.EditUndoLimit && len(keys) > 0 {
+		err = e.store.Delete(keys[0])
+		if err != nil && !errors.Is(err, blob.ErrNotFound) {
+			return err
+		}
+		keys = keys[1:]
+	}
+	return nil
+}
+
+// undoKeys returns keys of undo entries for the current epoch, oldest first
+func (e *Editor) undoKeys(p *types.Project) ([]string, error) {
+	found, err := e.store.List(p.Canvas(tagEditUndo) + "-")
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, k := range found {
+		if strings.HasSuffix(k, editUndoExt) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys) // numbers are zero padded
+	return keys, nil
+}
+
+// setEdit sets how far a pixel has been raised & lowered by edits
+func setEdit(raise, lower paint.Canvas, x, y int, r, l uint8) error {
+	err := raise.Set(x, y, color.Gray{Y: r})
+	if err != nil {
+		return err
+	}
+	return lower.Set(x, y, color.Gray{Y: l})
+}
+
+// grey returns the (8 bit) grey value of a pixel
+func grey(im image.Image, x, y int) uint8 {
+	r, _, _, _ := im.At(x, y).RGBA()
+	return uint8(r >> 8)
+}
@@ -0,0 +1,134 @@
+package geography
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// rawHeights returns the whole (unsmoothed) heightmap, so edits can be seen exactly
+func rawHeights(t *testing.T, ctx context.Context, e *Editor, p *types.Project) image.Image {
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	im, err := e.currentHeight(ctx, p, pnt, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	assert.Nil(t, err)
+	return im
+}
+
+func TestRaiseLowerUndo(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 60))
+	start := rawHeights(t, ctx, e, p)
+
+	circle := &types.Brush{Centre: image.Pt(150, 100), Radius: 20}
+	inside, outside := image.Pt(150, 100), image.Pt(150, 130)
+
+	assert.Nil(t, e.RaiseArea(ctx, p.ID, circle, 30))
+	raised := rawHeights(t, ctx, e, p)
+	assert.Equal(t, int(grey(start, inside.X, inside.Y))+30, int(grey(raised, inside.X, inside.Y)))
+	assert.Equal(t, grey(start, outside.X, outside.Y), grey(raised, outside.X, outside.Y))
+
+	// lowering by as much leaves the terrain as it was
+	assert.Nil(t, e.LowerArea(ctx, p.ID, circle, 30))
+	assert.Equal(t, start, rawHeights(t, ctx, e, p))
+
+	assert.Nil(t, e.UndoEdit(ctx, p.ID))
+	assert.Equal(t, raised, rawHeights(t, ctx, e, p))
+	assert.Nil(t, e.UndoEdit(ctx, p.ID))
+	assert.Equal(t, start, rawHeights(t, ctx, e, p))
+	assert.ErrorIs(t, e.UndoEdit(ctx, p.ID), ErrNothingToUndo)
+}
+
+func TestFlattenTo(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 60))
+	start := rawHeights(t, ctx, e, p)
+
+	square := &types.Brush{Polygon: []image.Point{{100, 50}, {200, 50}, {200, 150}, {100, 150}}}
+	assert.Nil(t, e.FlattenTo(ctx, p.ID, square, 80))
+
+	after := rawHeights(t, ctx, e, p)
+	for _, pt := range []image.Point{{110, 60}, {150, 100}, {190, 140}} {
+		assert.Equal(t, uint8(80), grey(after, pt.X, pt.Y), pt)
+	}
+	assert.Equal(t, grey(start, 50, 100), grey(after, 50, 100))
+}
+
+func TestEditWithMask(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 60))
+	start := rawHeights(t, ctx, e, p)
+
+	// only the left half of the mask is white
+	mask := image.NewGray(image.Rect(100, 100, 140, 120))
+	for y := 100; y < 120; y++ {
+		for x := 100; x < 120; x++ {
+			mask.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	assert.Nil(t, e.RaiseArea(ctx, p.ID, &types.Brush{Mask: mask}, 10))
+
+	after := rawHeights(t, ctx, e, p)
+	assert.Equal(t, int(grey(start, 110, 110))+10, int(grey(after, 110, 110)))
+	assert.Equal(t, grey(start, 130, 110), grey(after, 130, 110))
+}
+
+func TestSmoothArea(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 60))
+
+	// a sharp step for smoothing to soften
+	assert.Nil(t, e.RaiseArea(ctx, p.ID, &types.Brush{Centre: image.Pt(150, 100), Radius: 15}, 60))
+	before := rawHeights(t, ctx, e, p)
+
+	area := image.Rect(120, 70, 180, 130)
+	assert.Nil(t, e.SmoothArea(ctx, p.ID, &types.Brush{Polygon: []image.Point{
+		area.Min, {area.Max.X, area.Min.Y}, area.Max, {area.Min.X, area.Max.Y},
+	}}, 4))
+	after := rawHeights(t, ctx, e, p)
+
+	steepest := func(im image.Image) int {
+		most := 0
+		for y := area.Min.Y + 5; y < area.Max.Y-5; y++ {
+			for x := area.Min.X + 5; x < area.Max.X-5; x++ {
+				d := int(grey(im, x+1, y)) - int(grey(im, x, y))
+				if d < 0 {
+					d = -d
+				}
+				if d > most {
+					most = d
+				}
+			}
+		}
+		return most
+	}
+	assert.Less(t, steepest(after), steepest(before))
+}
+
+func TestPaintNoise(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 60))
+	start := rawHeights(t, ctx, e, p)
+
+	circle := &types.Brush{Centre: image.Pt(150, 100), Radius: 30}
+	assert.Nil(t, e.PaintNoise(ctx, p.ID, circle, 10, 20))
+	after := rawHeights(t, ctx, e, p)
+
+	changed := 0
+	for y := 70; y <= 130; y++ {
+		for x := 120; x <= 180; x++ {
+			d := int(grey(after, x, y)) - int(grey(start, x, y))
+			assert.True(t, d >= -20 && d <= 20, d)
+			if d != 0 {
+				changed++
+			}
+		}
+	}
+	assert.Greater(t, changed, 0)
+	assert.Equal(t, grey(start, 150, 10), grey(after, 150, 10))
+}
@@ -1,28 +1,44 @@
 package geography
 
 import (
+	"context"
 	"fmt"
 	"image"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/voidshard/genesis/internal/blob"
 	"github.com/voidshard/genesis/internal/config"
 	"github.com/voidshard/genesis/internal/database"
 	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
 	"github.com/voidshard/genesis/internal/voronoi"
 	"github.com/voidshard/genesis/pkg/types"
 )
 
 const (
 	// tags for features & canvas names
-	tagMountains  = "mountains"
-	tagVolcanoes  = "volcanoes"
-	tagRavines    = "ravines"
-	tagRivers     = "rivers"
-	tagSea        = "sea"
-	tagLand       = "land"
-	tagRain       = "rain"
-	tagPerlin     = "noise-perlin"  // nice smooth noise
-	tagVoro       = "noise-voronoi" // rough fractal style noise
-	tagSeaCurrent = "sea-current"
+	tagMountains   = "mountains"
+	tagVolcanoes   = "volcanoes"
+	tagRavines     = "ravines"
+	tagRivers      = "rivers"
+	tagSea         = "sea"
+	tagLand        = "land"
+	tagRain        = "rain"
+	tagWind        = "wind"
+	tagTemperature = "temperature"
+	tagSeasonality = "seasonality" // how much the climate changes over the year
+	tagIce         = "ice"
+	tagGlaciers    = "glaciers"      // troughs cut by glaciers
+	tagDepth       = "depth"         // how deep the sea is
+	tagPerlin      = "noise-perlin"  // nice smooth noise
+	tagVoro        = "noise-voronoi" // rough fractal style noise
+	tagSeaCurrent  = "sea-current"
+	tagCoastline   = "coastline"
+	tagCoastLand   = "coast-land" // land raised by coastal tools (eg. islands)
+	tagCoastSea    = "coast-sea"  // land cut away by coastal tools (eg. bays)
+	tagArchipelago = "archipelago"
 )
 
 var (
@@ -48,70 +64,168 @@ var (
 		tagVolcanoes,
 		tagSea,
 		tagRain,
+		tagCoastLand,
+		tagCoastSea,
+		tagEditRaise,
+		tagEditLower,
+		tagGlaciers,
+		tagDepth,
 	}
 )
 
 type Editor struct {
-	cfg *config.Config
-	db  database.Database
+	cfg   *config.Config
+	db    database.Database
+	store blob.Blob
 
 	proj  *types.Project
 	graph voronoi.Graph
-	hmap  map[image.Rectangle]image.Image
+	hmap  map[hmapKey]image.Image
 
 	set *Settings
 }
 
-func New(cfg *config.Config, db database.Database, set *Settings) *Editor {
+// hmapKey is what we cache heightmaps by, an area of some project
+type hmapKey struct {
+	proj string
+	area image.Rectangle
+}
+
+func New(cfg *config.Config, db database.Database, store blob.Blob, set *Settings) *Editor {
 	return &Editor{
-		cfg:  cfg,
-		db:   db,
-		set:  set,
-		hmap: map[image.Rectangle]image.Image{},
+		cfg:   cfg,
+		db:    db,
+		store: store,
+		set:   set,
+		hmap:  map[hmapKey]image.Image{},
 	}
 }
 
 //
-func (e *Editor) NextEpoch(id string) error {
+func (e *Editor) NextEpoch(ctx context.Context, id string) error {
 	p, err := e.project(id)
 	if err != nil {
 		return err
 	}
 
 	// copy canvases across to new epoch
-	pnt := paint.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
-	for _, n := range copyCanvasBetweenEpoch {
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	stage, err := pnt.Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
+
+	copied := []paint.Canvas{}
+	for i, n := range copyCanvasBetweenEpoch {
+		err = progress.Report(ctx, "epoch", i, len(copyCanvasBetweenEpoch), "canvases")
+		if err != nil {
+			return err
+		}
+
 		co, err := pnt.Canvas(p.Canvas(n))
 		if err != nil {
 			return err
 		}
+		im, err := paint.Image(co)
+		if err != nil {
+			return err
+		}
 
-		cn, err := pnt.NewCanvasFromImage(p.CanvasFromEpoch(n, p.Epoch+1), co.Image())
+		cn, err := stage.NewCanvasFromImage(p.CanvasFromEpoch(n, p.Epoch+1), im)
 		if err != nil {
 			return err
 		}
+		copied = append(copied, cn)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
-		err = pnt.Save(cn)
+	// nothing is written until we've copied everything
+	for _, cn := range copied {
+		err = stage.Save(cn)
 		if err != nil {
 			return err
 		}
 	}
 
 	// increment project epoch
-	p.Epoch += 1
+	next := *p
+	next.Epoch += 1
 	tx, err := e.db.Begin()
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	err = tx.SetProjects([]*types.Project{p})
+	err = tx.SetProjects([]*types.Project{&next})
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	return tx.Commit()
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	p.Epoch = next.Epoch
+
+	// canvases for the new epoch go in only once the project says it's there
+	return stage.Commit()
+}
+
+// DeleteProject removes all canvases & graphs belonging to a project (from every epoch)
+func (e *Editor) DeleteProject(id string) error {
+	prefix := fmt.Sprintf("%s-", id)
+	found, err := e.store.List(prefix)
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{} // canvases are many blobs under their name
+	for _, k := range found {
+		names[strings.SplitN(k, "/", 2)[0]] = true
+	}
+	for name := range names {
+		err = e.store.Delete(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// along with any local copies
+	cached, err := filepath.Glob(filepath.Join(e.cfg.Gen.Root, prefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, f := range cached {
+		err = os.RemoveAll(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	e.Forget(id)
+	return nil
+}
+
+// Forget throws away anything about a project we're holding in memory, so it's read
+// again from storage (eg. after the project's canvases were replaced)
+func (e *Editor) Forget(id string) {
+	if e.proj != nil && e.proj.ID == id {
+		e.proj = nil
+	}
+	if e.graph != nil && strings.HasPrefix(e.graph.Name(), id) {
+		e.graph = nil
+	}
+	e.hmap = map[hmapKey]image.Image{}
+}
+
+// cancelled throws away cached state that an operation may have changed in memory
+// before it was stopped (so it's re-read from disk) & returns why it was stopped
+func (e *Editor) cancelled(ctx context.Context) error {
+	e.graph = nil
+	e.hmap = map[hmapKey]image.Image{}
+	return ctx.Err()
 }
 
 // project gets a single project by ID, proj is cached
@@ -129,14 +243,16 @@ func (e *Editor) project(id string) (*types.Project, error) {
 	return found[0], nil
 }
 
-func (e *Editor) cachedHeightmap(proj string, area image.Rectangle) (image.Image, error) {
-	hmap, ok := e.hmap[area]
+func (e *Editor) cachedHeightmap(ctx context.Context, proj string, area image.Rectangle) (image.Image, error) {
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+	hmap, ok := e.hmap[hmapKey{p.ID, area}]
 	if ok {
 		return hmap, nil
 	}
-	hmap, err := e.HeightMap(proj, area)
-	e.hmap[area] = hmap
-	return hmap, err
+	return e.HeightMap(ctx, p.ID, area) // caches the result
 }
 
 func (e *Editor) cachedGraph(voro voronoi.Voronoi, name string) (voronoi.Graph, error) {
@@ -0,0 +1,100 @@
+package geography
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/blob"
+	"github.com/voidshard/genesis/internal/config"
+	"github.com/voidshard/genesis/internal/database"
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+const (
+	// a world small enough that steps run quickly (a multiple of OceanCurrentGridSize)
+	testWidth  = 300
+	testHeight = 200
+)
+
+// testEditor returns an editor keeping everything in memory, holding a small world
+// (if `p` is nil) & a context seeded so steps make the same thing each run
+func testEditor(t *testing.T, p *types.Project) (context.Context, *Editor, *types.Project) {
+	if p == nil {
+		p = &types.Project{}
+	}
+	p.Name = "test"
+	p.ID = dbutils.NewID(p.Name)
+	if p.WorldWidth == 0 {
+		p.WorldWidth, p.WorldHeight = testWidth, testHeight
+	}
+
+	db, err := database.NewSqlite3(&config.Database{
+		Driver: config.DatabaseDriverSQLite,
+		Name:   config.DatabaseNameMemory,
+	})
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.SetProjects([]*types.Project{p}))
+	assert.Nil(t, tx.Commit())
+
+	cfg := &config.Config{}
+	cfg.Gen.Root = t.TempDir()
+
+	e := New(cfg, db, blob.NewMemory(), DefaultSettings())
+	return WithSeed(context.Background(), 1), e, p
+}
+
+// testWorld returns an editor holding a small world with tectonics & a sea map
+func testWorld(t *testing.T, p *types.Project) (context.Context, *Editor, *types.Project) {
+	ctx, e, p := testEditor(t, p)
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 60))
+	_, _, err := e.SeaMap(ctx, p.ID, 100, 30, 30, 2)
+	assert.Nil(t, err)
+	return ctx, e, p
+}
+
+// heights returns the whole heightmap of the world
+func heights(t *testing.T, ctx context.Context, e *Editor, p *types.Project) image.Image {
+	im, err := e.HeightMap(ctx, p.ID, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	assert.Nil(t, err)
+	return im
+}
+
+func TestCachedHeightmap(t *testing.T) {
+	ctx, e, p := testWorld(t, nil)
+	area := image.Rect(0, 0, p.WorldWidth, p.WorldHeight)
+	e.hmap = map[hmapKey]image.Image{} // the sea map caches one
+
+	// failures aren't cached
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := e.cachedHeightmap(cctx, p.ID, area)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, len(e.hmap))
+
+	first, err := e.cachedHeightmap(ctx, p.ID, area)
+	assert.Nil(t, err)
+	again, err := e.cachedHeightmap(ctx, p.ID, area)
+	assert.Nil(t, err)
+	assert.Equal(t, first, again)
+
+	// another project with the same area has its own heightmap
+	other := &types.Project{ID: dbutils.NewID("other"), Name: "other", WorldWidth: p.WorldWidth, WorldHeight: p.WorldHeight}
+	tx, err := e.db.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.SetProjects([]*types.Project{other}))
+	assert.Nil(t, tx.Commit())
+	assert.Nil(t, e.CreateTectonics(WithSeed(context.Background(), 2), other.ID, 0.5, 60))
+
+	second, err := e.cachedHeightmap(ctx, other.ID, area)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, 2, len(e.hmap))
+}
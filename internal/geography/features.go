@@ -0,0 +1,165 @@
+package geography
+
+import (
+	"image"
+	"strings"
+
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/vector"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// Features returns vector features of the current epoch;
+// - coastlines of each landmass (implies SeaMap)
+// - mountain ranges, ravines, volcanoes & glaciers (from graph tags)
+func (e *Editor) Features(proj string) ([]*vector.Feature, error) {
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	sea, err := pnt.Canvas(p.Canvas(tagSea))
+	if err != nil {
+		return nil, err
+	}
+
+	features, err := e.coastlines(p, sea)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged, err := e.TaggedFeatures(proj)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(features, tagged...), nil
+}
+
+// TaggedFeatures returns mountain ranges, ravines, volcanoes & glaciers (of the current
+// epoch, see Cryosphere) recorded as graph tags.
+// This is Features without (the relatively expensive) coastlines.
+func (e *Editor) TaggedFeatures(proj string) ([]*vector.Feature, error) {
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+
+	voro := voronoi.New(e.store, p.WorldWidth, p.WorldHeight)
+	graph, err := e.cachedGraph(voro, p.VoronoiDiagram())
+	if err != nil {
+		return nil, err
+	}
+
+	features := []*vector.Feature{}
+	for _, name := range graph.TagNames() {
+		kind := graph.TagKind(name)
+		pts, _ := graph.FromTag(name)
+		if len(pts) == 0 {
+			continue
+		}
+
+		f := &vector.Feature{Kind: kind, Name: name}
+		switch kind {
+		case tagMountains, tagRavines:
+			f.Line = vector.FromImagePoints(pts)
+		case tagVolcanoes:
+			f.Points = vector.FromImagePoints(pts)
+		case tagGlaciers:
+			if !strings.HasPrefix(name, glacierPrefix(p.Epoch)) {
+				continue // melted long ago
+			}
+			f.Line = vector.FromImagePoints(pts)
+		default:
+			continue // not something we know how to draw
+		}
+		features = append(features, f)
+	}
+
+	return features, nil
+}
+
+// coastlines traces the outline of each landmass on the sea map.
+//
+// Landmasses are painted onto the sea map by determineLand (with blue 0 and the
+// landmass number in red & green) so we first find the bounds of each, then trace
+// only within it's bounds.
+func (e *Editor) coastlines(p *types.Project, sea paint.Canvas) ([]*vector.Feature, error) {
+	byColor := map[uint16]*types.Landmass{}
+	tkn := ""
+	for {
+		found, next, err := e.db.ListLandmasses(p.ID, tkn)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range found {
+			if l.Epoch != p.Epoch {
+				continue
+			}
+			byColor[combineUint16(uint8(l.ColorR), uint8(l.ColorG))] = l
+		}
+		if next == "" {
+			break
+		}
+		tkn = next
+	}
+
+	seamap, err := paint.Image(sea)
+	if err != nil {
+		return nil, err
+	}
+
+	bnds := seamap.Bounds()
+	areas := map[uint16]image.Rectangle{}
+	for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+		for x := bnds.Min.X; x < bnds.Max.X; x++ {
+			num, ok := landAt(seamap, x, y)
+			if !ok {
+				continue
+			}
+			px := image.Rect(x, y, x+1, y+1)
+			r, ok := areas[num]
+			if ok {
+				px = r.Union(px)
+			}
+			areas[num] = px
+		}
+	}
+
+	features := []*vector.Feature{}
+	for num, area := range areas {
+		rings := vector.Contours(area, func(x, y int) bool {
+			found, ok := landAt(seamap, x, y)
+			return ok && found == num
+		})
+		if len(rings) == 0 {
+			continue
+		}
+		for i, r := range rings {
+			rings[i] = vector.Simplify(r, e.set.VectorSimplifyTolerance)
+		}
+
+		f := &vector.Feature{
+			Kind:       tagCoastline,
+			Rings:      rings,
+			Properties: map[string]interface{}{"landmass": int(num)},
+		}
+		l, ok := byColor[num]
+		if ok {
+			f.Properties["landmass_id"] = l.ID
+			f.Properties["size"] = l.Size
+		}
+		features = append(features, f)
+	}
+
+	return features, nil
+}
+
+// landAt returns the number of the landmass at some pixel of the sea map, if the
+// pixel is land at all
+func landAt(seamap image.Image, x, y int) (uint16, bool) {
+	r, g, b, _ := seamap.At(x, y).RGBA()
+	return combineUint16(uint8(r>>8), uint8(g>>8)), b == 0
+}
@@ -1,9 +1,12 @@
 package geography
 
 import (
+	"context"
+	"fmt"
 	"image"
 	"image/color"
 	"math/rand"
+	"sort"
 
 	"github.com/voidshard/genesis/internal/paint"
 	"github.com/voidshard/genesis/internal/voronoi"
@@ -19,16 +22,19 @@ type graphOperation struct {
 
 	from    image.Point
 	to      image.Point
+	route   *voronoi.Route // Weights is filled in when we know what we're pathing for
 	maxDist float64
+
+	rng *rand.Rand // for anything random the operation does (see WithSeed)
 }
 
-func (e *Editor) newGraphOp(proj string, s *types.PathSpec) (*graphOperation, error) {
+func (e *Editor) newGraphOp(ctx context.Context, proj string, s *types.PathSpec) (*graphOperation, error) {
 	p, err := e.project(proj)
 	if err != nil {
 		return nil, err
 	}
 
-	voro := voronoi.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	voro := voronoi.New(e.store, p.WorldWidth, p.WorldHeight)
 	graph, err := e.cachedGraph(voro, p.VoronoiDiagram())
 	if err != nil {
 		return nil, err
@@ -38,18 +44,24 @@ func (e *Editor) newGraphOp(proj string, s *types.PathSpec) (*graphOperation, er
 		s = &types.PathSpec{MaxDist: float64(p.WorldWidth+p.WorldHeight) / 2 / 3}
 	}
 
-	pnt := paint.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
 
 	// use given from / to or pick random points not too close to the edge
-	pointA := graph.RandomPoint()
+	rng := random(ctx)
+	pointA := graph.RandomPoint(rng)
 	if s.From != nil {
 		pointA = graph.ClosestPoint(*s.From)
 	}
-	pointB := graph.RandomPoint()
+	pointB := graph.RandomPoint(rng)
 	if s.To != nil {
 		pointB = graph.ClosestPoint(*s.To)
 	}
 
+	route := &voronoi.Route{Weights: s.Weights, Avoid: s.Avoid}
+	for _, v := range s.Via {
+		route.Via = append(route.Via, graph.ClosestPoint(v))
+	}
+
 	return &graphOperation{
 		p:       p,
 		voro:    voro,
@@ -57,20 +69,50 @@ func (e *Editor) newGraphOp(proj string, s *types.PathSpec) (*graphOperation, er
 		pnt:     pnt,
 		from:    pointA,
 		to:      pointB,
+		route:   route,
 		maxDist: s.MaxDist,
+		rng:     rng,
 	}, nil
 }
 
-func (e *Editor) newVoronoiNoise(p *types.Project, pnt paint.Painter, voro voronoi.Voronoi, points int) (voronoi.Graph, paint.Canvas, error) {
-	seed := rand.Int63()
+// path finds the path for the operation (trimmed to max dist), using the `tag`
+// weight unless told otherwise
+func (op *graphOperation) path(tag string) ([]image.Point, error) {
+	route := *op.route
+	if len(route.Weights) == 0 {
+		route.Weights = map[string]float64{tag: 1}
+	}
+
+	path, err := op.graph.Route(&route, op.from, op.to)
+	if err != nil {
+		return nil, fmt.Errorf("%w %v", ErrNoPath, err)
+	}
+	path = trimPath(op.p, path, op.maxDist)
+	if len(path) < 2 {
+		return nil, fmt.Errorf("%w path too short", ErrNoPath)
+	}
+	return path, nil
+}
+
+// stagedVoronoi returns graph storage whose writes are made only when the stage is
+// committed, so graphs & the canvases drawn from them are written together
+func stagedVoronoi(p *types.Project, stage paint.Stage) voronoi.Voronoi {
+	return voronoi.New(stage.Store(), p.WorldWidth, p.WorldHeight)
+}
+
+// newVoronoiNoise builds a new voronoi diagram & noise canvas from it (neither are saved)
+func (e *Editor) newVoronoiNoise(ctx context.Context, rng *rand.Rand, p *types.Project, pnt paint.Painter, voro voronoi.Voronoi, points int) (voronoi.Graph, paint.Canvas, error) {
+	seed := rng.Int63()
 
 	// build voronoi diagram
 	diag, err := voro.NewGraph(
+		ctx,
 		p.VoronoiDiagram(),
 		voroWeights,
 		e.set.GraphDefaultWeight,
 		points,
 		seed,
+		shape(p),
 	)
 	if err != nil {
 		return nil, nil, err
@@ -82,21 +124,24 @@ func (e *Editor) newVoronoiNoise(p *types.Project, pnt paint.Painter, voro voron
 
 	for i := 0; i < int(float64(len(diag.Sites()))*e.set.NoiseFractalSegments); i++ {
 		// pick random cells to elevate
-		c := diag.RandomCell()
+		c := diag.RandomCell(rng)
 		highPoints[c.ID()] = c
 		cells[c.ID()] = c
 	}
 
 	// mark cells & neighbouring cells for elevation
 	cellDeltas := map[int]int{}
-	for _, middle := range highPoints {
-		next := []*voronoi.Cell{middle}
+	for _, id := range sortedIDs(highPoints) {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		next := []*voronoi.Cell{highPoints[id]}
 		for i := 0; i < e.set.NoiseFractalIterations; i++ {
 			ns, err := diag.NeighbouringCells(next)
 			if err != nil {
 				return nil, nil, err
 			}
-			delta := rand.Intn(10) + 5
+			delta := rng.Intn(10) + 5
 			for _, c := range append(next, ns...) {
 				d, _ := cellDeltas[c.ID()]
 				cellDeltas[c.ID()] = d + delta
@@ -111,22 +156,60 @@ func (e *Editor) newVoronoiNoise(p *types.Project, pnt paint.Painter, voro voron
 	if err != nil {
 		return nil, nil, err
 	}
-	for id, delta := range cellDeltas {
+	for _, id := range sortedIDs(cells) {
+		delta, ok := cellDeltas[id]
+		if !ok {
+			continue
+		}
 		cell, ok := cells[id]
 		if !ok { // ??
 			continue
 		}
 		d8 := uint8(delta)
-		vnoise.Polygon(cell.Edges(), color.RGBA{d8, d8, d8, 255})
+		err = wrapped(p, vnoise).Polygon(cell.Edges(), color.RGBA{d8, d8, d8, 255})
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// and smooth so it's not quite so blocky
-	err = vnoise.Smooth(6)
+	vnoise, err = smoothCanvas(p, pnt, vnoise, 6)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	err = pnt.Save(vnoise)
+	return diag, vnoise, ctx.Err()
+}
+
+// sortedIDs returns the IDs of some cells in order, so they're gone over the same way
+// each time (rather than in map order)
+func sortedIDs(cells map[int]*voronoi.Cell) []int {
+	ids := make([]int, 0, len(cells))
+	for id := range cells {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
 
-	return diag, vnoise, err
+// featureTag returns `tag` or, if not given, a new unique tag for a feature of
+// the given kind (eg. mountains/3)
+func featureTag(graph voronoi.Graph, kind, tag string) string {
+	if tag != "" {
+		return tag
+	}
+	n := 0
+	for _, t := range graph.TagNames() {
+		if graph.TagKind(t) == kind {
+			n++
+		}
+	}
+	for {
+		candidate := fmt.Sprintf("%s/%d", kind, n)
+		_, taken := graph.FromTag(candidate)
+		if !taken {
+			return candidate
+		}
+		n++
+	}
 }
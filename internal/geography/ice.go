@@ -0,0 +1,328 @@
+package geography
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+const (
+	// glacierStep is how far (pixels) a glacier moves downhill at a time
+	glacierStep = 2
+
+	// iceSnow marks land that's only icy because it's high up (on the ice canvas)
+	iceSnow = 128
+)
+
+// Cryosphere works out where there's ice all year round; sea ice, ice caps over land
+// & snow above the snowline on mountains. Glaciers flow down from snowy mountains until
+// it gets too warm, cutting U shaped troughs into the terrain. Troughs are kept between
+// epochs, so glaciers that return to the same valleys deepen them.
+//
+// Ice is saved as a canvas; red is sea ice, green land ice (iceSnow for snow above the
+// snowline) & blue is glaciers. Glaciers are tagged as features (see TaggedFeatures).
+//
+// Uses temperatures from Seasons if they've been worked out, otherwise the summer
+// solstices. Implies SeaMap.
+func (e *Editor) Cryosphere(ctx context.Context, proj string) (image.Image, error) {
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	voro := voronoi.New(e.store, p.WorldWidth, p.WorldHeight)
+	graph, err := e.cachedGraph(voro, p.VoronoiDiagram())
+	if err != nil {
+		return nil, err
+	}
+
+	sea, err := pnt.Canvas(p.Canvas(tagSea))
+	if err != nil {
+		return nil, err
+	}
+	seamap, err := paint.Image(sea)
+	if err != nil {
+		return nil, err
+	}
+	mountains, err := canvasImage(ctx, p, pnt, tagMountains)
+	if err != nil {
+		return nil, err
+	}
+	heights, err := e.windHeights(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	level := landLevel(seamap, heights)
+
+	err = progress.Report(ctx, "cryosphere", 0, 3, "stages")
+	if err != nil {
+		return nil, err
+	}
+	warmest, err := e.warmest(p, pnt, seamap, heights, level)
+	if err != nil {
+		return nil, err
+	}
+
+	// ice sheets, sea ice & snow
+	im := image.NewRGBA(image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			t := warmest[y*p.WorldWidth+x]
+			c := color.RGBA{A: 255}
+			if isSea(seamap, x, y) {
+				if t < e.set.SeaIceTemperature {
+					c.R = 255
+				}
+			} else if t < e.set.IceTemperature {
+				c.G = 255
+				// it'd be warm enough were it not so high
+				h := grey(heights, x, y)
+				if grey(mountains, x, y) > 0 && h > level && t+float64(h-level)*e.set.TemperatureLapse >= e.set.IceTemperature {
+					c.G = iceSnow
+				}
+			}
+			im.SetRGBA(x, y, c)
+		}
+	}
+
+	err = progress.Report(ctx, "cryosphere", 1, 3, "stages")
+	if err != nil {
+		return nil, err
+	}
+	stage, err := pnt.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Rollback() // noop once committed
+
+	// glaciers of this epoch replace any we worked out before
+	prefix := glacierPrefix(p.Epoch)
+	for _, name := range graph.TagNames() {
+		if graph.TagKind(name) == tagGlaciers && strings.HasPrefix(name, prefix) {
+			graph.TagAs(tagGlaciers, name, nil)
+		}
+	}
+
+	cut, err := stage.NewCanvas(p.Canvas(tagGlaciers + "-cut")) // never saved
+	if err != nil {
+		return nil, err
+	}
+	floor := uint8(255 * math.Max(0, math.Min(1, e.set.GlacierDepth)))
+	rng := random(ctx)
+	for i, path := range e.glacierPaths(p, graph, im, seamap, mountains, heights, warmest) {
+		width := e.set.GlacierWidth.RollWith(rng)
+		err = wrapped(p, cut).Channel(path, width, e.set.GlacierDepth, paint.Convex)
+		if err != nil {
+			return nil, err
+		}
+		err = wrapped(p, cut).Line(path, maxInt(1, width/2), color.RGBA{floor, floor, floor, 255}) // flat bottomed
+		if err != nil {
+			return nil, err
+		}
+		graph.TagAs(tagGlaciers, fmt.Sprintf("%s%d", prefix, i), path)
+	}
+
+	err = progress.Report(ctx, "cryosphere", 2, 3, "stages")
+	if err != nil {
+		return nil, err
+	}
+	glaciers, err := stage.Canvas(p.Canvas(tagGlaciers))
+	if err != nil {
+		return nil, err
+	}
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			v, err := cut.R(x, y)
+			if err != nil {
+				return nil, err
+			}
+			if v == 0 {
+				continue
+			}
+			if v >= floor {
+				im.Pix[im.PixOffset(x, y)+2] = 255
+			}
+			was, err := glaciers.R(x, y)
+			if err != nil {
+				return nil, err
+			}
+			deeper := incrUint8(was, float64(v))
+			err = glaciers.Set(x, y, color.RGBA{deeper, deeper, deeper, 255})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ice, err := stage.NewCanvasFromImage(p.Canvas(tagIce), im)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	err = stage.Save(ice)
+	if err != nil {
+		return nil, err
+	}
+	err = stage.Save(glaciers)
+	if err != nil {
+		return nil, err
+	}
+	err = stagedVoronoi(p, stage).Save(graph)
+	if err != nil {
+		return nil, err
+	}
+	err = stage.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	progress.Report(ctx, "cryosphere", 3, 3, "stages")
+	return im, nil
+}
+
+// glacierPaths finds where glaciers go; they start on icy mountains (highest first)
+// at least GlacierSpacing apart & flow downhill until they melt, reach the sea or
+// run into another glacier.
+func (e *Editor) glacierPaths(p *types.Project, graph voronoi.Graph, ice *image.RGBA, seamap, mountains, hmap image.Image, warmest []float64) [][]image.Point {
+	starts := []image.Point{}
+	for _, at := range graph.Points() {
+		if ice.RGBAAt(at.X, at.Y).G > 0 && grey(mountains, at.X, at.Y) > 0 {
+			starts = append(starts, at)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return grey(hmap, starts[i].X, starts[i].Y) > grey(hmap, starts[j].X, starts[j].Y)
+	})
+
+	claimed := map[image.Point]bool{} // by glacierStep sized cells
+	cell := func(at image.Point) image.Point {
+		return image.Pt(at.X/glacierStep, at.Y/glacierStep)
+	}
+
+	paths := [][]image.Point{}
+	started := []image.Point{}
+	for _, start := range starts {
+		if claimed[cell(start)] {
+			continue
+		}
+		near := false
+		for _, other := range started {
+			if distance(p, start, other) < float64(e.set.GlacierSpacing) {
+				near = true
+				break
+			}
+		}
+		if near {
+			continue
+		}
+
+		path := []image.Point{start}
+		at := start
+		for len(path)*glacierStep < e.set.GlacierMaxLength {
+			next, ok := downhill(p, hmap, at)
+			if !ok {
+				break // a hollow, the ice pools here
+			}
+			at = next
+			path = append(path, at)
+			if claimed[cell(at)] || isSea(seamap, at.X, at.Y) || warmest[at.Y*p.WorldWidth+at.X] > e.set.GlacierMeltTemperature {
+				break
+			}
+		}
+		if len(path)*glacierStep < e.set.GlacierMinLength {
+			continue
+		}
+
+		for _, at := range path {
+			claimed[cell(at)] = true
+		}
+		started = append(started, start)
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// downhill returns the lowest point glacierStep away from `at`, if it's lower than `at`
+func downhill(p *types.Project, hmap image.Image, at image.Point) (image.Point, bool) {
+	best, lowest := at, grey(hmap, at.X, at.Y)
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			x, ok := neighbourX(p, at.X+dx*glacierStep)
+			y := at.Y + dy*glacierStep
+			if !ok || y < 0 || y >= p.WorldHeight {
+				continue
+			}
+			if h := grey(hmap, x, y); h < lowest {
+				best, lowest = image.Pt(x, y), h
+			}
+		}
+	}
+	return best, best != at
+}
+
+// warmest returns the highest temperature (celsius) over the year at each pixel (by
+// y * width + x). We use Seasons if it's been called, otherwise the summer solstices.
+func (e *Editor) warmest(p *types.Project, pnt paint.Painter, seamap, hmap image.Image, level uint8) ([]float64, error) {
+	temps := []paint.Canvas{}
+	for i := 0; ; i++ {
+		wind, err := pnt.Canvas(p.Canvas(seasonTag(tagWind, i)))
+		if err != nil {
+			return nil, err
+		}
+		made, err := windMade(wind)
+		if err != nil {
+			return nil, err
+		}
+		if !made {
+			break
+		}
+		temp, err := pnt.Canvas(p.Canvas(seasonTag(tagTemperature, i)))
+		if err != nil {
+			return nil, err
+		}
+		temps = append(temps, temp)
+	}
+	if len(temps) == 0 {
+		tilt := e.set.SeasonTilt * math.Pi / 180
+		for i, sun := range []float64{tilt, -tilt} {
+			temp, err := e.temperatureCanvas(p, pnt, p.Canvas(seasonTag(tagTemperature, i)), seamap, hmap, level, sun)
+			if err != nil {
+				return nil, err
+			}
+			temps = append(temps, temp)
+		}
+	}
+
+	out := make([]float64, p.WorldWidth*p.WorldHeight)
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			t := math.Inf(-1)
+			for _, temp := range temps {
+				v, err := temperatureAt(temp, x, y)
+				if err != nil {
+					return nil, err
+				}
+				t = math.Max(t, v)
+			}
+			out[y*p.WorldWidth+x] = t
+		}
+	}
+	return out, nil
+}
+
+// glacierPrefix is the start of the tags of glaciers of an epoch
+func glacierPrefix(epoch int) string {
+	return fmt.Sprintf("%s/%d/", tagGlaciers, epoch)
+}
@@ -0,0 +1,136 @@
+package geography
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func TestDownhill(t *testing.T) {
+	p := &types.Project{WorldWidth: 50, WorldHeight: 20}
+	hmap := slope(50, 20, 0, 50000) // rising to the east
+
+	next, ok := downhill(p, hmap, image.Pt(10, 10))
+	assert.True(t, ok)
+	assert.Equal(t, 10-glacierStep, next.X)
+
+	// nowhere lower to go on the western edge
+	_, ok = downhill(p, hmap, image.Pt(0, 10))
+	assert.False(t, ok)
+
+	// or in a hollow
+	flat := image.NewGray(image.Rect(0, 0, 50, 20))
+	_, ok = downhill(p, flat, image.Pt(10, 10))
+	assert.False(t, ok)
+}
+
+// glacierWorld has land in the (cold) north with mountains well inland of it's coasts
+func glacierWorld(t *testing.T) (context.Context, *Editor, *types.Project) {
+	ctx, e, p := testEditor(t, nil)
+
+	sketch := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			c := types.SketchSea
+			if y < 12 && x >= 3 && x < 27 {
+				c = types.SketchLand
+			}
+			if y >= 3 && y < 7 && x >= 10 && x < 20 {
+				c = types.SketchMountain
+			}
+			sketch.Set(x, y, c)
+		}
+	}
+	assert.Nil(t, e.ImportSketch(ctx, p.ID, sketch, &types.ImportOptions{Points: 60}))
+	_, _, err := e.SeaMap(ctx, p.ID, (e.set.SketchSeaHeight+e.set.SketchLandHeight)/2, 30, 30, 2)
+	assert.Nil(t, err)
+
+	return ctx, e, p
+}
+
+func TestCryosphere(t *testing.T) {
+	ctx, e, p := glacierWorld(t)
+	before := heights(t, ctx, e, p)
+
+	im, err := e.Cryosphere(ctx, p.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, p.WorldWidth, im.Bounds().Dx())
+
+	at := func(x, y int) color.RGBA {
+		return color.RGBAModel.Convert(im.At(x, y)).(color.RGBA)
+	}
+
+	// the sea freezes at the poles, but not the equator
+	assert.Equal(t, uint8(255), at(150, p.WorldHeight-1).R)
+	assert.Equal(t, uint8(0), at(10, 100).R)
+
+	// nor does the land
+	for x := 0; x < p.WorldWidth; x++ {
+		assert.Equal(t, uint8(0), at(x, 100).G, x)
+	}
+
+	// there's snow on the mountains
+	snow := 0
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			if at(x, y).G == iceSnow {
+				snow++
+			}
+		}
+	}
+	assert.Greater(t, snow, 0)
+
+	// glaciers flow down from them, cutting troughs as they go
+	glaciers := func() map[string][]image.Point {
+		graph, err := e.cachedGraph(voronoi.New(e.store, p.WorldWidth, p.WorldHeight), p.VoronoiDiagram())
+		assert.Nil(t, err)
+		found := map[string][]image.Point{}
+		for _, name := range graph.TagNames() {
+			pts, _ := graph.FromTag(name)
+			if graph.TagKind(name) == tagGlaciers && len(pts) > 0 {
+				assert.True(t, strings.HasPrefix(name, glacierPrefix(p.Epoch)), name)
+				found[name] = pts
+			}
+		}
+		return found
+	}
+	first := glaciers()
+	assert.Greater(t, len(first), 0)
+
+	after := heights(t, ctx, e, p)
+	for name, path := range first {
+		assert.GreaterOrEqual(t, len(path)*glacierStep, e.set.GlacierMinLength, name)
+		start := path[0]
+		assert.Equal(t, uint8(255), at(start.X, start.Y).B, name)
+		for _, pt := range path {
+			assert.LessOrEqual(t, grey(after, pt.X, pt.Y), grey(before, pt.X, pt.Y), name, pt)
+		}
+	}
+
+	features, err := e.TaggedFeatures(p.ID)
+	assert.Nil(t, err)
+	tagged := 0
+	for _, f := range features {
+		if f.Kind == tagGlaciers {
+			tagged++
+		}
+	}
+	assert.Equal(t, len(first), tagged)
+
+	// working them out again replaces the glaciers we had (rather than adding to them)
+	_, err = e.Cryosphere(ctx, p.ID)
+	assert.Nil(t, err)
+	second := glaciers()
+	assert.Greater(t, len(second), 0)
+	for i := 0; i < len(second); i++ {
+		assert.Contains(t, second, fmt.Sprintf("%s%d", glacierPrefix(p.Epoch), i))
+	}
+}
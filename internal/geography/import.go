@@ -0,0 +1,247 @@
+package geography
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/nfnt/resize"
+
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+const (
+	// importBroadRadius is the smoothing radius used to pull broad shapes (hills,
+	// plains, sea floor) out of imported terrain. These go into the perlin noise
+	// canvas & the rest (peaks, ridges) into mountains.
+	importBroadRadius = 20
+
+	// sketchNoiseScale is the scale of noise added over a sketch
+	sketchNoiseScale = 0.3
+)
+
+// ImportHeightmap sets up a project (in place of CreateTectonics) with terrain from a
+// heightmap. The image is resized to fit the world & can be 8 or 16 bit grey (other
+// images are read by their luminance).
+func (e *Editor) ImportHeightmap(ctx context.Context, proj string, im image.Image, opts *types.ImportOptions) error {
+	p, err := e.project(proj)
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &types.ImportOptions{}
+	}
+
+	err = progress.Report(ctx, "import", 0, 3, "stages")
+	if err != nil {
+		return err
+	}
+
+	heights := resampleHeights(im, p.WorldWidth, p.WorldHeight, opts.Stretch)
+	return e.importHeights(ctx, p, heights, opts.Points)
+}
+
+// ImportSketch sets up a project (in place of CreateTectonics) from a rough drawing of
+// land, sea & mountains (see types.SketchLand etc). The image is resized to fit the
+// world. Heights for each are set in Settings, with slopes & noise added between them
+// so it doesn't look drawn.
+func (e *Editor) ImportSketch(ctx context.Context, proj string, im image.Image, opts *types.ImportOptions) error {
+	p, err := e.project(proj)
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &types.ImportOptions{}
+	}
+
+	err = progress.Report(ctx, "import", 0, 3, "stages")
+	if err != nil {
+		return err
+	}
+
+	heights, err := e.sketchHeights(random(ctx), p, im)
+	if err != nil {
+		return err
+	}
+	return e.importHeights(ctx, p, heights, opts.Points)
+}
+
+// importHeights splits a world sized heightmap into the canvases a heightmap is built
+// from & builds a graph weighted by it, as CreateTectonics would from noise.
+// Other terrain (eg. ravines, manual edits) of the current epoch is left as is.
+func (e *Editor) importHeights(ctx context.Context, p *types.Project, heights *image.Gray, points int) error {
+	if e.set.HeightMapMountainWeight <= 0 {
+		return fmt.Errorf("import requires a positive mountain weight, got %f", e.set.HeightMapMountainWeight)
+	}
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	voro := voronoi.New(e.store, p.WorldWidth, p.WorldHeight)
+
+	stage, err := pnt.Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
+
+	diag, err := voro.NewGraph(ctx, p.VoronoiDiagram(), voroWeights, e.set.GraphDefaultWeight, points, random(ctx).Int63(), shape(p))
+	if err != nil {
+		return err
+	}
+
+	err = progress.Report(ctx, "import", 1, 3, "stages")
+	if err != nil {
+		return err
+	}
+
+	// broad shapes are the (smooth) noise, whatever is left over are mountains
+	broad, err := smoothed(p, heights, importBroadRadius)
+	if err != nil {
+		return err
+	}
+	bnds := heights.Bounds()
+	mountains := image.NewGray(bnds)
+	for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		for x := bnds.Min.X; x < bnds.Max.X; x++ {
+			left := float64(heights.GrayAt(x, y).Y) - float64(grey(broad, x, y))*e.set.HeightMapNoisePerlinWeight
+			mountains.SetGray(x, y, color.Gray{Y: forceUint8(int(math.Round(left / e.set.HeightMapMountainWeight)))})
+		}
+	}
+
+	// on the same scale as smooth noise in CreateTectonics
+	err = e.weightTectonics(p, diag, func(at image.Point) int {
+		return int(heights.GrayAt(at.X, at.Y).Y) / 2
+	})
+	if err != nil {
+		return err
+	}
+
+	err = progress.Report(ctx, "import", 2, 3, "stages")
+	if err != nil {
+		return err
+	}
+
+	canvases := map[string]image.Image{
+		tagPerlin:    broad,
+		tagVoro:      image.NewGray(bnds), // there's no fractal noise in imported terrain
+		tagMountains: mountains,
+	}
+	for name, im := range canvases {
+		cnv, err := stage.NewCanvasFromImage(p.Canvas(name), im)
+		if err != nil {
+			return err
+		}
+		err = stage.Save(cnv)
+		if err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	err = stagedVoronoi(p, stage).Save(diag)
+	if err != nil {
+		return err
+	}
+	err = stage.Commit()
+	if err != nil {
+		return err
+	}
+	e.graph = diag
+	e.hmap = map[hmapKey]image.Image{}
+
+	return progress.Report(ctx, "import", 3, 3, "stages")
+}
+
+// resampleHeights resizes a heightmap to width x height & reduces it to 8 bits,
+// optionally stretching values to use the full range.
+func resampleHeights(im image.Image, width, height int, stretch bool) *image.Gray {
+	scaled := resize.Resize(uint(width), uint(height), im, resize.Bilinear)
+	bnds := scaled.Bounds()
+
+	values := make([]uint16, width*height)
+	lo, hi := uint16(math.MaxUint16), uint16(0)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := color.Gray16Model.Convert(scaled.At(bnds.Min.X+x, bnds.Min.Y+y)).(color.Gray16).Y
+			values[y*width+x] = v
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+	}
+	if !stretch || hi <= lo {
+		lo, hi = 0, math.MaxUint16
+	}
+
+	out := image.NewGray(image.Rect(0, 0, width, height))
+	for i, v := range values {
+		out.Pix[i] = uint8(math.Round(float64(v-lo) * 255 / float64(hi-lo)))
+	}
+	return out
+}
+
+// sketchHeights turns a sketch into a heightmap the size of the world
+func (e *Editor) sketchHeights(rng *rand.Rand, p *types.Project, im image.Image) (*image.Gray, error) {
+	width, height := p.WorldWidth, p.WorldHeight
+
+	// nearest neighbour so we don't blend colours into ones that weren't drawn
+	scaled := resize.Resize(uint(width), uint(height), im, resize.NearestNeighbor)
+	bnds := scaled.Bounds()
+
+	flat := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			flat.Pix[y*width+x] = e.sketchHeight(scaled.At(bnds.Min.X+x, bnds.Min.Y+y))
+		}
+	}
+
+	// slope between land, sea & mountains then roughen it up a bit
+	sloped, err := smoothed(p, flat, e.set.SketchSlope)
+	if err != nil {
+		return nil, err
+	}
+	noise := perlin(rng.Int63(), p, sketchNoiseScale, false)
+
+	out := image.NewGray(flat.Bounds())
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			n := (float64(noise.GrayAt(x, y).Y) - 128) / 128 * float64(e.set.SketchNoise)
+			out.Pix[y*width+x] = forceUint8(int(float64(grey(sloped, x, y)) + n))
+		}
+	}
+	return out, nil
+}
+
+// sketchHeight returns the height of whichever sketch colour `c` is closest to
+func (e *Editor) sketchHeight(c color.Color) uint8 {
+	heights := map[color.RGBA]uint8{
+		types.SketchSea:      e.set.SketchSeaHeight,
+		types.SketchLand:     e.set.SketchLandHeight,
+		types.SketchMountain: e.set.SketchMountainHeight,
+	}
+
+	r, g, b, _ := c.RGBA()
+	best, dist := e.set.SketchSeaHeight, math.Inf(1)
+	for want, h := range heights {
+		d := math.Pow(float64(r>>8)-float64(want.R), 2) +
+			math.Pow(float64(g>>8)-float64(want.G), 2) +
+			math.Pow(float64(b>>8)-float64(want.B), 2)
+		if d < dist {
+			best, dist = h, d
+		}
+	}
+	return best
+}
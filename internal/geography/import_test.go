@@ -0,0 +1,90 @@
+package geography
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// slope returns a 16 bit heightmap rising from `lo` in the west to `hi` in the east
+func slope(w, h int, lo, hi uint16) *image.Gray16 {
+	im := image.NewGray16(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := float64(lo) + float64(hi-lo)*float64(x)/float64(w-1)
+			im.SetGray16(x, y, color.Gray16{Y: uint16(math.Round(v))})
+		}
+	}
+	return im
+}
+
+func TestResampleHeights(t *testing.T) {
+	im := slope(50, 20, 20000, 40000)
+
+	out := resampleHeights(im, 100, 40, false)
+	assert.Equal(t, image.Rect(0, 0, 100, 40), out.Bounds())
+	assert.InDelta(t, 20000*255/math.MaxUint16, int(out.GrayAt(0, 20).Y), 1)
+	assert.InDelta(t, 40000*255/math.MaxUint16, int(out.GrayAt(99, 20).Y), 1)
+
+	stretched := resampleHeights(im, 100, 40, true)
+	assert.Equal(t, uint8(0), stretched.GrayAt(0, 20).Y)
+	assert.Equal(t, uint8(255), stretched.GrayAt(99, 20).Y)
+	assert.Less(t, stretched.GrayAt(40, 20).Y, stretched.GrayAt(60, 20).Y)
+}
+
+func TestImportHeightmap(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+	in := slope(p.WorldWidth/2, p.WorldHeight/2, 0, math.MaxUint16)
+
+	assert.Nil(t, e.ImportHeightmap(ctx, p.ID, in, &types.ImportOptions{Points: 60}))
+
+	// the terrain is split over canvases, but adds back up to what we were given
+	want := resampleHeights(in, p.WorldWidth, p.WorldHeight, false)
+	got := rawHeights(t, ctx, e, p)
+	for _, pt := range []image.Point{{30, 100}, {100, 50}, {150, 100}, {220, 150}, {270, 100}} {
+		assert.InDelta(t, int(want.GrayAt(pt.X, pt.Y).Y), int(grey(got, pt.X, pt.Y)), 3, pt)
+	}
+
+	// & the graph is built over it
+	graph, err := e.cachedGraph(voronoi.New(e.store, p.WorldWidth, p.WorldHeight), p.VoronoiDiagram())
+	assert.Nil(t, err)
+	assert.Greater(t, len(graph.Sites()), 0)
+}
+
+func TestImportSketch(t *testing.T) {
+	ctx, e, p := testEditor(t, nil)
+
+	// sea in the west, land in the east with a mountain in the middle of it
+	sketch := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			c := types.SketchSea
+			if x >= 15 {
+				c = types.SketchLand
+			}
+			if x >= 20 && x < 25 && y >= 8 && y < 12 {
+				c = types.SketchMountain
+			}
+			sketch.Set(x, y, c)
+		}
+	}
+
+	assert.Nil(t, e.ImportSketch(ctx, p.ID, sketch, &types.ImportOptions{Points: 60}))
+
+	got := rawHeights(t, ctx, e, p)
+	sea, land, mountain := grey(got, 40, 100), grey(got, 170, 30), grey(got, 225, 100)
+	assert.Less(t, sea, land)
+	assert.Less(t, land, mountain)
+
+	seamap, lms, err := e.SeaMap(ctx, p.ID, (e.set.SketchSeaHeight+e.set.SketchLandHeight)/2, 30, 30, 2)
+	assert.Nil(t, err)
+	assert.Greater(t, len(lms), 0)
+	assert.True(t, isSea(seamap, 40, 100))
+	assert.False(t, isSea(seamap, 225, 100))
+}
@@ -0,0 +1,193 @@
+package geography
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+var (
+	// ErrUnknownLayer is returned if we're asked for a layer we don't know
+	ErrUnknownLayer = fmt.Errorf("unknown layer")
+
+	// ErrUnknownEpoch is returned if we're asked for an epoch a project hasn't reached
+	ErrUnknownEpoch = fmt.Errorf("unknown epoch")
+
+	// ErrUnknownSeason is returned if we're asked for a season that hasn't been worked out
+	ErrUnknownSeason = fmt.Errorf("unknown season")
+)
+
+// Layer returns `area` of some project data as 16 bit values, suitable for export.
+//
+// Values read from 8 bit canvases are scaled up to fill the 16 bit range, with the exception
+// of the landmass layer which holds landmass numbers (see determineLand).
+func (e *Editor) Layer(proj string, layer types.Layer, area image.Rectangle) (*image.Gray16, error) {
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+	return e.LayerFromEpoch(proj, p.Epoch, layer, area)
+}
+
+// LayerFromEpoch is Layer but reads canvases of the given epoch rather than the current one
+func (e *Editor) LayerFromEpoch(proj string, epoch int, layer types.Layer, area image.Rectangle) (*image.Gray16, error) {
+	current, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+	if epoch < 0 || epoch > current.Epoch {
+		return nil, fmt.Errorf("%w %d (project is at epoch %d)", ErrUnknownEpoch, epoch, current.Epoch)
+	}
+	p := *current
+	p.Epoch = epoch
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+
+	// seasonal layers read the canvases of that season (see Seasons)
+	tag := p.Canvas
+	if base, season, ok := layer.Season(); ok {
+		if !seasonLayers[base] {
+			return nil, fmt.Errorf("%w %s", ErrUnknownLayer, layer)
+		}
+		wind, err := pnt.Canvas(p.Canvas(seasonTag(tagWind, season)))
+		if err != nil {
+			return nil, err
+		}
+		made, err := windMade(wind)
+		if err != nil {
+			return nil, err
+		} else if !made {
+			return nil, fmt.Errorf("%w %d", ErrUnknownSeason, season)
+		}
+		layer = base
+		tag = func(name string) string {
+			return p.Canvas(seasonTag(name, season))
+		}
+	}
+
+	switch layer {
+	case types.LayerHeight:
+		return e.heightMap16(context.Background(), &p, pnt, area)
+	case types.LayerRain:
+		rain, err := pnt.Canvas(tag(tagRain))
+		if err != nil {
+			return nil, err
+		}
+		return band16(rain, area, func(r, g, b uint8) uint16 {
+			return uint16(b) * 257
+		})
+	case types.LayerSeaTemperature:
+		sea, err := pnt.Canvas(p.Canvas(tagSea))
+		if err != nil {
+			return nil, err
+		}
+		return band16(sea, area, func(r, g, b uint8) uint16 {
+			return uint16(b) * 257
+		})
+	case types.LayerLandmass:
+		sea, err := pnt.Canvas(p.Canvas(tagSea))
+		if err != nil {
+			return nil, err
+		}
+		return band16(sea, area, func(r, g, b uint8) uint16 {
+			if b > 0 {
+				return 0 // sea
+			}
+			return combineUint16(r, g)
+		})
+	case types.LayerWindEast, types.LayerWindNorth:
+		wind, err := pnt.Canvas(tag(tagWind))
+		if err != nil {
+			return nil, err
+		}
+		return band16(wind, area, func(r, g, b uint8) uint16 {
+			east, north := decodeWind(r, g)
+			if layer == types.LayerWindNorth {
+				east = north
+			}
+			return uint16(math.Round(32768 + east*32767))
+		})
+	case types.LayerIce:
+		ice, err := pnt.Canvas(p.Canvas(tagIce))
+		if err != nil {
+			return nil, err
+		}
+		return band16(ice, area, func(r, g, b uint8) uint16 {
+			if r > 0 || b > 0 {
+				g = 255
+			}
+			return uint16(g) * 257
+		})
+	case types.LayerDepth:
+		depth, err := pnt.Canvas(p.Canvas(tagDepth))
+		if err != nil {
+			return nil, err
+		}
+		return band16(depth, area, func(r, g, b uint8) uint16 {
+			return uint16(r) * 257
+		})
+	case types.LayerTemperature:
+		temp, err := pnt.Canvas(tag(tagTemperature))
+		if err != nil {
+			return nil, err
+		}
+		return band16(temp, area, func(r, g, b uint8) uint16 {
+			return uint16(b) * 257
+		})
+	case types.LayerTemperatureVariance, types.LayerRainVariance, types.LayerMonsoon:
+		season, err := pnt.Canvas(p.Canvas(tagSeasonality))
+		if err != nil {
+			return nil, err
+		}
+		return band16(season, area, func(r, g, b uint8) uint16 {
+			switch layer {
+			case types.LayerTemperatureVariance:
+				return uint16(r) * 257
+			case types.LayerRainVariance:
+				return uint16(g) * 257
+			}
+			return uint16(b) * 257
+		})
+	}
+
+	return nil, fmt.Errorf("%w %s", ErrUnknownLayer, layer)
+}
+
+// heightMap16 is HeightMap with 16 bits of precision (banding is very obvious
+// when 8 bit heightmaps are used as game terrain)
+func (e *Editor) heightMap16(ctx context.Context, p *types.Project, pnt paint.Painter, area image.Rectangle) (*image.Gray16, error) {
+	weights, err := e.heightMapWeights(p, pnt)
+	if err != nil {
+		return nil, err
+	}
+
+	im, err := pnt.Merge16(ctx, area, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	return paint.SmoothGray16(im, 3), nil
+}
+
+// band16 builds a 16 bit image of `area` (clipped to the canvas) from some func of
+// each pixel's 8 bit red, green & blue values
+func band16(cnv paint.Canvas, area image.Rectangle, value func(r, g, b uint8) uint16) (*image.Gray16, error) {
+	area = area.Intersect(cnv.Bounds())
+	im := image.NewGray16(area)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			c, err := cnv.At(x, y)
+			if err != nil {
+				return nil, err
+			}
+			r, g, b, _ := c.RGBA()
+			im.SetGray16(x, y, color.Gray16{Y: value(uint8(r>>8), uint8(g>>8), uint8(b>>8))})
+		}
+	}
+	return im, nil
+}
@@ -1,33 +1,54 @@
 package geography
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"math"
+	"math/rand"
 	"sync"
 
+	"github.com/voidshard/genesis/internal/globe"
 	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
 	"github.com/voidshard/genesis/pkg/types"
 )
 
+const (
+	// rainStillAir is wind too slow to say which way it's going
+	rainStillAir = 0.02
+
+	// rainMaxStretch is as far as a storm goes east / west in a step near the poles
+	// of a globe
+	rainMaxStretch = 16
+)
+
 type rainData struct {
 	Start     image.Point
 	Area      image.Rectangle
 	Direction types.Heading
 	Moisture  float64
 	Height    uint8
+	Seed      int64 // for the storm's own source of random numbers
 }
 
-func (e *Editor) Rain(proj string, stormMult float64, prevailingWinds []types.Heading) (image.Image, error) {
+// Rain sends storms along the wind (see Wind) that gather moisture over the sea & drop
+// it over land, more so going up mountains (leaving rain shadows behind them).
+// If prevailing winds are given storms instead blow in straight lines, the world being
+// split into horizontal bands (north to south) one per wind.
+func (e *Editor) Rain(ctx context.Context, proj string, stormMult float64, prevailingWinds []types.Heading) (image.Image, error) {
 	p, err := e.project(proj)
 	if err != nil {
 		return nil, err
 	}
-	pnt := paint.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
 
 	if stormMult <= 0 {
 		rain, err := pnt.Canvas(p.Canvas(tagRain))
-		return rain.Image(), err
+		if err != nil {
+			return nil, err
+		}
+		return paint.Image(rain)
 	}
 
 	// we need mountains to know when storms are forced upwards (dumping rain, losing moisture)
@@ -43,93 +64,345 @@ func (e *Editor) Rain(proj string, stormMult float64, prevailingWinds []types.He
 	}
 
 	// current rain map
-	rain, err := pnt.Canvas(p.Canvas(tagRain))
+	stage, err := pnt.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Rollback() // noop once committed
+	rain, err := stage.Canvas(p.Canvas(tagRain))
 	if err != nil {
 		return nil, err
 	}
 
-	wg := &sync.WaitGroup{}
-	work := make(chan *rainData)
+	if len(prevailingWinds) > 0 {
+		err = e.bandStorms(ctx, p, stormMult, prevailingWinds, sea, mountains, rain)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		wind, err := stage.Canvas(p.Canvas(tagWind))
+		if err != nil {
+			return nil, err
+		}
+		made, err := windMade(wind)
+		if err != nil {
+			return nil, err
+		}
+		if !made {
+			heights, err := e.windHeights(ctx, p)
+			if err != nil {
+				return nil, err
+			}
+			wind, err = e.windCanvas(ctx, p, stage, heights, p.Canvas(tagWind), 0)
+			if err != nil {
+				return nil, err
+			}
+			err = stage.Save(wind)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = e.windStorms(ctx, p, stormMult, wind, sea, mountains, rain)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	go func() {
-		defer close(work)
+	err = stage.Save(rain)
+	if err != nil {
+		return nil, err
+	}
+	im, err := paint.Image(rain)
+	if err != nil {
+		return nil, err
+	}
+	return im, stage.Commit()
+}
+
+// windStorms starts storms all over the world that follow the wind for a while
+func (e *Editor) windStorms(ctx context.Context, p *types.Project, stormMult float64, wind, sea, mountains, rain paint.Canvas) error {
+	spacing := e.set.RainfallStormSpacing
+	if spacing < 1 {
+		spacing = 1
+	}
+	rng := random(ctx)
+	storms := []*rainData{}
+	for y := spacing / 2; y < p.WorldHeight; y += spacing {
+		for x := spacing / 2; x < p.WorldWidth; x += spacing {
+			storms = append(storms, &rainData{Start: image.Pt(x, y), Seed: rng.Int63()})
+		}
+	}
+
+	fallen, err := e.stormRoutines(ctx, p, "storms", storms, func(data *rainData, out []float64) error {
+		return e.windStorm(p, data, stormMult, wind, sea, mountains, out)
+	})
+	if err != nil {
+		return err
+	}
+
+	// about steps / spacing^2 storms pass over each pixel, scale so it's about what
+	// one storm would drop
+	share := float64(spacing*spacing) / float64(e.set.RainfallStormSteps)
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			total := fallen[y*p.WorldWidth+x]
+			if total <= 0 {
+				continue
+			}
+			b, err := rain.B(x, y)
+			if err != nil {
+				return err
+			}
+			err = rain.Set(x, y, color.RGBA{0, 0, incrUint8(b, math.Round(total*share)), 255})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// windStorm follows the wind from the storm's start for RainfallStormSteps pixels (or
+// until it blows off the world) adding rain that falls to `fallen` (by y * width + x)
+func (e *Editor) windStorm(p *types.Project, data *rainData, stormMult float64, wind, sea, mountains paint.Canvas, fallen []float64) error {
+	rng := rand.New(rand.NewSource(data.Seed))
+	data.Moisture = float64(e.set.RainfallStormInitMoisture.RollWith(rng))
+
+	x, y := float64(data.Start.X)+0.5, float64(data.Start.Y)+0.5
+	east, north := 0.0, 0.0 // which way we're going, kept through still air
+	for i := 0; i < e.set.RainfallStormSteps; i++ {
+		if p.Wrap {
+			x = math.Mod(x, float64(p.WorldWidth))
+			if x < 0 {
+				x += float64(p.WorldWidth)
+			}
+		}
+		px, py := int(x), int(y)
+		if x < 0 || y < 0 || px >= p.WorldWidth || py >= p.WorldHeight {
+			return nil
+		}
+
+		we, wn, err := windAt(wind, px, py)
+		if err != nil {
+			return err
+		}
+		speed := math.Hypot(we, wn)
+		if speed > rainStillAir {
+			east, north = we/speed, wn/speed
+		} else if east == 0 && north == 0 {
+			return nil // nowhere to go
+		}
+
+		delta, err := e.stormStep(rng, data, sea, mountains, px, py, stormMult)
+		if err != nil {
+			return err
+		}
+		fallen[py*p.WorldWidth+px] += delta
+
+		// move about a pixel over the ground, on a globe that's further along x nearer
+		// the poles
+		stretch := 1.0
+		if p.Sphere() {
+			stretch = math.Min(globe.Stretch(py, p.WorldHeight), rainMaxStretch)
+		}
+		x += east * stretch
+		y -= north
+	}
+	return nil
+}
+
+// stormStep moves a storm over (x, y), it picks up moisture over the sea & drops some
+// over land. We return how much it dropped. Mult scales moisture gained & lost.
+func (e *Editor) stormStep(rng *rand.Rand, data *rainData, sea, mountains paint.Canvas, x, y int, mult float64) (float64, error) {
+	seaTemp, err := sea.B(x, y)
+	if err != nil {
+		return 0, err
+	}
+	height, err := mountains.R(x, y)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		data.Height = height
+	}()
+
+	isLand := seaTemp == 0 // 0 is a reserved value
+	if !isLand {           // eg. we're over the sea
+		// depending on ocean temp, gain moisture
+		if seaTemp >= e.set.OceanWaterVeryWarm {
+			data.Moisture += float64(e.set.RainfallMoistureGainVeryWarmSea.RollWith(rng)) * mult
+		} else if seaTemp >= e.set.OceanWaterWarm {
+			data.Moisture += float64(e.set.RainfallMoistureGainWarmSea.RollWith(rng)) * mult
+		} else if seaTemp >= e.set.OceanWaterCold {
+			data.Moisture += float64(e.set.RainfallMoistureGainColdSea.RollWith(rng)) * mult
+		} else if seaTemp >= e.set.OceanWaterVeryCold {
+			data.Moisture += float64(e.set.RainfallMoistureGainVeryColdSea.RollWith(rng)) * mult
+		}
+		return 0, nil
+	} else if data.Moisture <= 0 { // over land, but the air is dry
+		return 0, nil
+	}
+
+	delta := float64(e.set.RainfallMoistureLossOverLand.RollWith(rng)) * mult
+	if height > data.Height { // going up over mountains
+		delta = float64(e.set.RainfallMoistureLossOverMountains.RollWith(rng)) * mult * float64(height-data.Height)
+	}
+	if delta >= data.Moisture {
+		delta = data.Moisture
+	}
+	data.Moisture -= delta
+	return delta, nil
+}
 
-		if prevailingWinds == nil || len(prevailingWinds) == 0 {
-			prevailingWinds = e.set.RainfallPrevailingWinds
+// bandStorms splits the world into horizontal bands, one per prevailing wind, & blows
+// storms across each in a straight line
+func (e *Editor) bandStorms(ctx context.Context, p *types.Project, stormMult float64, prevailingWinds []types.Heading, sea, mountains, rain paint.Canvas) error {
+	// work out all storm fronts up front, so we can report how far along we are
+	rng := random(ctx)
+	fronts := []*rainData{}
+	sliceHeight := p.WorldHeight / len(prevailingWinds)
+	for i, direction := range prevailingWinds {
+		area := image.Rect(0, i*sliceHeight, p.WorldWidth, (i+1)*sliceHeight)
+		for start := range edgePoints(area, direction.Opposite()) {
+			fronts = append(fronts, &rainData{
+				Start:     start,
+				Area:      area,
+				Direction: direction,
+				Seed:      rng.Int63(),
+			})
 		}
+	}
 
-		sliceHeight := p.WorldHeight / len(prevailingWinds)
+	fallen, err := e.stormRoutines(ctx, p, "storm fronts", fronts, func(data *rainData, out []float64) error {
+		rng := rand.New(rand.NewSource(data.Seed))
+		data.Moisture = float64(e.set.RainfallStormInitMoisture.RollWith(rng))
 
-		for i, direction := range prevailingWinds {
-			area := image.Rect(0, i*sliceHeight, p.WorldWidth, (i+1)*sliceHeight)
-			for start := range edgePoints(area, direction.Opposite()) {
-				work <- &rainData{
-					Start:     start,
-					Area:      area,
-					Direction: direction,
-					Moisture:  float64(e.set.RainfallStormInitMoisture.Roll()),
+		// direction our winds / storm go (pixel dx/dy)
+		dx, dy := data.Direction.RiseRun()
+		area := data.Area
+		storm := data.Start
+		travelled := 0 // east / west, on a wrapping world storms go around (once)
+
+		for {
+			if p.Wrap {
+				if travelled >= p.WorldWidth {
+					break
 				}
+				storm = onWorld(p, storm)
+			}
+			if storm.X < area.Min.X || storm.X >= area.Max.X || storm.Y < area.Min.Y || storm.Y >= area.Max.Y {
+				break
+			}
+
+			// on a globe steps east / west cover less ground nearer the poles
+			mult := stormMult
+			if p.Sphere() && dx != 0 {
+				mult *= math.Hypot(float64(dx)/globe.Stretch(storm.Y, p.WorldHeight), float64(dy)) / math.Hypot(float64(dx), float64(dy))
+			}
+
+			delta, err := e.stormStep(rng, data, sea, mountains, storm.X, storm.Y, mult)
+			if err != nil {
+				return err
+			}
+			out[storm.Y*p.WorldWidth+storm.X] += math.Round(delta)
+
+			storm.X += dx
+			storm.Y += dy
+			travelled += int(math.Abs(float64(dx)))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			total := fallen[y*p.WorldWidth+x]
+			if total <= 0 {
+				continue
+			}
+			b, err := rain.B(x, y)
+			if err != nil {
+				return err
+			}
+			err = rain.Set(x, y, color.RGBA{0, 0, incrUint8(b, total), 255})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stormRoutines runs each storm in one of RainfallCalcRoutines routines & returns the
+// rain they dropped (by y * width + x). Routines keep their own totals (so we don't
+// have to lock) & always get the same storms, so the result doesn't depend on how
+// they're scheduled.
+func (e *Editor) stormRoutines(ctx context.Context, p *types.Project, unit string, storms []*rainData, run func(data *rainData, fallen []float64) error) ([]float64, error) {
+	routines := e.set.RainfallCalcRoutines
+	if routines < 1 {
+		routines = 1
+	}
+
+	errchan := make(chan error)
+	wg := &sync.WaitGroup{}
+	work := make([]chan *rainData, routines)
+	for i := range work {
+		work[i] = make(chan *rainData)
+	}
+
+	go func() {
+		defer func() {
+			for _, w := range work {
+				close(w)
+			}
+		}()
+
+		every := len(storms)/100 + 1
+		for i, data := range storms {
+			if i%every == 0 && progress.Report(ctx, "rain", i, len(storms), unit) != nil {
+				return // cancelled
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case work[i%routines] <- data:
 			}
 		}
 	}()
 
-	for i := 0; i < e.set.RainfallCalcRoutines; i++ {
+	fallen := make([][]float64, routines)
+	for i := range fallen {
+		fallen[i] = make([]float64, p.WorldWidth*p.WorldHeight)
 		wg.Add(1)
-		go func() {
+		go func(work <-chan *rainData, out []float64) {
 			defer wg.Done()
-
+			var err error
 			for data := range work {
-				// direction our winds / storm go (pixel dx/dy)
-				dx, dy := data.Direction.RiseRun()
-				area := data.Area
-				storm := data.Start
-
-				for {
-					if storm.X < area.Min.X || storm.X > area.Max.X || storm.Y < area.Min.Y || storm.Y >= area.Max.Y {
-						break
-					}
-
-					// read current values from maps
-					rainValue := rain.B(storm.X, storm.Y)
-					seaTemp := sea.B(storm.X, storm.Y)
-					height := mountains.R(storm.X, storm.Y)
-
-					isLand := seaTemp == 0 // 0 is a reserved value
-					if !isLand {           // eg. we're over the sea
-						// depending on ocean temp, gain moisture
-						if seaTemp >= e.set.OceanWaterVeryWarm {
-							data.Moisture += float64(e.set.RainfallMoistureGainVeryWarmSea.Roll()) * stormMult
-						} else if seaTemp >= e.set.OceanWaterWarm {
-							data.Moisture += float64(e.set.RainfallMoistureGainWarmSea.Roll()) * stormMult
-						} else if seaTemp >= e.set.OceanWaterCold {
-							data.Moisture += float64(e.set.RainfallMoistureGainColdSea.Roll()) * stormMult
-						} else if seaTemp >= e.set.OceanWaterVeryCold {
-							data.Moisture += float64(e.set.RainfallMoistureGainVeryColdSea.Roll()) * stormMult
-						}
-					} else if data.Moisture > 0 { // over land, air contains moisture
-						delta := float64(e.set.RainfallMoistureLossOverLand.Roll()) * stormMult
-
-						if height >= 0 && height > data.Height { // going up over mountains
-							delta = float64(e.set.RainfallMoistureLossOverMountains.Roll()) * stormMult * float64(height-data.Height)
-						}
-
-						if delta >= data.Moisture {
-							delta = data.Moisture
-						}
-
-						data.Moisture -= delta
-						rain.Set(storm.X, storm.Y, color.RGBA{0, 0, incrUint8(rainValue, math.Round(delta)), 255})
-					}
-
-					data.Height = height
-					storm.X += dx
-					storm.Y += dy
+				if err != nil {
+					continue // drain so the feeder isn't left waiting
 				}
+				err = run(data, out)
 			}
-		}()
+			errchan <- err
+		}(work[i], fallen[i])
 	}
 
-	wg.Wait()
+	err := fanIn(errchan, wg)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	progress.Report(ctx, "rain", len(storms), len(storms), unit)
 
-	return rain.Image(), pnt.Save(rain)
+	total := fallen[0]
+	for _, out := range fallen[1:] {
+		for i, v := range out {
+			total[i] += v
+		}
+	}
+	return total, nil
 }
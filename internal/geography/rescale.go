@@ -0,0 +1,274 @@
+package geography
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/nfnt/resize"
+
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+const (
+	// rescaleDetail is how much (+/-) fine noise is added over stretched perlin noise
+	rescaleDetail = 8
+
+	// rescaleDetailScale is the scale of the fine noise
+	rescaleDetailScale = 0.3
+)
+
+var (
+	// canvases with nothing to redraw them from, which are stretched as they are
+	rescaleStretched = []string{
+		tagCoastLand,
+		tagCoastSea,
+		tagEditRaise,
+		tagEditLower,
+		tagGlaciers,
+		tagDepth,
+	}
+)
+
+// Rescale draws the terrain of the current epoch of `src` into the current epoch of
+// `dst` at dst's size. The graph (with it's weights & tags) is scaled & features
+// (mountains, volcanoes, ravines) are redrawn along their scaled paths rather than
+// stretching pixels. Derived steps (SeaMap, Rain etc) should be called again after.
+func (e *Editor) Rescale(ctx context.Context, src, dst string) error {
+	ps, err := e.project(src)
+	if err != nil {
+		return err
+	}
+	pd, err := e.project(dst)
+	if err != nil {
+		return err
+	}
+	size := (float64(pd.WorldWidth)/float64(ps.WorldWidth) + float64(pd.WorldHeight)/float64(ps.WorldHeight)) / 2
+
+	spnt := paint.New(e.store, e.cfg.Gen.Root, ps.WorldWidth, ps.WorldHeight)
+	dpnt := paint.New(e.store, e.cfg.Gen.Root, pd.WorldWidth, pd.WorldHeight)
+	svoro := voronoi.New(e.store, ps.WorldWidth, ps.WorldHeight)
+
+	err = progress.Report(ctx, "rescale", 0, 4, "stages")
+	if err != nil {
+		return err
+	}
+
+	graph, err := svoro.Graph(ps.VoronoiDiagram())
+	if err != nil {
+		return err
+	}
+	scaled, err := graph.Scale(pd.VoronoiDiagram(), pd.WorldWidth, pd.WorldHeight)
+	if err != nil {
+		return err
+	}
+
+	stage, err := dpnt.Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
+
+	err = progress.Report(ctx, "rescale", 1, 4, "stages")
+	if err != nil {
+		return err
+	}
+	noise, err := e.rescaleNoise(ctx, ps, pd, spnt, stage, graph, scaled)
+	if err != nil {
+		return err
+	}
+
+	err = progress.Report(ctx, "rescale", 2, 4, "stages")
+	if err != nil {
+		return err
+	}
+	features, err := e.rescaleFeatures(ctx, ps, pd, spnt, stage, graph, scaled, size)
+	if err != nil {
+		return err
+	}
+
+	err = progress.Report(ctx, "rescale", 3, 4, "stages")
+	if err != nil {
+		return err
+	}
+	stretched := []paint.Canvas{}
+	for _, name := range rescaleStretched {
+		old, err := canvasImage(ctx, ps, spnt, name)
+		if err != nil {
+			return err
+		}
+		cnv, err := stage.NewCanvasFromImage(
+			pd.Canvas(name),
+			resize.Resize(uint(pd.WorldWidth), uint(pd.WorldHeight), old, resize.Bilinear),
+		)
+		if err != nil {
+			return err
+		}
+		stretched = append(stretched, cnv)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for _, cnv := range append(append(noise, features...), stretched...) {
+		err = stage.Save(cnv)
+		if err != nil {
+			return err
+		}
+	}
+	err = stagedVoronoi(pd, stage).Save(scaled)
+	if err != nil {
+		return err
+	}
+	err = stage.Commit()
+	if err != nil {
+		return err
+	}
+	e.graph = scaled
+	e.hmap = map[hmapKey]image.Image{}
+
+	return progress.Report(ctx, "rescale", 4, 4, "stages")
+}
+
+// rescaleNoise stretches (smooth) perlin noise with a little finer detail over the top &
+// repaints voronoi noise over the scaled cells so they keep sharp edges
+func (e *Editor) rescaleNoise(ctx context.Context, ps, pd *types.Project, spnt paint.Painter, stage paint.Stage, graph, scaled voronoi.Graph) ([]paint.Canvas, error) {
+	pold, err := canvasImage(ctx, ps, spnt, tagPerlin)
+	if err != nil {
+		return nil, err
+	}
+	stretched := resize.Resize(uint(pd.WorldWidth), uint(pd.WorldHeight), pold, resize.Bilinear)
+	detail := perlin(random(ctx).Int63(), pd, rescaleDetailScale, false)
+
+	broad := image.NewGray(image.Rect(0, 0, pd.WorldWidth, pd.WorldHeight))
+	for y := 0; y < pd.WorldHeight; y++ {
+		for x := 0; x < pd.WorldWidth; x++ {
+			n := (float64(detail.GrayAt(x, y).Y) - 128) / 128 * rescaleDetail
+			broad.Pix[broad.PixOffset(x, y)] = forceUint8(int(float64(grey(stretched, x, y)) + n))
+		}
+	}
+	pcnv, err := stage.NewCanvasFromImage(pd.Canvas(tagPerlin), broad)
+	if err != nil {
+		return nil, err
+	}
+
+	vold, err := spnt.Canvas(ps.Canvas(tagVoro))
+	if err != nil {
+		return nil, err
+	}
+	vcnv, err := stage.NewCanvasFromImage(pd.Canvas(tagVoro), image.NewGray(broad.Bounds()))
+	if err != nil {
+		return nil, err
+	}
+	for i, site := range graph.Sites() {
+		v, err := vold.R(site.X, site.Y)
+		if err != nil {
+			return nil, err
+		}
+		if v == 0 {
+			continue
+		}
+		cell := scaled.CellAt(scaled.Sites()[i])
+		if cell == nil {
+			continue
+		}
+		err = wrapped(pd, vcnv).Polygon(cell.Edges(), color.RGBA{v, v, v, 255})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return []paint.Canvas{pcnv, vcnv}, nil
+}
+
+// rescaleFeatures redraws mountains, volcanoes & ravines along their scaled paths.
+// `size` is how much larger (or smaller) the world has become.
+func (e *Editor) rescaleFeatures(ctx context.Context, ps, pd *types.Project, spnt paint.Painter, stage paint.Stage, graph, scaled voronoi.Graph, size float64) ([]paint.Canvas, error) {
+	rng := random(ctx)
+	blank := image.NewGray(image.Rect(0, 0, pd.WorldWidth, pd.WorldHeight))
+
+	mold, err := spnt.Canvas(ps.Canvas(tagMountains))
+	if err != nil {
+		return nil, err
+	}
+	mountains, err := stage.NewCanvasFromImage(pd.Canvas(tagMountains), blank)
+	if err != nil {
+		return nil, err
+	}
+	ravines, err := stage.NewCanvasFromImage(pd.Canvas(tagRavines), blank)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range scaled.TagNames() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		path, _ := scaled.FromTag(tag)
+
+		switch scaled.TagKind(tag) {
+		case tagMountains:
+			old, _ := graph.FromTag(tag)
+			var height float64
+			height, err = rangeHeight(ps, mold, old)
+			if err != nil {
+				break
+			}
+			_, err = e.drawMountains(ctx, rng, wrapped(pd, mountains), unwrapped(pd, path), height, size)
+		case tagVolcanoes:
+			for _, p := range path {
+				err = e.drawVolcano(rng, wrapped(pd, mountains), p, size)
+				if err != nil {
+					break
+				}
+			}
+		case tagRavines:
+			width := float64(e.set.RavineWidth.RollWith(rng)) * size
+			depth := rng.Float64()/5 + 0.8
+			if strings.Contains(tag, "/") { // a fork
+				width *= ravineForkShrink
+				depth *= ravineForkShrink
+			}
+			err = wrapped(pd, ravines).Channel(path, int(math.Max(1, width)), depth, paint.Convex)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return []paint.Canvas{mountains, ravines}, nil
+}
+
+// rangeHeight guesses the scale a mountain range was drawn with from the highest point
+// along it. Ranges are drawn with heights up to their scale.
+func rangeHeight(p *types.Project, cnv paint.Canvas, path []image.Point) (float64, error) {
+	highest := uint8(0)
+	line := unwrapped(p, path)
+	for j := 1; j < len(line); j++ {
+		for _, at := range voronoi.PointsBetween(line[j-1], line[j]) {
+			at = onWorld(p, at)
+			v, err := cnv.R(at.X, at.Y)
+			if err != nil {
+				return 0, err
+			}
+			if v > highest {
+				highest = v
+			}
+		}
+	}
+	return math.Max(0.1, float64(highest)/255), nil
+}
+
+// canvasImage returns the whole of a canvas (of the current epoch) as an image
+func canvasImage(ctx context.Context, p *types.Project, pnt paint.Painter, name string) (image.Image, error) {
+	cnv, err := pnt.Canvas(p.Canvas(name))
+	if err != nil {
+		return nil, err
+	}
+	return pnt.Merge(ctx, image.Rect(0, 0, p.WorldWidth, p.WorldHeight), map[paint.Canvas]float64{cnv: 1})
+}
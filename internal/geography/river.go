@@ -1,18 +1,19 @@
 package geography
 
 import (
+	"context"
 	"image"
 	//	"github.com/voidshard/genesis/internal/dijkstra"
 )
 
-func (e *Editor) Rivers(proj string, threshold int) (image.Image, error) {
+func (e *Editor) Rivers(ctx context.Context, proj string, threshold int) (image.Image, error) {
 	/*
 		p, err := e.project(proj)
 		if err != nil {
 			return nil, err
 		}
 
-		hmap, err := e.cachedHeightmap(proj, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+		hmap, err := e.cachedHeightmap(ctx, proj, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
 		if err != nil {
 			return nil, err
 		}
@@ -1,59 +1,101 @@
 package geography
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"math"
 	"math/rand"
+	"sort"
 
 	"github.com/voidshard/genesis/internal/dbutils"
 	"github.com/voidshard/genesis/internal/dijkstra"
 	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
 	"github.com/voidshard/genesis/internal/voronoi"
 	"github.com/voidshard/genesis/pkg/types"
 )
 
+// seaMapStages is the number of steps SeaMap reports progress for
+const seaMapStages = 5
+
 // SeaMap figures out where the sea should go & cold/hot ocean water currents
-func (e *Editor) SeaMap(proj string, sealevel uint8, equatorWidth, arcticWidth, currents int) (image.Image, []*types.Landmass, error) {
+func (e *Editor) SeaMap(ctx context.Context, proj string, sealevel uint8, equatorWidth, arcticWidth, currents int) (image.Image, []*types.Landmass, error) {
 	p, err := e.project(proj)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	voro := voronoi.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	voro := voronoi.New(e.store, p.WorldWidth, p.WorldHeight)
 	graph, err := e.cachedGraph(voro, p.VoronoiDiagram())
 	if err != nil {
 		return nil, nil, err
 	}
 
-	hmap, err := e.HeightMap(proj, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	err = progress.Report(ctx, "sea", 0, seaMapStages, "stages")
+	if err != nil {
+		return nil, nil, err
+	}
+	hmap, err := e.HeightMap(ctx, proj, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	stage, err := pnt.Begin()
 	if err != nil {
 		return nil, nil, err
 	}
+	defer stage.Rollback() // noop once committed
 
-	pnt := paint.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	rng := random(ctx)
 
 	// determine what pixels are in the sea and which are not
 	// note that this is simply a rough starting point ..
+	err = progress.Report(ctx, "sea", 1, seaMapStages, "stages")
+	if err != nil {
+		return nil, nil, err
+	}
 	sea, err := e.determineSea(p, pnt, hmap, voro, graph, sealevel)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// determine where ocean currents might run
-	currentPaths, err := e.determineWaterCurrent(p, sea, graph.Points(), equatorWidth, arcticWidth, currents)
+	err = progress.Report(ctx, "sea", 2, seaMapStages, "stages")
+	if err != nil {
+		return nil, nil, err
+	}
+	currentPaths, err := e.determineWaterCurrent(rng, p, sea, graph.Points(), equatorWidth, arcticWidth, currents)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// now we can paint the actual sea, with equator, poles, currets etc
-	sea, err = e.paintSea(p, pnt, sea, equatorWidth, arcticWidth, currentPaths)
+	err = progress.Report(ctx, "sea", 3, seaMapStages, "stages")
+	if err != nil {
+		return nil, nil, err
+	}
+	sea, err = e.paintSea(rng, p, stage, sea, equatorWidth, arcticWidth, currentPaths)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// now that we know where the sea is, we can figure out the land
-	landmasses, err := e.determineLand(p, pnt, sea)
+	err = progress.Report(ctx, "sea", 4, seaMapStages, "stages")
+	if err != nil {
+		return nil, nil, err
+	}
+	landmasses, err := e.determineLand(ctx, p, sea)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// nothing has been written up to here, so giving up now leaves the old sea map intact
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	err = stage.Save(sea)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -73,9 +115,20 @@ func (e *Editor) SeaMap(proj string, sealevel uint8, equatorWidth, arcticWidth,
 		tx.Rollback()
 		return nil, nil, err
 	}
-	tx.Commit()
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the sea map only replaces the old one once the landmasses that go with it are in
+	err = stage.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return sea.Image(), landmasses, nil
+	progress.Report(ctx, "sea", seaMapStages, seaMapStages, "stages")
+	im, err := paint.Image(sea)
+	return im, landmasses, err
 }
 
 // determineLand works out, once we've discovered where the sea goes, all of the unique landmasses.
@@ -83,16 +136,25 @@ func (e *Editor) SeaMap(proj string, sealevel uint8, equatorWidth, arcticWidth,
 // We track how large each landmass is, the first pixel we encountered and assign it a color.
 // The number we use for the color is currently a uint16 (max 65k or so) so the color is not unique
 // when the number of unique land forms exceeds that.
-func (e *Editor) determineLand(proj *types.Project, pnt paint.Painter, sea paint.Canvas) ([]*types.Landmass, error) {
+func (e *Editor) determineLand(ctx context.Context, proj *types.Project, sea paint.Canvas) ([]*types.Landmass, error) {
 	seen := map[image.Point]bool{}
 	bnds := sea.Bounds()
 
-	sea.SetMask(nil)
+	err := sea.SetMask(nil)
+	if err != nil {
+		return nil, err
+	}
 	found := []*types.Landmass{}
 
 	for dy := bnds.Min.Y; dy < bnds.Max.Y; dy++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		for dx := bnds.Min.X; dx < bnds.Max.X; dx++ {
-			if sea.B(dx, dy) > 0 {
+			b, err := sea.B(dx, dy)
+			if err != nil {
+				return nil, err
+			} else if b > 0 {
 				continue
 			}
 
@@ -117,7 +179,7 @@ func (e *Editor) determineLand(proj *types.Project, pnt paint.Painter, sea paint
 				if len(stack) < 1 {
 					found = append(found, &types.Landmass{
 						ProjectID: proj.ID,
-						ID:        dbutils.RandomID(),
+						ID:        dbutils.NewID(proj.ID, proj.Epoch, p.X, p.Y), // stable, so journalled calls naming it replay
 						Epoch:     proj.Epoch,
 						Size:      size,
 						ColorR:    int(red),
@@ -130,8 +192,9 @@ func (e *Editor) determineLand(proj *types.Project, pnt paint.Painter, sea paint
 
 				next := stack[0]
 
-				for px := next.X - 1; px <= next.X+1; px++ { // look at surrounding pixels
-					if px < bnds.Min.X || px >= bnds.Max.X {
+				for dx := -1; dx <= 1; dx++ { // look at surrounding pixels
+					px, ok := neighbourX(proj, next.X+dx)
+					if !ok {
 						continue
 					}
 					for py := next.Y - 1; py <= next.Y+1; py++ {
@@ -141,7 +204,10 @@ func (e *Editor) determineLand(proj *types.Project, pnt paint.Painter, sea paint
 						if px == next.X && py == next.Y {
 							continue
 						}
-						if sea.B(px, py) > 0 {
+						b, err := sea.B(px, py)
+						if err != nil {
+							return nil, err
+						} else if b > 0 {
 							continue
 						}
 
@@ -164,7 +230,7 @@ func (e *Editor) determineLand(proj *types.Project, pnt paint.Painter, sea paint
 		}
 	}
 
-	return found, pnt.Save(sea)
+	return found, nil
 }
 
 // determineSea; figure out where the sea should be.
@@ -214,7 +280,7 @@ func (e *Editor) determineSea(p *types.Project, pnt paint.Painter, hmap image.Im
 			stack = append(stack, i)
 		}
 	}
-	for dy := 0; dy < p.WorldHeight; dy++ {
+	for dy := 0; dy < p.WorldHeight && !p.Wrap; dy++ { // a wrapping world has no east / west edge
 		r0, _, _, _ := hmap.At(0, dy).RGBA()
 		if u8(r0) <= sealevel {
 			i := image.Pt(0, dy)
@@ -242,8 +308,9 @@ func (e *Editor) determineSea(p *types.Project, pnt paint.Painter, hmap image.Im
 
 		next := stack[0]
 
-		for px := next.X - 1; px <= next.X+1; px++ {
-			if px < 0 || px >= p.WorldWidth {
+		for dx := -1; dx <= 1; dx++ {
+			px, ok := neighbourX(p, next.X+dx)
+			if !ok {
 				continue // out of bounds
 			}
 
@@ -302,7 +369,7 @@ func (e *Editor) determineSea(p *types.Project, pnt paint.Painter, hmap image.Im
 	return sea, nil //, voro.Save(graph)
 }
 
-func (e *Editor) paintSea(p *types.Project, pnt paint.Painter, sea paint.Canvas, eqW, arW int, currents [][]image.Point) (paint.Canvas, error) {
+func (e *Editor) paintSea(rng *rand.Rand, p *types.Project, pnt paint.Painter, sea paint.Canvas, eqW, arW int, currents [][]image.Point) (paint.Canvas, error) {
 	waterVCold := color.RGBA{0, 0, e.set.OceanWaterVeryCold, 255}
 	waterCold := color.RGBA{0, 0, e.set.OceanWaterCold, 255}
 	waterWarm := color.RGBA{0, 0, e.set.OceanWaterWarm, 255}
@@ -314,18 +381,27 @@ func (e *Editor) paintSea(p *types.Project, pnt paint.Painter, sea paint.Canvas,
 	}
 
 	// use temp. sea image as a mask so we can't draw over land at all
-	cur.SetMask(sea)
+	err = cur.SetMask(sea)
+	if err != nil {
+		return nil, err
+	}
 
 	// start with the Northern & Southern hemisphere which gives the rough temp. without currents
 	eqTop := p.WorldHeight/2 - eqW/2
 	eqBot := p.WorldHeight/2 + eqW/2
-	cur.RectangleVertical(image.Rect(0, arW, p.WorldWidth, eqTop), waterCold, waterWarm, waterWarm)
-	cur.RectangleVertical(image.Rect(0, eqBot, p.WorldWidth, p.WorldHeight-arW), waterWarm, waterWarm, waterCold)
+	err = cur.RectangleVertical(image.Rect(0, arW, p.WorldWidth, eqTop), waterCold, waterWarm, waterWarm)
+	if err != nil {
+		return nil, err
+	}
+	err = cur.RectangleVertical(image.Rect(0, eqBot, p.WorldWidth, p.WorldHeight-arW), waterWarm, waterWarm, waterCold)
+	if err != nil {
+		return nil, err
+	}
 
 	// paint in sea currents
 	for _, path := range currents {
-		if rand.Float64() <= e.set.OceanColdCurrentProb { // cold
-			cur.Line(
+		if rng.Float64() <= e.set.OceanColdCurrentProb { // cold
+			err = wrapped(p, cur).Line(
 				path,
 				e.set.OceanCurrentWidth,
 				waterVWarm,
@@ -336,7 +412,7 @@ func (e *Editor) paintSea(p *types.Project, pnt paint.Painter, sea paint.Canvas,
 				waterVCold,
 			)
 		} else { // hot
-			cur.Line(
+			err = wrapped(p, cur).Line(
 				path,
 				e.set.OceanCurrentWidth,
 				waterVWarm,
@@ -347,22 +423,39 @@ func (e *Editor) paintSea(p *types.Project, pnt paint.Painter, sea paint.Canvas,
 				waterVCold,
 			)
 		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// draw in equator, Northern & Southern poles whose temperatures don't vary too much
-	cur.RectangleVertical(image.Rect(0, eqTop, p.WorldWidth, eqBot), waterWarm, waterVWarm, waterWarm)
-	cur.RectangleVertical(image.Rect(0, 0, p.WorldWidth, arW), waterVCold, waterCold)
-	cur.RectangleVertical(image.Rect(0, p.WorldHeight-arW, p.WorldWidth, p.WorldHeight), waterCold, waterVCold)
+	for _, band := range []struct {
+		area    image.Rectangle
+		colours []color.Color
+	}{
+		{image.Rect(0, eqTop, p.WorldWidth, eqBot), []color.Color{waterWarm, waterVWarm, waterWarm}},
+		{image.Rect(0, 0, p.WorldWidth, arW), []color.Color{waterVCold, waterCold}},
+		{image.Rect(0, p.WorldHeight-arW, p.WorldWidth, p.WorldHeight), []color.Color{waterCold, waterVCold}},
+	} {
+		err = cur.RectangleVertical(band.area, band.colours...)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return cur, pnt.Save(cur)
+	return cur, nil
 }
 
 // determineWaterCurrent figures out the temperature of the ocean, mostly we're interested in where
 // warm & cold ocean currents are - since they influence later calculations on rainfall and/or
 // lack there of.
-func (e *Editor) determineWaterCurrent(p *types.Project, sea paint.Canvas, allPoints []image.Point, eqW, arW, currents int) ([][]image.Point, error) {
+func (e *Editor) determineWaterCurrent(rng *rand.Rand, p *types.Project, sea paint.Canvas, allPoints []image.Point, eqW, arW, currents int) ([][]image.Point, error) {
+	im, err := paint.Image(sea)
+	if err != nil {
+		return nil, err
+	}
 	isSea := func(x, y int) bool {
-		_, _, b, _ := sea.At(x, y).RGBA()
+		_, _, b, _ := im.At(x, y).RGBA()
 		return b > 0
 	}
 
@@ -378,7 +471,7 @@ func (e *Editor) determineWaterCurrent(p *types.Project, sea paint.Canvas, allPo
 	// (ie. within the same grid or the next door grids) and usually (if our inter-grid connectivity
 	// is kept low) cut down on even that.
 	points := []image.Point{}
-	grid := NewGridBucket(p.WorldWidth, p.WorldHeight, e.set.OceanCurrentGridSize, false)
+	grid := NewGridBucket(p.WorldWidth, p.WorldHeight, e.set.OceanCurrentGridSize, p.Wrap)
 	north := []int{}
 	south := []int{}
 	centr := []int{}
@@ -403,22 +496,30 @@ func (e *Editor) determineWaterCurrent(p *types.Project, sea paint.Canvas, allPo
 		}
 	}
 
+	// the line between two points (the short way around, if the world wraps) is all sea
+	seaBetween := func(a, b int) bool {
+		line := unwrapped(p, []image.Point{points[a], points[b]})
+		for _, at := range voronoi.PointsBetween(line[0], line[1]) {
+			at = onWorld(p, at)
+			if !isSea(at.X, at.Y) {
+				return false
+			}
+		}
+		return true
+	}
+
 	edgeChan := grid.BuildEdges(
 		func(a, b int) float64 {
-			for _, p := range voronoi.PointsBetween(points[a], points[b]) {
-				if !isSea(p.X, p.Y) {
-					return -1 // reject this edge
-				}
+			if !seaBetween(a, b) {
+				return -1 // reject this edge
 			}
 			return 0 // accept this edge
 		},
 		func(a, b int) float64 {
-			for _, p := range voronoi.PointsBetween(points[a], points[b]) {
-				if !isSea(p.X, p.Y) {
-					return -1 // reject this edge
-				}
+			if !seaBetween(a, b) {
+				return -1 // reject this edge
 			}
-			return distBetween(points[a].X, points[a].Y, points[b].X, points[b].Y)
+			return distance(p, points[a], points[b])
 		},
 		2, // how many connections we allow between two grids
 	)
@@ -426,12 +527,24 @@ func (e *Editor) determineWaterCurrent(p *types.Project, sea paint.Canvas, allPo
 	for e := range edgeChan {
 		edges = append(edges, [2]image.Point{points[e[0]], points[e[1]]})
 	}
+	sort.Slice(edges, func(i, j int) bool { // edges arrive in any order
+		a, b := edges[i], edges[j]
+		if a[0] != b[0] {
+			return lessPoint(a[0], b[0])
+		}
+		return lessPoint(a[1], b[1])
+	})
 
 	// now with a list of points & edges between points, we can finally build a graph
 	seaGraph, err := dijkstra.New(0, []string{tagSeaCurrent}, points, edges)
 	if err != nil {
 		return nil, err
 	}
+	if p.Sphere() {
+		seaGraph.Sphere(p.WorldWidth, p.WorldHeight)
+	} else if p.Wrap {
+		seaGraph.Wrap(p.WorldWidth)
+	}
 
 	seaCurrents := [][]image.Point{} // equator -> pole
 	currentWeight := map[string]int{tagSeaCurrent: 10}
@@ -461,7 +574,7 @@ func (e *Editor) determineWaterCurrent(p *types.Project, sea paint.Canvas, allPo
 	}
 
 	if len(seaCurrents) > currents {
-		rand.Shuffle(len(seaCurrents), func(i, j int) {
+		rng.Shuffle(len(seaCurrents), func(i, j int) {
 			seaCurrents[i], seaCurrents[j] = seaCurrents[j], seaCurrents[i]
 		})
 		return seaCurrents[:currents], nil
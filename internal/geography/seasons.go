@@ -0,0 +1,314 @@
+package geography
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/voidshard/genesis/internal/globe"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+var (
+	// ErrTooFewSeasons is returned if we're asked for less than two seasons
+	ErrTooFewSeasons = fmt.Errorf("need at least 2 seasons")
+
+	// seasonTags are the canvases we keep for each season
+	seasonTags = []string{tagWind, tagRain, tagTemperature}
+
+	// seasonLayers are layers that can be read for a single season (see types.Layer.Seasonal)
+	seasonLayers = map[types.Layer]bool{
+		types.LayerRain:        true,
+		types.LayerTemperature: true,
+		types.LayerWindEast:    true,
+		types.LayerWindNorth:   true,
+	}
+)
+
+// Seasons works out wind, temperature & rain for `seasons` times of the year, evenly
+// spaced with season 0 being northern summer. Over the year the sun moves north &
+// south of the equator (see SeasonTilt), dragging the wind cells (see Wind) along with
+// it, so some places have wet & dry seasons where the wind turns around.
+//
+// The canvases of each season are kept (read them with types.Layer.Seasonal). The
+// yearly average replaces rain & temperature, & we work out how much each changes
+// over the year & where there are monsoons. Returns the seasonality canvas; red is how
+// much temperature changes, green how much rain changes & blue is set for monsoons.
+//
+// Seasons are stored per epoch, like rain. Implies SeaMap.
+func (e *Editor) Seasons(ctx context.Context, proj string, seasons int, stormMult float64) (image.Image, error) {
+	if seasons < 2 {
+		return nil, fmt.Errorf("%w, got %d", ErrTooFewSeasons, seasons)
+	}
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+
+	mountains, err := pnt.Canvas(p.Canvas(tagMountains))
+	if err != nil {
+		return nil, err
+	}
+	sea, err := pnt.Canvas(p.Canvas(tagSea))
+	if err != nil {
+		return nil, err
+	}
+	seamap, err := paint.Image(sea)
+	if err != nil {
+		return nil, err
+	}
+	heights, err := e.windHeights(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	level := landLevel(seamap, heights)
+
+	stage, err := pnt.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Rollback() // noop once committed
+
+	winds := make([]paint.Canvas, seasons)
+	rains := make([]image.Image, seasons)
+	temps := make([]image.Image, seasons)
+	tilt := e.set.SeasonTilt * math.Pi / 180
+	for i := 0; i < seasons; i++ {
+		err = progress.Report(ctx, "seasons", i, seasons, "seasons")
+		if err != nil {
+			return nil, err
+		}
+
+		// latitude the sun is overhead at
+		sun := tilt * math.Cos(2*math.Pi*float64(i)/float64(seasons))
+
+		winds[i], err = e.windCanvas(ctx, p, stage, heights, p.Canvas(seasonTag(tagWind, i)), sun*e.set.SeasonWindShift)
+		if err != nil {
+			return nil, err
+		}
+
+		rain, err := stage.NewCanvas(p.Canvas(seasonTag(tagRain, i)))
+		if err != nil {
+			return nil, err
+		}
+		err = e.windStorms(ctx, p, stormMult, winds[i], sea, mountains, rain)
+		if err != nil {
+			return nil, err
+		}
+
+		temp, err := e.temperatureCanvas(p, stage, p.Canvas(seasonTag(tagTemperature, i)), seamap, heights, level, sun)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cnv := range []paint.Canvas{winds[i], rain, temp} {
+			err = stage.Save(cnv)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// we read every pixel of each season below
+		rains[i], err = paint.Image(rain)
+		if err != nil {
+			return nil, err
+		}
+		temps[i], err = paint.Image(temp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the yearly picture
+	bnds := image.Rect(0, 0, p.WorldWidth, p.WorldHeight)
+	rainIm := image.NewRGBA(bnds)
+	tempIm := image.NewRGBA(bnds)
+	seasonIm := image.NewRGBA(bnds)
+	rain := make([]float64, seasons)
+	temp := make([]float64, seasons)
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			wet, dry := 0, 0
+			for i := 0; i < seasons; i++ {
+				rain[i] = float64(blue(rains[i], x, y))
+				temp[i] = float64(blue(temps[i], x, y))
+				if rain[i] > rain[wet] {
+					wet = i
+				}
+				if rain[i] < rain[dry] {
+					dry = i
+				}
+			}
+			rainMean, rainDev := meanDeviation(rain)
+			tempMean, tempDev := meanDeviation(temp)
+
+			rainIm.SetRGBA(x, y, color.RGBA{0, 0, forceUint8(int(math.Round(rainMean))), 255})
+			tempIm.SetRGBA(x, y, color.RGBA{0, 0, forceUint8(int(math.Round(tempMean))), 255})
+			monsoon := uint8(0)
+			if !isSea(seamap, x, y) {
+				ok, err := e.monsoon(rain[wet], rain[dry], winds[wet], winds[dry], x, y)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					monsoon = 255
+				}
+			}
+			seasonIm.SetRGBA(x, y, color.RGBA{forceUint8(int(math.Round(tempDev))), forceUint8(int(math.Round(rainDev))), monsoon, 255})
+		}
+	}
+
+	season, err := stage.NewCanvasFromImage(p.Canvas(tagSeasonality), seasonIm)
+	if err != nil {
+		return nil, err
+	}
+	for tag, im := range map[string]image.Image{tagRain: rainIm, tagTemperature: tempIm} {
+		cnv, err := stage.NewCanvasFromImage(p.Canvas(tag), im)
+		if err != nil {
+			return nil, err
+		}
+		err = stage.Save(cnv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	err = stage.Save(season)
+	if err != nil {
+		return nil, err
+	}
+	err = stage.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	// throw away seasons left over from working out more of them before
+	for i := seasons; ; i++ {
+		wind, err := pnt.Canvas(p.Canvas(seasonTag(tagWind, i)))
+		if err != nil {
+			return nil, err
+		}
+		made, err := windMade(wind)
+		if err != nil {
+			return nil, err
+		}
+		if !made {
+			break
+		}
+		for _, tag := range seasonTags {
+			err = pnt.Delete(p.Canvas(seasonTag(tag, i)))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	progress.Report(ctx, "seasons", seasons, seasons, "seasons")
+	return seasonIm, nil
+}
+
+// monsoon returns if (x, y) gets monsoons, that is the wind turns around between the
+// wettest & driest seasons & far more rain falls in the former
+func (e *Editor) monsoon(wet, dry float64, wetWind, dryWind paint.Canvas, x, y int) (bool, error) {
+	if wet < float64(e.set.SeasonMonsoonMinRain) || wet < dry*e.set.SeasonMonsoonRatio {
+		return false, nil
+	}
+	we, wn, err := windAt(wetWind, x, y)
+	if err != nil {
+		return false, err
+	}
+	de, dn, err := windAt(dryWind, x, y)
+	if err != nil {
+		return false, err
+	}
+	return we*de+wn*dn < 0, nil
+}
+
+// temperatureCanvas works out air temperatures (see temperatureColour) when the sun is
+// overhead at latitude `sun` (radians) into a new canvas called `name`
+func (e *Editor) temperatureCanvas(p *types.Project, pnt paint.Painter, name string, seamap, hmap image.Image, level uint8, sun float64) (paint.Canvas, error) {
+	im := image.NewRGBA(image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	span := e.set.TemperatureEquator - e.set.TemperaturePole
+	for y := 0; y < p.WorldHeight; y++ {
+		lat := globe.Latitude(y, p.WorldHeight)
+		yearly := e.set.TemperaturePole + span*math.Cos(lat)
+		now := e.set.TemperaturePole + span*math.Cos(lat-sun)
+		for x := 0; x < p.WorldWidth; x++ {
+			t := now
+			if isSea(seamap, x, y) {
+				t = yearly + (now-yearly)*(1-e.set.TemperatureSeaDamping)
+			} else if h := grey(hmap, x, y); h > level {
+				t -= float64(h-level) * e.set.TemperatureLapse
+			}
+			im.SetRGBA(x, y, color.RGBA{0, 0, temperatureColour(t), 255})
+		}
+	}
+	return pnt.NewCanvasFromImage(name, im)
+}
+
+// temperatureColour encodes a temperature (celsius) in half degrees, 128 being 0
+func temperatureColour(celsius float64) uint8 {
+	return forceUint8(int(math.Round(celsius*2 + 128)))
+}
+
+// temperatureAt decodes the temperature (celsius) at some point of a temperature canvas
+func temperatureAt(cnv paint.Canvas, x, y int) (float64, error) {
+	b, err := cnv.B(x, y)
+	return (float64(b) - 128) / 2, err
+}
+
+// landLevel returns the height of the lowest land, which we take to be sea level
+func landLevel(seamap, hmap image.Image) uint8 {
+	bnds := seamap.Bounds()
+	level := uint8(255)
+	found := false
+	for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+		for x := bnds.Min.X; x < bnds.Max.X; x++ {
+			if isSea(seamap, x, y) {
+				continue
+			}
+			found = true
+			if h := grey(hmap, x, y); h < level {
+				level = h
+			}
+		}
+	}
+	if !found {
+		return 0
+	}
+	return level
+}
+
+// blue returns the (8 bit) blue value of a pixel
+func blue(im image.Image, x, y int) uint8 {
+	_, _, b, _ := im.At(x, y).RGBA()
+	return uint8(b >> 8)
+}
+
+// meanDeviation returns the mean & standard deviation of some values
+func meanDeviation(in []float64) (float64, float64) {
+	mean := 0.0
+	for _, v := range in {
+		mean += v
+	}
+	mean /= float64(len(in))
+
+	variance := 0.0
+	for _, v := range in {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(in)))
+}
+
+// seasonTag returns the name of a canvas for a single season
+func seasonTag(tag string, season int) string {
+	return fmt.Sprintf("%s-season-%d", tag, season)
+}
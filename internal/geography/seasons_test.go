@@ -0,0 +1,93 @@
+package geography
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func TestMeanDeviation(t *testing.T) {
+	mean, dev := meanDeviation([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	assert.Equal(t, 5.0, mean)
+	assert.Equal(t, 2.0, dev)
+
+	mean, dev = meanDeviation([]float64{3, 3})
+	assert.Equal(t, 3.0, mean)
+	assert.Equal(t, 0.0, dev)
+}
+
+func TestTemperatureColour(t *testing.T) {
+	assert.Equal(t, uint8(128), temperatureColour(0))
+	assert.Equal(t, uint8(148), temperatureColour(10))
+	assert.Equal(t, uint8(98), temperatureColour(-15))
+	assert.Equal(t, uint8(255), temperatureColour(500))
+	assert.Equal(t, uint8(0), temperatureColour(-500))
+}
+
+func TestSeasons(t *testing.T) {
+	ctx, e, p := testWorld(t, nil)
+	world := image.Rect(0, 0, p.WorldWidth, p.WorldHeight)
+
+	_, err := e.Seasons(ctx, p.ID, 1, 1)
+	assert.ErrorIs(t, err, ErrTooFewSeasons)
+
+	_, err = e.Seasons(ctx, p.ID, 4, 1)
+	assert.Nil(t, err)
+
+	// season 0 is northern summer & season 2 northern winter
+	summer, err := e.Layer(p.ID, types.LayerTemperature.Seasonal(0), world)
+	assert.Nil(t, err)
+	winter, err := e.Layer(p.ID, types.LayerTemperature.Seasonal(2), world)
+	assert.Nil(t, err)
+	north, south := p.WorldHeight/5, p.WorldHeight*4/5
+	for x := 0; x < p.WorldWidth; x += 25 {
+		assert.Greater(t, summer.Gray16At(x, north).Y, winter.Gray16At(x, north).Y, x)
+		assert.Less(t, summer.Gray16At(x, south).Y, winter.Gray16At(x, south).Y, x)
+	}
+
+	// the year is the average of it's seasons
+	yearly, err := e.Layer(p.ID, types.LayerTemperature, world)
+	assert.Nil(t, err)
+	for _, at := range []image.Point{{30, north}, {150, 100}, {270, south}} {
+		mean := 0.0
+		for i := 0; i < 4; i++ {
+			season, err := e.Layer(p.ID, types.LayerTemperature.Seasonal(i), world)
+			assert.Nil(t, err)
+			mean += float64(season.Gray16At(at.X, at.Y).Y/257) / 4
+		}
+		assert.InDelta(t, mean, float64(yearly.Gray16At(at.X, at.Y).Y/257), 0.5, at)
+	}
+
+	// the sea keeps temperatures from changing as much as they do on land
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	sea, err := pnt.Canvas(p.Canvas(tagSea))
+	assert.Nil(t, err)
+	seamap, err := paint.Image(sea)
+	assert.Nil(t, err)
+	season, err := pnt.Canvas(p.Canvas(tagSeasonality))
+	assert.Nil(t, err)
+	seasonality, err := paint.Image(season)
+	assert.Nil(t, err)
+	landChange, seaChange := 0, 0
+	for x := 0; x < p.WorldWidth; x++ {
+		change := int(grey(seasonality, x, north))
+		if isSea(seamap, x, north) {
+			seaChange = maxInt(seaChange, change)
+		} else {
+			landChange = maxInt(landChange, change)
+		}
+	}
+	assert.Greater(t, landChange, seaChange)
+
+	// fewer seasons throws away the ones we don't need anymore
+	_, err = e.Seasons(ctx, p.ID, 2, 1)
+	assert.Nil(t, err)
+	_, err = e.Layer(p.ID, types.LayerRain.Seasonal(1), world)
+	assert.Nil(t, err)
+	_, err = e.Layer(p.ID, types.LayerRain.Seasonal(3), world)
+	assert.ErrorIs(t, err, ErrUnknownSeason)
+}
@@ -19,6 +19,17 @@ type Settings struct {
 	HeightMapNoisePerlinWeight  float64
 	HeightMapNoiseVoronoiWeight float64
 
+	// Weights for land raised & cut away by coastal tools (islands, bays etc).
+	// Cut should outweigh raised so a bay can be dug out of an island.
+	HeightMapCoastLandWeight float64
+	HeightMapCoastSeaWeight  float64
+
+	// HeightMapGlacierWeight is for troughs cut by glaciers (see Cryosphere)
+	HeightMapGlacierWeight float64
+
+	// HeightMapDepthWeight is for the depth of the sea (see Bathymetry)
+	HeightMapDepthWeight float64
+
 	// Graph weights for path calculations - these encourage paths
 	// to avoid certain points.
 	// Eg. GraphEdgeWeight encourages paths to avoid edges.
@@ -65,16 +76,116 @@ type Settings struct {
 	// RavineWidth seems .. obvious
 	RavineWidth *types.Dice
 
+	// Coastal settings. IslandSize is the radius of islands in an archipelago,
+	// FjordSpacing roughly how many pixels of coast are between fjords.
+	IslandSize   *types.Dice
+	FjordWidth   *types.Dice
+	FjordSpacing int
+
 	// Mountain settings affect size, frequency and range width
 	Mountain           *types.Dice
 	MountainsPerStep   *types.Dice
 	MountainStep       *types.Dice
 	MountainRangeWidth int
 
-	// List of prevailing wind directions from the North pole to the South pole,
-	// assuming that the world is divided into horizontal bands of equal(ish) size.
-	// Used for calculation of rain shadows / desertification etc.
-	RainfallPrevailingWinds           []types.Heading
+	// Sketch settings, heights given to sea, land & mountains drawn in a sketch.
+	// Sea should be well below (and land above) the sea level you intend to use.
+	// SketchSlope is the radius over which heights blend into each other &
+	// SketchNoise how much (+/-) noise is added over the top.
+	SketchSeaHeight      uint8
+	SketchLandHeight     uint8
+	SketchMountainHeight uint8
+	SketchSlope          uint32
+	SketchNoise          uint8
+
+	// EditUndoLimit is how many manual terrain edits (per epoch) can be undone
+	EditUndoLimit int
+
+	// VectorSimplifyTolerance is how far (in pixels) simplified coastlines may
+	// stray from the traced coastline when building vector features
+	VectorSimplifyTolerance float64
+
+	// WindCoriolis is how far (in degrees) the spin of the world turns winds nearest
+	// the poles
+	WindCoriolis float64
+
+	// WindMountainBend is how readily winds turn aside rather than go up a slope, per
+	// unit of slope (height / pixel)
+	WindMountainBend float64
+
+	// WindMountainRadius smooths heights before we look at slopes, so winds turn
+	// around mountains rather than every bump
+	WindMountainRadius uint32
+
+	// WindVariation is how far (in degrees) winds wander from what they'd be on a
+	// featureless world & WindVariationScale the scale of the (perlin) noise that
+	// decides it
+	WindVariation      float64
+	WindVariationScale float64
+
+	// SeasonTilt is how far (in degrees) north & south of the equator the sun is
+	// overhead over the year, wind cells (& so rain) follow it SeasonWindShift (0-1)
+	// of the way
+	SeasonTilt      float64
+	SeasonWindShift float64
+
+	// Land has monsoons if it's wettest season gets SeasonMonsoonRatio times the rain
+	// of it's driest (& at least SeasonMonsoonMinRain) & the wind turns around between
+	// the two
+	SeasonMonsoonRatio   float64
+	SeasonMonsoonMinRain uint8
+
+	// TemperatureEquator & TemperaturePole are yearly average temperatures (celsius)
+	// at sea level. Land is colder by TemperatureLapse per unit of height above the
+	// sea & the sea takes TemperatureSeaDamping (0-1) off how much temperatures
+	// change over the year.
+	TemperatureEquator    float64
+	TemperaturePole       float64
+	TemperatureLapse      float64
+	TemperatureSeaDamping float64
+
+	// There's ice where it's below IceTemperature (celsius) all year round, or
+	// SeaIceTemperature over the sea
+	IceTemperature    float64
+	SeaIceTemperature float64
+
+	// Glaciers start on icy mountains at least GlacierSpacing pixels apart & flow
+	// downhill until it's warmer than GlacierMeltTemperature for some of the year.
+	// Those shorter than GlacierMinLength pixels are dropped. Each time they're
+	// worked out they cut troughs GlacierWidth wide & GlacierDepth (0-1) deep.
+	GlacierMeltTemperature float64
+	GlacierSpacing         int
+	GlacierMinLength       int
+	GlacierMaxLength       int
+	GlacierWidth           *types.Dice
+	GlacierDepth           float64
+
+	// Bathymetry (depths are 0-255, lengths in pixels). Shelves run out
+	// BathymetryShelfWidth (give or take half, decided by perlin noise of
+	// BathymetryShelfNoise scale) from the coast sloping down to BathymetryShelfDepth,
+	// then fall away over BathymetrySlopeWidth to BathymetryAbyssDepth.
+	BathymetryShelfWidth float64
+	BathymetryShelfNoise float64
+	BathymetryShelfDepth float64
+	BathymetrySlopeWidth float64
+	BathymetryAbyssDepth float64
+
+	// Trenches next to volcanoes are BathymetryTrenchWidth wide & up to
+	// BathymetryTrenchDepth (0-1) of the deepest depth deeper
+	BathymetryTrenchWidth int
+	BathymetryTrenchDepth float64
+
+	// Ridges run along the middle of oceans at least BathymetryRidgeDistance from any
+	// coast, rising BathymetryRidgeHeight & falling off over BathymetryRidgeWidth
+	BathymetryRidgeDistance float64
+	BathymetryRidgeWidth    float64
+	BathymetryRidgeHeight   float64
+
+	// Storms start every RainfallStormSpacing pixels & follow the wind for
+	// RainfallStormSteps pixels. Used for calculation of rain shadows /
+	// desertification etc.
+	RainfallStormSpacing              int
+	RainfallStormSteps                int
 	RainfallStormInitMoisture         *types.Dice
 	RainfallMoistureGainVeryWarmSea   *types.Dice
 	RainfallMoistureGainWarmSea       *types.Dice
@@ -97,6 +208,15 @@ func DefaultSettings() *Settings {
 		VolcanoStep:                 types.NewDice(24, 20),
 		VolcanoRangeWidth:           45,
 		RavineWidth:                 types.NewDice(2, 10, 10),
+		IslandSize:                  types.NewDice(10, 10, 10),
+		FjordWidth:                  types.NewDice(2, 3, 3),
+		FjordSpacing:                60,
+		SketchSeaHeight:             40,
+		SketchLandHeight:            130,
+		SketchMountainHeight:        210,
+		SketchSlope:                 15,
+		SketchNoise:                 20,
+		EditUndoLimit:               50,
 		GraphDefaultWeight:          200,
 		GraphEdgeWeight:             200,
 		GraphMountainWeight:         500,
@@ -110,6 +230,10 @@ func DefaultSettings() *Settings {
 		HeightMapRiverWeight:        0.0,
 		HeightMapNoisePerlinWeight:  0.5,
 		HeightMapNoiseVoronoiWeight: 0.5,
+		HeightMapCoastLandWeight:    0.6,
+		HeightMapCoastSeaWeight:     -1.0,
+		HeightMapGlacierWeight:      -0.2,
+		HeightMapDepthWeight:        -0.4,
 		OceanWaterVeryCold:          100,
 		OceanWaterVeryWarm:          135,
 		OceanWaterCold:              105,
@@ -117,14 +241,40 @@ func DefaultSettings() *Settings {
 		OceanCurrentGridSize:        100,
 		OceanCurrentWidth:           30,
 		OceanColdCurrentProb:        0.4,
-		RainfallPrevailingWinds: []types.Heading{
-			types.EAST,      // Polar
-			types.WEST,      // Temperate
-			types.NORTHEAST, // Tropical
-			types.SOUTHWEST, // Tropical
-			types.WEST,      // Temperate
-			types.EAST,      // Polar
-		},
+		WindCoriolis:                70,
+		WindMountainBend:            0.2,
+		WindMountainRadius:          8,
+		WindVariation:               20,
+		WindVariationScale:          0.1,
+		SeasonTilt:                  23.5,
+		SeasonWindShift:             0.5,
+		SeasonMonsoonRatio:          3,
+		SeasonMonsoonMinRain:        20,
+		TemperatureEquator:          28,
+		TemperaturePole:             -20,
+		TemperatureLapse:            0.2,
+		TemperatureSeaDamping:       0.6,
+		IceTemperature:              0,
+		SeaIceTemperature:           -2,
+		GlacierMeltTemperature:      4,
+		GlacierSpacing:              40,
+		GlacierMinLength:            10,
+		GlacierMaxLength:            250,
+		GlacierWidth:                types.NewDice(8, 6, 6),
+		GlacierDepth:                0.3,
+		BathymetryShelfWidth:        20,
+		BathymetryShelfNoise:        0.05,
+		BathymetryShelfDepth:        20,
+		BathymetrySlopeWidth:        30,
+		BathymetryAbyssDepth:        160,
+		BathymetryTrenchWidth:       12,
+		BathymetryTrenchDepth:       0.8,
+		BathymetryRidgeDistance:     60,
+		BathymetryRidgeWidth:        25,
+		BathymetryRidgeHeight:       70,
+		RainfallStormSpacing:        5,
+		RainfallStormSteps:          400,
+
 		RainfallStormInitMoisture:         types.NewDice(0),
 		RainfallMoistureGainVeryWarmSea:   types.NewDice(10, 10, 5),
 		RainfallMoistureGainWarmSea:       types.NewDice(5, 5, 5),
@@ -134,5 +284,6 @@ func DefaultSettings() *Settings {
 		RainfallMoistureLossOverMountains: types.NewDice(1, 2, 2),
 		RainfallDryWindMoistureLoss:       types.NewDice(1, 2, 2),
 		RainfallCalcRoutines:              10,
+		VectorSimplifyTolerance:           1.0,
 	}
 }
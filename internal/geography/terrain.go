@@ -1,29 +1,48 @@
 package geography
 
 import (
+	"context"
 	"fmt"
 	"image"
+	"math"
 	"math/rand"
 	"sync"
 
 	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
 	"github.com/voidshard/genesis/internal/voronoi"
 	"github.com/voidshard/genesis/pkg/types"
 )
 
-func (e *Editor) CreateTectonics(proj string, noise float64, points int) error {
+func (e *Editor) CreateTectonics(ctx context.Context, proj string, noise float64, points int) error {
 	p, err := e.project(proj)
 	if err != nil {
 		return err
 	}
 
-	pnt := paint.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
-	voro := voronoi.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	voro := voronoi.New(e.store, p.WorldWidth, p.WorldHeight)
+
+	err = progress.Report(ctx, "tectonics", 0, 3, "stages")
+	if err != nil {
+		return err
+	}
+
+	stage, err := pnt.Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
 
 	errchan := make(chan error)
 	wg := &sync.WaitGroup{}
 	wg.Add(2)
 
+	// both halves are built at once, so each is handed a source of random numbers
+	// of it's own
+	rng := random(ctx)
+	voroRng, perlinSeed := fork(rng), rng.Int63()
+
 	var diag voronoi.Graph
 	var vnoise paint.Canvas
 	var verr error
@@ -32,200 +51,471 @@ func (e *Editor) CreateTectonics(proj string, noise float64, points int) error {
 		defer wg.Done()
 
 		// build voronoi noise (fractal noise)
-		diag, vnoise, verr = e.newVoronoiNoise(p, pnt, voro, points)
+		diag, vnoise, verr = e.newVoronoiNoise(ctx, voroRng, p, stage, voro, points)
 		errchan <- verr
 	}()
 
-	e.graph = diag // cache graph we'll probably be using ..
-
 	var pnoise paint.Canvas
 	go func() {
 		defer wg.Done()
 
 		// build perlin noise (smooth noise)
-		pcnv, err := pnt.NewPerlinCanvas(p.Canvas(tagPerlin), noise)
-		if err != nil {
-			errchan <- err
+		var pcnv paint.Canvas
+		var err error
+		if p.Wrap {
+			pcnv, err = stage.NewCanvasFromImage(p.Canvas(tagPerlin), perlin(perlinSeed, p, noise, false))
+		} else {
+			pcnv, err = stage.NewPerlinCanvas(ctx, p.Canvas(tagPerlin), noise, perlinSeed)
 		}
 		pnoise = pcnv
-		err = pnt.Save(pcnv)
 		errchan <- err
 	}()
 
+	// nothing is saved until everything is built, so if we're cancelled
+	// the previous tectonics (if any) are left as they were
 	err = fanIn(errchan, wg)
 	if err != nil {
 		return err
 	}
 
 	// weight voronoi based on noise values at vertexes
-	perlinImg := pnoise.Image()
-	voroImg := vnoise.Image()
-	for _, p := range diag.Points() {
+	err = progress.Report(ctx, "tectonics", 1, 3, "stages")
+	if err != nil {
+		return err
+	}
+	perlinImg, err := paint.Image(pnoise)
+	if err != nil {
+		return err
+	}
+	voroImg, err := paint.Image(vnoise)
+	if err != nil {
+		return err
+	}
+	err = e.weightTectonics(p, diag, func(p image.Point) int {
 		pnValue, _, _, _ := perlinImg.At(p.X, p.Y).RGBA()
 		flValue, _, _, _ := voroImg.At(p.X, p.Y).RGBA()
+		return int((float64(pnValue>>8)*e.set.HeightMapNoisePerlinWeight + float64(flValue)*e.set.HeightMapNoiseVoronoiWeight) / 2)
+	})
+	if err != nil {
+		return err
+	}
+	err = progress.Report(ctx, "tectonics", 2, 3, "stages")
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for _, cnv := range []paint.Canvas{pnoise, vnoise} {
+		err = stage.Save(cnv)
+		if err != nil {
+			return err
+		}
+	}
+	err = stagedVoronoi(p, stage).Save(diag)
+	if err != nil {
+		return err
+	}
+	err = stage.Commit()
+	if err != nil {
+		return err
+	}
+	e.graph = diag // cache graph we'll probably be using ..
+
+	return progress.Report(ctx, "tectonics", 3, 3, "stages")
+}
 
-		v := int((float64(pnValue>>8)*e.set.HeightMapNoisePerlinWeight + float64(flValue)*e.set.HeightMapNoiseVoronoiWeight) / 2)
-		diag.IncrWeights([]image.Point{p}, map[string]int{
+// weightTectonics weights graph vertexes by how high the land is around them (so
+// mountains follow high ground & rivers low) & weights vertexes along the edges of
+// the map (encourage stuff to avoid sides)
+func (e *Editor) weightTectonics(p *types.Project, diag voronoi.Graph, height func(image.Point) int) error {
+	for _, pnt := range diag.Points() {
+		v := height(pnt)
+		err := diag.IncrWeights([]image.Point{pnt}, map[string]int{
 			tagMountains: -1 * v,
 			tagVolcanoes: -1 * v / 2,
 			tagRavines:   v,
 			tagRivers:    v * 2,
 		})
+		if err != nil {
+			return err
+		}
 	}
 
-	// weight vertexes along edges (encourage stuff to avoid sides)
 	deltaOnEdge := map[string]int{}
 	for _, w := range voroWeights {
 		deltaOnEdge[w] = e.set.GraphEdgeWeight
 	}
 	bounds := image.Rect(5, 5, p.WorldWidth-5, p.WorldHeight-5)
-
-	err = diag.IncrWeightsOutside(bounds, deltaOnEdge)
-	if err != nil {
-		return err
+	if p.Wrap { // there's no east or west edge to avoid
+		bounds.Min.X, bounds.Max.X = -1, p.WorldWidth
 	}
 
-	return voro.Save(diag)
+	return diag.IncrWeightsOutside(bounds, deltaOnEdge)
 }
 
 //
-func (e *Editor) FlattenOutside(proj string, bounds image.Rectangle) error {
+func (e *Editor) FlattenOutside(ctx context.Context, proj string, bounds image.Rectangle) error {
 	p, err := e.project(proj)
 	if err != nil {
 		return nil
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	stage, err := pnt.Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
 
-	pnt := paint.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	names := []string{tagMountains, tagPerlin, tagVoro}
+	flattened := make([]paint.Canvas, len(names))
 
 	errchan := make(chan error)
 	wg := &sync.WaitGroup{}
-	wg.Add(3)
-
-	go func() {
-		defer wg.Done()
-		cnv, err := pnt.Canvas(p.Canvas(tagMountains))
-		if err != nil {
+	wg.Add(len(names))
+
+	for i, name := range names {
+		go func(i int, name string) {
+			defer wg.Done()
+			cnv, err := stage.Canvas(p.Canvas(name))
+			if err != nil {
+				errchan <- err
+				return
+			}
+			im, err := paint.Image(cnv)
+			if err != nil {
+				errchan <- err
+				return
+			}
+			flat, err := paint.FlattenOutside(im, bounds)
+			if err != nil {
+				errchan <- err
+				return
+			}
+			flattened[i], err = stage.NewCanvasFromImage(cnv.Name(), flat)
 			errchan <- err
-			return
-		}
-		cnv.FlattenOutside(bounds)
-		errchan <- pnt.Save(cnv)
-	}()
+		}(i, name)
+	}
 
-	go func() {
-		defer wg.Done()
-		cnv, err := pnt.Canvas(p.Canvas(tagPerlin))
-		if err != nil {
-			errchan <- err
-			return
-		}
-		cnv.FlattenOutside(bounds)
-		errchan <- pnt.Save(cnv)
-	}()
+	err = fanIn(errchan, wg)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
-	go func() {
-		defer wg.Done()
-		cnv, err := pnt.Canvas(p.Canvas(tagVoro))
+	for _, cnv := range flattened {
+		err = stage.Save(cnv)
 		if err != nil {
-			errchan <- err
-			return
+			return err
 		}
-		cnv.FlattenOutside(bounds)
-		errchan <- pnt.Save(cnv)
-	}()
-
-	return fanIn(errchan, wg)
+	}
+	return stage.Commit()
 }
 
 //
-func (e *Editor) SmoothTerrain(proj string, radius uint32) error {
+func (e *Editor) SmoothTerrain(ctx context.Context, proj string, radius uint32) error {
 	p, err := e.project(proj)
 	if err != nil {
 		return err
 	}
 
-	pnt := paint.New(e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
-	mountains, err := pnt.Canvas(p.Canvas(tagMountains))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+	stage, err := pnt.Begin()
 	if err != nil {
 		return err
 	}
+	defer stage.Rollback() // noop once committed
 
-	mountains.Smooth(radius)
+	mountains, err := stage.Canvas(p.Canvas(tagMountains))
+	if err != nil {
+		return err
+	}
+
+	mountains, err = smoothCanvas(p, stage, mountains, radius)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
-	return pnt.Save(mountains)
+	err = stage.Save(mountains)
+	if err != nil {
+		return err
+	}
+	return stage.Commit()
 }
 
 //
-func (e *Editor) HeightMap(proj string, area image.Rectangle) (image.Image, error) {
-	op, err := e.newGraphOp(proj, nil)
+func (e *Editor) HeightMap(ctx context.Context, proj string, area image.Rectangle) (image.Image, error) {
+	op, err := e.newGraphOp(ctx, proj, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	wfull, err := e.heightMapWeights(op.p, op.pnt)
+	if err != nil {
+		return nil, err
+	}
+
+	im, err := op.pnt.Merge(ctx, area, wfull)
 	if err != nil {
 		return nil, err
 	}
 
-	mountains, err := op.pnt.Canvas(op.p.Canvas(tagMountains))
+	final, err := smoothed(op.p, im, 3)
+	if err != nil {
+		return nil, err
+	}
+	e.hmap[hmapKey{op.p.ID, area}] = final // cached for other internal funcs to call
+	return final, nil
+}
+
+// drawMountains places mountains along a path, returning their centres. Size scales
+// the width & spacing of mountains (eg. for larger worlds) & scale their height.
+func (e *Editor) drawMountains(ctx context.Context, rng *rand.Rand, cnv paint.Canvas, path []image.Point, scale, size float64) ([]image.Point, error) {
+	sized := func(v int) int {
+		return int(float64(v) * size)
+	}
+	width := sized(e.set.MountainRangeWidth)
+	if width < 1 {
+		width = 1
+	}
+
+	placed := []image.Point{}
+	for j := 1; j < len(path); j++ { // for each segment of the range
+		if progress.Report(ctx, "mountains", j-1, len(path)-1, "segments") != nil {
+			return placed, nil // cancelled
+		}
+
+		// walk along the segment
+		alongLine := voronoi.PointsBetween(path[j-1], path[j])
+		rangeHeight := 3 * rng.Float64() / 4
+		for p := 0; p < len(alongLine); {
+			// pick a point along the segment
+			centre := alongLine[p]
+			for mnt := 0; mnt < e.set.MountainsPerStep.RollWith(rng); mnt++ {
+				// place mountain centred around segment point
+				cp := image.Pt(
+					centre.X-width/2+rng.Intn(width),
+					centre.Y-width/2+rng.Intn(width),
+				)
+				err := cnv.Ellipse(
+					cp,
+					sized(e.set.Mountain.RollWith(rng)),
+					sized(e.set.Mountain.RollWith(rng)),
+					rng.Intn(90),
+					(rng.Float64()/4+rangeHeight)*scale,
+					paint.Convex,
+				)
+				if err != nil {
+					return placed, err
+				}
+				placed = append(placed, cp)
+			}
+			p += 1 + sized(e.set.MountainStep.RollWith(rng))
+		}
+	}
+
+	progress.Report(ctx, "mountains", len(path)-1, len(path)-1, "segments")
+	return placed, nil
+}
+
+// heightMapWeights returns all canvases that go into a heightmap & how much
+// each contributes
+func (e *Editor) heightMapWeights(p *types.Project, pnt paint.Painter) (map[paint.Canvas]float64, error) {
+	mountains, err := pnt.Canvas(p.Canvas(tagMountains))
+	if err != nil {
+		return nil, err
+	}
+	pnNoise, err := pnt.Canvas(p.Canvas(tagPerlin))
+	if err != nil {
+		return nil, err
+	}
+	viNoise, err := pnt.Canvas(p.Canvas(tagVoro))
+	if err != nil {
+		return nil, err
+	}
+	ravines, err := pnt.Canvas(p.Canvas(tagRavines))
+	if err != nil {
+		return nil, err
+	}
+	rivers, err := pnt.Canvas(p.Canvas(tagRivers))
+	if err != nil {
+		return nil, err
+	}
+	coastLand, err := pnt.Canvas(p.Canvas(tagCoastLand))
+	if err != nil {
+		return nil, err
+	}
+	coastSea, err := pnt.Canvas(p.Canvas(tagCoastSea))
 	if err != nil {
 		return nil, err
 	}
-	pnNoise, err := op.pnt.Canvas(op.p.Canvas(tagPerlin))
+	raised, err := pnt.Canvas(p.Canvas(tagEditRaise))
 	if err != nil {
 		return nil, err
 	}
-	viNoise, err := op.pnt.Canvas(op.p.Canvas(tagVoro))
+	lowered, err := pnt.Canvas(p.Canvas(tagEditLower))
 	if err != nil {
 		return nil, err
 	}
-	ravines, err := op.pnt.Canvas(op.p.Canvas(tagRavines))
+	glaciers, err := pnt.Canvas(p.Canvas(tagGlaciers))
 	if err != nil {
 		return nil, err
 	}
-	rivers, err := op.pnt.Canvas(op.p.Canvas(tagRivers))
+	depth, err := pnt.Canvas(p.Canvas(tagDepth))
 	if err != nil {
 		return nil, err
 	}
 
-	wfull := map[paint.Canvas]float64{
+	return map[paint.Canvas]float64{
 		mountains: e.set.HeightMapMountainWeight,
 		ravines:   e.set.HeightMapRavineWeight,
 		rivers:    e.set.HeightMapRiverWeight,
 		pnNoise:   e.set.HeightMapNoisePerlinWeight,
 		viNoise:   e.set.HeightMapNoiseVoronoiWeight,
+		coastLand: e.set.HeightMapCoastLandWeight,
+		coastSea:  e.set.HeightMapCoastSeaWeight,
+		glaciers:  e.set.HeightMapGlacierWeight,
+		depth:     e.set.HeightMapDepthWeight,
+		raised:    1, // manual edits are exact changes in height
+		lowered:   -1,
+	}, nil
+}
+
+const (
+	// ravineForkDepth is how many times forks can themselves fork
+	ravineForkDepth = 3
+
+	// ravineForkShrink is how much smaller (length, width, depth & fork chance) each
+	// fork is than the path it comes off
+	ravineForkShrink = 0.6
+)
+
+// AddRavine cuts a ravine, which forks into branches with `forkChance` at each point
+// along it's path. Forks are tagged `tag/n`.
+func (e *Editor) AddRavine(ctx context.Context, proj, tag string, s *types.PathSpec, forkChance float64) (*types.PathTree, error) {
+	op, err := e.newGraphOp(ctx, proj, s)
+	if err != nil {
+		return nil, err
 	}
 
-	im, err := op.pnt.Merge(area, wfull)
+	// find path of ravine
+	path, err := op.path(tagRavines)
 	if err != nil {
 		return nil, err
 	}
 
-	final, err := paint.SmoothImage(im, 3)
-	e.hmap[area] = final // cached for other internal funcs to call
-	return final, err
-}
+	stage, err := op.pnt.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Rollback() // noop once committed
 
-//
-func (e *Editor) AddRavine(proj, tag string, s *types.PathSpec, forkChance float64) ([]image.Point, error) {
-	op, err := e.newGraphOp(proj, s)
+	cnv, err := stage.Canvas(op.p.Canvas(tagRavines))
 	if err != nil {
 		return nil, err
 	}
 
-	// find path of ravine
-	path, err := op.graph.Shortest(tagRavines, op.from, op.to)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	root := &types.PathTree{Tag: featureTag(op.graph, tagRavines, tag), Path: path}
+	width := float64(e.set.RavineWidth.RollWith(op.rng))
+	depth := op.rng.Float64()/5 + 0.8
+
+	err = e.cutRavine(op, cnv, root, width, depth)
 	if err != nil {
-		return nil, fmt.Errorf("%w %v", ErrNoPath, err)
+		return nil, err
+	}
+
+	type branch struct {
+		tree   *types.PathTree
+		width  float64
+		depth  float64
+		chance float64
+		level  int
+	}
+	todo := []*branch{{tree: root, width: width, depth: depth, chance: forkChance}}
+	forks := 0
+
+	for len(todo) > 0 {
+		if ctx.Err() != nil {
+			return nil, e.cancelled(ctx)
+		}
+
+		parent := todo[0]
+		todo = todo[1:]
+		if parent.level >= ravineForkDepth {
+			continue
+		}
+
+		// forks can't come off the very start or end
+		for i := 1; i < len(parent.tree.Path)-1; i++ {
+			if op.rng.Float64() >= parent.chance {
+				continue
+			}
+
+			fork, err := e.ravineFork(op, parent.tree.Path, i)
+			if err != nil {
+				continue // nowhere to go from here, that's fine
+			}
+
+			forks++
+			child := &branch{
+				tree:   &types.PathTree{Tag: fmt.Sprintf("%s/%d", root.Tag, forks), Path: fork},
+				width:  math.Max(1, parent.width*ravineForkShrink),
+				depth:  parent.depth * ravineForkShrink,
+				chance: parent.chance * ravineForkShrink,
+				level:  parent.level + 1,
+			}
+			err = e.cutRavine(op, cnv, child.tree, child.width, child.depth)
+			if err != nil {
+				return nil, err
+			}
+
+			parent.tree.Children = append(parent.tree.Children, child.tree)
+			todo = append(todo, child)
+		}
 	}
-	path = trimPath(path, op.maxDist)
-	if len(path) < 2 {
-		return nil, fmt.Errorf("%w path too short", ErrNoPath)
+
+	if ctx.Err() != nil {
+		return nil, e.cancelled(ctx)
 	}
 
-	cnv, err := op.pnt.Canvas(op.p.Canvas(tagRavines))
+	// save everything and return
+	err = stage.Save(cnv)
+	if err != nil {
+		return nil, err
+	}
+	err = stagedVoronoi(op.p, stage).Save(op.graph)
 	if err != nil {
 		return nil, err
 	}
+	return root, stage.Commit()
+}
 
+// cutRavine weights, tags & draws one branch of a ravine
+func (e *Editor) cutRavine(op *graphOperation, cnv paint.Canvas, t *types.PathTree, width, depth float64) error {
 	// mark ravine on mountain too
-	err = op.graph.IncrWeights(
-		path,
+	err := op.graph.IncrWeights(
+		t.Path,
 		map[string]int{
 			tagRavines:   e.set.GraphRavineWeight,
 			tagMountains: e.set.GraphRavineWeight,
@@ -234,47 +524,80 @@ func (e *Editor) AddRavine(proj, tag string, s *types.PathSpec, forkChance float
 		},
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if tag != "" {
-		op.graph.Tag(tag, path)
-	}
+	op.graph.TagAs(tagRavines, t.Tag, t.Path)
 
 	// draw the ravine
-	cnv.Channel(
-		path,
-		e.set.RavineWidth.Roll(),
-		rand.Float64()/5+0.8,
+	return wrapped(op.p, cnv).Channel(
+		t.Path,
+		int(width),
+		depth,
 		paint.Convex, // we'll treat > 0 as "low". Eg this map is inverted
 	)
+}
 
-	// save everything and return
-	err = op.voro.Save(op.graph)
+// ravineFork finds a path off from path[at], roughly at right angles to the parent &
+// shorter than the rest of the parent path.
+func (e *Editor) ravineFork(op *graphOperation, path []image.Point, at int) ([]image.Point, error) {
+	start := path[at]
+	line := unwrapped(op.p, path)
+	dir := line[at+1].Sub(line[at-1])
+
+	length := 0.0
+	for i := at + 1; i < len(path); i++ {
+		length += distance(op.p, path[i-1], path[i])
+	}
+	length *= ravineForkShrink
+
+	// turn left or right & a bit forward
+	norm := math.Hypot(float64(dir.X), float64(dir.Y))
+	if norm == 0 {
+		return nil, fmt.Errorf("%w no direction to fork", ErrNoPath)
+	}
+	side := 1.0
+	if op.rng.Intn(2) == 0 {
+		side = -1.0
+	}
+	fx := float64(dir.X) / norm
+	fy := float64(dir.Y) / norm
+	target := onWorld(op.p, image.Pt(
+		start.X+int((fx*0.5-fy*side)*length),
+		start.Y+int((fy*0.5+fx*side)*length),
+	))
+
+	fork, err := op.graph.Shortest(tagRavines, start, op.graph.ClosestPoint(target))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w %v", ErrNoPath, err)
+	}
+	fork = trimPath(op.p, fork, length)
+	if len(fork) < 2 {
+		return nil, fmt.Errorf("%w fork too short", ErrNoPath)
 	}
-	return path, op.pnt.Save(cnv)
+	return fork, nil
 }
 
 //
-func (e *Editor) AddMountainRange(proj, tag string, s *types.PathSpec, scale float64) ([]image.Point, []image.Point, error) {
-	op, err := e.newGraphOp(proj, s)
+func (e *Editor) AddMountainRange(ctx context.Context, proj, tag string, s *types.PathSpec, scale float64) ([]image.Point, []image.Point, error) {
+	op, err := e.newGraphOp(ctx, proj, s)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// find segments on voronoi that link the ends, mark as mountains
-	path, err := op.graph.Shortest(tagMountains, op.from, op.to)
+	path, err := op.path(tagMountains)
 	if err != nil {
-		return nil, nil, fmt.Errorf("%w %v", ErrNoPath, err)
+		return nil, nil, err
 	}
-	path = trimPath(path, op.maxDist)
-	if len(path) < 2 {
-		return nil, nil, fmt.Errorf("%w path too short", ErrNoPath)
+
+	stage, err := op.pnt.Begin()
+	if err != nil {
+		return nil, nil, err
 	}
+	defer stage.Rollback() // noop once committed
 
-	cnv, err := op.pnt.Canvas(op.p.Canvas(tagMountains))
+	cnv, err := stage.Canvas(op.p.Canvas(tagMountains))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -322,51 +645,32 @@ func (e *Editor) AddMountainRange(proj, tag string, s *types.PathSpec, scale flo
 		}
 
 		// tag mountain range on map
-		if tag != "" {
-			op.graph.Tag(tag, path)
-		}
-
-		err = op.voro.Save(op.graph)
-		if err != nil {
-			errchan <- err
-			return
-		}
+		op.graph.TagAs(tagMountains, featureTag(op.graph, tagMountains, tag), path)
 	}()
 
 	placed := []image.Point{}
 	go func() {
 		defer wg.Done()
-
-		// draw mountains along the path
-		for j := 1; j < len(path); j++ { // for each segment of the range
-			// walk along the segment
-			alongLine := voronoi.PointsBetween(path[j-1], path[j])
-			rangeHeight := 3 * rand.Float64() / 4
-			for p := 0; p < len(alongLine); {
-				// pick a point along the segment
-				centre := alongLine[p]
-				for mnt := 0; mnt < e.set.MountainsPerStep.Roll(); mnt++ {
-					// place mountain centred around segment point
-					cp := image.Pt(
-						centre.X-e.set.MountainRangeWidth/2+rand.Intn(e.set.MountainRangeWidth),
-						centre.Y-e.set.MountainRangeWidth/2+rand.Intn(e.set.MountainRangeWidth),
-					)
-					cnv.Ellipse(
-						cp,
-						e.set.Mountain.Roll(),
-						e.set.Mountain.Roll(),
-						rand.Intn(90),
-						(rand.Float64()/4+rangeHeight)*scale,
-						paint.Convex,
-					)
-					placed = append(placed, cp)
-				}
-				p += 1 + e.set.MountainStep.Roll()
-			}
-		}
-
-		errchan <- op.pnt.Save(cnv)
+		drawn, err := e.drawMountains(ctx, op.rng, wrapped(op.p, cnv), unwrapped(op.p, path), scale, 1)
+		placed = onWorldAll(op.p, drawn)
+		errchan <- err
 	}()
 
-	return placed, path, fanIn(errchan, wg)
+	err = fanIn(errchan, wg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, nil, e.cancelled(ctx)
+	}
+
+	err = stage.Save(cnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = stagedVoronoi(op.p, stage).Save(op.graph)
+	if err != nil {
+		return nil, nil, err
+	}
+	return placed, path, stage.Commit()
 }
@@ -0,0 +1,54 @@
+package geography
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func TestRavineForks(t *testing.T) {
+	ravine := func() (*types.PathTree, voronoi.Graph) {
+		ctx, e, p := testWorld(t, nil)
+		from, to := image.Pt(30, 100), image.Pt(270, 110)
+		tree, err := e.AddRavine(WithSeed(ctx, 3), p.ID, "gorge", &types.PathSpec{From: &from, To: &to, MaxDist: 400}, 0.3)
+		assert.Nil(t, err)
+
+		graph, err := voronoi.New(e.store, p.WorldWidth, p.WorldHeight).Graph(p.VoronoiDiagram())
+		assert.Nil(t, err)
+		return tree, graph
+	}
+
+	tree, graph := ravine()
+	assert.Greater(t, len(tree.Children), 0)
+
+	// forks come off the path they branch from & are tagged under the ravine
+	seen := map[string]bool{}
+	var check func(parent *types.PathTree)
+	check = func(parent *types.PathTree) {
+		for _, child := range parent.Children {
+			assert.Contains(t, parent.Path[1:len(parent.Path)-1], child.Path[0], child.Tag)
+			assert.True(t, strings.HasPrefix(child.Tag, tree.Tag+"/"), child.Tag)
+			assert.False(t, seen[child.Tag], child.Tag)
+			seen[child.Tag] = true
+
+			tagged, ok := graph.FromTag(child.Tag)
+			assert.True(t, ok, child.Tag)
+			assert.Equal(t, child.Path, tagged, child.Tag)
+			check(child)
+		}
+	}
+	check(tree)
+	for i := 1; i <= len(seen); i++ {
+		assert.True(t, seen[fmt.Sprintf("%s/%d", tree.Tag, i)], i)
+	}
+
+	// the same seed forks the same way
+	again, _ := ravine()
+	assert.Equal(t, tree, again)
+}
@@ -1,6 +1,7 @@
 package geography
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"math"
@@ -15,6 +16,31 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
+type randKey struct{}
+
+// WithSeed returns a context whose steps draw random numbers from a source seeded
+// with `seed`, so making a step again with the same seed (on the same world) makes
+// the same result. The source isn't safe to share between goroutines (see fork).
+func WithSeed(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, randKey{}, rand.New(rand.NewSource(seed)))
+}
+
+// random returns the context's source of random numbers (see WithSeed), or a new one
+// if it has none
+func random(ctx context.Context) *rand.Rand {
+	rng, ok := ctx.Value(randKey{}).(*rand.Rand)
+	if ok {
+		return rng
+	}
+	return rand.New(rand.NewSource(rand.Int63()))
+}
+
+// fork returns a new source of random numbers seeded from `rng`, for handing to a
+// goroutine
+func fork(rng *rand.Rand) *rand.Rand {
+	return rand.New(rand.NewSource(rng.Int63()))
+}
+
 func edgePoints(r image.Rectangle, h types.Heading) <-chan image.Point {
 	ch := make(chan image.Point)
 
@@ -104,6 +130,13 @@ func minInt(a, b int) int {
 	return b
 }
 
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // splitUint16 turns a uint16 into two uint8
 func splitUint16(in uint16) (uint8, uint8) {
 	return uint8(in >> 8), uint8(in)
@@ -142,14 +175,22 @@ func fanIn(errs chan error, wg *sync.WaitGroup) error {
 	return err  // return final err
 }
 
+// lessPoint orders points top to bottom, then left to right
+func lessPoint(a, b image.Point) bool {
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	return a.X < b.X
+}
+
 // pointNear returns a point nearby to `p`
-func pointNear(p image.Point, min int, max int) image.Point {
-	dx := rand.Intn(max-min) + min
-	dy := rand.Intn(max-min) + min
-	if rand.Intn(2) == 1 {
+func pointNear(rng *rand.Rand, p image.Point, min int, max int) image.Point {
+	dx := rng.Intn(max-min) + min
+	dy := rng.Intn(max-min) + min
+	if rng.Intn(2) == 1 {
 		dx *= -1
 	}
-	if rand.Intn(2) == 1 {
+	if rng.Intn(2) == 1 {
 		dy *= -1
 	}
 	return image.Pt(p.X+dx, p.Y+dy)
@@ -160,11 +201,12 @@ func distBetween(ax, ay, bx, by int) float64 {
 	return math.Sqrt(math.Pow(float64(ax-bx), 2) + math.Pow(float64(ay-by), 2))
 }
 
-//
-func trimPath(path []image.Point, max float64) []image.Point {
+// trimPath cuts a path down to at most `max` long (measured around the world if it
+// wraps)
+func trimPath(p *types.Project, path []image.Point, max float64) []image.Point {
 	total := 0.0
 	for i := 1; i < len(path); i++ {
-		total += distBetween(path[i-1].X, path[i-1].Y, path[i].X, path[i].Y)
+		total += distance(p, path[i-1], path[i])
 		if total >= max {
 			return path[0:i]
 		}
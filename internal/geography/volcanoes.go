@@ -1,7 +1,7 @@
 package geography
 
 import (
-	"fmt"
+	"context"
 	"image"
 	"math/rand"
 
@@ -12,63 +12,97 @@ import (
 
 // Volcanoes are similar to mountains in that they follow fault lines, but are placed
 // less frequently & further out (they don't sit directly on the line).
-func (e *Editor) AddVolanoes(proj string, count int, s *types.PathSpec) ([]image.Point, []image.Point, error) {
-	op, err := e.newGraphOp(proj, s)
+func (e *Editor) AddVolanoes(ctx context.Context, proj string, count int, s *types.PathSpec) ([]image.Point, []image.Point, error) {
+	op, err := e.newGraphOp(ctx, proj, s)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	cnv, err := op.pnt.Canvas(op.p.Canvas(tagMountains))
+	stage, err := op.pnt.Begin()
 	if err != nil {
 		return nil, nil, err
 	}
+	defer stage.Rollback() // noop once committed
 
-	path, err := op.graph.Shortest(tagVolcanoes, op.from, op.to)
+	cnv, err := stage.Canvas(op.p.Canvas(tagMountains))
 	if err != nil {
-		return nil, nil, fmt.Errorf("%w %v", ErrNoPath, err)
+		return nil, nil, err
 	}
-	path = trimPath(path, op.maxDist)
-	if len(path) < 2 {
-		return nil, nil, fmt.Errorf("%w path too short", ErrNoPath)
+
+	path, err := op.path(tagVolcanoes)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// choose where we might put a volcano
 	candidates := []image.Point{}
-	for j := 1; j < len(path); j++ { // for each segment of the range
-		alongLine := voronoi.PointsBetween(path[j-1], path[j])
-		for p := rand.Intn(5); p < len(alongLine); {
+	line := unwrapped(op.p, path)
+	for j := 1; j < len(line); j++ { // for each segment of the range
+		alongLine := voronoi.PointsBetween(line[j-1], line[j])
+		for p := op.rng.Intn(5); p < len(alongLine); {
 			candidates = append(
 				candidates,
-				pointNear(alongLine[p], e.set.VolcanoRangeWidth/2, e.set.VolcanoRangeWidth),
+				pointNear(op.rng, alongLine[p], e.set.VolcanoRangeWidth/2, e.set.VolcanoRangeWidth),
 			)
-			p += 1 + e.set.VolcanoStep.Roll()
+			p += 1 + e.set.VolcanoStep.RollWith(op.rng)
 		}
 	}
 
 	if len(candidates) > count {
-		rand.Shuffle(len(candidates), func(a, b int) {
+		op.rng.Shuffle(len(candidates), func(a, b int) {
 			candidates[a], candidates[b] = candidates[b], candidates[a]
 		})
 		candidates = candidates[0:count]
 	}
 	for _, p := range candidates {
-		cnv.Ellipse( // cone
-			p,
-			e.set.VolcanoCone.Roll(),
-			e.set.VolcanoCone.Roll(),
-			rand.Intn(90),
-			0.75+rand.Float64()/4,
-			paint.Convex,
-		)
-		cnv.Ellipse( // caldera
-			p,
-			e.set.VolcanoCaldera.Roll(),
-			e.set.VolcanoCaldera.Roll(),
-			rand.Intn(90),
-			0.75+rand.Float64()/4,
-			paint.Concave,
-		)
+		err = e.drawVolcano(op.rng, wrapped(op.p, cnv), p, 1)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+	candidates = onWorldAll(op.p, candidates)
 
-	return candidates, path, op.pnt.Save(cnv)
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	// record where we put volcanoes so we can find them later
+	op.graph.TagAs(tagVolcanoes, featureTag(op.graph, tagVolcanoes, ""), candidates)
+	err = stage.Save(cnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = stagedVoronoi(op.p, stage).Save(op.graph)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return candidates, path, stage.Commit()
+}
+
+// drawVolcano draws a volcano (cone & caldera) centred on p. Size scales the width of
+// both (eg. for larger worlds).
+func (e *Editor) drawVolcano(rng *rand.Rand, cnv paint.Canvas, p image.Point, size float64) error {
+	sized := func(v int) int {
+		return int(float64(v) * size)
+	}
+	err := cnv.Ellipse( // cone
+		p,
+		sized(e.set.VolcanoCone.RollWith(rng)),
+		sized(e.set.VolcanoCone.RollWith(rng)),
+		rng.Intn(90),
+		0.75+rng.Float64()/4,
+		paint.Convex,
+	)
+	if err != nil {
+		return err
+	}
+	return cnv.Ellipse( // caldera
+		p,
+		sized(e.set.VolcanoCaldera.RollWith(rng)),
+		sized(e.set.VolcanoCaldera.RollWith(rng)),
+		rng.Intn(90),
+		0.75+rng.Float64()/4,
+		paint.Concave,
+	)
 }
@@ -0,0 +1,181 @@
+package geography
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/voidshard/genesis/internal/globe"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// Wind works out prevailing (surface) winds all over the world, which Rain carries
+// moisture along. Winds are saved as a canvas; red is how far east & green how far
+// north the wind blows (128 being still air) & blue is it's speed.
+//
+// Air rises at the equator & sinks around 30 degrees (Hadley cells), rises again around
+// 60 degrees (polar cells) with Ferrel cells between. So at the surface air flows towards
+// the equator (0-30 & 60-90 degrees) or towards the poles (30-60). The spin of the world
+// turns these to the right in the north & left in the south (Coriolis) giving easterly
+// trade winds, westerlies & polar easterlies. Mountains turn winds aside.
+//
+// Flat worlds are taken to go from the north pole (top) to the south pole (bottom).
+func (e *Editor) Wind(ctx context.Context, proj string) (image.Image, error) {
+	p, err := e.project(proj)
+	if err != nil {
+		return nil, err
+	}
+	pnt := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+
+	stage, err := pnt.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Rollback() // noop once committed
+
+	heights, err := e.windHeights(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	wind, err := e.windCanvas(ctx, p, stage, heights, p.Canvas(tagWind), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = stage.Save(wind)
+	if err != nil {
+		return nil, err
+	}
+	im, err := paint.Image(wind)
+	if err != nil {
+		return nil, err
+	}
+	return im, stage.Commit()
+}
+
+// windHeights returns the heightmap winds turn aside from (see windCanvas)
+func (e *Editor) windHeights(ctx context.Context, p *types.Project) (image.Image, error) {
+	hmap, err := e.HeightMap(ctx, p.ID, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	if err != nil {
+		return nil, err
+	}
+
+	// winds turn around mountains rather than every little bump
+	return smoothed(p, hmap, e.set.WindMountainRadius)
+}
+
+// windCanvas works out the wind (see Wind) into a new canvas called `name`, with the
+// cells centred on latitude `itcz` (radians) rather than the equator
+func (e *Editor) windCanvas(ctx context.Context, p *types.Project, pnt paint.Painter, hmap image.Image, name string, itcz float64) (paint.Canvas, error) {
+	height := func(x, y int) float64 {
+		x, ok := neighbourX(p, x)
+		if !ok {
+			x = minInt(p.WorldWidth-1, maxInt(0, x))
+		}
+		return float64(grey(hmap, x, minInt(p.WorldHeight-1, maxInt(0, y))))
+	}
+
+	// so winds aren't all perfectly straight
+	variation := perlin(random(ctx).Int63(), p, e.set.WindVariationScale, true)
+
+	im := image.NewRGBA(image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	every := p.WorldHeight/100 + 1
+	for y := 0; y < p.WorldHeight; y++ {
+		if y%every == 0 && progress.Report(ctx, "wind", y, p.WorldHeight, "rows") != nil {
+			return nil, ctx.Err()
+		}
+
+		east, north := e.surfaceWind(globe.Latitude(y, p.WorldHeight), itcz)
+		for x := 0; x < p.WorldWidth; x++ {
+			// turn by up to +/- WindVariation degrees
+			turn := (float64(variation.GrayAt(x, y).Y)/255*2 - 1) * e.set.WindVariation * math.Pi / 180
+			sin, cos := math.Sin(turn), math.Cos(turn)
+			we, wn := east*cos-north*sin, east*sin+north*cos
+
+			// take away however much of the wind would go up a slope, more so the
+			// steeper it is. In image terms north is -y.
+			gx := (height(x+1, y) - height(x-1, y)) / 2
+			gy := (height(x, y+1) - height(x, y-1)) / 2
+			slope := math.Hypot(gx, gy)
+			if slope > 0 {
+				ux, uy := gx/slope, gy/slope
+				uphill := we*ux - wn*uy
+				if uphill > 0 {
+					bend := math.Min(1, slope*e.set.WindMountainBend)
+					we -= ux * uphill * bend
+					wn += uy * uphill * bend
+				}
+			}
+
+			im.SetRGBA(x, y, windColour(we, wn))
+		}
+	}
+	progress.Report(ctx, "wind", p.WorldHeight, p.WorldHeight, "rows")
+
+	return pnt.NewCanvasFromImage(name, im)
+}
+
+// surfaceWind returns the wind (how far east & north, -1 to 1) at some latitude in
+// radians, before it's turned aside by mountains. Cells are squashed & stretched so
+// they meet at `itcz` rather than the equator, but still end at the poles.
+func (e *Editor) surfaceWind(lat, itcz float64) (float64, float64) {
+	cell := (lat - itcz) / (math.Pi/2 - itcz) * math.Pi / 2
+	if lat < itcz {
+		cell = (lat - itcz) / (math.Pi/2 + itcz) * math.Pi / 2
+	}
+
+	// + away from the itcz (Ferrel cells), - towards it (Hadley & polar cells)
+	poleward := -math.Sin(6 * math.Abs(cell))
+	north := poleward
+	if cell < 0 {
+		north = -poleward
+	}
+
+	// coriolis turns winds right (clockwise) in the north & left in the south, more so
+	// nearer the poles
+	turn := e.set.WindCoriolis * math.Pi / 180 * math.Sqrt(math.Abs(math.Sin(lat)))
+	if lat > 0 {
+		turn = -turn
+	}
+	return -north * math.Sin(turn), north * math.Cos(turn)
+}
+
+// windColour encodes wind for the wind canvas. Red is never 0 so a blank canvas
+// means we've not worked out the wind (see windMade).
+func windColour(east, north float64) color.RGBA {
+	enc := func(v float64) uint8 {
+		return uint8(math.Round(128 + 127*math.Max(-1, math.Min(1, v))))
+	}
+	return color.RGBA{
+		enc(east),
+		enc(north),
+		uint8(math.Round(math.Min(1, math.Hypot(east, north)) * 255)),
+		255,
+	}
+}
+
+// windAt decodes the wind at some point of the wind canvas (how far east & north)
+func windAt(cnv paint.Canvas, x, y int) (float64, float64, error) {
+	c, err := cnv.At(x, y)
+	if err != nil {
+		return 0, 0, err
+	}
+	r, g, _, _ := c.RGBA()
+	east, north := decodeWind(uint8(r>>8), uint8(g>>8))
+	return east, north, nil
+}
+
+// decodeWind decodes the red & green of a wind canvas pixel (see windColour)
+func decodeWind(r, g uint8) (float64, float64) {
+	return (float64(r) - 128) / 127, (float64(g) - 128) / 127
+}
+
+// windMade returns if the wind canvas has been worked out (see Wind)
+func windMade(cnv paint.Canvas) (bool, error) {
+	bnds := cnv.Bounds()
+	r, err := cnv.R(bnds.Min.X+bnds.Dx()/2, bnds.Min.Y+bnds.Dy()/2)
+	return r > 0, err
+}
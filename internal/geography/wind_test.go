@@ -0,0 +1,109 @@
+package geography
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/globe"
+	"github.com/voidshard/genesis/internal/paint"
+)
+
+func degrees(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func TestSurfaceWind(t *testing.T) {
+	e := &Editor{set: DefaultSettings()}
+
+	cases := []struct {
+		Name     string
+		Lat      float64
+		East     bool // blows east (or west)
+		Poleward bool // blows towards the pole (or equator)
+	}{
+		{"north trades", 15, false, false},
+		{"north westerlies", 45, true, true},
+		{"north polar easterlies", 75, false, false},
+		{"south trades", -15, false, false},
+		{"south westerlies", -45, true, true},
+		{"south polar easterlies", -75, false, false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			east, north := e.surfaceWind(degrees(tt.Lat), 0)
+			assert.Equal(t, tt.East, east > 0, east)
+
+			poleward := north
+			if tt.Lat < 0 {
+				poleward = -north
+			}
+			assert.Equal(t, tt.Poleward, poleward > 0, north)
+		})
+	}
+
+	// cells follow the itcz, so the trades meet there rather than at the equator
+	east, north := e.surfaceWind(degrees(10), degrees(10))
+	assert.InDelta(t, 0, east, 1e-9)
+	assert.InDelta(t, 0, north, 1e-9)
+	_, north = e.surfaceWind(degrees(5), degrees(10))
+	assert.Greater(t, north, 0.0)
+}
+
+func TestWindColour(t *testing.T) {
+	for _, w := range [][2]float64{{0, 0}, {1, 0}, {-0.5, 0.25}, {0.3, -1}} {
+		c := windColour(w[0], w[1])
+		assert.Greater(t, c.R, uint8(0))
+
+		east, north := decodeWind(c.R, c.G)
+		assert.InDelta(t, w[0], east, 0.01)
+		assert.InDelta(t, w[1], north, 0.01)
+	}
+}
+
+func TestWind(t *testing.T) {
+	ctx, e, p := testWorld(t, nil)
+
+	im, err := e.Wind(ctx, p.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, p.WorldWidth, im.Bounds().Dx())
+
+	// mostly blowing the way the cell at each latitude does
+	eastward := func(lat float64) float64 {
+		y := globe.Y(degrees(lat), p.WorldHeight)
+		n := 0
+		for x := 0; x < p.WorldWidth; x++ {
+			r, g, _, _ := im.At(x, y).RGBA()
+			east, _ := decodeWind(uint8(r>>8), uint8(g>>8))
+			if east > 0 {
+				n++
+			}
+		}
+		return float64(n) / float64(p.WorldWidth)
+	}
+	assert.Less(t, eastward(15), 0.25)
+	assert.Greater(t, eastward(45), 0.75)
+	assert.Less(t, eastward(-15), 0.25)
+
+	// rain follows the wind we made, falling on land (& never the sea)
+	rain, err := e.Rain(ctx, p.ID, 1, nil)
+	assert.Nil(t, err)
+	sea, err := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight).Canvas(p.Canvas(tagSea))
+	assert.Nil(t, err)
+	seamap, err := paint.Image(sea)
+	assert.Nil(t, err)
+
+	wet := 0
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			if blue(rain, x, y) == 0 {
+				continue
+			}
+			assert.False(t, isSea(seamap, x, y), x, y)
+			wet++
+		}
+	}
+	assert.Greater(t, wet, 0)
+}
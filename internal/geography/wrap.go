@@ -0,0 +1,284 @@
+package geography
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/voidshard/genesis/internal/globe"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// Worlds that wrap (see types.Project) are cylinders, x=0 & x=WorldWidth-1 are
+// neighbours. Points we keep (eg. in graphs, tags) are always on the world, but while
+// drawing a path across the edge we carry on past it (see voronoi.Unwrap) & draw
+// whatever is past the edge on the other side.
+//
+// Spheres wrap too, but are also drawn wider near the poles (see globe).
+
+// wrapCanvas draws shapes that cross the east / west edge of a wrapping world on both
+// sides of it. Save the canvas it wraps, not this.
+type wrapCanvas struct {
+	paint.Canvas
+	width  int
+	height int // set if we're a globe
+}
+
+// wrapped returns a canvas to draw on for the project, which is `cnv` unless the world
+// wraps
+func wrapped(p *types.Project, cnv paint.Canvas) paint.Canvas {
+	if !p.Wrap {
+		return cnv
+	}
+	w := &wrapCanvas{Canvas: cnv, width: p.WorldWidth}
+	if p.Sphere() {
+		w.height = p.WorldHeight
+	}
+	return w
+}
+
+// shape returns the shape of graph to make for the project
+func shape(p *types.Project) voronoi.Shape {
+	if p.Sphere() {
+		return voronoi.Sphere
+	} else if p.Wrap {
+		return voronoi.Cylinder
+	}
+	return voronoi.Flat
+}
+
+// distance between two points on the world (ie. around or over it, if it wraps)
+func distance(p *types.Project, a, b image.Point) float64 {
+	if p.Sphere() {
+		return globe.Distance(a, b, p.WorldWidth, p.WorldHeight)
+	} else if p.Wrap {
+		return voronoi.Distance(a, b, p.WorldWidth)
+	}
+	return distBetween(a.X, a.Y, b.X, b.Y)
+}
+
+// perlin returns perlin noise the size of the world (see paint.NewPerlin) that
+// carries on over the east / west edges if the world wraps
+func perlin(seed int64, p *types.Project, scale float64, stretch bool) *image.Gray {
+	if p.Sphere() {
+		return paint.NewPerlinSphere(seed, p.WorldWidth, p.WorldHeight, scale, stretch)
+	} else if p.Wrap {
+		return paint.NewPerlinWrapped(seed, p.WorldWidth, p.WorldHeight, scale, stretch)
+	}
+	return paint.NewPerlin(seed, p.WorldWidth, p.WorldHeight, scale, stretch)
+}
+
+// unwrapped returns the path as one continuous line if the world wraps
+func unwrapped(p *types.Project, path []image.Point) []image.Point {
+	if !p.Wrap {
+		return path
+	}
+	return voronoi.Unwrap(path, p.WorldWidth)
+}
+
+// onWorld returns where a point past the east / west edge of a wrapping world is
+func onWorld(p *types.Project, at image.Point) image.Point {
+	if p.Wrap {
+		at.X = voronoi.WrapX(at.X, p.WorldWidth)
+	}
+	return at
+}
+
+// onWorldAll is onWorld for many points
+func onWorldAll(p *types.Project, pts []image.Point) []image.Point {
+	if !p.Wrap {
+		return pts
+	}
+	out := make([]image.Point, len(pts))
+	for i, at := range pts {
+		out[i] = onWorld(p, at)
+	}
+	return out
+}
+
+// neighbourX returns x of a neighbouring pixel (around the world if it wraps) & whether
+// it's on the world at all
+func neighbourX(p *types.Project, x int) (int, bool) {
+	if p.Wrap {
+		return voronoi.WrapX(x, p.WorldWidth), true
+	}
+	return x, x >= 0 && x < p.WorldWidth
+}
+
+// offsets returns how far to move something drawn between minX & maxX so each part of
+// it is drawn on the world
+func (w *wrapCanvas) offsets(minX, maxX int) []int {
+	out := []int{}
+	for k := floorDiv(minX, w.width); k <= floorDiv(maxX, w.width); k++ {
+		out = append(out, -k*w.width)
+	}
+	return out
+}
+
+func (w *wrapCanvas) Set(x, y int, c color.Color) error {
+	return w.Canvas.Set(voronoi.WrapX(x, w.width), y, c)
+}
+
+func (w *wrapCanvas) Line(line []image.Point, width int, colours ...color.Color) error {
+	line = voronoi.Unwrap(line, w.width)
+	lo, hi := spanX(line)
+	for _, dx := range w.offsets(lo-width, hi+width) {
+		err := w.Canvas.Line(shiftX(line, dx), width, colours...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *wrapCanvas) Polygon(outline [][2]image.Point, c color.Color) error {
+	pts := []image.Point{}
+	for _, e := range outline {
+		pts = append(pts, e[0], e[1])
+	}
+	lo, hi := spanX(pts)
+	for _, dx := range w.offsets(lo, hi) {
+		moved := make([][2]image.Point, len(outline))
+		for i, e := range outline {
+			moved[i] = [2]image.Point{e[0].Add(image.Pt(dx, 0)), e[1].Add(image.Pt(dx, 0))}
+		}
+		err := w.Canvas.Polygon(moved, c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *wrapCanvas) Ellipse(centre image.Point, rx, ry, rotationDegrees int, depth float64, mode paint.Mode) error {
+	if w.height > 0 {
+		rx, ry = w.stretched(centre.Y, rx, ry, rotationDegrees)
+	}
+	r := rx
+	if ry > r {
+		r = ry
+	}
+	for _, dx := range w.offsets(centre.X-r, centre.X+r) {
+		err := w.Canvas.Ellipse(centre.Add(image.Pt(dx, 0)), rx, ry, rotationDegrees, depth, mode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stretched returns the radii of an ellipse drawn on row y of a globe, so that it's
+// the given size on the sphere. We stretch each axis by how much it points east / west.
+func (w *wrapCanvas) stretched(y, rx, ry, rotationDegrees int) (int, int) {
+	if y < 0 {
+		y = 0
+	} else if y >= w.height {
+		y = w.height - 1
+	}
+	s := globe.Stretch(y, w.height)
+	theta := float64(rotationDegrees) * math.Pi / 180
+	cos, sin := math.Cos(theta), math.Sin(theta)
+
+	axis := func(r int, along, across float64) int {
+		out := float64(r) * math.Hypot(along*s, across)
+		return int(math.Min(out, float64(w.width/2))) // past half way it covers every longitude
+	}
+	return axis(rx, cos, sin), axis(ry, sin, cos)
+}
+
+func (w *wrapCanvas) Channel(pts []image.Point, width int, depth float64, mode paint.Mode) error {
+	pts = voronoi.Unwrap(pts, w.width)
+	lo, hi := spanX(pts)
+	for _, dx := range w.offsets(lo-width, hi+width) {
+		err := w.Canvas.Channel(shiftX(pts, dx), width, depth, mode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// smoothed is paint.SmoothImage but if the world wraps (& the image is the whole world
+// across) the east & west edges are smoothed into each other
+func smoothed(p *types.Project, im image.Image, radius uint32) (image.Image, error) {
+	bnds := im.Bounds()
+	if !p.Wrap || bnds.Dx() != p.WorldWidth {
+		return paint.SmoothImage(im, radius)
+	}
+
+	// pad either side with the far edge, smooth & cut the padding off again
+	w, h := bnds.Dx(), bnds.Dy()
+	pad := int(radius) * 2
+	if pad > w {
+		pad = w
+	}
+	padded := image.NewRGBA(image.Rect(0, 0, w+pad*2, h))
+	draw.Draw(padded, image.Rect(pad, 0, pad+w, h), im, bnds.Min, draw.Src)
+	draw.Draw(padded, image.Rect(0, 0, pad, h), im, image.Pt(bnds.Max.X-pad, bnds.Min.Y), draw.Src)
+	draw.Draw(padded, image.Rect(pad+w, 0, pad*2+w, h), im, bnds.Min, draw.Src)
+
+	smooth, err := paint.SmoothImage(padded, radius)
+	if err != nil {
+		return nil, err
+	}
+	out := image.NewNRGBA(bnds)
+	draw.Draw(out, bnds, smooth, image.Pt(pad, 0), draw.Src)
+	return out, nil
+}
+
+// smoothCanvas is cnv.Smooth but wraps like smoothed, returns the smoothed canvas
+func smoothCanvas(p *types.Project, pnt paint.Painter, cnv paint.Canvas, radius uint32) (paint.Canvas, error) {
+	if !p.Wrap {
+		return cnv, cnv.Smooth(radius)
+	}
+	im, err := paint.Image(cnv)
+	if err != nil {
+		return nil, err
+	}
+	im, err = smoothed(p, im, radius)
+	if err != nil {
+		return nil, err
+	}
+	return pnt.NewCanvasFromImage(cnv.Name(), im)
+}
+
+// spanX returns the smallest & largest x of some points
+func spanX(pts []image.Point) (int, int) {
+	if len(pts) == 0 {
+		return 0, -1
+	}
+	lo, hi := pts[0].X, pts[0].X
+	for _, p := range pts[1:] {
+		if p.X < lo {
+			lo = p.X
+		}
+		if p.X > hi {
+			hi = p.X
+		}
+	}
+	return lo, hi
+}
+
+// shiftX returns a copy of points moved dx along x
+func shiftX(pts []image.Point, dx int) []image.Point {
+	if dx == 0 {
+		return pts
+	}
+	out := make([]image.Point, len(pts))
+	for i, p := range pts {
+		out[i] = image.Pt(p.X+dx, p.Y)
+	}
+	return out
+}
+
+// floorDiv is a / b rounded down (rather than towards 0)
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
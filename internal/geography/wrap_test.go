@@ -0,0 +1,128 @@
+package geography
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/blob"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/voronoi"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func sphere() *types.Project {
+	return &types.Project{WorldWidth: testWidth, WorldHeight: testHeight, Wrap: true, Projection: types.ProjectionSphere}
+}
+
+func TestDistance(t *testing.T) {
+	flat := &types.Project{WorldWidth: testWidth, WorldHeight: testHeight}
+	cylinder := &types.Project{WorldWidth: testWidth, WorldHeight: testHeight, Wrap: true}
+	globe := sphere()
+
+	// across the east / west edge
+	a, b := image.Pt(5, 100), image.Pt(testWidth-5, 100)
+	assert.Equal(t, float64(testWidth-10), distance(flat, a, b))
+	assert.Equal(t, 10.0, distance(cylinder, a, b))
+	assert.InDelta(t, 10.0, distance(globe, a, b), 0.1)
+
+	// on a globe east / west steps are shorter near the poles
+	assert.Less(t, distance(globe, image.Pt(100, 10), image.Pt(150, 10)), distance(globe, image.Pt(100, 100), image.Pt(150, 100)))
+	assert.Equal(t, distance(cylinder, image.Pt(100, 10), image.Pt(150, 10)), distance(cylinder, image.Pt(100, 100), image.Pt(150, 100)))
+}
+
+func TestSphereEllipse(t *testing.T) {
+	p := sphere()
+	cnv, err := paint.New(blob.NewMemory(), "", p.WorldWidth, p.WorldHeight).Canvas("test")
+	assert.Nil(t, err)
+
+	// the same sized circle on the globe is drawn wider near the poles
+	draw := wrapped(p, cnv)
+	assert.Nil(t, draw.Ellipse(image.Pt(150, 100), 10, 10, 0, 1, paint.Convex))
+	assert.Nil(t, draw.Ellipse(image.Pt(150, 25), 10, 10, 0, 1, paint.Convex))
+
+	im, err := paint.Image(cnv)
+	assert.Nil(t, err)
+	across := func(y int) int {
+		n := 0
+		for x := 0; x < p.WorldWidth; x++ {
+			if grey(im, x, y) > 0 {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Greater(t, across(25), across(100)*3/2)
+
+	// & drawn over the east / west edge on the other side
+	assert.Nil(t, draw.Ellipse(image.Pt(2, 150), 10, 10, 0, 1, paint.Convex))
+	im, err = paint.Image(cnv)
+	assert.Nil(t, err)
+	assert.Greater(t, grey(im, p.WorldWidth-3, 150), uint8(0))
+}
+
+func TestSpherePerlin(t *testing.T) {
+	p := sphere()
+	noise := perlin(1, p, 0.5, false)
+
+	spread := func(y int) int {
+		lo, hi := 255, 0
+		for x := 0; x < p.WorldWidth; x++ {
+			v := int(noise.GrayAt(x, y).Y)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		return hi - lo
+	}
+
+	// the top row is all (nearly) the north pole, so hardly changes
+	assert.Less(t, spread(0), spread(p.WorldHeight/2))
+
+	// no seam where the east & west edges meet
+	for y := 10; y < p.WorldHeight; y += 40 {
+		assert.InDelta(t, int(noise.GrayAt(0, y).Y), int(noise.GrayAt(p.WorldWidth-1, y).Y), 6, y)
+	}
+
+	assert.Equal(t, noise, perlin(1, p, 0.5, false))
+}
+
+func TestSphereWorld(t *testing.T) {
+	ctx, e, p := testEditor(t, sphere())
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 60))
+	seamap, _, err := e.SeaMap(ctx, p.ID, 100, 30, 30, 2)
+	assert.Nil(t, err)
+
+	graph, err := e.cachedGraph(voronoi.New(e.store, p.WorldWidth, p.WorldHeight), p.VoronoiDiagram())
+	assert.Nil(t, err)
+
+	// sites are spread over the globe, so fewer are in the (stretched) polar rows
+	polar, equator := 0, 0
+	for _, s := range graph.Sites() {
+		if s.Y < 25 || s.Y >= 175 {
+			polar++
+		} else if s.Y >= 75 && s.Y < 125 {
+			equator++
+		}
+	}
+	assert.Less(t, polar, equator)
+
+	// the sea is colder at the poles than the equator
+	temperature := func(y int) float64 {
+		sum, n := 0, 0
+		for x := 0; x < p.WorldWidth; x++ {
+			if isSea(seamap, x, y) {
+				sum += int(blue(seamap, x, y))
+				n++
+			}
+		}
+		assert.Greater(t, n, 0, y)
+		return float64(sum) / float64(n)
+	}
+	assert.Less(t, temperature(0), temperature(100))
+	assert.Less(t, temperature(p.WorldHeight-1), temperature(100))
+}
@@ -0,0 +1,60 @@
+// Package globe is geometry for worlds that are spheres drawn equirectangular. That is
+// x is longitude (-180 degrees at x=0 to 180 at x=width) & y is latitude (90 degrees, the
+// north pole, at y=0 to -90 at y=height).
+//
+// Distances are in pixels as measured at the equator, where the image isn't stretched.
+package globe
+
+import (
+	"image"
+	"math"
+)
+
+// Latitude of the centre of row y in radians
+func Latitude(y, height int) float64 {
+	return math.Pi/2 - (float64(y)+0.5)/float64(height)*math.Pi
+}
+
+// Longitude of the centre of column x in radians
+func Longitude(x, width int) float64 {
+	return (float64(x)+0.5)/float64(width)*2*math.Pi - math.Pi
+}
+
+// Y returns the row at some latitude (radians)
+func Y(lat float64, height int) int {
+	y := int((math.Pi/2 - lat) / math.Pi * float64(height))
+	if y < 0 {
+		return 0
+	} else if y >= height {
+		return height - 1
+	}
+	return y
+}
+
+// Radius of a world `width` pixels around the equator
+func Radius(width int) float64 {
+	return float64(width) / (2 * math.Pi)
+}
+
+// Distance is the great circle distance between two points
+func Distance(a, b image.Point, width, height int) float64 {
+	lat1, lat2 := Latitude(a.Y, height), Latitude(b.Y, height)
+	dlat := lat2 - lat1
+	dlon := Longitude(b.X, width) - Longitude(a.X, width)
+
+	// haversine
+	h := math.Pow(math.Sin(dlat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dlon/2), 2)
+	return 2 * Radius(width) * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// Stretch is how much wider than at the equator something is drawn on row y, ie. east /
+// west distances shrink by this much as we near the poles.
+func Stretch(y, height int) float64 {
+	return 1 / math.Cos(Latitude(y, height))
+}
+
+// SiteY returns a row for u (0-1) such that uniformly random u are spread evenly over
+// the surface of the sphere (fewer rows near the poles, since they're smaller).
+func SiteY(u float64, height int) int {
+	return Y(math.Asin(2*u-1), height)
+}
@@ -0,0 +1,40 @@
+package globe
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	cases := []struct {
+		Name   string
+		A, B   image.Point
+		Expect float64
+	}{
+		{"same", image.Pt(10, 10), image.Pt(10, 10), 0},
+		{"along-equator", image.Pt(0, 100), image.Pt(100, 100), 100},
+		{"over-the-edge", image.Pt(0, 100), image.Pt(399, 100), 1},
+		{"pole-to-pole", image.Pt(0, 0), image.Pt(200, 199), 200},
+		{"near-pole", image.Pt(0, 0), image.Pt(200, 0), 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			// a world 400 around is 200 pole to pole, pixel centres are a little off the poles
+			assert.InDelta(t, c.Expect, Distance(c.A, c.B, 400, 200), 0.5)
+		})
+	}
+}
+
+func TestSiteY(t *testing.T) {
+	assert.Equal(t, 100, SiteY(0.5, 200))
+	assert.Equal(t, 0, SiteY(1, 200))
+	assert.Equal(t, 199, SiteY(0, 200))
+
+	// a quarter of the sphere is within 14.5 degrees of the equator
+	y := SiteY(0.625, 200)
+	assert.InDelta(t, 14.5, (Latitude(y, 200))*180/math.Pi, 1)
+}
@@ -0,0 +1,398 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/voidshard/genesis/internal/database"
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+const (
+	// saveInterval is the most often we'll write progress of a job to the DB
+	saveInterval = time.Second
+
+	// msgInterrupted is the error set on jobs that were running when we last stopped
+	msgInterrupted = "interrupted (process stopped while job was running)"
+)
+
+var (
+	// ErrNotFound is returned if a job doesn't exist
+	ErrNotFound = fmt.Errorf("job not found")
+
+	// ErrFinished is returned if asked to cancel a job that has already finished
+	ErrFinished = fmt.Errorf("job already finished")
+
+	// ErrClosed is returned if a job is submitted after the runner is closed
+	ErrClosed = fmt.Errorf("job runner closed")
+)
+
+// Func is the work a job performs. It should return promptly (with ctx.Err())
+// when the context is cancelled & can report progress via progress.Report
+type Func func(ctx context.Context) (interface{}, error)
+
+// Runner runs jobs in the background one at a time (in the order they're submitted)
+// & keeps their state in the DB.
+type Runner struct {
+	db database.Database
+
+	lock    sync.Mutex
+	live    map[string]*entry // jobs queued or running
+	pending []*entry
+	wake    chan struct{}
+	closed  bool
+	done    chan struct{} // closed when work returns
+}
+
+// entry is a job we're holding in memory
+type entry struct {
+	job    *types.Job
+	run    Func
+	ctx    context.Context
+	cancel context.CancelFunc
+	subs   []chan *types.Job
+	saved  time.Time
+}
+
+// New returns a runner that saves jobs to the given DB.
+//
+// Any jobs left queued or running (eg. by a crash) are marked as failed.
+func New(db database.Database) (*Runner, error) {
+	r := &Runner{
+		db:   db,
+		live: map[string]*entry{},
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+
+	err := r.failInterrupted()
+	if err != nil {
+		return nil, err
+	}
+
+	go r.work()
+	return r, nil
+}
+
+// Submit queues `fn` to be run & returns the new job
+func (r *Runner) Submit(projectID, kind string, fn Func) (*types.Job, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &entry{
+		job: &types.Job{
+			ID:        dbutils.RandomID(),
+			ProjectID: projectID,
+			Kind:      kind,
+			Status:    types.JobQueued,
+			Created:   time.Now(),
+		},
+		run:    fn,
+		cancel: cancel,
+	}
+	e.ctx = progress.WithReporter(ctx, func(ev *progress.Event) { r.progress(e, ev) })
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		cancel()
+		return nil, ErrClosed
+	}
+
+	err := r.save(e.job)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	r.live[e.job.ID] = e
+	r.pending = append(r.pending, e)
+	cpy := *e.job
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+
+	return &cpy, nil
+}
+
+// Close cancels all queued & running jobs, waiting for the running job to return.
+// No more jobs can be submitted afterwards.
+func (r *Runner) Close() {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		<-r.done
+		return
+	}
+	r.closed = true
+	for _, e := range r.live {
+		e.cancel()
+		if e.job.Status == types.JobQueued {
+			r.finish(e, nil, context.Canceled)
+		}
+	}
+	r.pending = nil
+	close(r.wake)
+	r.lock.Unlock()
+
+	<-r.done
+}
+
+// Job returns a job by ID
+func (r *Runner) Job(id string) (*types.Job, error) {
+	r.lock.Lock()
+	e, ok := r.live[id]
+	if ok {
+		cpy := *e.job
+		r.lock.Unlock()
+		return &cpy, nil
+	}
+	r.lock.Unlock()
+
+	found, err := r.db.Jobs([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) != 1 {
+		return nil, fmt.Errorf("%w %s", ErrNotFound, id)
+	}
+	return found[0], nil
+}
+
+// List iterates over jobs (newest first), optionally only those of one project
+func (r *Runner) List(projectID, tkn string) ([]*types.Job, string, error) {
+	found, tkn, err := r.db.ListJobs(projectID, tkn)
+	if err != nil {
+		return nil, tkn, err
+	}
+
+	// progress of live jobs is only saved every so often, so prefer what we have in memory
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, j := range found {
+		e, ok := r.live[j.ID]
+		if ok {
+			cpy := *e.job
+			found[i] = &cpy
+		}
+	}
+
+	return found, tkn, nil
+}
+
+// Cancel stops a job. Queued jobs are cancelled immediately, running jobs
+// are cancelled when they next check their context.
+func (r *Runner) Cancel(id string) (*types.Job, error) {
+	r.lock.Lock()
+	e, ok := r.live[id]
+	if ok {
+		e.cancel()
+		if e.job.Status == types.JobQueued {
+			r.finish(e, nil, context.Canceled)
+		}
+		cpy := *e.job
+		r.lock.Unlock()
+		return &cpy, nil
+	}
+	r.lock.Unlock()
+
+	j, err := r.Job(id)
+	if err != nil {
+		return nil, err
+	}
+	return j, fmt.Errorf("%w %s is %s", ErrFinished, id, j.Status)
+}
+
+// Watch returns a channel that is sent the job every time it changes (it's status or
+// progress). The channel is closed when the job finishes. The returned func should be
+// called when the caller is no longer interested.
+//
+// Slow readers may miss intermediate updates, but not the final one.
+func (r *Runner) Watch(id string) (<-chan *types.Job, func(), error) {
+	r.lock.Lock()
+	e, ok := r.live[id]
+	if !ok {
+		r.lock.Unlock()
+		j, err := r.Job(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		ch := make(chan *types.Job, 1)
+		ch <- j
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	defer r.lock.Unlock()
+
+	ch := make(chan *types.Job, 10)
+	cpy := *e.job
+	ch <- &cpy
+	e.subs = append(e.subs, ch)
+
+	return ch, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		for i, sub := range e.subs {
+			if sub == ch {
+				e.subs = append(e.subs[:i], e.subs[i+1:]...)
+				close(ch)
+				return
+			}
+		}
+	}, nil
+}
+
+// work runs pending jobs, one at a time
+func (r *Runner) work() {
+	defer close(r.done)
+	for range r.wake {
+		for {
+			r.lock.Lock()
+			if len(r.pending) == 0 {
+				r.lock.Unlock()
+				break
+			}
+			e := r.pending[0]
+			r.pending = r.pending[1:]
+			if e.job.Done() { // cancelled while queued
+				r.lock.Unlock()
+				continue
+			}
+			now := time.Now()
+			e.job.Status = types.JobRunning
+			e.job.Started = &now
+			r.changed(e, true)
+			r.lock.Unlock()
+
+			result, err := e.run(e.ctx)
+
+			r.lock.Lock()
+			r.finish(e, result, err)
+			r.lock.Unlock()
+		}
+	}
+}
+
+// progress records a progress event for a job
+func (r *Runner) progress(e *entry, ev *progress.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if e.job.Done() {
+		return
+	}
+	e.job.Progress = ev.Fraction()
+	e.job.Message = ev.String()
+	r.changed(e, time.Since(e.saved) > saveInterval)
+}
+
+// finish sets a job's final state, must be called holding the lock
+func (r *Runner) finish(e *entry, result interface{}, err error) {
+	now := time.Now()
+	e.job.Finished = &now
+
+	if err == nil && result != nil {
+		e.job.Result, err = json.Marshal(result)
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled) || (err != nil && e.ctx.Err() != nil):
+		e.job.Status = types.JobCancelled
+		e.job.Error = err.Error()
+	case err != nil:
+		e.job.Status = types.JobFailed
+		e.job.Error = err.Error()
+	default:
+		e.job.Status = types.JobSucceeded
+		e.job.Progress = 1
+	}
+
+	r.changed(e, true)
+	for _, sub := range e.subs {
+		close(sub)
+	}
+	e.subs = nil
+	e.cancel()
+	delete(r.live, e.job.ID)
+}
+
+// changed tells watchers about a job & (optionally) saves it, must be called holding the lock
+func (r *Runner) changed(e *entry, save bool) {
+	for _, sub := range e.subs {
+		cpy := *e.job
+		select {
+		case sub <- &cpy:
+		default: // reader is behind, they'll get the next one
+			if !e.job.Done() {
+				continue
+			}
+			// make room for the final state, we hold the lock so no one else is
+			// sending, but we mustn't block if the reader has emptied it already
+			select {
+			case <-sub:
+			default:
+			}
+			select {
+			case sub <- &cpy:
+			default:
+			}
+		}
+	}
+
+	if !save {
+		return
+	}
+	e.saved = time.Now()
+	err := r.save(e.job)
+	if err != nil {
+		log.Println("failed to save job", e.job.ID, err)
+	}
+}
+
+func (r *Runner) save(j *types.Job) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = tx.SetJobs([]*types.Job{j})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// failInterrupted marks jobs that never finished as failed
+func (r *Runner) failInterrupted() error {
+	tkn := ""
+	for {
+		found, next, err := r.db.ListJobs("", tkn)
+		if err != nil {
+			return err
+		}
+		for _, j := range found {
+			if j.Done() {
+				continue
+			}
+			now := time.Now()
+			j.Status = types.JobFailed
+			j.Error = msgInterrupted
+			j.Finished = &now
+			err = r.save(j)
+			if err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		tkn = next
+	}
+}
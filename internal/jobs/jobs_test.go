@@ -0,0 +1,199 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/config"
+	"github.com/voidshard/genesis/internal/database"
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func testRunner(t *testing.T) (*Runner, database.Database) {
+	db, err := database.NewSqlite3(&config.Database{Location: t.TempDir(), Name: "test.sqlite"})
+	assert.Nil(t, err)
+	r, err := New(db)
+	assert.Nil(t, err)
+	return r, db
+}
+
+func waitFor(t *testing.T, r *Runner, id string) *types.Job {
+	ch, done, err := r.Watch(id)
+	assert.Nil(t, err)
+	defer done()
+
+	var last *types.Job
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case j, ok := <-ch:
+			if !ok {
+				return last
+			}
+			last = j
+		case <-timeout:
+			t.Fatal("timed out waiting for job", id)
+		}
+	}
+}
+
+func TestRunner(t *testing.T) {
+	r, _ := testRunner(t)
+	proj := dbutils.NewID("test")
+
+	cases := []struct {
+		Fn     Func
+		Status types.JobStatus
+		Result string
+	}{
+		{
+			func(ctx context.Context) (interface{}, error) {
+				for i := 0; i <= 10; i++ {
+					progress.Report(ctx, "test", i, 10, "things")
+				}
+				return map[string]int{"a": 1}, nil
+			},
+			types.JobSucceeded,
+			`{"a":1}`,
+		},
+		{
+			func(ctx context.Context) (interface{}, error) {
+				return nil, fmt.Errorf("broken")
+			},
+			types.JobFailed,
+			"",
+		},
+	}
+
+	for _, tt := range cases {
+		j, err := r.Submit(proj, "test", tt.Fn)
+		assert.Nil(t, err)
+
+		result := waitFor(t, r, j.ID)
+		assert.Equal(t, tt.Status, result.Status)
+		assert.Equal(t, tt.Result, string(result.Result))
+
+		saved, err := r.Job(j.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.Status, saved.Status)
+		assert.NotNil(t, saved.Finished)
+	}
+
+	found, _, err := r.List(proj, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(found))
+}
+
+func TestRunnerCancel(t *testing.T) {
+	r, _ := testRunner(t)
+	proj := dbutils.NewID("test")
+
+	started := make(chan bool)
+	j, err := r.Submit(proj, "slow", func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Nil(t, err)
+
+	queued, err := r.Submit(proj, "queued", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	assert.Nil(t, err)
+
+	<-started
+	_, err = r.Cancel(queued.ID)
+	assert.Nil(t, err)
+	_, err = r.Cancel(j.ID)
+	assert.Nil(t, err)
+
+	assert.Equal(t, types.JobCancelled, waitFor(t, r, j.ID).Status)
+	assert.Equal(t, types.JobCancelled, waitFor(t, r, queued.ID).Status)
+
+	_, err = r.Cancel(j.ID)
+	assert.ErrorIs(t, err, ErrFinished)
+}
+
+func TestRunnerInterrupted(t *testing.T) {
+	_, db := testRunner(t)
+
+	j := &types.Job{ID: dbutils.RandomID(), ProjectID: dbutils.NewID("test"), Status: types.JobRunning, Created: time.Now()}
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.SetJobs([]*types.Job{j}))
+	assert.Nil(t, tx.Commit())
+
+	r, err := New(db)
+	assert.Nil(t, err)
+
+	result, err := r.Job(j.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, types.JobFailed, result.Status)
+	assert.Equal(t, msgInterrupted, result.Error)
+}
+
+func TestRunnerSlowWatcher(t *testing.T) {
+	r, _ := testRunner(t)
+	proj := dbutils.NewID("test")
+
+	j, err := r.Submit(proj, "chatty", func(ctx context.Context) (interface{}, error) {
+		for i := 0; i <= 100; i++ {
+			progress.Report(ctx, "test", i, 100, "things")
+		}
+		return nil, nil
+	})
+	assert.Nil(t, err)
+
+	ch, done, err := r.Watch(j.ID)
+	assert.Nil(t, err)
+	defer done()
+
+	// we don't read until it's over, so most updates are dropped
+	assert.Equal(t, types.JobSucceeded, waitFor(t, r, j.ID).Status)
+
+	var last *types.Job
+	for got := range ch {
+		last = got
+	}
+	assert.Equal(t, types.JobSucceeded, last.Status)
+}
+
+func TestRunnerClose(t *testing.T) {
+	r, _ := testRunner(t)
+	proj := dbutils.NewID("test")
+
+	started := make(chan bool)
+	j, err := r.Submit(proj, "slow", func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Nil(t, err)
+
+	queued, err := r.Submit(proj, "queued", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	assert.Nil(t, err)
+
+	<-started
+	r.Close()
+	r.Close() // noop
+
+	for _, id := range []string{j.ID, queued.ID} {
+		saved, err := r.Job(id)
+		assert.Nil(t, err)
+		assert.Equal(t, types.JobCancelled, saved.Status)
+		assert.NotNil(t, saved.Finished)
+	}
+
+	_, err = r.Submit(proj, "late", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -1,24 +1,43 @@
 package paint
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
+	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 
-	"github.com/voidshard/mimage"
+	"github.com/voidshard/genesis/internal/blob"
 )
 
 type fsPaint struct {
-	root   string
+	store  blob.Blob
+	root   string // where we work on canvas files
 	width  int
 	height int
 }
 
-func New(root string, width, height int) Painter {
+// New returns a Painter keeping canvases in `store`. Canvases are worked on as files, if the
+// store isn't on the local disk they're copied to & from the `cache` folder as needed.
+//
+// If the store is in memory canvases are kept entirely in memory (the cache is unused).
+func New(store blob.Blob, cache string, width, height int) Painter {
+	_, ok := store.(*blob.Memory)
+	if ok {
+		return newMemPaint(store, width, height)
+	}
+	return newFsPaint(store, cache, width, height)
+}
+
+func newFsPaint(store blob.Blob, cache string, width, height int) *fsPaint {
+	root := workDir(store, cache)
 	os.MkdirAll(root, 0750)
 	return &fsPaint{
+		store:  store,
 		root:   root,
 		width:  width,
 		height: height,
@@ -27,22 +46,27 @@ func New(root string, width, height int) Painter {
 
 // NewCanvas returns a blank canvas
 func (p *fsPaint) NewCanvas(name string) (Canvas, error) {
-	return newMimageCanvas(p.pathFor(name), p.width, p.height), nil
+	return newMimageCanvas(p.pathFor(name), p.width, p.height)
 }
 
-// NewPerlinCanvas returns a canvas with some perlin noise on it
-func (p *fsPaint) NewPerlinCanvas(name string, scale float64) (Canvas, error) {
+// NewPerlinCanvas returns a canvas with some perlin noise on it, the same seed gives
+// the same noise
+func (p *fsPaint) NewPerlinCanvas(ctx context.Context, name string, scale float64, seed int64) (Canvas, error) {
 	cnv, err := newMimageCanvas(p.pathFor(name), p.width, p.height)
 	if err != nil {
 		return nil, err
 	}
 
 	size := 500
+	rng := rand.New(rand.NewSource(seed)) // each tile has noise of it's own
 
 	for x := 0; x < p.width; x += size {
 		for y := 0; y < p.height; y += size {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			op := cnv.im.Draw()
-			im.DrawImage(NewPerlin(size, size, scale, false), x, y)
+			op.DrawImage(NewPerlin(rng.Int63(), size, size, scale, false), x, y)
 			err = op.Do()
 			if err != nil {
 				return nil, err
@@ -53,27 +77,61 @@ func (p *fsPaint) NewPerlinCanvas(name string, scale float64) (Canvas, error) {
 	return cnv, nil
 }
 
+// NewCanvasFromImage returns a canvas based on the given image
+func (p *fsPaint) NewCanvasFromImage(name string, im image.Image) (Canvas, error) {
+	cnv, err := newMimageCanvas(p.pathFor(name), p.width, p.height)
+	if err != nil {
+		return nil, err
+	}
+
+	op := cnv.im.Draw()
+	op.DrawImage(im, 0, 0)
+	return cnv, op.Do()
+}
+
 // Merge canvases together & output the resulting image
-func (p *fsPaint) Merge(area image.Rectangle, weights map[Canvas]float64) (image.Image, error) {
-	return merge(p, area, weights)
+func (p *fsPaint) Merge(ctx context.Context, area image.Rectangle, weights map[Canvas]float64) (image.Image, error) {
+	return merge(ctx, p, area, weights)
+}
+
+// Merge16 canvases together & output the resulting 16 bit image
+func (p *fsPaint) Merge16(ctx context.Context, area image.Rectangle, weights map[Canvas]float64) (*image.Gray16, error) {
+	return merge16(ctx, p, area, weights)
 }
 
 // Save given canvas
 func (p *fsPaint) Save(in Canvas) error {
+	err := flush(in)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(in.Name()) // canvases live directly under root
+	err = push(p.store, name, p.pathFor(name))
+	if err == nil {
+		changed(p.store, name)
+	}
+	return err
+}
+
+// flush writes a canvas to disk
+func flush(in Canvas) error {
 	im, ok := in.(*mimCanvas)
 	if ok {
 		return im.Flush()
 	}
-	return fmt.Errorf("", in)
+	return fmt.Errorf("unsupported canvas %v", in)
 }
 
 // Canvas returns canvas if it exists or makes a new one
 func (p *fsPaint) Canvas(name string) (Canvas, error) {
 	key := p.pathFor(name)
 
-	_, err := os.Stat(key)
-	if errors.Is(err, os.ErrNotExist) {
+	err := fetch(p.store, name, key)
+	if errors.Is(err, blob.ErrNotFound) {
 		return p.NewCanvas(name)
+	} else if err != nil {
+		return nil, err
 	}
 
 	return loadMimageCanvas(key)
@@ -81,10 +139,125 @@ func (p *fsPaint) Canvas(name string) (Canvas, error) {
 
 // Delete existing canvas (noop if it doesn't exist)
 func (p *fsPaint) Delete(name string) error {
-	return os.RemoveAll(p.pathFor(name))
+	err := p.store.Delete(name)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(p.pathFor(name))
+	if err == nil {
+		changed(p.store, name)
+	}
+	return err
 }
 
 // pathFor retrns where on the disk we store this named graph
 func (p *fsPaint) pathFor(name string) string {
 	return filepath.Join(p.root, name)
 }
+
+// workDir returns where canvas files are worked on; in place if the store is on the local
+// disk, otherwise in the cache
+func workDir(store blob.Blob, cache string) string {
+	l, ok := store.(blob.Local)
+	if ok {
+		return l.Path("")
+	}
+	return cache
+}
+
+// fetch makes sure the files of canvas `name` in store are in `dir`
+func fetch(store blob.Blob, name, dir string) error {
+	l, ok := store.(blob.Local)
+	if ok {
+		src := l.Path(name)
+		_, err := os.Stat(src)
+		if errors.Is(err, os.ErrNotExist) {
+			return blob.ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		if src == dir {
+			return nil // already where we want it
+		}
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return err
+		}
+		return copyDir(src, dir)
+	}
+
+	keys, err := store.List(name + "/")
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return blob.ErrNotFound
+	}
+
+	err = os.RemoveAll(dir) // whatever we had may be out of date
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		data, err := store.Get(k)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(k, name+"/")))
+		err = os.MkdirAll(filepath.Dir(target), 0750)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(target, data, 0640)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// push writes the files of canvas `name` in `dir` to the store (removing any files that
+// the canvas no longer has)
+func push(store blob.Blob, name, dir string) error {
+	l, ok := store.(blob.Local)
+	if ok && l.Path(name) == dir {
+		return nil // already where it should be
+	}
+
+	written := map[string]bool{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		key := name + "/" + filepath.ToSlash(rel)
+		written[key] = true
+		return store.Put(key, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	existing, err := store.List(name + "/")
+	if err != nil {
+		return err
+	}
+	for _, k := range existing {
+		if written[k] {
+			continue
+		}
+		err = store.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
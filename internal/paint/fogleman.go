@@ -4,9 +4,9 @@ package paint
 Canvas implementation using github.com/fogleman/gg
 */
 import (
+	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 
 	"github.com/fogleman/gg"
 )
@@ -34,36 +34,53 @@ func (c *ggCanvas) Name() string {
 	return c.name
 }
 
-func (c *ggCanvas) R(x, y int) uint8 {
-	v, _, _, _ := c.At(x, y).RGBA()
-	return uint8(v >> 8)
+func (c *ggCanvas) R(x, y int) (uint8, error) {
+	v, _, _, _ := c.Image().At(x, y).RGBA()
+	return uint8(v >> 8), nil
 }
 
-func (c *ggCanvas) G(x, y int) uint8 {
-	_, v, _, _ := c.At(x, y).RGBA()
-	return uint8(v >> 8)
+func (c *ggCanvas) G(x, y int) (uint8, error) {
+	_, v, _, _ := c.Image().At(x, y).RGBA()
+	return uint8(v >> 8), nil
 }
 
-func (c *ggCanvas) B(x, y int) uint8 {
-	_, _, v, _ := c.At(x, y).RGBA()
-	return uint8(v >> 8)
+func (c *ggCanvas) B(x, y int) (uint8, error) {
+	_, _, v, _ := c.Image().At(x, y).RGBA()
+	return uint8(v >> 8), nil
 }
 
 func (c *ggCanvas) Image() image.Image {
 	return c.ctx.Image()
 }
 
-func (c *ggCanvas) SetMask(cnv Canvas) {
+// Mask returns the canvas as an alpha mask (anything drawn is opaque)
+func (c *ggCanvas) Mask() *image.Alpha {
+	return c.ctx.AsMask()
+}
+
+func (c *ggCanvas) SetMask(cnv Canvas) error {
 	if cnv == nil {
-		c.ctx.SetMask(image.NewAlpha(c.Bounds()))
-		return
+		return c.ctx.SetMask(image.NewAlpha(c.Bounds()))
+	}
+	in, ok := cnv.(*ggCanvas)
+	if !ok {
+		return fmt.Errorf("unsupported canvas %v", cnv)
 	}
-	c.ctx.SetMask(cnv.Mask())
+	return c.ctx.SetMask(in.Mask())
+}
+
+func (c *ggCanvas) Flatten(r image.Rectangle) error {
+	c.ctx.Push()
+	c.ctx.SetColor(color.Black)
+	c.ctx.DrawRectangle(float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()))
+	c.ctx.Fill()
+	c.ctx.Pop()
+	return nil
 }
 
-func (c *ggCanvas) RectangleHorizontal(r image.Rectangle, colours ...color.Color) {
+func (c *ggCanvas) RectangleHorizontal(r image.Rectangle, colours ...color.Color) error {
 	if len(colours) == 0 {
-		return
+		return nil
 	}
 
 	my := float64((r.Max.Y - r.Min.Y) / 2)
@@ -81,11 +98,12 @@ func (c *ggCanvas) RectangleHorizontal(r image.Rectangle, colours ...color.Color
 	c.ctx.LineTo(float64(r.Min.X), float64(r.Max.Y))
 	c.ctx.ClosePath()
 	c.ctx.Fill()
+	return nil
 }
 
-func (c *ggCanvas) RectangleVertical(r image.Rectangle, colours ...color.Color) {
+func (c *ggCanvas) RectangleVertical(r image.Rectangle, colours ...color.Color) error {
 	if len(colours) == 0 {
-		return
+		return nil
 	}
 
 	mx := float64((r.Max.X - r.Min.X) / 2)
@@ -103,11 +121,12 @@ func (c *ggCanvas) RectangleVertical(r image.Rectangle, colours ...color.Color)
 	c.ctx.LineTo(float64(r.Min.X), float64(r.Max.Y))
 	c.ctx.ClosePath()
 	c.ctx.Fill()
+	return nil
 }
 
-func (c *ggCanvas) Line(line []image.Point, width int, colours ...color.Color) {
+func (c *ggCanvas) Line(line []image.Point, width int, colours ...color.Color) error {
 	if len(line) < 2 {
-		return
+		return nil
 	}
 
 	a, b := line[0], line[len(line)-1]
@@ -125,30 +144,17 @@ func (c *ggCanvas) Line(line []image.Point, width int, colours ...color.Color) {
 		c.ctx.LineTo(float64(line[i].X), float64(line[i].Y))
 	}
 	c.ctx.Stroke()
+	return nil
 }
 
 func (c *ggCanvas) FlattenOutside(r image.Rectangle) {
-	blkctx := gg.NewContext(c.ctx.Width(), c.ctx.Height())
-	blkctx.SetColor(color.Black)
-	blkctx.DrawRectangle(0, 0, float64(c.ctx.Width()), float64(c.ctx.Height()))
-	blkctx.Fill()
-	blk := blkctx.Image().(*image.RGBA)
-
-	orig := c.Image()
-	draw.Draw(blk, r, orig, r.Min, draw.Src)
-
-	im, _ := SmoothImage(blk, 10)
-
-	ng := im.(*image.NRGBA)
-	inset := r.Inset(5)
-	draw.Draw(ng, inset, orig, inset.Min, draw.Src)
-
+	im, _ := FlattenOutside(c.Image(), r)
 	c.ctx = gg.NewContextForImage(im)
 }
 
-func (c *ggCanvas) Polygon(poly [][2]image.Point, col color.Color) {
+func (c *ggCanvas) Polygon(poly [][2]image.Point, col color.Color) error {
 	if len(poly) < 3 {
-		return
+		return nil
 	}
 
 	in := orderedPolygon(poly)
@@ -160,6 +166,7 @@ func (c *ggCanvas) Polygon(poly [][2]image.Point, col color.Color) {
 	}
 	c.ctx.ClosePath()
 	c.ctx.Fill()
+	return nil
 }
 
 func (c *ggCanvas) Bounds() image.Rectangle {
@@ -175,16 +182,17 @@ func (c *ggCanvas) Smooth(radius uint32) error {
 	return nil
 }
 
-func (c *ggCanvas) Set(x, y int, col color.Color) {
+func (c *ggCanvas) Set(x, y int, col color.Color) error {
 	c.ctx.SetColor(col)
 	c.ctx.SetPixel(x, y)
+	return nil
 }
 
-func (c *ggCanvas) At(x, y int) color.Color {
-	return c.Image().At(x, y)
+func (c *ggCanvas) At(x, y int) (color.Color, error) {
+	return c.Image().At(x, y), nil
 }
 
-func (c *ggCanvas) Ellipse(centre image.Point, rx, ry, rot int, depth float64, mode Mode) {
+func (c *ggCanvas) Ellipse(centre image.Point, rx, ry, rot int, depth float64, mode Mode) error {
 	maj := rx
 	if ry > maj {
 		maj = ry
@@ -196,11 +204,12 @@ func (c *ggCanvas) Ellipse(centre image.Point, rx, ry, rot int, depth float64, m
 	c.ctx.DrawEllipse(x, y, float64(rx), float64(ry))
 	c.ctx.Fill()
 	c.ctx.Pop()
+	return nil
 }
 
-func (c *ggCanvas) Channel(path []image.Point, width int, depth float64, mode Mode) {
+func (c *ggCanvas) Channel(path []image.Point, width int, depth float64, mode Mode) error {
 	if len(path) < 2 {
-		return
+		return nil
 	}
 
 	fullWidth := float64(width)
@@ -226,4 +235,5 @@ func (c *ggCanvas) Channel(path []image.Point, width int, depth float64, mode Mo
 
 		currentWidth -= 1
 	}
+	return nil
 }
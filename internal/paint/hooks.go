@@ -0,0 +1,57 @@
+package paint
+
+import (
+	"sync"
+
+	"github.com/voidshard/genesis/internal/blob"
+)
+
+// SaveHook is called with the name of a canvas after it's been saved or deleted
+// (eg. so caches built from the canvas can be thrown away)
+type SaveHook func(name string)
+
+var (
+	hooks    = map[blob.Blob]map[int]SaveHook{}
+	hookID   = 0
+	hookLock sync.Mutex
+)
+
+// OnSave registers a func to be called whenever a canvas kept in `store` is saved or
+// deleted. Call the returned func to unregister it.
+func OnSave(store blob.Blob, fn SaveHook) func() {
+	hookLock.Lock()
+	defer hookLock.Unlock()
+
+	hookID++
+	id := hookID
+
+	_, ok := hooks[store]
+	if !ok {
+		hooks[store] = map[int]SaveHook{}
+	}
+	hooks[store][id] = fn
+
+	return func() {
+		hookLock.Lock()
+		defer hookLock.Unlock()
+
+		delete(hooks[store], id)
+		if len(hooks[store]) == 0 {
+			delete(hooks, store)
+		}
+	}
+}
+
+// changed calls the hooks of `store` for the given canvas name
+func changed(store blob.Blob, name string) {
+	hookLock.Lock()
+	found := []SaveHook{}
+	for _, fn := range hooks[store] {
+		found = append(found, fn)
+	}
+	hookLock.Unlock()
+
+	for _, fn := range found {
+		fn(name)
+	}
+}
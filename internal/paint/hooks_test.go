@@ -0,0 +1,45 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/blob"
+)
+
+func TestOnSave(t *testing.T) {
+	storeA, storeB := blob.NewMemory(), blob.NewMemory()
+	pntA, pntB := New(storeA, t.TempDir(), 2, 2), New(storeB, t.TempDir(), 2, 2)
+
+	heard := []string{}
+	unhook := OnSave(storeA, func(name string) { heard = append(heard, name) })
+
+	save := func(pnt Painter, name string) {
+		cnv, err := pnt.NewCanvas(name)
+		assert.Nil(t, err)
+		assert.Nil(t, pnt.Save(cnv))
+	}
+
+	save(pntA, "a")
+	save(pntB, "b") // someone else's store
+	assert.Nil(t, pntA.Delete("a"))
+
+	stage, err := pntA.Begin()
+	assert.Nil(t, err)
+	cnv, err := stage.NewCanvas("staged")
+	assert.Nil(t, err)
+	assert.Nil(t, stage.Save(cnv))
+	assert.Equal(t, []string{"a", "a"}, heard) // nothing until commit
+	assert.Nil(t, stage.Commit())
+
+	assert.Equal(t, []string{"a", "a", "staged"}, heard)
+
+	unhook()
+	save(pntA, "c")
+	assert.Equal(t, []string{"a", "a", "staged"}, heard)
+
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	assert.Equal(t, 0, len(hooks))
+}
@@ -1,8 +1,11 @@
 package paint
 
 import (
+	"context"
 	"image"
 	"image/color"
+
+	"github.com/voidshard/genesis/internal/blob"
 )
 
 type Mode string
@@ -16,8 +19,9 @@ type Painter interface {
 	// NewCanvas returns a blank canvas
 	NewCanvas(name string) (Canvas, error)
 
-	// NewPerlinCanvas returns a canvas with some perlin noise on it
-	NewPerlinCanvas(name string, noise float64) (Canvas, error)
+	// NewPerlinCanvas returns a canvas with some perlin noise on it, the same seed
+	// gives the same noise
+	NewPerlinCanvas(ctx context.Context, name string, noise float64, seed int64) (Canvas, error)
 
 	// NewCanvasFromImage returns a canvas based on the given image
 	NewCanvasFromImage(name string, im image.Image) (Canvas, error)
@@ -28,11 +32,36 @@ type Painter interface {
 	// Delete existing canvas (noop if it doesn't exist)
 	Delete(name string) error
 
-	// Save given canvas
+	// Save given canvas. Changes to a canvas are not written until it is saved.
 	Save(Canvas) error
 
 	// Merge `area` of canvases, weighted into one greyscale image.
-	Merge(area image.Rectangle, weights map[Canvas]float64) (image.Image, error)
+	Merge(ctx context.Context, area image.Rectangle, weights map[Canvas]float64) (image.Image, error)
+
+	// Merge16 is Merge but keeps 16 bits of precision in the output.
+	Merge16(ctx context.Context, area image.Rectangle, weights map[Canvas]float64) (*image.Gray16, error)
+
+	// Begin a set of canvas writes that are only made visible by Commit.
+	Begin() (Stage, error)
+}
+
+// Stage is a Painter whose canvases are private copies in a staging area. Saved
+// canvases replace the real ones together on Commit, or are thrown away on Rollback.
+//
+// Delete on a Stage only discards staged changes to a canvas.
+type Stage interface {
+	Painter
+
+	// Commit moves all saved canvases into place.
+	Commit() error
+
+	// Rollback throws away all staged canvases (noop after Commit).
+	Rollback() error
+
+	// Store returns storage whose writes are kept in the stage & moved into place
+	// along with the canvases on Commit (eg. so a graph is only written if the
+	// canvases drawn from it are).
+	Store() blob.Blob
 }
 
 type Canvas interface {
@@ -0,0 +1,291 @@
+package paint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/voidshard/genesis/internal/blob"
+)
+
+const (
+	// memExt is added to canvas names to make their key in memory storage
+	memExt = ".png"
+)
+
+// memPaint keeps canvases as PNGs in memory storage, drawn on with ggCanvas. For when we
+// don't want to touch the disk at all (eg. tests).
+type memPaint struct {
+	store  blob.Blob
+	width  int
+	height int
+}
+
+type memStage struct {
+	*memPaint // our copies of canvases
+
+	parent *memPaint
+	saved  map[string]bool // canvases to write on commit
+	blobs  map[string]bool // blobs (see Store) to write on commit
+
+	done bool
+	lock sync.Mutex
+}
+
+func newMemPaint(store blob.Blob, width, height int) *memPaint {
+	return &memPaint{store: store, width: width, height: height}
+}
+
+// NewCanvas returns a blank canvas
+func (p *memPaint) NewCanvas(name string) (Canvas, error) {
+	return newFoglemanCanvas(name, p.width, p.height), nil
+}
+
+// NewPerlinCanvas returns a canvas with some perlin noise on it, the same seed gives
+// the same noise
+func (p *memPaint) NewPerlinCanvas(ctx context.Context, name string, scale float64, seed int64) (Canvas, error) {
+	cnv := newFoglemanCanvas(name, p.width, p.height)
+
+	size := 500
+	rng := rand.New(rand.NewSource(seed)) // each tile has noise of it's own
+
+	for x := 0; x < p.width; x += size {
+		for y := 0; y < p.height; y += size {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			cnv.ctx.DrawImage(NewPerlin(rng.Int63(), size, size, scale, false), x, y)
+		}
+	}
+
+	return cnv, nil
+}
+
+// NewCanvasFromImage returns a canvas based on the given image
+func (p *memPaint) NewCanvasFromImage(name string, im image.Image) (Canvas, error) {
+	return newFoglemanCanvasForImage(name, im), nil
+}
+
+// Canvas returns canvas if it exists or makes a new one
+func (p *memPaint) Canvas(name string) (Canvas, error) {
+	data, err := p.store.Get(name + memExt)
+	if errors.Is(err, blob.ErrNotFound) {
+		return p.NewCanvas(name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	im, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return newFoglemanCanvasForImage(name, im), nil
+}
+
+// Delete existing canvas (noop if it doesn't exist)
+func (p *memPaint) Delete(name string) error {
+	err := p.store.Delete(name + memExt)
+	if err == nil {
+		changed(p.store, name)
+	}
+	return err
+}
+
+// Save given canvas
+func (p *memPaint) Save(in Canvas) error {
+	err := p.put(in)
+	if err == nil {
+		changed(p.store, in.Name())
+	}
+	return err
+}
+
+// Merge canvases together & output the resulting image
+func (p *memPaint) Merge(ctx context.Context, area image.Rectangle, weights map[Canvas]float64) (image.Image, error) {
+	return merge(ctx, p, area, weights)
+}
+
+// Merge16 canvases together & output the resulting 16 bit image
+func (p *memPaint) Merge16(ctx context.Context, area image.Rectangle, weights map[Canvas]float64) (*image.Gray16, error) {
+	return merge16(ctx, p, area, weights)
+}
+
+// Begin a set of canvas writes that are only made visible by Commit
+func (p *memPaint) Begin() (Stage, error) {
+	return &memStage{
+		memPaint: newMemPaint(blob.NewMemory(), p.width, p.height),
+		parent:   p,
+		saved:    map[string]bool{},
+		blobs:    map[string]bool{},
+	}, nil
+}
+
+// put encodes a canvas into storage
+func (p *memPaint) put(in Canvas) error {
+	cnv, ok := in.(*ggCanvas)
+	if !ok {
+		return fmt.Errorf("unsupported canvas %v", in)
+	}
+
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, cnv.Image())
+	if err != nil {
+		return err
+	}
+	return p.store.Put(in.Name()+memExt, buf.Bytes())
+}
+
+// Begin isn't supported on a Stage
+func (s *memStage) Begin() (Stage, error) {
+	return nil, ErrStaged
+}
+
+// Canvas returns our copy of a canvas if we have one, otherwise the real canvas
+func (s *memStage) Canvas(name string) (Canvas, error) {
+	_, err := s.store.Get(name + memExt)
+	if err == nil {
+		return s.memPaint.Canvas(name)
+	}
+	return s.parent.Canvas(name)
+}
+
+// Save keeps the canvas in the stage, it'll be written on Commit
+func (s *memStage) Save(in Canvas) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return ErrStageDone
+	}
+
+	err := s.put(in)
+	if err == nil {
+		s.saved[in.Name()] = true
+	}
+	return err
+}
+
+// Delete throws away our copy of a canvas (the real canvas is untouched)
+func (s *memStage) Delete(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.saved, name)
+	return s.store.Delete(name + memExt)
+}
+
+// Commit writes all saved canvases to the real storage
+func (s *memStage) Commit() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return ErrStageDone
+	}
+	s.done = true
+
+	for name := range s.saved {
+		data, err := s.store.Get(name + memExt)
+		if err != nil {
+			return err
+		}
+		err = s.parent.store.Put(name+memExt, data)
+		if err != nil {
+			return err
+		}
+		changed(s.parent.store, name)
+	}
+	for key := range s.blobs {
+		data, err := s.store.Get(key)
+		if err != nil {
+			return err
+		}
+		err = s.parent.store.Put(key, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback throws away all staged canvases
+func (s *memStage) Rollback() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return nil
+	}
+
+	s.done = true
+	s.saved = map[string]bool{} // our copies go when we do
+	s.blobs = map[string]bool{}
+	return nil
+}
+
+// Store returns storage whose writes are made on Commit
+func (s *memStage) Store() blob.Blob {
+	return &stageBlob{stage: s, staged: s.store, parent: s.parent.store}
+}
+
+// keep writes a blob into the stage, it'll be written for real on Commit
+func (s *memStage) keep(key string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return ErrStageDone
+	}
+
+	err := s.store.Put(key, data)
+	if err == nil {
+		s.blobs[key] = true
+	}
+	return err
+}
+
+// drop throws away our copy of a blob
+func (s *memStage) drop(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.blobs, key)
+	return s.store.Delete(key)
+}
+
+// Names returns the names of all canvases in `store` that start with prefix
+func Names(store blob.Blob, prefix string) ([]string, error) {
+	keys, err := store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	_, inMemory := store.(*blob.Memory)
+
+	seen := map[string]bool{}
+	names := []string{}
+	for _, k := range keys {
+		name := k
+		if inMemory {
+			if !strings.HasSuffix(k, memExt) {
+				continue
+			}
+			name = strings.TrimSuffix(k, memExt)
+		} else {
+			// mimage canvases are folders of files
+			parts := strings.SplitN(k, "/", 2)
+			if len(parts) < 2 {
+				continue
+			}
+			name = parts[0]
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,188 @@
+package paint
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/blob"
+)
+
+func TestMemoryCanvasRoundTrip(t *testing.T) {
+	store := blob.NewMemory()
+	pnt := New(store, t.TempDir(), 8, 6)
+
+	cnv, err := pnt.NewCanvas("a")
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 8, 6), cnv.Bounds())
+
+	assert.Nil(t, cnv.Set(2, 3, color.RGBA{R: 10, G: 20, B: 30, A: 255}))
+	assert.Nil(t, pnt.Save(cnv))
+
+	_, err = store.Get("a" + memExt)
+	assert.Nil(t, err)
+
+	found, err := pnt.Canvas("a")
+	assert.Nil(t, err)
+	for _, tt := range []struct {
+		Name string
+		Read func(x, y int) (uint8, error)
+		Want uint8
+	}{
+		{"red", found.R, 10},
+		{"green", found.G, 20},
+		{"blue", found.B, 30},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			v, err := tt.Read(2, 3)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.Want, v)
+
+			v, err = tt.Read(0, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, uint8(0), v)
+		})
+	}
+}
+
+func TestMemoryCanvasMissingIsBlank(t *testing.T) {
+	pnt := New(blob.NewMemory(), t.TempDir(), 4, 4)
+
+	cnv, err := pnt.Canvas("nothing")
+	assert.Nil(t, err)
+
+	v, err := cnv.R(1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0), v)
+}
+
+func TestMemoryDelete(t *testing.T) {
+	store := blob.NewMemory()
+	pnt := New(store, t.TempDir(), 4, 4)
+
+	cnv, err := pnt.NewCanvas("a")
+	assert.Nil(t, err)
+	assert.Nil(t, cnv.Set(0, 0, color.White))
+	assert.Nil(t, pnt.Save(cnv))
+
+	assert.Nil(t, pnt.Delete("a"))
+
+	_, err = store.Get("a" + memExt)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestMemoryCanvasFromImage(t *testing.T) {
+	pnt := New(blob.NewMemory(), t.TempDir(), 4, 4)
+
+	im := image.NewGray(image.Rect(0, 0, 4, 4))
+	im.SetGray(3, 1, color.Gray{Y: 200})
+
+	cnv, err := pnt.NewCanvasFromImage("a", im)
+	assert.Nil(t, err)
+
+	v, err := cnv.B(3, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(200), v)
+
+	out, err := Image(cnv)
+	assert.Nil(t, err)
+	r, _, _, _ := out.At(3, 1).RGBA()
+	assert.Equal(t, uint32(200), r>>8)
+}
+
+func TestMemoryFlatten(t *testing.T) {
+	pnt := New(blob.NewMemory(), t.TempDir(), 4, 4)
+
+	cnv, err := pnt.NewCanvas("a")
+	assert.Nil(t, err)
+	assert.Nil(t, cnv.RectangleHorizontal(image.Rect(0, 0, 4, 4), color.White, color.White))
+	assert.Nil(t, cnv.Flatten(image.Rect(0, 0, 2, 4)))
+
+	v, err := cnv.R(0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0), v)
+
+	v, err = cnv.R(3, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(255), v)
+}
+
+func TestMemoryMerge(t *testing.T) {
+	pnt := New(blob.NewMemory(), t.TempDir(), 2, 1)
+
+	a, err := pnt.NewCanvas("a")
+	assert.Nil(t, err)
+	assert.Nil(t, a.Set(0, 0, color.Gray{Y: 100}))
+	assert.Nil(t, a.Set(1, 0, color.Gray{Y: 200}))
+
+	b, err := pnt.NewCanvas("b")
+	assert.Nil(t, err)
+	assert.Nil(t, b.Set(0, 0, color.Gray{Y: 50}))
+	assert.Nil(t, b.Set(1, 0, color.Gray{Y: 200}))
+
+	im, err := pnt.Merge(context.Background(), image.Rect(0, 0, 2, 1), map[Canvas]float64{a: 1, b: 1})
+	assert.Nil(t, err)
+
+	gray := im.(*image.Gray)
+	assert.Equal(t, uint8(150), gray.GrayAt(0, 0).Y)
+	assert.Equal(t, uint8(255), gray.GrayAt(1, 0).Y) // clamped
+}
+
+func TestMemoryStage(t *testing.T) {
+	for _, tt := range []struct {
+		Name   string
+		Finish func(Stage) error
+		Want   uint8
+	}{
+		{"commit", func(s Stage) error { return s.Commit() }, 255},
+		{"rollback", func(s Stage) error { return s.Rollback() }, 0},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			pnt := New(blob.NewMemory(), t.TempDir(), 4, 4)
+
+			stage, err := pnt.Begin()
+			assert.Nil(t, err)
+
+			cnv, err := stage.Canvas("a")
+			assert.Nil(t, err)
+			assert.Nil(t, cnv.Set(1, 1, color.White))
+			assert.Nil(t, stage.Save(cnv))
+
+			// nothing is visible until we commit
+			real, err := pnt.Canvas("a")
+			assert.Nil(t, err)
+			v, err := real.R(1, 1)
+			assert.Nil(t, err)
+			assert.Equal(t, uint8(0), v)
+
+			assert.Nil(t, tt.Finish(stage))
+
+			real, err = pnt.Canvas("a")
+			assert.Nil(t, err)
+			v, err = real.R(1, 1)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.Want, v)
+
+			assert.ErrorIs(t, stage.Save(cnv), ErrStageDone)
+		})
+	}
+}
+
+func TestNamesInMemory(t *testing.T) {
+	store := blob.NewMemory()
+	pnt := New(store, t.TempDir(), 2, 2)
+
+	for _, name := range []string{"p-a", "p-b", "q-a"} {
+		cnv, err := pnt.NewCanvas(name)
+		assert.Nil(t, err)
+		assert.Nil(t, pnt.Save(cnv))
+	}
+	assert.Nil(t, store.Put("p-graph.graph", []byte("not a canvas")))
+
+	names, err := Names(store, "p-")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"p-a", "p-b"}, names)
+}
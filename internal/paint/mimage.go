@@ -1,6 +1,7 @@
 package paint
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"path/filepath"
@@ -26,7 +27,7 @@ func newMimageCanvas(key string, w, h int) (*mimCanvas, error) {
 		mimage.OperationRoutines(defaultRoutines),
 	)
 	return &mimCanvas{
-		name: filepath.Dirname(key),
+		name: filepath.Base(key),
 		im:   im,
 	}, err
 }
@@ -34,7 +35,7 @@ func newMimageCanvas(key string, w, h int) (*mimCanvas, error) {
 func loadMimageCanvas(key string) (*mimCanvas, error) {
 	im, err := mimage.Load(key)
 	return &mimCanvas{
-		name: filepath.Dirname(key),
+		name: filepath.Base(key),
 		im:   im,
 	}, err
 }
@@ -51,7 +52,7 @@ func (m *mimCanvas) At(x, y int) (color.Color, error) {
 }
 
 func (m *mimCanvas) R(x, y int) (uint8, error) {
-	c, err := m.AtOk(x, y)
+	c, err := m.At(x, y)
 	if err != nil {
 		return 0, err
 	}
@@ -60,7 +61,7 @@ func (m *mimCanvas) R(x, y int) (uint8, error) {
 }
 
 func (m *mimCanvas) G(x, y int) (uint8, error) {
-	c, err := m.AtOk(x, y)
+	c, err := m.At(x, y)
 	if err != nil {
 		return 0, err
 	}
@@ -69,7 +70,7 @@ func (m *mimCanvas) G(x, y int) (uint8, error) {
 }
 
 func (m *mimCanvas) B(x, y int) (uint8, error) {
-	c, err := m.AtOk(x, y)
+	c, err := m.At(x, y)
 	if err != nil {
 		return 0, err
 	}
@@ -82,14 +83,14 @@ func (m *mimCanvas) SetMask(in Canvas) error {
 	if !ok {
 		return fmt.Errorf("unsupported canvas %v", in)
 	}
-	m.SetMask(im)
+	m.im.SetMask(im.im)
 	return nil
 }
 
 func (m *mimCanvas) Flatten(r image.Rectangle) error {
 	op := m.im.Draw()
 	op.SetColor(color.Black)
-	op.DrawRectangle(r)
+	op.DrawRectangle(float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()))
 	op.Fill()
 	return op.Do()
 }
@@ -121,7 +122,7 @@ func (m *mimCanvas) RectangleHorizontal(r image.Rectangle, colours ...color.Colo
 
 func (m *mimCanvas) RectangleVertical(r image.Rectangle, colours ...color.Color) error {
 	if len(colours) == 0 {
-		return
+		return nil
 	}
 
 	mx := float64((r.Max.X - r.Min.X) / 2)
@@ -132,6 +133,7 @@ func (m *mimCanvas) RectangleVertical(r image.Rectangle, colours ...color.Color)
 		g.AddColorStop(delta*float64(i), c)
 	}
 
+	op := m.im.Draw()
 	op.SetFillStyle(g)
 	op.MoveTo(float64(r.Min.X), float64(r.Min.Y))
 	op.LineTo(float64(r.Max.X), float64(r.Min.Y))
@@ -169,7 +171,7 @@ func (m *mimCanvas) Line(line []image.Point, width int, colours ...color.Color)
 }
 
 // Polygon solid colour
-func (m *mimCanvas) Polygon(poly [][2]image.Point, c color.Color) error {
+func (m *mimCanvas) Polygon(poly [][2]image.Point, col color.Color) error {
 	if len(poly) < 3 {
 		return nil
 	}
@@ -242,6 +244,11 @@ func (m *mimCanvas) Channel(path []image.Point, width int, depth float64, mode M
 	}
 }
 
+// Flush writes the canvas to disk
+func (m *mimCanvas) Flush() error {
+	return m.im.Flush()
+}
+
 func (m *mimCanvas) Smooth(radius uint32) error {
 	return nil
 }
@@ -7,7 +7,6 @@ import (
 	"image/color"
 	"math"
 	"math/rand"
-	"time"
 )
 
 // From stack overflow I believe, can't recall the original
@@ -24,14 +23,16 @@ type noise2DContext struct {
 	permutations []int
 	gradients    [4]vec2
 	origins      [4]vec2
+
+	period int // if set gradients repeat every `period` along x
 }
 
-func newNoise2DContext(seed int) *noise2DContext {
-	rnd := rand.New(rand.NewSource(int64(seed)))
+func newNoise2DContext(seed int64) *noise2DContext {
+	rnd := rand.New(rand.NewSource(seed))
 
 	n2d := new(noise2DContext)
 	n2d.rgradients = make([]vec2, 256)
-	n2d.permutations = rand.Perm(256)
+	n2d.permutations = rnd.Perm(256)
 	for i := range n2d.rgradients {
 		n2d.rgradients[i] = random_gradient(rnd)
 	}
@@ -43,11 +44,71 @@ func newNoise2DContext(seed int) *noise2DContext {
 // The image is greyscale with colours 0-255 (0->black 255->white).
 // The scale indicates how 'zoomed in' you wish the map to be with higher values
 // being increasingly chaotic. Scale here is intended to be positive only, and we use
-// it's absolute value.
-func NewPerlin(fx, fy int, scale float64, stretch bool) *image.Gray {
+// it's absolute value. The same seed gives the same noise.
+func NewPerlin(seed int64, fx, fy int, scale float64, stretch bool) *image.Gray {
+	return newPerlin(seed, fx, fy, scale, stretch, false)
+}
+
+// NewPerlinWrapped is NewPerlin but the noise carries on smoothly from the right
+// edge to the left, for worlds that wrap around.
+func NewPerlinWrapped(seed int64, fx, fy int, scale float64, stretch bool) *image.Gray {
+	return newPerlin(seed, fx, fy, scale, stretch, true)
+}
+
+func newPerlin(seed int64, fx, fy int, scale float64, stretch, wrap bool) *image.Gray {
 	x, y := sanitize(fx, fy, scale)
-	noise := generate2DNoise(0, x, 0, y, ITTERATIONS, int(time.Now().UnixNano()))
+	n2d := newNoise2DContext(seed)
+
+	var stepX, stepY float32 = 0.1, 0.1
+	if wrap && x > 0 && fx > 0 && fy > 0 {
+		// fit a whole number of gradients across the width (about as many as we'd have
+		// anyway) so they repeat. We sample at full size since resizing would blur each
+		// edge with itself rather than the other edge.
+		n2d.period = int(math.Max(1, math.Round(float64(x)*0.1)))
+		stepX = float32(n2d.period) / float32(fx)
+		stepY = float32(y) * 0.1 / float32(fy)
+		x, y = fx, fy
+	}
+	noise := generate2DNoise(n2d, x, y, stepX, stepY, ITTERATIONS)
+
+	im := toGray(noise, x, y, stretch)
+	if fx != x || fy != y {
+		im = (resizeImage(float64(fx), float64(fy), im)).(*image.Gray)
+	}
 
+	return im
+}
+
+// NewPerlinSphere is NewPerlin for a globe drawn equirectangular (x is longitude, y
+// latitude). Noise is sampled over the surface of the sphere so it's the same size
+// all over the globe (ie. stretched along x near the poles of the image), without seams.
+func NewPerlinSphere(seed int64, fx, fy int, scale float64, stretch bool) *image.Gray {
+	x, _ := sanitize(fx, fy, scale)
+	n3d := newNoise3DContext(seed)
+
+	// about as many gradients around the equator as NewPerlin has across
+	radius := float64(x) * 0.1 / (2 * PI)
+
+	noise := make([]float32, fx*fy)
+	for dy := 0; dy < fy; dy++ {
+		lat := PI/2 - (float64(dy)+0.5)/float64(fy)*PI
+		for dx := 0; dx < fx; dx++ {
+			lon := (float64(dx)+0.5)/float64(fx)*2*PI - PI
+			v := n3d.Get(
+				radius*math.Cos(lat)*math.Cos(lon),
+				radius*math.Cos(lat)*math.Sin(lon),
+				radius*math.Sin(lat),
+			)
+			noise[dy*fx+dx] = float32(v*0.5 + 0.5)
+		}
+	}
+
+	return toGray(noise, fx, fy, stretch)
+}
+
+// toGray turns noise values (0-1) into an image, optionally stretching them to use
+// the full range of colours
+func toGray(noise []float32, x, y int, stretch bool) *image.Gray {
 	var max float32 = 0
 	var min float32 = 1
 	if stretch {
@@ -71,24 +132,16 @@ func NewPerlin(fx, fy int, scale float64, stretch bool) *image.Gray {
 			im.Set(dx, dy, color.Gray{uint8(n * math.MaxUint8)})
 		}
 	}
-	if fx != x || fy != y {
-		im = (resizeImage(float64(fx), float64(fy), im)).(*image.Gray)
-	}
-
 	return im
 }
 
-func generate2DNoise(x, w, y, h, itterations, seed int) []float32 {
-	dx := w - x
-	dy := h - y
-
-	n2d := newNoise2DContext(seed)
+func generate2DNoise(n2d *noise2DContext, w, h int, stepX, stepY float32, itterations int) []float32 {
 	pixels := make([]float32, w*h)
 
 	for i := itterations; i > 0; i-- {
-		for xi := 0; xi < dx-x; xi++ {
-			for yi := 0; yi < dy-y; yi++ {
-				v := n2d.Get(float32(x)+float32(xi)*0.1, float32(y)+float32(yi)*0.1)
+		for xi := 0; xi < w; xi++ {
+			for yi := 0; yi < h; yi++ {
+				v := n2d.Get(float32(xi)*stepX, float32(yi)*stepY)
 				v = v*0.5 + 0.5
 				pixels[yi*w+xi] = v
 			}
@@ -120,6 +173,9 @@ func gradient(orig, grad, p vec2) float32 {
 }
 
 func (n2d *noise2DContext) get_gradient(x, y int) vec2 {
+	if n2d.period > 0 {
+		x = ((x % n2d.period) + n2d.period) % n2d.period
+	}
 	idx := n2d.permutations[x&255] + n2d.permutations[y&255]
 	return n2d.rgradients[idx&255]
 }
@@ -157,6 +213,66 @@ func (n2d *noise2DContext) Get(x, y float32) float32 {
 	return lerpPerlin(vx0, vx1, fy)
 }
 
+// noise3DContext is (improved) perlin noise in 3 dimensions
+type noise3DContext struct {
+	permutations [512]int
+}
+
+// gradients3D are the edges of a cube, from Ken Perlin's improved noise
+var gradients3D = [12][3]float64{
+	{1, 1, 0}, {-1, 1, 0}, {1, -1, 0}, {-1, -1, 0},
+	{1, 0, 1}, {-1, 0, 1}, {1, 0, -1}, {-1, 0, -1},
+	{0, 1, 1}, {0, -1, 1}, {0, 1, -1}, {0, -1, -1},
+}
+
+func newNoise3DContext(seed int64) *noise3DContext {
+	rnd := rand.New(rand.NewSource(seed))
+	n3d := new(noise3DContext)
+	for i, p := range rnd.Perm(256) {
+		n3d.permutations[i] = p
+		n3d.permutations[i+256] = p
+	}
+	return n3d
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func (n3d *noise3DContext) grad(hash int, x, y, z float64) float64 {
+	g := gradients3D[hash%12]
+	return g[0]*x + g[1]*y + g[2]*z
+}
+
+// Get returns noise at some point (about -1 to 1)
+func (n3d *noise3DContext) Get(x, y, z float64) float64 {
+	x0, y0, z0 := math.Floor(x), math.Floor(y), math.Floor(z)
+	xi, yi, zi := int(x0)&255, int(y0)&255, int(z0)&255
+	x, y, z = x-x0, y-y0, z-z0
+	u, v, w := fade(x), fade(y), fade(z)
+
+	p := n3d.permutations
+	a := p[xi] + yi
+	aa, ab := p[a]+zi, p[a+1]+zi
+	b := p[xi+1] + yi
+	ba, bb := p[b]+zi, p[b+1]+zi
+
+	lerp := func(a, b, t float64) float64 { return a + t*(b-a) }
+	return lerp(
+		lerp(
+			lerp(n3d.grad(p[aa], x, y, z), n3d.grad(p[ba], x-1, y, z), u),
+			lerp(n3d.grad(p[ab], x, y-1, z), n3d.grad(p[bb], x-1, y-1, z), u),
+			v,
+		),
+		lerp(
+			lerp(n3d.grad(p[aa+1], x, y, z-1), n3d.grad(p[ba+1], x-1, y, z-1), u),
+			lerp(n3d.grad(p[ab+1], x, y-1, z-1), n3d.grad(p[bb+1], x-1, y-1, z-1), u),
+			v,
+		),
+		w,
+	)
+}
+
 // sanitize fixes weird input values & determines size of perlin map
 func sanitize(x, y int, scale float64) (int, int) {
 	if scale < 0 {
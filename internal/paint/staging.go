@@ -0,0 +1,383 @@
+package paint
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/voidshard/genesis/internal/blob"
+)
+
+const (
+	// stagingDir is where (under root) stages keep their canvases until they're committed
+	stagingDir = ".staging"
+
+	// commitFile is written into a stage when it's committed & lists the canvases to move
+	// into place. A stage without one was never committed.
+	commitFile = "COMMIT"
+)
+
+var (
+	// ErrStaged is returned if Begin is called on a Stage
+	ErrStaged = fmt.Errorf("already staging canvas writes")
+
+	// ErrStageDone is returned if a Stage is used after Commit / Rollback
+	ErrStageDone = fmt.Errorf("stage already committed or rolled back")
+
+	// ErrStagedMove is returned if Move is called on a Stage's storage
+	ErrStagedMove = fmt.Errorf("cannot move blobs within a stage")
+)
+
+type fsStage struct {
+	*fsPaint // our copies of canvases, kept under id/ in the parent's store
+
+	parent *fsPaint
+	id     string          // where the stage is in the parent's store
+	saved  map[string]bool // canvases to move on commit
+
+	done bool
+	lock sync.Mutex
+}
+
+// Begin a set of canvas writes that are only made visible by Commit
+func (p *fsPaint) Begin() (Stage, error) {
+	base := filepath.Join(p.root, stagingDir)
+	err := os.MkdirAll(base, 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir(base, "stage-")
+	if err != nil {
+		return nil, err
+	}
+	id := stagingDir + "/" + filepath.Base(dir)
+
+	return &fsStage{
+		fsPaint: newFsPaint(blob.Prefix(p.store, id), dir, p.width, p.height),
+		parent:  p,
+		id:      id,
+		saved:   map[string]bool{},
+	}, nil
+}
+
+// Begin isn't supported on a Stage
+func (s *fsStage) Begin() (Stage, error) {
+	return nil, ErrStaged
+}
+
+// Canvas returns our copy of a canvas, copying the real one into the stage the first time
+// it's asked for
+func (s *fsStage) Canvas(name string) (Canvas, error) {
+	staged := s.pathFor(name)
+
+	_, err := os.Stat(staged)
+	if err == nil {
+		return loadMimageCanvas(staged)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	err = fetch(s.parent.store, name, staged)
+	if errors.Is(err, blob.ErrNotFound) {
+		return s.NewCanvas(name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return loadMimageCanvas(staged)
+}
+
+// Save writes the canvas into the stage, it'll be moved into place on Commit
+func (s *fsStage) Save(in Canvas) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return ErrStageDone
+	}
+
+	err := flush(in)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(in.Name())
+	err = push(s.store, name, s.pathFor(name))
+	if err != nil {
+		return err
+	}
+
+	s.saved[name] = true
+	return nil
+}
+
+// Delete throws away our copy of a canvas (the real canvas is untouched)
+func (s *fsStage) Delete(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.saved, name)
+	err := s.store.Delete(name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(s.pathFor(name))
+}
+
+// Commit moves all saved canvases into place.
+//
+// We first write out what we're going to move, so if we die half way through Recover
+// can finish the job.
+func (s *fsStage) Commit() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return ErrStageDone
+	}
+
+	names := []string{}
+	for name := range s.saved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	err := s.store.Put(commitFile, []byte(strings.Join(names, "\n")))
+	if err != nil {
+		return err
+	}
+	s.done = true
+
+	names, err = applyStage(s.parent.store, s.id)
+	for _, name := range names {
+		changed(s.parent.store, name)
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(s.root)
+}
+
+// Rollback throws away all staged canvases
+func (s *fsStage) Rollback() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return nil
+	}
+
+	s.done = true
+	err := s.parent.store.Delete(s.id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(s.root)
+}
+
+// Store returns storage whose writes are moved into place on Commit
+func (s *fsStage) Store() blob.Blob {
+	return &stageBlob{stage: s, staged: s.store, parent: s.parent.store}
+}
+
+// keep writes a blob into the stage, it'll be moved into place on Commit
+func (s *fsStage) keep(key string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return ErrStageDone
+	}
+
+	err := s.store.Put(key, data)
+	if err == nil {
+		s.saved[key] = true
+	}
+	return err
+}
+
+// drop throws away our copy of a blob
+func (s *fsStage) drop(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.saved, key)
+	return s.store.Delete(key)
+}
+
+// stageBlob is storage that writes into a stage. Reads find the staged copy of a blob
+// if there is one, otherwise the real one.
+//
+// As with canvases, Delete only throws away a staged copy.
+type stageBlob struct {
+	stage interface {
+		keep(key string, data []byte) error
+		drop(key string) error
+	}
+	staged blob.Blob
+	parent blob.Blob
+}
+
+func (b *stageBlob) Get(key string) ([]byte, error) {
+	data, err := b.staged.Get(key)
+	if errors.Is(err, blob.ErrNotFound) {
+		return b.parent.Get(key)
+	}
+	return data, err
+}
+
+func (b *stageBlob) Put(key string, data []byte) error {
+	return b.stage.keep(key, data)
+}
+
+func (b *stageBlob) Delete(key string) error {
+	return b.stage.drop(key)
+}
+
+func (b *stageBlob) List(prefix string) ([]string, error) {
+	staged, err := b.staged.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	found, err := b.parent.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	out := []string{}
+	for _, k := range append(staged, found...) {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// Move isn't supported, staged writes are only moved by Commit
+func (b *stageBlob) Move(from, to string) error {
+	return ErrStagedMove
+}
+
+// Recover finishes any committed stages in the store that we didn't finish moving into
+// place (ie. we died mid commit) & throws away uncommitted stages.
+//
+// It must not be called while stages are in use.
+func Recover(store blob.Blob, cache string) error {
+	keys, err := store.List(stagingDir + "/")
+	if err != nil {
+		return err
+	}
+
+	ids := map[string]bool{}
+	for _, k := range keys {
+		parts := strings.SplitN(k, "/", 3)
+		if len(parts) > 2 {
+			ids[parts[0]+"/"+parts[1]] = true
+		}
+	}
+
+	for id := range ids {
+		_, err = store.Get(id + "/" + commitFile)
+		if errors.Is(err, blob.ErrNotFound) {
+			err = store.Delete(id)
+			if err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		names, err := applyStage(store, id)
+		for _, name := range names {
+			changed(store, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// anything left on disk is of no use now
+	return os.RemoveAll(filepath.Join(workDir(store, cache), stagingDir))
+}
+
+// applyStage moves canvases listed in a stage's commit file into place. This is safe to
+// call again if it's interrupted, until the commit file is removed.
+func applyStage(store blob.Blob, id string) ([]string, error) {
+	data, err := store.Get(id + "/" + commitFile)
+	if err != nil {
+		return nil, err
+	}
+
+	moved := []string{}
+	for _, name := range strings.Split(string(data), "\n") {
+		if name == "" {
+			continue
+		}
+		err = store.Move(id+"/"+name, name)
+		if err != nil {
+			return moved, err
+		}
+		moved = append(moved, name)
+	}
+
+	// the commit file goes first, what's left without it is never applied again
+	err = store.Delete(id + "/" + commitFile)
+	if err != nil {
+		return moved, err
+	}
+	return moved, store.Delete(id)
+}
+
+// copyDir copies the files of a canvas from src to dst
+func copyDir(src, dst string) error {
+	_, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0750)
+		}
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+// copyFile copies a single file
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	cerr := out.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
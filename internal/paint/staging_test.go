@@ -0,0 +1,182 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/blob"
+)
+
+func testStages(t *testing.T) map[string]func() (blob.Blob, Painter) {
+	return map[string]func() (blob.Blob, Painter){
+		"filesystem": func() (blob.Blob, Painter) {
+			store, err := blob.NewFilesystem(t.TempDir())
+			assert.Nil(t, err)
+			return store, newFsPaint(store, t.TempDir(), 4, 4)
+		},
+		"remote": func() (blob.Blob, Painter) {
+			store := blob.NewMemory() // anything that isn't local is copied via a cache
+			return store, newFsPaint(store, t.TempDir(), 4, 4)
+		},
+		"memory": func() (blob.Blob, Painter) {
+			store := blob.NewMemory()
+			return store, newMemPaint(store, 4, 4)
+		},
+	}
+}
+
+func TestStageCommit(t *testing.T) {
+	for name, mk := range testStages(t) {
+		t.Run(name, func(t *testing.T) {
+			store, pnt := mk()
+			assert.Nil(t, store.Put("graph", []byte("old")))
+
+			stage, err := pnt.Begin()
+			assert.Nil(t, err)
+
+			assert.Nil(t, stage.Store().Put("graph", []byte("new")))
+
+			// staged writes are read back from the stage, but nowhere else
+			data, err := stage.Store().Get("graph")
+			assert.Nil(t, err)
+			assert.Equal(t, "new", string(data))
+
+			data, err = store.Get("graph")
+			assert.Nil(t, err)
+			assert.Equal(t, "old", string(data))
+
+			assert.Nil(t, stage.Commit())
+
+			data, err = store.Get("graph")
+			assert.Nil(t, err)
+			assert.Equal(t, "new", string(data))
+
+			assert.ErrorIs(t, stage.Commit(), ErrStageDone)
+			assert.ErrorIs(t, stage.Store().Put("graph", []byte("late")), ErrStageDone)
+			assert.Nil(t, stage.Rollback()) // noop once committed
+
+			// the stage leaves nothing behind
+			keys, err := store.List(stagingDir)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, len(keys))
+		})
+	}
+}
+
+func TestStageRollback(t *testing.T) {
+	for name, mk := range testStages(t) {
+		t.Run(name, func(t *testing.T) {
+			store, pnt := mk()
+			assert.Nil(t, store.Put("graph", []byte("old")))
+
+			stage, err := pnt.Begin()
+			assert.Nil(t, err)
+
+			assert.Nil(t, stage.Store().Put("graph", []byte("new")))
+			assert.Nil(t, stage.Store().Put("other", []byte("new")))
+
+			assert.Nil(t, stage.Rollback())
+
+			data, err := store.Get("graph")
+			assert.Nil(t, err)
+			assert.Equal(t, "old", string(data))
+
+			_, err = store.Get("other")
+			assert.ErrorIs(t, err, blob.ErrNotFound)
+
+			assert.ErrorIs(t, stage.Commit(), ErrStageDone)
+
+			keys, err := store.List(stagingDir)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, len(keys))
+		})
+	}
+}
+
+func TestStageDeleteDropsStagedCopy(t *testing.T) {
+	for name, mk := range testStages(t) {
+		t.Run(name, func(t *testing.T) {
+			store, pnt := mk()
+			assert.Nil(t, store.Put("graph", []byte("old")))
+
+			stage, err := pnt.Begin()
+			assert.Nil(t, err)
+
+			assert.Nil(t, stage.Store().Put("graph", []byte("new")))
+			assert.Nil(t, stage.Store().Delete("graph"))
+
+			// the real blob shows through again & is left alone
+			data, err := stage.Store().Get("graph")
+			assert.Nil(t, err)
+			assert.Equal(t, "old", string(data))
+
+			assert.Nil(t, stage.Commit())
+
+			data, err = store.Get("graph")
+			assert.Nil(t, err)
+			assert.Equal(t, "old", string(data))
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	store := blob.NewMemory()
+	cache := t.TempDir()
+
+	for k, v := range map[string]string{
+		"a/0-0.png": "old a",
+		"b/0-0.png": "old b",
+		"c/0-0.png": "old c",
+
+		// committed, but we died having moved only "a" into place
+		stagingDir + "/stage-1/" + commitFile: "a\nb",
+		stagingDir + "/stage-1/b/0-0.png":     "new b",
+
+		// never committed
+		stagingDir + "/stage-2/c/0-0.png": "new c",
+	} {
+		assert.Nil(t, store.Put(k, []byte(v)))
+	}
+
+	assert.Nil(t, Recover(store, cache))
+
+	for k, want := range map[string]string{
+		"a/0-0.png": "old a", // untouched, the moved copy is already in place
+		"b/0-0.png": "new b",
+		"c/0-0.png": "old c",
+	} {
+		data, err := store.Get(k)
+		assert.Nil(t, err)
+		assert.Equal(t, want, string(data), k)
+	}
+
+	keys, err := store.List(stagingDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(keys))
+
+	// nothing to do the second time around
+	assert.Nil(t, Recover(store, cache))
+}
+
+func TestRecoverInterruptedCommit(t *testing.T) {
+	store := blob.NewMemory()
+	pnt := newFsPaint(store, t.TempDir(), 4, 4)
+
+	stage, err := pnt.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, stage.Store().Put("graph", []byte("new")))
+
+	// write the commit file as Commit does, but "die" before anything is moved
+	fs := stage.(*fsStage)
+	assert.Nil(t, fs.store.Put(commitFile, []byte("graph")))
+
+	_, err = store.Get("graph")
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+
+	assert.Nil(t, Recover(store, pnt.root))
+
+	data, err := store.Get("graph")
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(data))
+}
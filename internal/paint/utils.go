@@ -1,8 +1,10 @@
 package paint
 
 import (
+	"context"
 	"image"
 	"image/color"
+	"image/draw"
 )
 
 //
@@ -10,6 +12,48 @@ func SmoothImage(in image.Image, radius uint32) (image.Image, error) {
 	return smoothImage(in, radius)
 }
 
+// Image returns what is drawn on a canvas as an image
+func Image(in Canvas) (image.Image, error) {
+	im, ok := in.(interface{ Image() image.Image })
+	if ok {
+		return im.Image(), nil
+	}
+
+	// otherwise we read it a pixel at a time
+	bnds := in.Bounds()
+	out := image.NewRGBA(bnds)
+	for x := bnds.Min.X; x < bnds.Max.X; x++ {
+		for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+			c, err := in.At(x, y)
+			if err != nil {
+				return nil, err
+			}
+			out.Set(x, y, c)
+		}
+	}
+	return out, nil
+}
+
+// FlattenOutside returns a copy of the image that is black outside of r, with the
+// edges of r smoothed down into the black
+func FlattenOutside(in image.Image, r image.Rectangle) (image.Image, error) {
+	bnds := in.Bounds()
+	blk := image.NewRGBA(bnds)
+	draw.Draw(blk, bnds, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(blk, r, in, r.Min, draw.Src)
+
+	im, err := SmoothImage(blk, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.NewNRGBA(bnds)
+	draw.Draw(out, bnds, im, bnds.Min, draw.Src)
+	inset := r.Inset(5)
+	draw.Draw(out, inset, in, inset.Min, draw.Src)
+	return out, nil
+}
+
 //
 func orderedPolygon(in [][2]image.Point) []image.Point {
 	to := map[image.Point]image.Point{}
@@ -35,25 +79,32 @@ func orderedPolygon(in [][2]image.Point) []image.Point {
 
 // merge does a simple in-elegant weighted merge
 // I suspect there are more efficient ways of doing this .. using draw / masks maybe?
-func merge(pnt Painter, area image.Rectangle, weights map[Canvas]float64) (image.Image, error) {
+func merge(ctx context.Context, pnt Painter, area image.Rectangle, weights map[Canvas]float64) (image.Image, error) {
 	im := image.NewGray(area)
 
-	canvases := map[image.Image]float64{}
+	canvases := map[Canvas]float64{}
 	for cnv, w := range weights {
 		if w == 0 {
 			continue
 		}
-		canvases[cnv.Image()] = w
+		canvases[cnv] = w
 	}
 	if len(canvases) == 0 {
 		return im, nil
 	}
 
 	for x := area.Min.X; x < area.Max.X; x++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		for y := area.Min.Y; y < area.Max.Y; y++ {
 			v := 0.0
 			for cnv, w := range canvases {
-				r, _, _, _ := cnv.At(x, y).RGBA()
+				c, err := cnv.At(x, y)
+				if err != nil {
+					return nil, err
+				}
+				r, _, _, _ := c.RGBA()
 				v += w * float64(r>>8)
 			}
 			if v < 0 { // clamp
@@ -67,3 +118,80 @@ func merge(pnt Painter, area image.Rectangle, weights map[Canvas]float64) (image
 
 	return im, nil
 }
+
+// merge16 is merge but output as 16 bit values, since the weighted sum of
+// many 8 bit canvases has more precision than fits in a uint8
+func merge16(ctx context.Context, pnt Painter, area image.Rectangle, weights map[Canvas]float64) (*image.Gray16, error) {
+	im := image.NewGray16(area)
+
+	canvases := map[Canvas]float64{}
+	for cnv, w := range weights {
+		if w == 0 {
+			continue
+		}
+		canvases[cnv] = w
+	}
+	if len(canvases) == 0 {
+		return im, nil
+	}
+
+	for x := area.Min.X; x < area.Max.X; x++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			v := 0.0
+			for cnv, w := range canvases {
+				c, err := cnv.At(x, y)
+				if err != nil {
+					return nil, err
+				}
+				r, _, _, _ := c.RGBA()
+				v += w * float64(r)
+			}
+			if v < 0 { // clamp
+				v = 0
+			} else if v > 0xffff {
+				v = 0xffff
+			}
+			im.SetGray16(x, y, color.Gray16{Y: uint16(v)})
+		}
+	}
+
+	return im, nil
+}
+
+// SmoothGray16 applies a box blur of the given radius (two passes, horizontal then
+// vertical) to a 16 bit image. Used in place of SmoothImage where we don't want to
+// throw away precision.
+func SmoothGray16(in *image.Gray16, radius int) *image.Gray16 {
+	if radius < 1 {
+		return in
+	}
+
+	bnds := in.Bounds()
+	tmp := image.NewGray16(bnds)
+	out := image.NewGray16(bnds)
+
+	blur := func(src, dst *image.Gray16, dx, dy int) {
+		for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+			for x := bnds.Min.X; x < bnds.Max.X; x++ {
+				total, count := 0, 0
+				for i := -radius; i <= radius; i++ {
+					p := image.Pt(x+i*dx, y+i*dy)
+					if !p.In(bnds) {
+						continue
+					}
+					total += int(src.Gray16At(p.X, p.Y).Y)
+					count++
+				}
+				dst.SetGray16(x, y, color.Gray16{Y: uint16(total / count)})
+			}
+		}
+	}
+
+	blur(in, tmp, 1, 0)
+	blur(tmp, out, 0, 1)
+
+	return out
+}
@@ -0,0 +1,59 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+)
+
+// Event describes how far along some step of an operation is.
+// Eg. "rain: 40% of storm fronts"
+type Event struct {
+	Step  string
+	Unit  string
+	Done  int
+	Total int
+}
+
+// Reporter is handed progress events
+type Reporter func(*Event)
+
+type reporterKey struct{}
+
+// WithReporter returns a context that passes progress events to `fn`.
+// Reporters may be called from multiple goroutines.
+func WithReporter(ctx context.Context, fn Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, fn)
+}
+
+// Report tells the context's reporter (if any) how far along `step` is.
+//
+// Returns ctx.Err() so long running loops can report & check for cancellation
+// in one go.
+func Report(ctx context.Context, step string, done, total int, unit string) error {
+	fn, ok := ctx.Value(reporterKey{}).(Reporter)
+	if ok && fn != nil {
+		fn(&Event{Step: step, Unit: unit, Done: done, Total: total})
+	}
+	return ctx.Err()
+}
+
+// Fraction returns how far along (0-1) the step is
+func (e *Event) Fraction() float64 {
+	if e.Total <= 0 {
+		return 0
+	}
+	f := float64(e.Done) / float64(e.Total)
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// String returns a human readable message, eg. "rain: 40% of storm fronts"
+func (e *Event) String() string {
+	msg := fmt.Sprintf("%s: %d%%", e.Step, int(e.Fraction()*100))
+	if e.Unit != "" {
+		msg += " of " + e.Unit
+	}
+	return msg
+}
@@ -0,0 +1,304 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+
+	"github.com/fogleman/gg"
+)
+
+const (
+	// reliefScale exaggerates heights (normalised 0-1) relative to pixel distances
+	// when computing surface normals for hillshading
+	reliefScale = 100.0
+)
+
+var (
+	// light direction for hillshading; from the north west, 45 degrees up
+	lightX, lightY, lightZ = normalise(-1, -1, math.Sqrt2)
+)
+
+// Label is some text placed on the map
+type Label struct {
+	Text string
+	At   image.Point // world pixel
+}
+
+// Layers are the inputs to a render.
+//
+// All images are expected to cover the same area (the area being rendered).
+type Layers struct {
+	// Height is the heightmap (required)
+	Height *image.Gray16
+
+	// Water is sea temperature, where 0 implies land (optional).
+	// If not given anything at or below SeaLevel is considered sea.
+	Water *image.Gray16
+
+	// SeaLevel height, at or below which is sea (used only if Water is not given)
+	SeaLevel uint16
+
+	// Rain is rainfall (optional)
+	Rain *image.Gray16
+
+	// Labels are drawn over the map
+	Labels []*Label
+
+	// UnitsPerPixel & Unit are used to draw the scale bar (default 1 "px")
+	UnitsPerPixel float64
+	Unit          string
+
+	// Ranges to normalise values with (optional). If not given they're measured
+	// from the layers, so renders of different areas aren't coloured consistently
+	// unless the same Ranges are passed to each.
+	Ranges *Ranges
+
+	// Plain skips drawing labels, the scale bar & compass (eg. for map tiles)
+	Plain bool
+}
+
+// Render composites layers into a coloured map in the given style with hillshading,
+// labels, a scale bar & compass.
+func Render(in *Layers, style Style) (image.Image, error) {
+	if in.Height == nil {
+		return nil, fmt.Errorf("height layer is required")
+	}
+	bnds := in.Height.Bounds()
+
+	rng := in.Ranges
+	if rng == nil {
+		rng = NewRanges()
+		rng.Measure(in)
+	}
+
+	// output is relative to the area rendered (ie. starts at 0,0)
+	out := image.NewRGBA(image.Rect(0, 0, bnds.Dx(), bnds.Dy()))
+	strength := style.Hillshade()
+	for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+		for x := bnds.Min.X; x < bnds.Max.X; x++ {
+			h := in.Height.Gray16At(x, y).Y
+
+			var c color.RGBA
+			if in.isSea(x, y) {
+				temp := 0.5
+				if in.Water != nil {
+					temp = norm(in.Water.Gray16At(x, y).Y, rng.TempMin, rng.TempMax)
+				}
+				c = style.Sea(1-norm(h, rng.SeaMin, rng.SeaMax), temp)
+			} else {
+				rain := 0.5
+				if in.Rain != nil {
+					rain = norm(in.Rain.Gray16At(x, y).Y, 0, rng.RainMax)
+				}
+				c = style.Land(norm(h, rng.LandMin, rng.LandMax), rain)
+				c = shade(c, hillshade(in.Height, x, y), strength)
+			}
+
+			out.SetRGBA(x-bnds.Min.X, y-bnds.Min.Y, c)
+		}
+	}
+
+	if in.Plain {
+		return out, nil
+	}
+	return decorate(out, bnds.Min, in, style), nil
+}
+
+// Ranges are the min / max values seen in each layer, used to normalise values
+// before they're coloured
+type Ranges struct {
+	LandMin, LandMax uint16
+	SeaMin, SeaMax   uint16
+	TempMin, TempMax uint16
+	RainMax          uint16
+}
+
+// NewRanges returns empty ranges, ready to Measure
+func NewRanges() *Ranges {
+	return &Ranges{LandMin: math.MaxUint16, SeaMin: math.MaxUint16, TempMin: math.MaxUint16}
+}
+
+// Measure extends the ranges to include the values in the given layers.
+// Can be called repeatedly to measure an area too large to load at once.
+func (r *Ranges) Measure(in *Layers) {
+	bnds := in.Height.Bounds()
+	for y := bnds.Min.Y; y < bnds.Max.Y; y++ {
+		for x := bnds.Min.X; x < bnds.Max.X; x++ {
+			h := in.Height.Gray16At(x, y).Y
+			if in.isSea(x, y) {
+				r.SeaMin, r.SeaMax = min16(r.SeaMin, h), max16(r.SeaMax, h)
+				if in.Water != nil {
+					t := in.Water.Gray16At(x, y).Y
+					r.TempMin, r.TempMax = min16(r.TempMin, t), max16(r.TempMax, t)
+				}
+			} else {
+				r.LandMin, r.LandMax = min16(r.LandMin, h), max16(r.LandMax, h)
+			}
+			if in.Rain != nil {
+				r.RainMax = max16(r.RainMax, in.Rain.Gray16At(x, y).Y)
+			}
+		}
+	}
+}
+
+func (in *Layers) isSea(x, y int) bool {
+	if in.Water != nil {
+		return in.Water.Gray16At(x, y).Y > 0
+	}
+	return in.Height.Gray16At(x, y).Y <= in.SeaLevel
+}
+
+// WritePNG saves an image as a PNG
+func WritePNG(path string, im image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, im)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// decorate draws labels, a scale bar & a compass over the map, where `origin` is
+// the world pixel of the top left of the image
+func decorate(im *image.RGBA, origin image.Point, in *Layers, style Style) image.Image {
+	bnds := im.Bounds()
+	w, h := float64(bnds.Dx()), float64(bnds.Dy())
+
+	ctx := gg.NewContextForRGBA(im)
+	ink, paper := style.Ink(), style.Paper()
+
+	for _, l := range in.Labels {
+		at := l.At.Sub(origin)
+		if !at.In(bnds) {
+			continue
+		}
+		x, y := float64(at.X), float64(at.Y)
+		ctx.SetColor(paper)
+		for _, d := range [][2]float64{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			ctx.DrawStringAnchored(l.Text, x+d[0], y+d[1], 0.5, 0.5)
+		}
+		ctx.SetColor(ink)
+		ctx.DrawStringAnchored(l.Text, x, y, 0.5, 0.5)
+	}
+
+	// scale bar, bottom left, roughly a fifth of the width
+	upp := in.UnitsPerPixel
+	if upp <= 0 {
+		upp = 1
+	}
+	unit := in.Unit
+	if unit == "" {
+		unit = "px"
+	}
+	length := niceNumber(w / 5 * upp)
+	px := length / upp
+	margin := math.Max(10, w/50)
+	ctx.SetColor(ink)
+	ctx.SetLineWidth(2)
+	ctx.DrawLine(margin, h-margin, margin+px, h-margin)
+	ctx.DrawLine(margin, h-margin-4, margin, h-margin+4)
+	ctx.DrawLine(margin+px, h-margin-4, margin+px, h-margin+4)
+	ctx.Stroke()
+	ctx.DrawStringAnchored(fmt.Sprintf("%g %s", length, unit), margin+px/2, h-margin-8, 0.5, 0)
+
+	// compass, top right
+	size := math.Max(12, w/40)
+	cx, cy := w-margin-size, margin+size*1.5
+	ctx.MoveTo(cx, cy-size)
+	ctx.LineTo(cx+size/3, cy+size/2)
+	ctx.LineTo(cx, cy+size/4)
+	ctx.LineTo(cx-size/3, cy+size/2)
+	ctx.ClosePath()
+	ctx.Fill()
+	ctx.DrawStringAnchored("N", cx, cy-size-4, 0.5, 0)
+
+	return im
+}
+
+// hillshade returns how lit (0-1) the terrain at x,y is, based on it's surface normal
+func hillshade(im *image.Gray16, x, y int) float64 {
+	bnds := im.Bounds()
+	at := func(px, py int) float64 {
+		p := image.Pt(px, py)
+		if !p.In(bnds) {
+			p = image.Pt(x, y)
+		}
+		return float64(im.Gray16At(p.X, p.Y).Y) / math.MaxUint16
+	}
+
+	dx := (at(x+1, y) - at(x-1, y)) / 2 * reliefScale
+	dy := (at(x, y+1) - at(x, y-1)) / 2 * reliefScale
+	nx, ny, nz := normalise(-dx, -dy, 1)
+
+	lit := nx*lightX + ny*lightY + nz*lightZ
+	if lit < 0 {
+		return 0
+	}
+	return lit
+}
+
+// shade darkens a colour by how unlit it is
+func shade(c color.RGBA, lit, strength float64) color.RGBA {
+	// flat ground has lit = lightZ, we want that to be unchanged
+	f := 1 + (lit-lightZ)*strength
+	s := func(v uint8) uint8 {
+		r := float64(v) * f
+		if r > 255 {
+			return 255
+		} else if r < 0 {
+			return 0
+		}
+		return uint8(r)
+	}
+	return color.RGBA{s(c.R), s(c.G), s(c.B), c.A}
+}
+
+// niceNumber rounds down to 1, 2 or 5 times a power of ten
+func niceNumber(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	p := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{5, 2, 1} {
+		if m*p <= v {
+			return m * p
+		}
+	}
+	return p
+}
+
+func normalise(x, y, z float64) (float64, float64, float64) {
+	l := math.Sqrt(x*x + y*y + z*z)
+	return x / l, y / l, z / l
+}
+
+func norm(v, min, max uint16) float64 {
+	if max <= min || v <= min {
+		return 0
+	} else if v >= max {
+		return 1
+	}
+	return float64(v-min) / float64(max-min)
+}
+
+func min16(a, b uint16) uint16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max16(a, b uint16) uint16 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,119 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStyles(t *testing.T) {
+	for _, name := range []string{"atlas", "parchment", "satellite"} {
+		assert.Contains(t, Styles(), name)
+		s, err := GetStyle(name)
+		assert.Nil(t, err)
+		assert.NotNil(t, s)
+	}
+
+	_, err := GetStyle("nope")
+	assert.ErrorIs(t, err, ErrUnknownStyle)
+
+	custom := &rampStyle{
+		dryLand: []stop{{0, color.RGBA{1, 2, 3, 255}}},
+		wetLand: []stop{{0, color.RGBA{4, 5, 6, 255}}},
+		coldSea: []stop{{0, color.RGBA{7, 8, 9, 255}}},
+		warmSea: []stop{{0, color.RGBA{10, 11, 12, 255}}},
+	}
+	Register("custom", custom)
+	defer func() {
+		styleLock.Lock()
+		defer styleLock.Unlock()
+		delete(styles, "custom")
+	}()
+	s, err := GetStyle("custom")
+	assert.Nil(t, err)
+	assert.Equal(t, custom, s)
+	assert.Contains(t, Styles(), "custom")
+
+	// the ends of each ramp are what they say, rain & temperature blend between them
+	assert.Equal(t, atlas.dryLand[0].col, atlas.Land(0, 0))
+	assert.Equal(t, atlas.wetLand[len(atlas.wetLand)-1].col, atlas.Land(1, 1))
+	assert.Equal(t, atlas.coldSea[0].col, atlas.Sea(0, 0))
+	assert.Equal(t, atlas.warmSea[1].col, atlas.Sea(1, 1))
+	assert.Equal(t, mix(atlas.dryLand[1].col, atlas.wetLand[1].col, 0.5), atlas.Land(0.3, 0.5))
+}
+
+// slope returns a heightmap rising `step` per pixel east (or west, if step < 0)
+func slope(area image.Rectangle, step int) *image.Gray16 {
+	im := image.NewGray16(area)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			h := 30000 + (x-area.Min.X)*step
+			im.SetGray16(x, y, color.Gray16{uint16(h)})
+		}
+	}
+	return im
+}
+
+func TestHillshade(t *testing.T) {
+	area := image.Rect(0, 0, 10, 10)
+
+	// flat ground is lit as much as the light is high & shading leaves it alone
+	flat := slope(area, 0)
+	assert.InDelta(t, lightZ, hillshade(flat, 5, 5), 1e-9)
+	c := color.RGBA{100, 150, 200, 255}
+	assert.Equal(t, c, shade(c, hillshade(flat, 5, 5), 1))
+
+	// 45 degree slopes (rising 1 per pixel, after reliefScale), the light being in the
+	// north west slopes facing west are lit more
+	step := int(math.Round(math.MaxUint16 / reliefScale))
+	facingWest := (1 + math.Sqrt2) / (2 * math.Sqrt2)
+	facingEast := (math.Sqrt2 - 1) / (2 * math.Sqrt2)
+	assert.InDelta(t, facingWest, hillshade(slope(area, step), 5, 5), 0.001)
+	assert.InDelta(t, facingEast, hillshade(slope(area, -step), 5, 5), 0.001)
+
+	// at the edge we only see half the slope
+	half := (0.5 + math.Sqrt2) / (2 * math.Sqrt(1.25))
+	assert.InDelta(t, half, hillshade(slope(area, step), 0, 5), 0.001)
+
+	assert.Greater(t, shade(c, facingWest, 1).R, c.R)
+	assert.Less(t, shade(c, facingEast, 1).R, c.R)
+}
+
+func TestRenderBounds(t *testing.T) {
+	// some area away from the world origin, sea to the west
+	area := image.Rect(100, 50, 140, 80)
+	height := slope(area, 500)
+	in := &Layers{Height: height, SeaLevel: 30000 + 500*9, Plain: true}
+
+	im, err := Render(in, atlas)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, area.Dx(), area.Dy()), im.Bounds())
+
+	rng := NewRanges()
+	rng.Measure(in)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			got := im.At(x-area.Min.X, y-area.Min.Y)
+			h := height.Gray16At(x, y).Y
+			if x-area.Min.X < 10 {
+				assert.Equal(t, atlas.Sea(1-norm(h, rng.SeaMin, rng.SeaMax), 0.5), got)
+			} else {
+				lit := shade(atlas.Land(norm(h, rng.LandMin, rng.LandMax), 0.5), hillshade(height, x, y), atlas.Hillshade())
+				assert.Equal(t, lit, got)
+			}
+		}
+	}
+
+	// decorations are drawn over the same area, labels are placed by world pixel
+	in.Plain = false
+	in.Labels = []*Label{{Text: "here", At: image.Pt(120, 60)}, {Text: "off the map", At: image.Pt(5, 5)}}
+	im, err = Render(in, atlas)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, area.Dx(), area.Dy()), im.Bounds())
+
+	_, err = Render(&Layers{}, atlas)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,214 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"sync"
+)
+
+// Style decides the colours of a rendered map.
+//
+// All inputs are normalised to 0-1.
+type Style interface {
+	// Land returns the colour of land at some elevation (0 at sea level, 1 at the
+	// highest point) given the rainfall (0 driest, 1 wettest).
+	Land(elevation, rain float64) color.RGBA
+
+	// Sea returns the colour of the sea at some depth (0 at the coast, 1 deepest)
+	// given the water temperature (0 coldest, 1 warmest).
+	Sea(depth, temperature float64) color.RGBA
+
+	// Hillshade is how strongly terrain relief darkens slopes facing away from the light
+	Hillshade() float64
+
+	// Ink is the colour of labels, the scale bar & compass
+	Ink() color.RGBA
+
+	// Paper is the colour of the halo behind labels
+	Paper() color.RGBA
+}
+
+var (
+	// ErrUnknownStyle is returned when asking for a style that isn't registered
+	ErrUnknownStyle = fmt.Errorf("unknown style")
+
+	styleLock = sync.RWMutex{}
+	styles    = map[string]Style{
+		"atlas":     atlas,
+		"parchment": parchment,
+		"satellite": satellite,
+	}
+)
+
+// Register makes a style available by name, replacing any existing style of the same name
+func Register(name string, s Style) {
+	styleLock.Lock()
+	defer styleLock.Unlock()
+	styles[name] = s
+}
+
+// GetStyle returns the named style
+func GetStyle(name string) (Style, error) {
+	styleLock.RLock()
+	defer styleLock.RUnlock()
+	s, ok := styles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownStyle, name)
+	}
+	return s, nil
+}
+
+// Styles returns the names of all registered styles
+func Styles() []string {
+	styleLock.RLock()
+	defer styleLock.RUnlock()
+	names := []string{}
+	for n := range styles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stop is a colour at a position (0-1) along a ramp
+type stop struct {
+	pos float64
+	col color.RGBA
+}
+
+// ramp returns the colour at `pos` linearly interpolated between stops
+func ramp(pos float64, stops []stop) color.RGBA {
+	if pos <= stops[0].pos {
+		return stops[0].col
+	}
+	for i := 1; i < len(stops); i++ {
+		if pos <= stops[i].pos {
+			a, b := stops[i-1], stops[i]
+			return mix(a.col, b.col, (pos-a.pos)/(b.pos-a.pos))
+		}
+	}
+	return stops[len(stops)-1].col
+}
+
+// mix returns a colour `t` (0-1) of the way from a to b
+func mix(a, b color.RGBA, t float64) color.RGBA {
+	if t < 0 {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+	l := func(x, y uint8) uint8 {
+		return uint8(float64(x)*(1-t) + float64(y)*t)
+	}
+	return color.RGBA{l(a.R, b.R), l(a.G, b.G), l(a.B, b.B), 255}
+}
+
+// rampStyle is a style built from colour ramps.
+//
+// Land is a blend of a dry & wet hypsometric tint depending on rainfall, sea is a blend
+// of a cold & warm bathymetric tint depending on temperature.
+type rampStyle struct {
+	dryLand   []stop
+	wetLand   []stop
+	coldSea   []stop
+	warmSea   []stop
+	hillshade float64
+	ink       color.RGBA
+	paper     color.RGBA
+}
+
+func (s *rampStyle) Land(elevation, rain float64) color.RGBA {
+	return mix(ramp(elevation, s.dryLand), ramp(elevation, s.wetLand), rain)
+}
+
+func (s *rampStyle) Sea(depth, temperature float64) color.RGBA {
+	return mix(ramp(depth, s.coldSea), ramp(depth, s.warmSea), temperature)
+}
+
+func (s *rampStyle) Hillshade() float64 { return s.hillshade }
+
+func (s *rampStyle) Ink() color.RGBA { return s.ink }
+
+func (s *rampStyle) Paper() color.RGBA { return s.paper }
+
+var (
+	// atlas is a classic hypsometric tint; greens through browns to white peaks
+	atlas = &rampStyle{
+		dryLand: []stop{
+			{0, color.RGBA{196, 186, 128, 255}},
+			{0.3, color.RGBA{214, 190, 140, 255}},
+			{0.6, color.RGBA{168, 124, 84, 255}},
+			{0.85, color.RGBA{140, 120, 110, 255}},
+			{1, color.RGBA{250, 250, 250, 255}},
+		},
+		wetLand: []stop{
+			{0, color.RGBA{98, 160, 90, 255}},
+			{0.3, color.RGBA{150, 186, 110, 255}},
+			{0.6, color.RGBA{176, 150, 96, 255}},
+			{0.85, color.RGBA{150, 130, 120, 255}},
+			{1, color.RGBA{255, 255, 255, 255}},
+		},
+		coldSea: []stop{
+			{0, color.RGBA{170, 205, 225, 255}},
+			{1, color.RGBA{30, 70, 120, 255}},
+		},
+		warmSea: []stop{
+			{0, color.RGBA{160, 220, 230, 255}},
+			{1, color.RGBA{30, 90, 140, 255}},
+		},
+		hillshade: 0.6,
+		ink:       color.RGBA{30, 30, 30, 255},
+		paper:     color.RGBA{255, 255, 255, 200},
+	}
+
+	// parchment is an old fashioned, mostly monochrome sepia map
+	parchment = &rampStyle{
+		dryLand: []stop{
+			{0, color.RGBA{232, 216, 176, 255}},
+			{1, color.RGBA{160, 128, 88, 255}},
+		},
+		wetLand: []stop{
+			{0, color.RGBA{222, 214, 170, 255}},
+			{1, color.RGBA{150, 126, 86, 255}},
+		},
+		coldSea: []stop{
+			{0, color.RGBA{214, 206, 176, 255}},
+			{1, color.RGBA{180, 170, 140, 255}},
+		},
+		warmSea: []stop{
+			{0, color.RGBA{214, 206, 176, 255}},
+			{1, color.RGBA{180, 170, 140, 255}},
+		},
+		hillshade: 0.8,
+		ink:       color.RGBA{70, 44, 20, 255},
+		paper:     color.RGBA{232, 216, 176, 200},
+	}
+
+	// satellite roughly imitates true colour imagery
+	satellite = &rampStyle{
+		dryLand: []stop{
+			{0, color.RGBA{200, 180, 140, 255}},
+			{0.5, color.RGBA{170, 140, 100, 255}},
+			{0.85, color.RGBA{120, 110, 100, 255}},
+			{1, color.RGBA{240, 240, 245, 255}},
+		},
+		wetLand: []stop{
+			{0, color.RGBA{50, 90, 40, 255}},
+			{0.5, color.RGBA{70, 100, 50, 255}},
+			{0.85, color.RGBA{100, 100, 90, 255}},
+			{1, color.RGBA{245, 245, 250, 255}},
+		},
+		coldSea: []stop{
+			{0, color.RGBA{40, 80, 100, 255}},
+			{1, color.RGBA{5, 20, 45, 255}},
+		},
+		warmSea: []stop{
+			{0, color.RGBA{40, 140, 150, 255}},
+			{1, color.RGBA{5, 30, 60, 255}},
+		},
+		hillshade: 1,
+		ink:       color.RGBA{255, 255, 255, 255},
+		paper:     color.RGBA{0, 0, 0, 160},
+	}
+)
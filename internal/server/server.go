@@ -0,0 +1,385 @@
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/voidshard/genesis"
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/internal/geography"
+	"github.com/voidshard/genesis/internal/render"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+var (
+	// OpenAPI describes the HTTP API
+	//go:embed openapi.yaml
+	OpenAPI []byte
+
+	// errBadRequest is returned for requests we can't understand
+	errBadRequest = fmt.Errorf("bad request")
+
+	// errNoRoute is returned when no handler exists for a path / method
+	errNoRoute = fmt.Errorf("%w no such route", genesis.ErrNotFound)
+)
+
+// Server exposes a GenesisEditor over HTTP, with JSON requests & responses.
+//
+// Geography steps can take a long time, so they're run as jobs in the background;
+// POSTing a step returns a job that can be polled (or watched) for it's progress.
+type Server struct {
+	gen genesis.GenesisEditor
+
+	// busy is held whilst reading / writing canvases (the editor isn't safe to
+	// use concurrently)
+	busy *sync.Mutex
+}
+
+// New returns a server for the given editor
+func New(gen genesis.GenesisEditor) *Server {
+	return &Server{gen: gen, busy: &sync.Mutex{}}
+}
+
+// ListenAndServe serves the API on the given address (eg. ":8080")
+func (s *Server) ListenAndServe(addr string) error {
+	log.Println("serving on", addr)
+	return http.ListenAndServe(addr, s)
+}
+
+// ServeHTTP routes requests to handlers
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "openapi.yaml" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(OpenAPI)
+	case len(parts) == 1 && parts[0] == "projects" && r.Method == http.MethodGet:
+		s.listProjects(w, r)
+	case len(parts) == 1 && parts[0] == "projects" && r.Method == http.MethodPost:
+		s.createProject(w, r)
+	case len(parts) == 2 && parts[0] == "projects" && r.Method == http.MethodGet:
+		s.getProject(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "projects" && r.Method == http.MethodDelete:
+		s.deleteProject(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "projects" && r.Method == http.MethodPost:
+		s.startStep(w, r, parts[1], parts[2])
+	case len(parts) == 3 && parts[0] == "projects" && parts[2] == "operations" && r.Method == http.MethodGet:
+		s.listOperations(w, r, parts[1])
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "layers" && r.Method == http.MethodGet:
+		s.getLayer(w, r, parts[1], strings.TrimSuffix(parts[3], ".png"))
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "render" && r.Method == http.MethodGet:
+		s.getRender(w, r, parts[1], strings.TrimSuffix(parts[3], ".png"))
+	case len(parts) == 7 && parts[0] == "tiles" && r.Method == http.MethodGet:
+		s.getTile(w, r, parts[1:])
+	case len(parts) == 1 && parts[0] == "jobs" && r.Method == http.MethodGet:
+		s.listJobs(w, r)
+	case len(parts) == 2 && parts[0] == "jobs" && r.Method == http.MethodGet:
+		s.getJob(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "cancel" && r.Method == http.MethodPost:
+		s.cancelJob(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "events" && r.Method == http.MethodGet:
+		s.watchJob(w, r, parts[1])
+	default:
+		writeError(w, fmt.Errorf("%w %s %s", errNoRoute, r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	found, tkn, err := s.gen.ListProjects(r.URL.Query().Get("token"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"projects": found, "token": tkn})
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	p := types.NewProject()
+	err := json.NewDecoder(r.Body).Decode(p)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w %v", errBadRequest, err))
+		return
+	}
+	if p.Name == "" {
+		writeError(w, fmt.Errorf("%w project name is required", errBadRequest))
+		return
+	}
+
+	err = s.gen.CreateProject(p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (s *Server) getProject(w http.ResponseWriter, r *http.Request, key string) {
+	p, err := s.gen.Project(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) deleteProject(w http.ResponseWriter, r *http.Request, key string) {
+	err := s.gen.DeleteProject(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) startStep(w http.ResponseWriter, r *http.Request, key, name string) {
+	step, ok := steps[name]
+	if !ok {
+		writeError(w, fmt.Errorf("%w step %s", errNoRoute, name))
+		return
+	}
+
+	p, err := s.gen.Project(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	run, err := step(s.gen, p, r)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w %v", errBadRequest, err))
+		return
+	}
+
+	j, err := s.gen.SubmitJob(p.ID, name, func(ctx context.Context) (interface{}, error) {
+		s.busy.Lock()
+		defer s.busy.Unlock()
+		if ctx.Err() != nil { // cancelled while waiting
+			return nil, ctx.Err()
+		}
+		return run(ctx)
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+func (s *Server) listOperations(w http.ResponseWriter, r *http.Request, key string) {
+	found, tkn, err := s.gen.ListOperations(key, r.URL.Query().Get("token"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"operations": found, "token": tkn})
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	found, tkn, err := s.gen.ListJobs(r.URL.Query().Get("project"), r.URL.Query().Get("token"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": found, "token": tkn})
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request, id string) {
+	j, err := s.gen.Job(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request, id string) {
+	j, err := s.gen.CancelJob(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// watchJob streams a job as server sent events (one "job" event per change) until
+// the job finishes or the client goes away
+func (s *Server) watchJob(w http.ResponseWriter, r *http.Request, id string) {
+	updates, done, err := s.gen.WatchJob(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer done()
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case j, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(j)
+			if err != nil {
+				log.Println("failed to marshal job", err)
+				return
+			}
+			fmt.Fprintf(w, "event: job\ndata: %s\n\n", data)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func (s *Server) getLayer(w http.ResponseWriter, r *http.Request, key, layer string) {
+	p, err := s.gen.Project(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	area, err := queryArea(r, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.busy.Lock()
+	im, err := s.gen.Layer(p.ID, types.Layer(layer), area)
+	s.busy.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writePNG(w, im)
+}
+
+func (s *Server) getRender(w http.ResponseWriter, r *http.Request, key, style string) {
+	p, err := s.gen.Project(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	area, err := queryArea(r, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.busy.Lock()
+	im, err := s.gen.Render(p.ID, style, area)
+	s.busy.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writePNG(w, im)
+}
+
+// getTile serves /tiles/{project}/{epoch}/{layer}/{z}/{x}/{y}.png
+func (s *Server) getTile(w http.ResponseWriter, r *http.Request, parts []string) {
+	parts[5] = strings.TrimSuffix(parts[5], ".png")
+
+	nums := []int{}
+	for _, i := range []int{1, 3, 4, 5} {
+		v, err := strconv.Atoi(parts[i])
+		if err != nil {
+			writeError(w, fmt.Errorf("%w tile path: %v", errBadRequest, err))
+			return
+		}
+		nums = append(nums, v)
+	}
+
+	s.busy.Lock()
+	data, err := s.gen.Tile(parts[0], nums[0], parts[2], nums[1], nums[2], nums[3])
+	s.busy.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
+
+// queryArea reads x, y, w, h query params (defaulting to the whole world)
+func queryArea(r *http.Request, p *types.Project) (image.Rectangle, error) {
+	values := map[string]int{"x": 0, "y": 0, "w": p.WorldWidth, "h": p.WorldHeight}
+	q := r.URL.Query()
+	for k := range values {
+		v := q.Get(k)
+		if v == "" {
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("%w query param %s: %v", errBadRequest, k, err)
+		}
+		values[k] = i
+	}
+	if values["w"] <= 0 || values["h"] <= 0 {
+		return image.Rectangle{}, fmt.Errorf("%w w and h must be positive", errBadRequest)
+	}
+	return image.Rect(values["x"], values["y"], values["x"]+values["w"], values["y"]+values["h"]), nil
+}
+
+// statusFor returns the http status code for an error
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, genesis.ErrNotFound), errors.Is(err, geography.ErrUnknownEpoch),
+		errors.Is(err, geography.ErrUnknownSeason):
+		return http.StatusNotFound
+	case errors.Is(err, genesis.ErrJobFinished), errors.Is(err, genesis.ErrExists):
+		return http.StatusConflict
+	case errors.Is(err, genesis.ErrNoPath):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errBadRequest),
+		errors.Is(err, dbutils.ErrInvalidToken),
+		errors.Is(err, render.ErrUnknownStyle),
+		errors.Is(err, geography.ErrUnknownLayer),
+		errors.Is(err, geography.ErrTooFewSeasons):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := statusFor(err)
+	if code == http.StatusInternalServerError {
+		log.Println("internal error", err)
+	}
+	writeJSON(w, code, map[string]interface{}{"error": err.Error(), "status": code})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println("failed to write response", err)
+	}
+}
+
+func writePNG(w http.ResponseWriter, im image.Image) {
+	w.Header().Set("Content-Type", "image/png")
+	err := png.Encode(w, im)
+	if err != nil {
+		log.Println("failed to write png", err)
+	}
+}
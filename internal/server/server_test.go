@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis"
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/internal/geography"
+	"github.com/voidshard/genesis/internal/render"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func testServer(t *testing.T) *Server {
+	gen, err := genesis.New(&genesis.Options{Root: t.TempDir(), InMemory: true})
+	assert.Nil(t, err)
+	t.Cleanup(func() { gen.Close() })
+	return New(gen)
+}
+
+// do sends a request to the server & returns the response code & body
+func do(s *Server, method, path string, body interface{}) (int, []byte) {
+	var in bytes.Buffer
+	switch v := body.(type) {
+	case nil:
+	case string:
+		in.WriteString(v)
+	default:
+		json.NewEncoder(&in).Encode(v)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, path, &in))
+	return w.Code, w.Body.Bytes()
+}
+
+// waitJob waits for a job to finish
+func waitJob(t *testing.T, s *Server, id string) *types.Job {
+	timeout := time.After(10 * time.Second)
+	for {
+		j, err := s.gen.Job(id)
+		assert.Nil(t, err)
+		if j.Done() {
+			return j
+		}
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for job", id)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestProjectRoutes(t *testing.T) {
+	s := testServer(t)
+
+	code, body := do(s, http.MethodPost, "/projects", &types.Project{Name: "world"})
+	assert.Equal(t, http.StatusCreated, code, string(body))
+	p := &types.Project{}
+	assert.Nil(t, json.Unmarshal(body, p))
+	assert.Equal(t, dbutils.NewID("world"), p.ID)
+
+	for _, tt := range []struct {
+		Name   string
+		Method string
+		Path   string
+		Body   interface{}
+		Want   int
+	}{
+		{"openapi", http.MethodGet, "/openapi.yaml", nil, http.StatusOK},
+		{"list", http.MethodGet, "/projects", nil, http.StatusOK},
+		{"list bad token", http.MethodGet, "/projects?token=nope", nil, http.StatusBadRequest},
+		{"create exists", http.MethodPost, "/projects", &types.Project{Name: "world"}, http.StatusConflict},
+		{"create no name", http.MethodPost, "/projects", &types.Project{}, http.StatusBadRequest},
+		{"create bad json", http.MethodPost, "/projects", "{", http.StatusBadRequest},
+		{"get by id", http.MethodGet, "/projects/" + p.ID, nil, http.StatusOK},
+		{"get by name", http.MethodGet, "/projects/world", nil, http.StatusOK},
+		{"get missing", http.MethodGet, "/projects/nope", nil, http.StatusNotFound},
+		{"operations", http.MethodGet, "/projects/world/operations", nil, http.StatusOK},
+		{"step unknown", http.MethodPost, "/projects/world/nope", nil, http.StatusNotFound},
+		{"step bad body", http.MethodPost, "/projects/world/smooth", "{", http.StatusBadRequest},
+		{"step missing project", http.MethodPost, "/projects/nope/smooth", nil, http.StatusNotFound},
+		{"layer unknown", http.MethodGet, "/projects/world/layers/nope.png", nil, http.StatusBadRequest},
+		{"layer bad area", http.MethodGet, "/projects/world/layers/height.png?w=0", nil, http.StatusBadRequest},
+		{"layer bad query", http.MethodGet, "/projects/world/layers/height.png?x=a", nil, http.StatusBadRequest},
+		{"render unknown", http.MethodGet, "/projects/world/render/nope.png?w=10&h=10", nil, http.StatusBadRequest},
+		{"tile bad path", http.MethodGet, "/tiles/world/a/height/0/0/0.png", nil, http.StatusBadRequest},
+		{"tile missing project", http.MethodGet, "/tiles/nope/0/height/0/0/0.png", nil, http.StatusNotFound},
+		{"no route", http.MethodPut, "/projects", nil, http.StatusNotFound},
+		{"delete missing", http.MethodDelete, "/projects/nope", nil, http.StatusNotFound},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			code, body := do(s, tt.Method, tt.Path, tt.Body)
+			assert.Equal(t, tt.Want, code, string(body))
+
+			if code >= http.StatusBadRequest {
+				out := map[string]interface{}{}
+				assert.Nil(t, json.Unmarshal(body, &out))
+				assert.Equal(t, float64(code), out["status"])
+			}
+		})
+	}
+
+	code, body = do(s, http.MethodDelete, "/projects/world", nil)
+	assert.Equal(t, http.StatusNoContent, code, string(body))
+
+	code, _ = do(s, http.MethodGet, "/projects/world", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestLayerRoute(t *testing.T) {
+	s := testServer(t)
+
+	code, body := do(s, http.MethodPost, "/projects", &types.Project{Name: "world"})
+	assert.Equal(t, http.StatusCreated, code, string(body))
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/world/layers/height.png?x=1&y=2&w=3&h=4", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+}
+
+func TestJobRoutes(t *testing.T) {
+	s := testServer(t)
+
+	code, body := do(s, http.MethodPost, "/projects", &types.Project{Name: "world"})
+	assert.Equal(t, http.StatusCreated, code, string(body))
+
+	code, body = do(s, http.MethodPost, "/projects/world/smooth", map[string]interface{}{"radius": 1})
+	assert.Equal(t, http.StatusAccepted, code, string(body))
+	j := &types.Job{}
+	assert.Nil(t, json.Unmarshal(body, j))
+	assert.Equal(t, "smooth", j.Kind)
+
+	waitJob(t, s, j.ID)
+
+	for _, tt := range []struct {
+		Name   string
+		Method string
+		Path   string
+		Want   int
+	}{
+		{"list", http.MethodGet, "/jobs", http.StatusOK},
+		{"list by project", http.MethodGet, "/jobs?project=world", http.StatusOK},
+		{"list missing project", http.MethodGet, "/jobs?project=nope", http.StatusNotFound},
+		{"get", http.MethodGet, "/jobs/" + j.ID, http.StatusOK},
+		{"get missing", http.MethodGet, "/jobs/" + dbutils.NewID("nope"), http.StatusNotFound},
+		{"cancel finished", http.MethodPost, "/jobs/" + j.ID + "/cancel", http.StatusConflict},
+		{"events", http.MethodGet, "/jobs/" + j.ID + "/events", http.StatusOK},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			code, body := do(s, tt.Method, tt.Path, nil)
+			assert.Equal(t, tt.Want, code, string(body))
+		})
+	}
+}
+
+func TestStatusFor(t *testing.T) {
+	for _, tt := range []struct {
+		Err  error
+		Want int
+	}{
+		{genesis.ErrNotFound, http.StatusNotFound},
+		{geography.ErrUnknownEpoch, http.StatusNotFound},
+		{geography.ErrUnknownSeason, http.StatusNotFound},
+		{errNoRoute, http.StatusNotFound},
+		{genesis.ErrJobFinished, http.StatusConflict},
+		{genesis.ErrExists, http.StatusConflict},
+		{genesis.ErrNoPath, http.StatusUnprocessableEntity},
+		{errBadRequest, http.StatusBadRequest},
+		{dbutils.ErrInvalidToken, http.StatusBadRequest},
+		{render.ErrUnknownStyle, http.StatusBadRequest},
+		{geography.ErrUnknownLayer, http.StatusBadRequest},
+		{geography.ErrTooFewSeasons, http.StatusBadRequest},
+		{errors.New("boom"), http.StatusInternalServerError},
+	} {
+		t.Run(tt.Err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.Want, statusFor(tt.Err))
+			assert.Equal(t, tt.Want, statusFor(fmt.Errorf("%w: wrapped", tt.Err)))
+		})
+	}
+}
@@ -0,0 +1,474 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+
+	"github.com/voidshard/genesis"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// stepFunc reads a request body & returns the work to run for it
+type stepFunc func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error)
+
+// steps are the geography operations that can be run via
+// POST /projects/{project}/{step}
+var steps = map[string]stepFunc{
+	"tectonics": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &tectonicsRequest{Noise: 0.3, Points: 100}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.CreateTectonics(ctx, p.ID, in.Noise, in.Points)
+		}, err
+	},
+	"import-heightmap": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &importRequest{Points: 100}
+		im, err := in.decode(r)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.ImportHeightmap(ctx, p.ID, im, &types.ImportOptions{Points: in.Points, Stretch: in.Stretch})
+		}, err
+	},
+	"import-sketch": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &importRequest{Points: 100}
+		im, err := in.decode(r)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.ImportSketch(ctx, p.ID, im, &types.ImportOptions{Points: in.Points})
+		}, err
+	},
+	"mountains": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &mountainsRequest{Scale: 1}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			peaks, ridges, err := gen.AddMountainRange(ctx, p.ID, in.Tag, in.Path.spec(), in.Scale)
+			return map[string]interface{}{"peaks": toPoints(peaks), "ridges": toPoints(ridges)}, err
+		}, err
+	},
+	"volcanoes": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &volcanoesRequest{Count: 3}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			volcanoes, path, err := gen.AddVolanoes(ctx, p.ID, in.Count, in.Path.spec())
+			return map[string]interface{}{"volcanoes": toPoints(volcanoes), "path": toPoints(path)}, err
+		}, err
+	},
+	"ravines": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &ravineRequest{}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			tree, err := gen.AddRavine(ctx, p.ID, in.Tag, in.Path.spec(), in.ForkChance)
+			if err != nil {
+				return nil, err
+			}
+			return toPathTree(tree), nil
+		}, err
+	},
+	"archipelago": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &archipelagoRequest{Islands: 5}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			islands, err := gen.AddArchipelago(ctx, p.ID, in.Tag, in.Path.spec(), in.Islands)
+			return map[string]interface{}{"islands": toPoints(islands)}, err
+		}, err
+	},
+	"bay": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &bayRequest{Radius: 50}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.AddBay(ctx, p.ID, image.Pt(in.X, in.Y), in.Radius)
+		}, err
+	},
+	"fjords": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &fjordsRequest{Depth: 60}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			fjords, err := gen.CarveFjords(ctx, p.ID, in.Landmass, in.Depth)
+			out := make([][]point, len(fjords))
+			for i, f := range fjords {
+				out[i] = toPoints(f)
+			}
+			return map[string]interface{}{"fjords": out}, err
+		}, err
+	},
+	"raise": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &editRequest{Amount: 10}
+		b, err := in.decode(r)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.RaiseArea(ctx, p.ID, b, in.Amount)
+		}, err
+	},
+	"lower": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &editRequest{Amount: 10}
+		b, err := in.decode(r)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.LowerArea(ctx, p.ID, b, in.Amount)
+		}, err
+	},
+	"flatten-to": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &editRequest{}
+		b, err := in.decode(r)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.FlattenTo(ctx, p.ID, b, in.Height)
+		}, err
+	},
+	"smooth-area": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &editRequest{Radius: 3}
+		b, err := in.decode(r)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.SmoothArea(ctx, p.ID, b, in.Radius)
+		}, err
+	},
+	"noise": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &editRequest{Amount: 10, Scale: 0.3}
+		b, err := in.decode(r)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.PaintNoise(ctx, p.ID, b, in.Scale, in.Amount)
+		}, err
+	},
+	"undo": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.UndoEdit(ctx, p.ID)
+		}, nil
+	},
+	"replay": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &replayRequest{}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.Replay(ctx, p.ID, in.UpTo)
+		}, err
+	},
+	"smooth": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &smoothRequest{Radius: 3}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.SmoothTerrain(ctx, p.ID, in.Radius)
+		}, err
+	},
+	"flatten": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &flattenRequest{}
+		err := decode(r, in)
+		if err == nil && (in.Width <= 0 || in.Height <= 0) {
+			err = fmt.Errorf("width and height must be positive")
+		}
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.FlattenOutside(ctx, p.ID, image.Rect(in.X, in.Y, in.X+in.Width, in.Y+in.Height))
+		}, err
+	},
+	"sea": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &seaRequest{SeaLevel: 100, EquatorWidth: 100, ArcticWidth: 100, Currents: 6}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			_, land, err := gen.SeaMap(ctx, p.ID, in.SeaLevel, in.EquatorWidth, in.ArcticWidth, in.Currents)
+			return map[string]interface{}{"landmasses": land}, err
+		}, err
+	},
+	"bathymetry": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			_, err := gen.Bathymetry(ctx, p.ID)
+			return nil, err
+		}, nil
+	},
+	"wind": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			_, err := gen.Wind(ctx, p.ID)
+			return nil, err
+		}, nil
+	},
+	"rain": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &rainRequest{StormMult: 1}
+		err := decode(r, in)
+		if err != nil {
+			return nil, err
+		}
+		winds := []types.Heading{}
+		for _, w := range in.Winds {
+			h, err := types.ToHeadingStr(w)
+			if err != nil {
+				return nil, err
+			}
+			winds = append(winds, h)
+		}
+		return func(ctx context.Context) (interface{}, error) {
+			_, err := gen.Rain(ctx, p.ID, in.StormMult, winds)
+			return nil, err
+		}, nil
+	},
+	"seasons": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &seasonsRequest{Seasons: 4, StormMult: 1}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			_, err := gen.Seasons(ctx, p.ID, in.Seasons, in.StormMult)
+			return nil, err
+		}, err
+	},
+	"cryosphere": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			_, err := gen.Cryosphere(ctx, p.ID)
+			return nil, err
+		}, nil
+	},
+	"rivers": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &riversRequest{Threshold: 100}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			_, err := gen.Rivers(ctx, p.ID, in.Threshold)
+			return nil, err
+		}, err
+	},
+	"epoch": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			return nil, gen.NextEpoch(ctx, p.ID)
+		}, nil
+	},
+	"rescale": func(gen genesis.GenesisEditor, p *types.Project, r *http.Request) (genesis.JobFunc, error) {
+		in := &rescaleRequest{Width: p.WorldWidth * 2, Height: p.WorldHeight * 2}
+		err := decode(r, in)
+		return func(ctx context.Context) (interface{}, error) {
+			return gen.Rescale(ctx, p.ID, in.Width, in.Height)
+		}, err
+	},
+}
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func toPoints(in []image.Point) []point {
+	out := make([]point, len(in))
+	for i, p := range in {
+		out[i] = point{X: p.X, Y: p.Y}
+	}
+	return out
+}
+
+// pathTree is types.PathTree for JSON
+type pathTree struct {
+	Tag      string      `json:"tag"`
+	Path     []point     `json:"path"`
+	Children []*pathTree `json:"children"`
+}
+
+func toPathTree(in *types.PathTree) *pathTree {
+	out := &pathTree{Tag: in.Tag, Path: toPoints(in.Path), Children: []*pathTree{}}
+	for _, c := range in.Children {
+		out.Children = append(out.Children, toPathTree(c))
+	}
+	return out
+}
+
+// pathSpec is types.PathSpec for JSON
+type pathSpec struct {
+	From    *point             `json:"from"`
+	To      *point             `json:"to"`
+	Via     []point            `json:"via"`
+	Avoid   []rect             `json:"avoid"`
+	Weights map[string]float64 `json:"weights"`
+	MaxDist float64            `json:"max_dist"`
+}
+
+// rect is image.Rectangle for JSON
+type rect struct {
+	Min point `json:"min"`
+	Max point `json:"max"`
+}
+
+func (p *pathSpec) spec() *types.PathSpec {
+	if p == nil {
+		return nil
+	}
+	s := &types.PathSpec{MaxDist: p.MaxDist, Weights: p.Weights}
+	if p.From != nil {
+		s.From = &image.Point{X: p.From.X, Y: p.From.Y}
+	}
+	if p.To != nil {
+		s.To = &image.Point{X: p.To.X, Y: p.To.Y}
+	}
+	for _, v := range p.Via {
+		s.Via = append(s.Via, image.Pt(v.X, v.Y))
+	}
+	for _, a := range p.Avoid {
+		s.Avoid = append(s.Avoid, image.Rect(a.Min.X, a.Min.Y, a.Max.X, a.Max.Y))
+	}
+	return s
+}
+
+type tectonicsRequest struct {
+	Noise  float64 `json:"noise"`
+	Points int     `json:"points"`
+}
+
+// importRequest carries a PNG (base64 in JSON) to import
+type importRequest struct {
+	Points  int    `json:"points"`
+	Stretch bool   `json:"stretch"`
+	PNG     []byte `json:"png"`
+}
+
+// decode reads the request & returns it's image
+func (i *importRequest) decode(r *http.Request) (image.Image, error) {
+	err := decode(r, i)
+	if err != nil {
+		return nil, err
+	}
+	im, err := png.Decode(bytes.NewReader(i.PNG))
+	if err != nil {
+		return nil, fmt.Errorf("%w png %v", errBadRequest, err)
+	}
+	return im, nil
+}
+
+type mountainsRequest struct {
+	Tag   string    `json:"tag"`
+	Path  *pathSpec `json:"path"`
+	Scale float64   `json:"scale"`
+}
+
+type volcanoesRequest struct {
+	Count int       `json:"count"`
+	Path  *pathSpec `json:"path"`
+}
+
+type ravineRequest struct {
+	Tag        string    `json:"tag"`
+	Path       *pathSpec `json:"path"`
+	ForkChance float64   `json:"fork_chance"`
+}
+
+type archipelagoRequest struct {
+	Tag     string    `json:"tag"`
+	Path    *pathSpec `json:"path"`
+	Islands int       `json:"islands"`
+}
+
+type bayRequest struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Radius int `json:"radius"`
+}
+
+type fjordsRequest struct {
+	Landmass string `json:"landmass"`
+	Depth    int    `json:"depth"`
+}
+
+// brushSpec is types.Brush for JSON, a mask is a PNG (base64 in JSON) placed at x,y
+type brushSpec struct {
+	Polygon []point `json:"polygon"`
+	Centre  point   `json:"centre"`
+	Radius  int     `json:"radius"`
+	Mask    *struct {
+		X   int    `json:"x"`
+		Y   int    `json:"y"`
+		PNG []byte `json:"png"`
+	} `json:"mask"`
+	Falloff int `json:"falloff"`
+}
+
+func (b *brushSpec) brush() (*types.Brush, error) {
+	out := &types.Brush{
+		Centre:  image.Pt(b.Centre.X, b.Centre.Y),
+		Radius:  b.Radius,
+		Falloff: b.Falloff,
+	}
+	for _, p := range b.Polygon {
+		out.Polygon = append(out.Polygon, image.Pt(p.X, p.Y))
+	}
+	if b.Mask != nil {
+		im, err := png.Decode(bytes.NewReader(b.Mask.PNG))
+		if err != nil {
+			return nil, fmt.Errorf("%w mask %v", errBadRequest, err)
+		}
+		out.Mask = &offsetImage{Image: im, at: image.Pt(b.Mask.X, b.Mask.Y)}
+	}
+	return out, nil
+}
+
+// offsetImage moves an image so it's top left corner is at `at`
+type offsetImage struct {
+	image.Image
+	at image.Point
+}
+
+func (o *offsetImage) Bounds() image.Rectangle {
+	b := o.Image.Bounds()
+	return b.Sub(b.Min).Add(o.at)
+}
+
+func (o *offsetImage) At(x, y int) color.Color {
+	b := o.Image.Bounds()
+	return o.Image.At(x-o.at.X+b.Min.X, y-o.at.Y+b.Min.Y)
+}
+
+type editRequest struct {
+	Brush  brushSpec `json:"brush"`
+	Amount uint8     `json:"amount"`
+	Height uint8     `json:"height"`
+	Radius uint32    `json:"radius"`
+	Scale  float64   `json:"scale"`
+}
+
+// decode reads the request & returns it's brush
+func (e *editRequest) decode(r *http.Request) (*types.Brush, error) {
+	err := decode(r, e)
+	if err != nil {
+		return nil, err
+	}
+	return e.Brush.brush()
+}
+
+type replayRequest struct {
+	UpTo int `json:"up_to"`
+}
+
+type rescaleRequest struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type smoothRequest struct {
+	Radius uint32 `json:"radius"`
+}
+
+type flattenRequest struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type seaRequest struct {
+	SeaLevel     uint8 `json:"sea_level"`
+	EquatorWidth int   `json:"equator_width"`
+	ArcticWidth  int   `json:"arctic_width"`
+	Currents     int   `json:"currents"`
+}
+
+type rainRequest struct {
+	StormMult float64  `json:"storm_mult"`
+	Winds     []string `json:"winds"`
+}
+
+type seasonsRequest struct {
+	Seasons   int     `json:"seasons"`
+	StormMult float64 `json:"storm_mult"`
+}
+
+type riversRequest struct {
+	Threshold int `json:"threshold"`
+}
+
+// decode reads a JSON body into `v` (an empty body leaves the defaults as is)
+func decode(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
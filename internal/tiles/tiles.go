@@ -0,0 +1,241 @@
+package tiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/voidshard/genesis/internal/blob"
+)
+
+const (
+	// Size of a tile in pixels (width & height)
+	Size = 256
+)
+
+var (
+	// ErrOutOfRange is returned for a tile outside of the world (or an invalid zoom)
+	ErrOutOfRange = fmt.Errorf("tile out of range")
+)
+
+// Key identifies a tile set
+type Key struct {
+	Project string
+	Epoch   int
+	Layer   string
+}
+
+// Source returns `area` of a layer at full resolution.
+//
+// The result may be a *image.Gray16 (in which case tiles are 16 bit greyscale)
+// otherwise tiles are RGBA. Image bounds may either match `area` or start at 0,0.
+type Source func(area image.Rectangle) (image.Image, error)
+
+// Cache builds XYZ (slippy map) tiles & keeps them in some storage.
+//
+// Zoom levels run from 0 (the whole world in one tile) to MaxZoom, where one tile pixel
+// is one world pixel. Only max zoom tiles are cut from the Source, lower zooms are built
+// from the four tiles below them, so a whole world never needs to be in memory at once.
+type Cache struct {
+	store blob.Blob
+	lock  sync.Mutex
+}
+
+// New returns a tile cache that writes to `store`
+func New(store blob.Blob) *Cache {
+	return &Cache{store: store}
+}
+
+// MaxZoom is the zoom level at which tile pixels are world pixels
+func MaxZoom(world image.Rectangle) int {
+	z := 0
+	for Size<<z < world.Dx() || Size<<z < world.Dy() {
+		z++
+	}
+	return z
+}
+
+// Bounds returns the area of the world covered by tile z/x/y (which may extend beyond
+// the edge of the world) or ErrOutOfRange
+func Bounds(world image.Rectangle, z, x, y int) (image.Rectangle, error) {
+	maxZ := MaxZoom(world)
+	if z < 0 || z > maxZ || x < 0 || y < 0 {
+		return image.Rectangle{}, fmt.Errorf("%w %d/%d/%d", ErrOutOfRange, z, x, y)
+	}
+	span := Size << (maxZ - z)
+	area := image.Rect(x*span, y*span, (x+1)*span, (y+1)*span).Add(world.Min)
+	if !area.Overlaps(world) {
+		return image.Rectangle{}, fmt.Errorf("%w %d/%d/%d", ErrOutOfRange, z, x, y)
+	}
+	return area, nil
+}
+
+// Tile returns tile z/x/y as a PNG, building it (and any tiles below it) if it's not cached.
+func (c *Cache) Tile(key Key, world image.Rectangle, z, x, y int, src Source) ([]byte, error) {
+	_, err := Bounds(world, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	data, err := c.store.Get(c.keyFor(key, z, x, y))
+	if err == nil {
+		return data, nil
+	}
+
+	im, err := c.build(key, world, z, x, y, src)
+	if err != nil {
+		return nil, err
+	}
+	return encode(im)
+}
+
+// Invalidate removes all cached tiles for a project epoch
+func (c *Cache) Invalidate(project string, epoch int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.store.Delete(project + "/" + strconv.Itoa(epoch))
+}
+
+// InvalidateProject removes all cached tiles for a project (all epochs)
+func (c *Cache) InvalidateProject(project string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.store.Delete(project)
+}
+
+// build returns tile z/x/y, from storage if we have it, otherwise made & stored.
+// Tiles outside of the world are returned as nil.
+func (c *Cache) build(key Key, world image.Rectangle, z, x, y int, src Source) (image.Image, error) {
+	area, err := Bounds(world, z, x, y)
+	if errors.Is(err, ErrOutOfRange) {
+		return nil, nil
+	}
+
+	path := c.keyFor(key, z, x, y)
+	data, err := c.store.Get(path)
+	if err == nil {
+		return png.Decode(bytes.NewReader(data))
+	}
+
+	var im image.Image
+	if z == MaxZoom(world) {
+		cut, err := src(area.Intersect(world))
+		if err != nil {
+			return nil, err
+		}
+		im = place(cut, area.Intersect(world).Sub(area.Min))
+	} else {
+		children := [4]image.Image{}
+		for i := range children {
+			children[i], err = c.build(key, world, z+1, x*2+i%2, y*2+i/2, src)
+			if err != nil {
+				return nil, err
+			}
+		}
+		im = downsample(children)
+	}
+
+	data, err = encode(im)
+	if err != nil {
+		return nil, err
+	}
+	return im, c.store.Put(path, data)
+}
+
+func (c *Cache) keyFor(key Key, z, x, y int) string {
+	return strings.Join([]string{
+		key.Project,
+		strconv.Itoa(key.Epoch),
+		key.Layer,
+		strconv.Itoa(z),
+		strconv.Itoa(x),
+		fmt.Sprintf("%d.png", y),
+	}, "/")
+}
+
+// place draws `in` at `at` (relative to the tile) on a new blank tile
+func place(in image.Image, at image.Rectangle) image.Image {
+	tile := image.Rect(0, 0, Size, Size)
+
+	gray, ok := in.(*image.Gray16)
+	if ok {
+		out := image.NewGray16(tile)
+		b := gray.Bounds()
+		for y := 0; y < at.Dy() && y < b.Dy(); y++ {
+			for x := 0; x < at.Dx() && x < b.Dx(); x++ {
+				out.SetGray16(at.Min.X+x, at.Min.Y+y, gray.Gray16At(b.Min.X+x, b.Min.Y+y))
+			}
+		}
+		return out
+	}
+
+	out := image.NewRGBA(tile)
+	draw.Draw(out, at, in, in.Bounds().Min, draw.Src)
+	return out
+}
+
+// downsample shrinks four tiles (top left, top right, bottom left, bottom right, any
+// of which may be nil) into one tile by averaging each 2x2 block of pixels
+func downsample(children [4]image.Image) image.Image {
+	tile := image.Rect(0, 0, Size, Size)
+	half := Size / 2
+
+	var gray *image.Gray16
+	var rgba *image.RGBA
+	for _, ch := range children {
+		if ch == nil {
+			continue
+		}
+		if _, ok := ch.(*image.Gray16); ok {
+			gray = image.NewGray16(tile)
+		} else {
+			rgba = image.NewRGBA(tile)
+		}
+		break
+	}
+	if gray == nil && rgba == nil {
+		return image.NewRGBA(tile)
+	}
+
+	for i, ch := range children {
+		if ch == nil {
+			continue
+		}
+		ox, oy := (i%2)*half, (i/2)*half
+		for y := 0; y < half; y++ {
+			for x := 0; x < half; x++ {
+				var r, g, b, a uint32
+				for _, d := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+					cr, cg, cb, ca := ch.At(x*2+d[0], y*2+d[1]).RGBA()
+					r, g, b, a = r+cr, g+cg, b+cb, a+ca
+				}
+				if gray != nil {
+					gray.SetGray16(ox+x, oy+y, color.Gray16{Y: uint16(r / 4)})
+				} else {
+					rgba.Set(ox+x, oy+y, color.RGBA64{uint16(r / 4), uint16(g / 4), uint16(b / 4), uint16(a / 4)})
+				}
+			}
+		}
+	}
+
+	if gray != nil {
+		return gray
+	}
+	return rgba
+}
+
+func encode(im image.Image) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, im)
+	return buf.Bytes(), err
+}
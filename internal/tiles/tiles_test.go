@@ -0,0 +1,75 @@
+package tiles
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/internal/blob"
+)
+
+func TestBounds(t *testing.T) {
+	world := image.Rect(0, 0, 1000, 600)
+
+	cases := []struct {
+		Z, X, Y int
+		Expect  image.Rectangle
+		Err     bool
+	}{
+		{0, 0, 0, image.Rect(0, 0, 1024, 1024), false},
+		{2, 3, 2, image.Rect(768, 512, 1024, 768), false},
+		{2, 0, 3, image.Rectangle{}, true},
+		{3, 0, 0, image.Rectangle{}, true},
+		{-1, 0, 0, image.Rectangle{}, true},
+	}
+
+	assert.Equal(t, 2, MaxZoom(world))
+	for _, tt := range cases {
+		result, err := Bounds(world, tt.Z, tt.X, tt.Y)
+		assert.Equal(t, tt.Err, err != nil)
+		assert.Equal(t, tt.Expect, result)
+	}
+}
+
+func TestTile(t *testing.T) {
+	world := image.Rect(0, 0, 300, 300)
+	cuts := 0
+	src := func(area image.Rectangle) (image.Image, error) {
+		cuts++
+		im := image.NewGray16(area)
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				im.SetGray16(x, y, color.Gray16{Y: 1000})
+			}
+		}
+		return im, nil
+	}
+	c := New(blob.NewMemory())
+	key := Key{Project: "p", Epoch: 1, Layer: "height"}
+
+	data, err := c.Tile(key, world, 0, 0, 0, src)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, cuts) // one per max zoom tile
+
+	im, err := png.Decode(bytes.NewReader(data))
+	assert.Nil(t, err)
+	gray, ok := im.(*image.Gray16)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(1000), gray.Gray16At(10, 10).Y)
+	assert.Equal(t, uint16(0), gray.Gray16At(200, 200).Y) // outside the world
+
+	// cached
+	_, err = c.Tile(key, world, 1, 1, 1, src)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, cuts)
+
+	// invalidated
+	assert.Nil(t, c.Invalidate("p", 1))
+	_, err = c.Tile(key, world, 1, 1, 1, src)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, cuts)
+}
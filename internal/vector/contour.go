@@ -0,0 +1,146 @@
+package vector
+
+import (
+	"image"
+	"sort"
+)
+
+// cell edges, where a contour may cross a marching squares cell
+const (
+	edgeTop = iota
+	edgeRight
+	edgeBottom
+	edgeLeft
+)
+
+// segments per marching squares case, where bits are set for corners that are inside;
+//
+//	top left = 8, top right = 4, bottom right = 2, bottom left = 1
+//
+// The saddles (5, 10) join the diagonal corners that are inside, which matches the
+// 8 way connectivity we use when flood filling landmasses.
+var cases = [16][][2]int{
+	{},
+	{{edgeLeft, edgeBottom}},
+	{{edgeBottom, edgeRight}},
+	{{edgeLeft, edgeRight}},
+	{{edgeTop, edgeRight}},
+	{{edgeLeft, edgeTop}, {edgeBottom, edgeRight}},
+	{{edgeTop, edgeBottom}},
+	{{edgeLeft, edgeTop}},
+	{{edgeLeft, edgeTop}},
+	{{edgeTop, edgeBottom}},
+	{{edgeTop, edgeRight}, {edgeLeft, edgeBottom}},
+	{{edgeTop, edgeRight}},
+	{{edgeLeft, edgeRight}},
+	{{edgeBottom, edgeRight}},
+	{{edgeLeft, edgeBottom}},
+	{},
+}
+
+// Contours runs marching squares over `area` and returns the closed rings that
+// separate pixels that are `inside` from those that are not, sorted by the area
+// they enclose (largest first).
+//
+// Pixels outside of `area` are considered outside, so all rings are closed. Ring
+// points run through pixel centres, with the first point repeated at the end.
+func Contours(area image.Rectangle, inside func(x, y int) bool) [][]Point {
+	in := func(x, y int) bool {
+		if !image.Pt(x, y).In(area) {
+			return false
+		}
+		return inside(x, y)
+	}
+
+	// we key points by twice their value so edge mid points are whole numbers
+	// (that is, a cell at x,y has corners at 2x,2y & 2x+2,2y+2)
+	midpoint := func(x, y, edge int) image.Point {
+		switch edge {
+		case edgeTop:
+			return image.Pt(2*x+1, 2*y)
+		case edgeRight:
+			return image.Pt(2*x+2, 2*y+1)
+		case edgeBottom:
+			return image.Pt(2*x+1, 2*y+2)
+		}
+		return image.Pt(2*x, 2*y+1)
+	}
+
+	segments := [][2]image.Point{}
+	joins := map[image.Point][]int{} // point -> segments that touch it
+
+	for y := area.Min.Y - 1; y < area.Max.Y; y++ {
+		for x := area.Min.X - 1; x < area.Max.X; x++ {
+			c := 0
+			if in(x, y) {
+				c |= 8
+			}
+			if in(x+1, y) {
+				c |= 4
+			}
+			if in(x+1, y+1) {
+				c |= 2
+			}
+			if in(x, y+1) {
+				c |= 1
+			}
+
+			for _, s := range cases[c] {
+				a, b := midpoint(x, y, s[0]), midpoint(x, y, s[1])
+				i := len(segments)
+				segments = append(segments, [2]image.Point{a, b})
+				joins[a] = append(joins[a], i)
+				joins[b] = append(joins[b], i)
+			}
+		}
+	}
+
+	// walk segments end to end until we return to the start
+	used := make([]bool, len(segments))
+	rings := [][]Point{}
+	for i := range segments {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+
+		start := segments[i][0]
+		next := segments[i][1]
+		ring := []image.Point{start}
+
+		for next != start {
+			ring = append(ring, next)
+
+			found := false
+			for _, j := range joins[next] {
+				if used[j] {
+					continue
+				}
+				used[j] = true
+				found = true
+				if segments[j][0] == next {
+					next = segments[j][1]
+				} else {
+					next = segments[j][0]
+				}
+				break
+			}
+			if !found { // shouldn't happen as everything outside area is outside
+				break
+			}
+		}
+		ring = append(ring, start)
+
+		out := make([]Point, len(ring))
+		for k, p := range ring {
+			out[k] = Pt(float64(p.X)/2+0.5, float64(p.Y)/2+0.5)
+		}
+		rings = append(rings, out)
+	}
+
+	sort.Slice(rings, func(i, j int) bool {
+		return ringArea(rings[i]) > ringArea(rings[j])
+	})
+
+	return rings
+}
@@ -0,0 +1,57 @@
+package vector
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContoursSquare(t *testing.T) {
+	square := image.Rect(2, 2, 6, 6)
+
+	rings := Contours(image.Rect(0, 0, 10, 10), func(x, y int) bool {
+		return image.Pt(x, y).In(square)
+	})
+
+	assert.Equal(t, 1, len(rings))
+	assert.Equal(t, rings[0][0], rings[0][len(rings[0])-1])
+	min, max := bounds(rings[0])
+	assert.Equal(t, Pt(2, 2), min)
+	assert.Equal(t, Pt(6, 6), max)
+}
+
+func TestContoursDiagonalJoined(t *testing.T) {
+	// diagonal neighbours are connected (8 way connectivity) so we expect one ring
+	rings := Contours(image.Rect(0, 0, 4, 4), func(x, y int) bool {
+		return (x == 1 && y == 1) || (x == 2 && y == 2)
+	})
+
+	assert.Equal(t, 1, len(rings))
+}
+
+func TestContoursTouchingEdge(t *testing.T) {
+	rings := Contours(image.Rect(0, 0, 4, 4), func(x, y int) bool {
+		return true
+	})
+
+	// marching squares cuts each corner (by half a pixel squared)
+	assert.Equal(t, 1, len(rings))
+	assert.Equal(t, 15.5, ringArea(rings[0]))
+}
+
+func TestSimplify(t *testing.T) {
+	line := []Point{Pt(0, 0), Pt(1, 0.1), Pt(2, -0.1), Pt(3, 0), Pt(3, 5)}
+
+	result := Simplify(line, 0.5)
+
+	assert.Equal(t, []Point{Pt(0, 0), Pt(3, 0), Pt(3, 5)}, result)
+}
+
+func TestSimplifyClosed(t *testing.T) {
+	ring := []Point{Pt(0, 0), Pt(2, 0), Pt(4, 0), Pt(4, 4), Pt(0, 4), Pt(0, 2), Pt(0, 0)}
+
+	result := Simplify(ring, 0.1)
+
+	assert.Equal(t, []Point{Pt(0, 0), Pt(4, 0), Pt(4, 4), Pt(0, 4), Pt(0, 0)}, result)
+}
@@ -0,0 +1,83 @@
+package vector
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// geoJSONCollection is a GeoJSON FeatureCollection (RFC 7946)
+type geoJSONCollection struct {
+	Type     string            `json:"type"`
+	Features []*geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// WriteGeoJSON writes features out as a GeoJSON FeatureCollection.
+//
+// Coordinates are world pixels (x right, y down) rather than longitude / latitude,
+// so viewers should use a flat (non geographic) coordinate system.
+func WriteGeoJSON(w io.Writer, features []*Feature) error {
+	out := &geoJSONCollection{Type: "FeatureCollection", Features: []*geoJSONFeature{}}
+
+	for _, f := range features {
+		geom := geometry(f)
+		if geom == nil {
+			continue
+		}
+
+		props := map[string]interface{}{}
+		for k, v := range f.Properties {
+			props[k] = v
+		}
+		props["kind"] = f.Kind
+		if f.Name != "" {
+			props["name"] = f.Name
+		}
+
+		out.Features = append(out.Features, &geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geom,
+			Properties: props,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(out)
+}
+
+// geometry returns the GeoJSON geometry for a feature (if it has one)
+func geometry(f *Feature) *geoJSONGeometry {
+	switch {
+	case len(f.Rings) > 0:
+		rings := make([][][2]float64, len(f.Rings))
+		for i, r := range f.Rings {
+			rings[i] = coords(r)
+		}
+		return &geoJSONGeometry{Type: "Polygon", Coordinates: rings}
+	case len(f.Line) > 1:
+		return &geoJSONGeometry{Type: "LineString", Coordinates: coords(f.Line)}
+	case len(f.Points) == 1:
+		return &geoJSONGeometry{Type: "Point", Coordinates: [2]float64{f.Points[0].X, f.Points[0].Y}}
+	case len(f.Points) > 1:
+		return &geoJSONGeometry{Type: "MultiPoint", Coordinates: coords(f.Points)}
+	}
+	return nil
+}
+
+func coords(in []Point) [][2]float64 {
+	out := make([][2]float64, len(in))
+	for i, p := range in {
+		out[i] = [2]float64{p.X, p.Y}
+	}
+	return out
+}
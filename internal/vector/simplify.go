@@ -0,0 +1,70 @@
+package vector
+
+import (
+	"math"
+)
+
+// Simplify reduces the number of points in a line using Douglas-Peucker.
+// Points closer than `tolerance` to the simplified line are dropped.
+//
+// If the line is closed (first point == last point) it stays closed.
+func Simplify(line []Point, tolerance float64) []Point {
+	if tolerance <= 0 || len(line) < 3 {
+		return line
+	}
+
+	if line[0] == line[len(line)-1] {
+		// a closed ring has no natural end points, so split it at the point
+		// furthest from the start & simplify each half
+		far, dist := 0, -1.0
+		for i, p := range line {
+			d := math.Hypot(p.X-line[0].X, p.Y-line[0].Y)
+			if d > dist {
+				far, dist = i, d
+			}
+		}
+		if far == 0 || far == len(line)-1 {
+			return line
+		}
+		a := douglasPeucker(line[:far+1], tolerance)
+		b := douglasPeucker(line[far:], tolerance)
+		return append(a[:len(a)-1], b...)
+	}
+
+	return douglasPeucker(line, tolerance)
+}
+
+// douglasPeucker keeps the end points & recursively the point furthest from
+// the line between them, if it is further than `tolerance`
+func douglasPeucker(line []Point, tolerance float64) []Point {
+	if len(line) < 3 {
+		return line
+	}
+
+	a, b := line[0], line[len(line)-1]
+	index, dist := 0, 0.0
+	for i := 1; i < len(line)-1; i++ {
+		d := perpendicular(line[i], a, b)
+		if d > dist {
+			index, dist = i, d
+		}
+	}
+
+	if dist <= tolerance {
+		return []Point{a, b}
+	}
+
+	left := douglasPeucker(line[:index+1], tolerance)
+	right := douglasPeucker(line[index:], tolerance)
+	return append(left[:len(left)-1:len(left)-1], right...)
+}
+
+// perpendicular distance from p to the line through a,b
+func perpendicular(p, a, b Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return math.Hypot(p.X-a.X, p.Y-a.Y)
+	}
+	return math.Abs(dy*p.X-dx*p.Y+b.X*a.Y-b.Y*a.X) / length
+}
@@ -0,0 +1,128 @@
+package vector
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Style controls how features of a given kind are drawn in SVG output
+type Style struct {
+	Fill        string
+	Stroke      string
+	StrokeWidth float64
+	Radius      float64 // for points
+}
+
+var (
+	// defaultStyles for SVG output, by feature kind.
+	// Layers are written in this order (so later layers are drawn on top)
+	defaultStyles = []*layerStyle{
+		{"coastline", &Style{Fill: "#efe6c8", Stroke: "#4a4a4a", StrokeWidth: 1}},
+		{"ravines", &Style{Fill: "none", Stroke: "#8a6d4b", StrokeWidth: 2}},
+		{"glaciers", &Style{Fill: "none", Stroke: "#a8d8ea", StrokeWidth: 2}},
+		{"mountains", &Style{Fill: "none", Stroke: "#6b4f2a", StrokeWidth: 3}},
+		{"volcanoes", &Style{Fill: "#c0392b", Stroke: "#5a1a12", StrokeWidth: 1, Radius: 4}},
+	}
+
+	// fallback for kinds we don't have a style for
+	defaultStyle = &Style{Fill: "none", Stroke: "#000000", StrokeWidth: 1, Radius: 3}
+)
+
+type layerStyle struct {
+	Kind  string
+	Style *Style
+}
+
+// WriteSVG writes features out as an SVG image of the given size.
+// Each kind of feature is placed in it's own layer (group) & names are written into
+// a final "labels" layer.
+func WriteSVG(w io.Writer, width, height int, features []*Feature) error {
+	buf := bufio.NewWriter(w)
+
+	byKind := map[string][]*Feature{}
+	order := []string{}
+	styles := map[string]*Style{}
+	for _, l := range defaultStyles {
+		order = append(order, l.Kind)
+		styles[l.Kind] = l.Style
+	}
+	for _, f := range features {
+		_, known := byKind[f.Kind]
+		_, styled := styles[f.Kind]
+		if !known && !styled {
+			order = append(order, f.Kind)
+		}
+		byKind[f.Kind] = append(byKind[f.Kind], f)
+	}
+
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+
+	for _, kind := range order {
+		fs, ok := byKind[kind]
+		if !ok {
+			continue
+		}
+		st, ok := styles[kind]
+		if !ok {
+			st = defaultStyle
+		}
+
+		fmt.Fprintf(
+			buf,
+			`<g id="%s" fill="%s" stroke="%s" stroke-width="%g" stroke-linejoin="round">`+"\n",
+			html.EscapeString(kind), st.Fill, st.Stroke, st.StrokeWidth,
+		)
+		for _, f := range fs {
+			writeSVGFeature(buf, f, st)
+		}
+		buf.WriteString("</g>\n")
+	}
+
+	buf.WriteString(`<g id="labels" font-family="serif" font-size="12" text-anchor="middle" fill="#222222">` + "\n")
+	for _, f := range features {
+		if f.Name == "" {
+			continue
+		}
+		p, ok := f.Anchor()
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(buf, `<text x="%.1f" y="%.1f">%s</text>`+"\n", p.X, p.Y, html.EscapeString(f.Name))
+	}
+	buf.WriteString("</g>\n</svg>\n")
+
+	return buf.Flush()
+}
+
+func writeSVGFeature(w io.Writer, f *Feature, st *Style) {
+	switch {
+	case len(f.Rings) > 0:
+		d := []string{}
+		for _, r := range f.Rings {
+			d = append(d, svgPath(r)+"Z")
+		}
+		fmt.Fprintf(w, `<path fill-rule="evenodd" d="%s"/>`+"\n", strings.Join(d, " "))
+	case len(f.Line) > 1:
+		fmt.Fprintf(w, `<path fill="none" d="%s"/>`+"\n", svgPath(f.Line))
+	default:
+		for _, p := range f.Points {
+			fmt.Fprintf(w, `<circle cx="%.1f" cy="%.1f" r="%g"/>`+"\n", p.X, p.Y, st.Radius)
+		}
+	}
+}
+
+func svgPath(in []Point) string {
+	b := strings.Builder{}
+	for i, p := range in {
+		if i == 0 {
+			b.WriteString("M")
+		} else {
+			b.WriteString(" L")
+		}
+		fmt.Fprintf(&b, "%.1f %.1f", p.X, p.Y)
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package vector
+
+import (
+	"image"
+	"math"
+)
+
+// Point is a location in world (pixel) space.
+//
+// Contours run between pixel centres so unlike image.Point we need fractions.
+type Point struct {
+	X, Y float64
+}
+
+// Pt is shorthand for Point{x, y}
+func Pt(x, y float64) Point {
+	return Point{X: x, Y: y}
+}
+
+// FromImagePoints converts image points to vector points
+func FromImagePoints(in []image.Point) []Point {
+	out := make([]Point, len(in))
+	for i, p := range in {
+		out[i] = Pt(float64(p.X), float64(p.Y))
+	}
+	return out
+}
+
+// Feature is some named thing on a map that we can draw as vector data.
+//
+// Exactly one of Rings, Line or Points is expected to be set.
+type Feature struct {
+	// Kind of feature (eg. coastline, mountains)
+	Kind string
+
+	// Name is a human readable label (may be empty)
+	Name string
+
+	// Rings are closed outlines of area features, the first is the outer
+	// boundary & any others are holes.
+	Rings [][]Point
+
+	// Line is the path of a linear feature
+	Line []Point
+
+	// Points are locations of point features
+	Points []Point
+
+	// Properties are extra key / values written out with the feature
+	Properties map[string]interface{}
+}
+
+// Anchor returns a reasonable place to put a label for the feature
+func (f *Feature) Anchor() (Point, bool) {
+	switch {
+	case len(f.Rings) > 0 && len(f.Rings[0]) > 0:
+		min, max := bounds(f.Rings[0])
+		return Pt((min.X+max.X)/2, (min.Y+max.Y)/2), true
+	case len(f.Line) > 0:
+		return f.Line[len(f.Line)/2], true
+	case len(f.Points) > 0:
+		return f.Points[0], true
+	}
+	return Point{}, false
+}
+
+// bounds returns the min & max corners of the given points
+func bounds(in []Point) (Point, Point) {
+	min := Pt(math.Inf(1), math.Inf(1))
+	max := Pt(math.Inf(-1), math.Inf(-1))
+	for _, p := range in {
+		min.X = math.Min(min.X, p.X)
+		min.Y = math.Min(min.Y, p.Y)
+		max.X = math.Max(max.X, p.X)
+		max.Y = math.Max(max.Y, p.Y)
+	}
+	return min, max
+}
+
+// ringArea returns the (absolute) area of a closed ring (shoelace formula)
+func ringArea(ring []Point) float64 {
+	total := 0.0
+	for i := 0; i < len(ring); i++ {
+		a := ring[i]
+		b := ring[(i+1)%len(ring)]
+		total += a.X*b.Y - b.X*a.Y
+	}
+	return math.Abs(total / 2)
+}
@@ -0,0 +1,334 @@
+package voronoi
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+)
+
+const (
+	// codecVersion is written after the magic header, bump it if the layout changes
+	codecVersion = 2
+)
+
+var (
+	// codecMagic starts every graph written in our binary format. Anything else
+	// is assumed to be JSON.
+	codecMagic = []byte("GVG\x00")
+
+	// ErrUnknownVersion is returned if we're given a binary graph we can't read
+	ErrUnknownVersion = fmt.Errorf("unknown graph format version")
+
+	// ErrCorrupt is returned if a binary graph doesn't make sense
+	ErrCorrupt = fmt.Errorf("graph data is corrupt")
+)
+
+// isBinary returns if the data is in our binary format
+func isBinary(data []byte) bool {
+	return bytes.HasPrefix(data, codecMagic)
+}
+
+// Debug returns the given graph data (binary or JSON) as indented JSON, for humans.
+func Debug(data []byte) ([]byte, error) {
+	g := &graph{}
+	err := g.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// decode reads either format into the exported fields of `g`
+func (g *graph) decode(data []byte) error {
+	if !isBinary(data) {
+		return json.Unmarshal(data, g)
+	}
+
+	data = data[len(codecMagic):]
+	if len(data) < 1 {
+		return ErrCorrupt
+	}
+	version := data[0]
+	if version < 1 || version > codecVersion {
+		return fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	r := &reader{r: bufio.NewReader(zr)}
+
+	g.GraphName = r.str()
+	g.Width = int(r.varint())
+	g.Height = int(r.varint())
+	g.Seed = r.varint()
+	g.DefaultWeight = int(r.varint())
+	if version >= 2 {
+		shape := Shape(r.uvarint())
+		g.Wrap = shape != Flat
+		g.Sphere = shape == Sphere
+	}
+
+	g.WeightNames = make([]string, r.count())
+	for i := range g.WeightNames {
+		g.WeightNames[i] = r.str()
+	}
+
+	g.SiteCentres = r.points()
+	g.Vertices = r.points()
+
+	// edges are pairs of indexes into vertices
+	g.Edges = make([][2]image.Point, r.count())
+	for i := range g.Edges {
+		a, b := r.uvarint(), r.uvarint()
+		if r.err != nil {
+			break
+		}
+		if a >= uint64(len(g.Vertices)) || b >= uint64(len(g.Vertices)) {
+			return fmt.Errorf("%w: edge %d vertex out of range", ErrCorrupt, i)
+		}
+		g.Edges[i] = [2]image.Point{g.Vertices[a], g.Vertices[b]}
+	}
+
+	tags := r.count()
+	g.Tags = map[string][]image.Point{}
+	g.TagKinds = map[string]string{}
+	for i := 0; i < tags && r.err == nil; i++ {
+		name := r.str()
+		kind := r.str()
+		g.Tags[name] = r.points()
+		if kind != "" {
+			g.TagKinds[name] = kind
+		}
+	}
+
+	weights := r.count()
+	g.Weights = map[string][]int{}
+	for i := 0; i < weights && r.err == nil; i++ {
+		name := r.str()
+		values := make([]int, r.count())
+		for j := range values {
+			values[j] = int(r.int32())
+		}
+		g.Weights[name] = values
+	}
+
+	return r.err
+}
+
+// encode writes the exported fields of `g` in our binary format
+func (g *graph) encode() ([]byte, error) {
+	vertex := make(map[image.Point]uint64, len(g.Vertices))
+	for i, v := range g.Vertices {
+		vertex[v] = uint64(i)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(codecMagic)
+	buf.WriteByte(codecVersion)
+
+	zw := gzip.NewWriter(buf)
+	w := &writer{w: bufio.NewWriter(zw)}
+
+	w.str(g.GraphName)
+	w.varint(int64(g.Width))
+	w.varint(int64(g.Height))
+	w.varint(g.Seed)
+	w.varint(int64(g.DefaultWeight))
+	w.uvarint(uint64(g.shape()))
+
+	w.uvarint(uint64(len(g.WeightNames)))
+	for _, name := range g.WeightNames {
+		w.str(name)
+	}
+
+	w.points(g.SiteCentres)
+	w.points(g.Vertices)
+
+	w.uvarint(uint64(len(g.Edges)))
+	for i, e := range g.Edges {
+		a, aok := vertex[e[0]]
+		b, bok := vertex[e[1]]
+		if !aok || !bok {
+			return nil, fmt.Errorf("edge %d is not between vertices", i)
+		}
+		w.uvarint(a)
+		w.uvarint(b)
+	}
+
+	// maps are written sorted so the same graph always gives the same bytes
+	w.uvarint(uint64(len(g.Tags)))
+	for _, name := range sortedKeys(g.Tags) {
+		w.str(name)
+		w.str(g.TagKinds[name])
+		w.points(g.Tags[name])
+	}
+
+	names := make([]string, 0, len(g.Weights))
+	for name := range g.Weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.uvarint(uint64(len(names)))
+	for _, name := range names {
+		w.str(name)
+		w.uvarint(uint64(len(g.Weights[name])))
+		for _, v := range g.Weights[name] {
+			w.int32(int32(v))
+		}
+	}
+
+	err := w.flush()
+	if err != nil {
+		return nil, err
+	}
+	err = zw.Close()
+	return buf.Bytes(), err
+}
+
+func sortedKeys(in map[string][]image.Point) []string {
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writer writes our binary format, holding on to the first error
+type writer struct {
+	w       *bufio.Writer
+	err     error
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (w *writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(b)
+}
+
+func (w *writer) uvarint(v uint64) {
+	n := binary.PutUvarint(w.scratch[:], v)
+	w.write(w.scratch[:n])
+}
+
+func (w *writer) varint(v int64) {
+	n := binary.PutVarint(w.scratch[:], v)
+	w.write(w.scratch[:n])
+}
+
+func (w *writer) int32(v int32) {
+	binary.LittleEndian.PutUint32(w.scratch[:4], uint32(v))
+	w.write(w.scratch[:4])
+}
+
+func (w *writer) str(s string) {
+	w.uvarint(uint64(len(s)))
+	w.write([]byte(s))
+}
+
+// points are written as deltas from the previous point, which are usually small
+func (w *writer) points(pts []image.Point) {
+	w.uvarint(uint64(len(pts)))
+	prev := image.Pt(0, 0)
+	for _, p := range pts {
+		w.varint(int64(p.X - prev.X))
+		w.varint(int64(p.Y - prev.Y))
+		prev = p
+	}
+}
+
+func (w *writer) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// reader reads our binary format, holding on to the first error
+type reader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(r.r)
+	r.fail(err)
+	return v
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(r.r)
+	r.fail(err)
+	return v
+}
+
+func (r *reader) int32() int32 {
+	if r.err != nil {
+		return 0
+	}
+	var b [4]byte
+	_, err := io.ReadFull(r.r, b[:])
+	r.fail(err)
+	return int32(binary.LittleEndian.Uint32(b[:]))
+}
+
+// count reads a length, refusing anything silly so corrupt data can't make us
+// allocate the world
+func (r *reader) count() int {
+	v := r.uvarint()
+	if v > 1<<28 {
+		r.fail(fmt.Errorf("%w: length %d", ErrCorrupt, v))
+		return 0
+	}
+	return int(v)
+}
+
+func (r *reader) str() string {
+	n := r.count()
+	if r.err != nil || n == 0 {
+		return ""
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r.r, b)
+	r.fail(err)
+	return string(b)
+}
+
+func (r *reader) points() []image.Point {
+	pts := make([]image.Point, r.count())
+	prev := image.Pt(0, 0)
+	for i := range pts {
+		prev = image.Pt(prev.X+int(r.varint()), prev.Y+int(r.varint()))
+		pts[i] = prev
+	}
+	return pts
+}
+
+func (r *reader) fail(err error) {
+	if err == nil || r.err != nil {
+		return
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: unexpected end of data", ErrCorrupt)
+	}
+	r.err = err
+}
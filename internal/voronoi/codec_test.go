@@ -0,0 +1,93 @@
+package voronoi
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshal(t *testing.T) {
+	g, err := newGraph(context.Background(), "g", []string{"a", "b"}, 500, 400, 3, 100, 42, Flat)
+	assert.Nil(t, err)
+
+	g.TagAs("mountains", "range-1", g.Vertices[:5])
+	g.Tag("plain", []image.Point{{-4, 7}, {1, 2}})
+	assert.Nil(t, g.IncrWeights(g.Vertices[3:9], map[string]int{"b": -2}))
+
+	bin, err := g.Marshal()
+	assert.Nil(t, err)
+	assert.True(t, isBinary(bin))
+
+	plain, err := json.Marshal(g)
+	assert.Nil(t, err)
+	assert.False(t, isBinary(plain))
+	assert.Less(t, len(bin), len(plain))
+
+	for name, data := range map[string][]byte{"binary": bin, "json": plain} {
+		t.Run(name, func(t *testing.T) {
+			result := &graph{}
+			assert.Nil(t, result.Unmarshal(data))
+
+			assert.Equal(t, g.GraphName, result.GraphName)
+			assert.Equal(t, g.Width, result.Width)
+			assert.Equal(t, g.Height, result.Height)
+			assert.Equal(t, g.Seed, result.Seed)
+			assert.Equal(t, g.WeightNames, result.WeightNames)
+			assert.Equal(t, g.SiteCentres, result.SiteCentres)
+			assert.Equal(t, g.Vertices, result.Vertices)
+			assert.Equal(t, g.Edges, result.Edges)
+			assert.Equal(t, g.Tags, result.Tags)
+			assert.Equal(t, g.TagKinds, result.TagKinds)
+			assert.Equal(t, g.dij.Weights(), result.dij.Weights())
+
+			// the diagram isn't read, but built when cells are wanted
+			assert.Nil(t, result.voro)
+			for _, s := range g.SiteCentres[:10] {
+				assert.Equal(t, g.CellAt(s).Edges(), result.CellAt(s).Edges())
+			}
+		})
+	}
+
+	readable, err := Debug(bin)
+	assert.Nil(t, err)
+	assert.Contains(t, string(readable), `"range-1": "mountains"`)
+
+	_, err = Debug(append(bin[:len(codecMagic)], 99))
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
+
+// benchGraph is a graph big enough that reading it takes a while (we don't go as big as
+// our usual worlds, building the diagram for the first one takes minutes)
+func benchGraph(b *testing.B) *graph {
+	g, err := newGraph(context.Background(), "g", []string{"a", "b", "c"}, 2000, 1000, 3, 1500, 42, Flat)
+	assert.Nil(b, err)
+	g.TagAs("mountains", "range-1", g.Vertices[:500])
+	assert.Nil(b, g.IncrWeights(g.Vertices[100:900], map[string]int{"b": 7}))
+	return g
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	g := benchGraph(b)
+	bin, err := g.Marshal()
+	assert.Nil(b, err)
+	plain, err := json.Marshal(g)
+	assert.Nil(b, err)
+
+	for _, name := range []string{"binary", "json"} {
+		data := map[string][]byte{"binary": bin, "json": plain}[name]
+
+		b.Run(name+"/decode", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				assert.Nil(b, (&graph{}).decode(data))
+			}
+		})
+		b.Run(name+"/all", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				assert.Nil(b, (&graph{}).Unmarshal(data))
+			}
+		})
+	}
+}
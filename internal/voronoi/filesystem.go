@@ -1,29 +1,33 @@
 package voronoi
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"strings"
+
+	"github.com/voidshard/genesis/internal/blob"
 )
 
-type fsVoronoi struct {
-	root   string
+type blobVoronoi struct {
+	store  blob.Blob
 	width  int
 	height int
 }
 
-func New(root string, width, height int) Voronoi {
-	os.MkdirAll(root, 0750)
-	return &fsVoronoi{root: root, width: width, height: height}
+func New(store blob.Blob, width, height int) Voronoi {
+	return &blobVoronoi{store: store, width: width, height: height}
 }
 
-func (f *fsVoronoi) NewGraph(name string, weightNames []string, defaultWeight, points int, seed int64) (Graph, error) {
-	return newGraph(name, weightNames, f.width, f.height, defaultWeight, points, seed)
+func (f *blobVoronoi) NewGraph(ctx context.Context, name string, weightNames []string, defaultWeight, points int, seed int64, shape Shape) (Graph, error) {
+	return newGraph(ctx, name, weightNames, f.width, f.height, defaultWeight, points, seed, shape)
 }
 
-func (f *fsVoronoi) Graph(name string) (Graph, error) {
-	data, err := ioutil.ReadFile(f.pathFor(name))
+func (f *blobVoronoi) Graph(name string) (Graph, error) {
+	data, err := f.store.Get(keyFor(name))
+	if errors.Is(err, blob.ErrNotFound) {
+		data, err = f.store.Get(legacyKeyFor(name))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -31,19 +35,43 @@ func (f *fsVoronoi) Graph(name string) (Graph, error) {
 	return g, g.Unmarshal(data)
 }
 
-func (f *fsVoronoi) Delete(name string) error {
-	return os.Remove(f.pathFor(name))
+func (f *blobVoronoi) Delete(name string) error {
+	err := f.store.Delete(keyFor(name))
+	if err != nil {
+		return err
+	}
+	return f.store.Delete(legacyKeyFor(name))
 }
 
-func (f *fsVoronoi) Save(in Graph) error {
+func (f *blobVoronoi) Save(in Graph) error {
 	data, err := in.Marshal()
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(f.pathFor(in.Name()), data, 0660)
+	err = f.store.Put(keyFor(in.Name()), data)
+	if err != nil {
+		return err
+	}
+	// we've a newer copy now
+	return f.store.Delete(legacyKeyFor(in.Name()))
+}
+
+// IsKey returns if the given storage key holds a graph
+func IsKey(key string) bool {
+	return strings.HasSuffix(key, graphExt) || strings.HasSuffix(key, legacyGraphExt)
+}
+
+const (
+	graphExt       = ".graph"
+	legacyGraphExt = ".json" // graphs were JSON before we had a binary format
+)
+
+// keyFor returns where in storage we keep this named graph
+func keyFor(name string) string {
+	return fmt.Sprintf("%s%s", name, graphExt)
 }
 
-// pathFor retrns where on the disk we store this named graph
-func (f *fsVoronoi) pathFor(name string) string {
-	return filepath.Join(f.root, fmt.Sprintf("%s.json", name))
+// legacyKeyFor returns where we used to keep this named graph
+func legacyKeyFor(name string) string {
+	return fmt.Sprintf("%s%s", name, legacyGraphExt)
 }
@@ -1,11 +1,16 @@
 package voronoi
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"image"
 	"math/rand"
+	"sort"
+	"sync"
 
 	"github.com/voidshard/genesis/internal/dijkstra"
+	"github.com/voidshard/genesis/internal/globe"
 	"github.com/voidshard/voronoi"
 )
 
@@ -22,6 +27,8 @@ type graph struct {
 	Width     int    `json:"width"`
 	Height    int    `json:"height"`
 	Seed      int64  `json:"seed"`
+	Wrap      bool   `json:"wrap"`   // east & west edges meet
+	Sphere    bool   `json:"sphere"` // we're a globe (implies Wrap)
 
 	DefaultWeight int      `json:"default_weight"`
 	WeightNames   []string `json:"weight_names"`
@@ -30,47 +37,170 @@ type graph struct {
 	Vertices    []image.Point    `json:"vertices"` // corners of cells
 	Edges       [][2]image.Point `json:"edges"`    // edges of cells
 
-	Tags map[string][]image.Point `json:"tags"` // user defined
+	Tags     map[string][]image.Point `json:"tags"`      // user defined
+	TagKinds map[string]string        `json:"tag_kinds"` // tag -> kind of feature (optional)
 
 	Weights map[string][]int `json:"weights"` // tag -> vertex index -> weight
 	dij     *dijkstra.Graph
 
-	voro *voronoi.Voronoi
+	voroLock sync.Mutex
+	voro     *diagram // built when first needed, see diagram
+	ghostOf  []int    // voro site index - len(SiteCentres) -> site copied (if we wrap)
+
+	vertIndex *index // over Vertices
+	siteIndex *index // over SiteCentres
 }
 
 // newGraph creates a voronoi diagram
-func newGraph(name string, weightNames []string, width, height, defweight, points int, seed int64) (*graph, error) {
-	voro, sites, verts, edges, err := randomVoronoi(seed, width, height, points)
+func newGraph(ctx context.Context, name string, weightNames []string, width, height, defweight, points int, seed int64, shape Shape) (*graph, error) {
+	voro, ghostOf, sites, verts, edges, err := randomVoronoi(seed, width, height, points, shape)
 	if err != nil {
 		return nil, err
 	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 
 	dij, err := dijkstra.New(defweight, weightNames, verts, edges)
 	if err != nil {
 		return nil, err
 	}
 
-	return &graph{
+	g := &graph{
 		GraphName:   name,
 		Width:       width,
 		Height:      height,
 		Seed:        seed,
+		Wrap:        shape != Flat,
+		Sphere:      shape == Sphere,
 		WeightNames: weightNames,
 		SiteCentres: sites,
 		Vertices:    verts,
 		Edges:       edges,
 		dij:         dij,
 		Tags:        map[string][]image.Point{},
+		TagKinds:    map[string]string{},
 		voro:        voro,
-	}, nil
+		ghostOf:     ghostOf,
+	}
+	g.wrapGraph()
+	g.buildIndexes()
+
+	return g, nil
+}
+
+// buildIndexes sets up spatial indexes for vertices & sites
+func (g *graph) buildIndexes() {
+	bounds := image.Rect(0, 0, g.Width, g.Height)
+	wrap := 0
+	if g.Wrap {
+		wrap = g.Width
+	}
+	g.vertIndex = newIndex(bounds, g.Vertices, wrap)
+	g.siteIndex = newIndex(bounds, g.SiteCentres, wrap)
+}
+
+// shape of the world we cover
+func (g *graph) shape() Shape {
+	if g.Sphere {
+		return Sphere
+	} else if g.Wrap {
+		return Cylinder
+	}
+	return Flat
+}
+
+// wrapGraph tells our dijkstra graph about wrapping (if we do)
+func (g *graph) wrapGraph() {
+	if g.Sphere {
+		g.dij.Sphere(g.Width, g.Height)
+	} else if g.Wrap {
+		g.dij.Wrap(g.Width)
+	}
+}
+
+// distance between two points, around / over the world if we wrap
+func (g *graph) distance(a, b image.Point) float64 {
+	if g.Sphere {
+		return globe.Distance(a, b, g.Width, g.Height)
+	} else if g.Wrap {
+		return Distance(a, b, g.Width)
+	}
+	return pythagoras(a, b)
+}
+
+// diagram returns the voronoi diagram over our sites. It's by far the slowest part of
+// a graph to build (much slower than reading the rest) so graphs that are read back
+// or scaled only build it when cells are first asked for.
+func (g *graph) diagram() (*diagram, error) {
+	g.voroLock.Lock()
+	defer g.voroLock.Unlock()
+	if g.voro != nil {
+		return g.voro, nil
+	}
+	voro, ghostOf, err := rebuildVoronoi(g.Width, g.Height, g.SiteCentres, g.shape())
+	if err != nil {
+		return nil, err
+	}
+	g.voro = voro
+	g.ghostOf = ghostOf
+	return voro, nil
+}
+
+// site returns the voronoi site of SiteCentres[i], given the index of a copy of a site
+// (see ghostSites) we return the site it's a copy of
+func (g *graph) site(i int) (voronoi.Site, error) {
+	voro, err := g.diagram()
+	if err != nil {
+		return nil, err
+	}
+	if i >= len(g.SiteCentres) && i-len(g.SiteCentres) < len(g.ghostOf) {
+		i = g.ghostOf[i-len(g.SiteCentres)]
+	}
+	return voro.siteAt(i), nil
+}
+
+// siteByID returns a voronoi site given the diagram's ID for it (eg. of a neighbour)
+func (g *graph) siteByID(id int) (voronoi.Site, error) {
+	voro, err := g.diagram()
+	if err != nil {
+		return nil, err
+	}
+	i, ok := voro.indexOf(id)
+	if !ok {
+		return nil, fmt.Errorf("site %d is not in the diagram", id)
+	}
+	return g.site(i)
+}
+
+// nearest returns indexes of the k closest `pts` to p (from idx, an index over pts),
+// closest first. The index looks around the east / west edges if we wrap, but on a
+// globe points near the poles can be far off along x & still be close, so we look at
+// more & sort them by distance over the globe.
+func (g *graph) nearest(idx *index, pts []image.Point, p image.Point, k int) []int {
+	if !g.Sphere {
+		return idx.nearest(p, k)
+	}
+
+	found := idx.nearest(p, k*4)
+	sort.SliceStable(found, func(a, b int) bool {
+		return g.distance(p, pts[found[a]]) < g.distance(p, pts[found[b]])
+	})
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found
 }
 
 func (g *graph) Points() []image.Point { return g.Vertices }
 
 func (g *graph) Sites() []image.Point { return g.SiteCentres }
 
-func (g *graph) RandomCell() *Cell {
-	c := g.voro.SiteByID(rand.Intn(len(g.SiteCentres)))
+func (g *graph) RandomCell(rng *rand.Rand) *Cell {
+	c, err := g.site(rng.Intn(len(g.SiteCentres)))
+	if err != nil || c == nil {
+		return nil
+	}
 	return &Cell{parent: c, Site: image.Pt(c.X(), c.Y())}
 }
 
@@ -80,22 +210,31 @@ func (g *graph) NeighbouringCells(in []*Cell) ([]*Cell, error) {
 		given[c.parent.ID()] = true
 	}
 
-	found := map[int]*voronoi.Neighbour{}
+	found := map[int]voronoi.Site{}
 	for _, c := range in {
 		for _, neighbour := range c.parent.Neighbours() {
-			_, wasGiven := given[neighbour.Site.ID()]
+			site, err := g.siteByID(neighbour.Site.ID())
+			if err != nil {
+				return nil, err
+			}
+			_, wasGiven := given[site.ID()]
 			if wasGiven {
 				continue
 			}
-			found[neighbour.Site.ID()] = neighbour
+			found[site.ID()] = site
 		}
 	}
 
-	res := make([]*Cell, len(found))
-	i := 0
-	for _, v := range found {
-		res[i] = &Cell{parent: v.Site, Site: image.Pt(v.Site.X(), v.Site.Y())}
-		i += 1
+	ids := make([]int, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids) // so callers see cells in the same order each time
+
+	res := make([]*Cell, len(ids))
+	for i, id := range ids {
+		v := found[id]
+		res[i] = &Cell{parent: v, Site: image.Pt(v.X(), v.Y())}
 	}
 
 	return res, nil
@@ -109,27 +248,67 @@ func (g *graph) Tag(name string, in []image.Point) {
 	g.Tags[name] = in
 }
 
+func (g *graph) TagAs(kind, name string, in []image.Point) {
+	g.Tags[name] = in
+	if g.TagKinds == nil { // graphs saved before we had kinds
+		g.TagKinds = map[string]string{}
+	}
+	g.TagKinds[name] = kind
+}
+
 func (g *graph) FromTag(name string) ([]image.Point, bool) {
 	result, ok := g.Tags[name]
 	return result, ok
 }
 
-func (g *graph) RandomPoint() image.Point { return g.dij.RandomPoint() }
+func (g *graph) TagKind(name string) string {
+	return g.TagKinds[name]
+}
+
+func (g *graph) TagNames() []string {
+	names := make([]string, 0, len(g.Tags))
+	for name := range g.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g *graph) RandomPoint(rng *rand.Rand) image.Point { return g.dij.RandomPoint(rng) }
 
 func (g *graph) ClosestPoint(in image.Point) image.Point {
-	// TODO make more efficient this is .. pretty expensive
-	dist := pythagoras(in, g.Vertices[0])
-	pnt := g.Vertices[0]
-
-	for _, p := range g.Vertices[1:] {
-		d := pythagoras(in, p)
-		if d < dist {
-			dist = d
-			pnt = p
-		}
+	return g.Vertices[g.nearest(g.vertIndex, g.Vertices, in, 1)[0]]
+}
+
+func (g *graph) KNearest(in image.Point, k int) []image.Point {
+	found := g.nearest(g.vertIndex, g.Vertices, in, k)
+	result := make([]image.Point, len(found))
+	for i, v := range found {
+		result[i] = g.Vertices[v]
 	}
+	return result
+}
 
-	return pnt
+func (g *graph) PointsWithin(area image.Rectangle) []image.Point {
+	found := g.vertIndex.within(area)
+	result := make([]image.Point, len(found))
+	for i, v := range found {
+		result[i] = g.Vertices[v]
+	}
+	return result
+}
+
+func (g *graph) CellAt(in image.Point) *Cell {
+	// the cell containing a point is the one whose site is closest
+	found := g.nearest(g.siteIndex, g.SiteCentres, in, 1)
+	if len(found) == 0 {
+		return nil
+	}
+	c, err := g.site(found[0])
+	if err != nil || c == nil {
+		return nil
+	}
+	return &Cell{parent: c, Site: image.Pt(c.X(), c.Y())}
 }
 
 func (g *graph) IncrWeights(pts []image.Point, delta map[string]int) error {
@@ -144,15 +323,31 @@ func (g *graph) Shortest(weight string, a, b image.Point) ([]image.Point, error)
 	return g.dij.Shortest(weight, a, b)
 }
 
+func (g *graph) Route(r *Route, a, b image.Point) ([]image.Point, error) {
+	return g.dij.Route(r, a, b)
+}
+
 func (g *graph) Name() string { return g.GraphName }
 
+// Marshal writes the graph in our (compressed) binary format, see Debug if you want
+// to read it.
 func (g *graph) Marshal() ([]byte, error) {
 	g.Weights = g.dij.Weights()
-	return json.Marshal(g)
+	return g.encode()
 }
 
+// MarshalJSON is Marshal but in plain JSON
+func (g *graph) MarshalJSON() ([]byte, error) {
+	if g.dij != nil {
+		g.Weights = g.dij.Weights()
+	}
+	type plain graph // without our methods, or we'd recurse
+	return json.Marshal((*plain)(g))
+}
+
+// Unmarshal reads a graph written by either Marshal or MarshalJSON
 func (g *graph) Unmarshal(data []byte) error {
-	err := json.Unmarshal(data, g)
+	err := g.decode(data)
 	if err != nil {
 		return err
 	}
@@ -163,14 +358,17 @@ func (g *graph) Unmarshal(data []byte) error {
 	}
 
 	g.dij = dij
+	g.wrapGraph()
 
 	err = g.dij.SetWeights(g.Weights)
 	if err != nil {
 		return err
 	}
 
-	voro, err := rebuildVoronoi(g.Width, g.Height, g.SiteCentres)
+	g.buildIndexes()
 
-	g.voro = voro
-	return err
+	g.voroLock.Lock()
+	g.voro, g.ghostOf = nil, nil // built again when needed, see diagram
+	g.voroLock.Unlock()
+	return nil
 }
@@ -0,0 +1,207 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+const (
+	// indexDensity is roughly how many points we aim to have per index cell
+	indexDensity = 2
+)
+
+// index is a uniform grid over a set of points, so we can find points near
+// to some location without looking at all of them.
+//
+// Voronoi sites & vertices are spread pretty evenly, so a grid does as well as
+// anything fancier.
+type index struct {
+	pts  []image.Point
+	wrap int // width of the world if east & west edges meet, otherwise 0
+
+	origin     image.Point
+	size       int // width & height of each grid cell
+	cols, rows int
+	cells      [][]int // grid cell -> indexes into pts
+}
+
+// newIndex builds an index over `pts`, which are expected to be (mostly) in `bounds`.
+// If wrap is given (the width of the world) points are found around the east / west
+// edges too.
+func newIndex(bounds image.Rectangle, pts []image.Point, wrap int) *index {
+	area := float64(bounds.Dx()) * float64(bounds.Dy())
+	size := int(math.Ceil(math.Sqrt(area * indexDensity / float64(len(pts)+1))))
+	if size < 1 {
+		size = 1
+	}
+
+	idx := &index{
+		pts:    pts,
+		wrap:   wrap,
+		origin: bounds.Min,
+		size:   size,
+		cols:   bounds.Dx()/size + 1,
+		rows:   bounds.Dy()/size + 1,
+	}
+	idx.cells = make([][]int, idx.cols*idx.rows)
+	for i, p := range pts {
+		c := idx.cell(p)
+		idx.cells[c] = append(idx.cells[c], i)
+	}
+	return idx
+}
+
+// col / row returns the grid coords for p (points outside are clamped to the edge)
+func (x *index) col(p image.Point) int {
+	return clamp((p.X-x.origin.X)/x.size, 0, x.cols-1)
+}
+
+func (x *index) row(p image.Point) int {
+	return clamp((p.Y-x.origin.Y)/x.size, 0, x.rows-1)
+}
+
+func (x *index) cell(p image.Point) int {
+	return x.row(p)*x.cols + x.col(p)
+}
+
+// distance between two points, around the world if we wrap
+func (x *index) distance(a, b image.Point) float64 {
+	if x.wrap > 0 {
+		return Distance(a, b, x.wrap)
+	}
+	return pythagoras(a, b)
+}
+
+// within returns indexes of all points in area. If we wrap the area may go past the
+// east / west edges, points there are found on the other side.
+func (x *index) within(area image.Rectangle) []int {
+	found := []int{}
+	if len(x.pts) == 0 || area.Empty() {
+		return found
+	}
+	if x.wrap <= 0 {
+		found = x.inside(area, found)
+		sort.Ints(found)
+		return found
+	}
+
+	seen := map[int]bool{}
+	for _, dx := range []int{-x.wrap, 0, x.wrap} {
+		for _, i := range x.inside(area.Add(image.Pt(dx, 0)), nil) {
+			if !seen[i] {
+				seen[i] = true
+				found = append(found, i)
+			}
+		}
+	}
+	sort.Ints(found)
+	return found
+}
+
+// inside appends indexes of all points in area to found
+func (x *index) inside(area image.Rectangle, found []int) []int {
+	c0, r0 := x.col(area.Min), x.row(area.Min)
+	c1, r1 := x.col(area.Max), x.row(area.Max)
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= c1; c++ {
+			for _, i := range x.cells[r*x.cols+c] {
+				if x.pts[i].In(area) {
+					found = append(found, i)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// nearest returns the indexes of the k closest points to p, closest first
+func (x *index) nearest(p image.Point, k int) []int {
+	if k <= 0 || len(x.pts) == 0 {
+		return []int{}
+	}
+	if k > len(x.pts) {
+		k = len(x.pts)
+	}
+
+	type candidate struct {
+		i    int
+		dist float64
+	}
+	found := []candidate{}
+
+	var seen map[int]bool // if we wrap, rings may meet around the back
+	if x.wrap > 0 {
+		p.X = ((p.X % x.wrap) + x.wrap) % x.wrap
+		seen = map[int]bool{}
+	}
+
+	pc, pr := x.col(p), x.row(p)
+	maxRing := x.cols
+	if x.rows > maxRing {
+		maxRing = x.rows
+	}
+
+	for ring := 0; ring <= maxRing; ring++ {
+		for r := pr - ring; r <= pr+ring; r++ {
+			if r < 0 || r >= x.rows {
+				continue
+			}
+			for c := pc - ring; c <= pc+ring; c++ {
+				if r != pr-ring && r != pr+ring && c != pc-ring && c != pc+ring {
+					continue // inner cells were done in a previous ring
+				}
+				cc := c
+				if seen != nil {
+					cc = ((c % x.cols) + x.cols) % x.cols
+					if seen[r*x.cols+cc] {
+						continue
+					}
+					seen[r*x.cols+cc] = true
+				} else if c < 0 || c >= x.cols {
+					continue
+				}
+				for _, i := range x.cells[r*x.cols+cc] {
+					found = append(found, candidate{i: i, dist: x.distance(p, x.pts[i])})
+				}
+			}
+		}
+
+		if len(found) < k {
+			continue
+		}
+		sort.Slice(found, func(a, b int) bool {
+			if found[a].dist == found[b].dist {
+				return found[a].i < found[b].i
+			}
+			return found[a].dist < found[b].dist
+		})
+		found = found[:k]
+
+		// anything in the next ring is at least this far from p (less a cell if we
+		// wrap, the last column may be narrower than the rest)
+		reach := ring
+		if x.wrap > 0 {
+			reach--
+		}
+		if found[k-1].dist <= float64(reach*x.size) {
+			break
+		}
+	}
+
+	result := make([]int, len(found))
+	for i, c := range found {
+		result[i] = c.i
+	}
+	return result
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
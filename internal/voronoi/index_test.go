@@ -0,0 +1,116 @@
+package voronoi
+
+import (
+	"context"
+	"image"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPythagoras(t *testing.T) {
+	assert.Equal(t, 5.0, pythagoras(image.Pt(1, 1), image.Pt(4, 5)))
+	assert.Equal(t, 5.0, pythagoras(image.Pt(4, 5), image.Pt(1, 1)))
+	assert.Equal(t, 0.0, pythagoras(image.Pt(7, 7), image.Pt(7, 7)))
+}
+
+func TestIndex(t *testing.T) {
+	g, err := newGraph(context.Background(), "g", []string{"a"}, 800, 600, 1, 300, 7, Flat)
+	assert.Nil(t, err)
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		// include some points off the graph
+		p := image.Pt(rng.Intn(1000)-100, rng.Intn(800)-100)
+
+		// closest is as close as the closest found by looking at everything
+		best := g.Vertices[0]
+		for _, v := range g.Vertices {
+			if pythagoras(p, v) < pythagoras(p, best) {
+				best = v
+			}
+		}
+		assert.Equal(t, pythagoras(p, best), pythagoras(p, g.ClosestPoint(p)))
+
+		dists := []float64{}
+		for _, v := range g.Vertices {
+			dists = append(dists, pythagoras(p, v))
+		}
+		sort.Float64s(dists)
+		near := g.KNearest(p, 5)
+		assert.Equal(t, 5, len(near))
+		for j, v := range near {
+			assert.Equal(t, dists[j], pythagoras(p, v))
+		}
+
+		area := image.Rect(p.X, p.Y, p.X+rng.Intn(200), p.Y+rng.Intn(200))
+		expect := []image.Point{}
+		for _, v := range g.Vertices {
+			if v.In(area) {
+				expect = append(expect, v)
+			}
+		}
+		assert.Equal(t, expect, g.PointsWithin(area))
+
+		if p.In(image.Rect(0, 0, g.Width, g.Height)) {
+			c := g.CellAt(p)
+			assert.Equal(t, g.voro.SiteFor(p.X, p.Y).ID(), c.ID())
+		}
+	}
+
+	assert.Equal(t, len(g.Vertices), len(g.KNearest(image.Pt(0, 0), len(g.Vertices)+10)))
+}
+
+func TestIndexWraps(t *testing.T) {
+	g, err := newGraph(context.Background(), "g", []string{"a"}, 800, 600, 1, 300, 7, Cylinder)
+	assert.Nil(t, err)
+
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		// mostly near the east / west edges, where it matters
+		p := image.Pt(rng.Intn(100)-50, rng.Intn(g.Height))
+		if i%2 == 0 {
+			p.X += g.Width
+		}
+
+		dists := []float64{}
+		for _, v := range g.Vertices {
+			dists = append(dists, Distance(p, v, g.Width))
+		}
+		sort.Float64s(dists)
+		assert.Equal(t, dists[0], Distance(p, g.ClosestPoint(p), g.Width))
+		for j, v := range g.KNearest(p, 5) {
+			assert.Equal(t, dists[j], Distance(p, v, g.Width))
+		}
+
+		// the cell we're in has the closest site, around the world or not
+		best := g.SiteCentres[0]
+		for _, s := range g.SiteCentres {
+			if Distance(p, s, g.Width) < Distance(p, best, g.Width) {
+				best = s
+			}
+		}
+		c := g.CellAt(p)
+		assert.Equal(t, Distance(p, best, g.Width), Distance(p, c.Site, g.Width))
+	}
+
+	// an area over the west edge holds points from the far east
+	area := image.Rect(-60, 100, 60, 300)
+	expect := []image.Point{}
+	for _, v := range g.Vertices {
+		if v.In(area) || v.Sub(image.Pt(g.Width, 0)).In(area) {
+			expect = append(expect, v)
+		}
+	}
+	found := g.PointsWithin(area)
+	assert.ElementsMatch(t, expect, found)
+	east := 0
+	for _, v := range found {
+		if v.X > g.Width/2 {
+			east++
+		}
+	}
+	assert.Greater(t, east, 0)
+}
@@ -1,14 +1,39 @@
 package voronoi
 
 import (
+	"context"
 	"image"
+	"math/rand"
+
+	"github.com/voidshard/genesis/internal/dijkstra"
+)
+
+// Route describes how a path should get from a to b (blended weights, waypoints
+// & areas to avoid).
+type Route = dijkstra.Route
+
+// Shape of the world a graph covers
+type Shape int
+
+const (
+	// Flat worlds are rectangles with edges all round
+	Flat Shape = iota
+
+	// Cylinder worlds wrap; the east & west edges meet
+	Cylinder
+
+	// Sphere worlds are globes drawn equirectangular (see globe), they wrap like a
+	// Cylinder & distances are measured over the sphere
+	Sphere
 )
 
 // Voronoi provides a database like interface for interacting with
 // voronoi diagrams.
 type Voronoi interface {
-	// NewGraph makes a new graph and returns it.
-	NewGraph(name string, wieghtNames []string, defaultWeight, points int, seed int64) (Graph, error)
+	// NewGraph makes a new graph and returns it (without saving it). Unless the shape
+	// is Flat the east & west edges of the graph meet, so cells & paths carry on
+	// across them.
+	NewGraph(ctx context.Context, name string, wieghtNames []string, defaultWeight, points int, seed int64, shape Shape) (Graph, error)
 
 	// Graph returns existing graph
 	Graph(name string) (Graph, error)
@@ -33,16 +58,25 @@ type Graph interface {
 	Unmarshal([]byte) error
 
 	// RandomPoint returns a point at random from the graph
-	RandomPoint() image.Point
+	RandomPoint(rng *rand.Rand) image.Point
 
-	// RandomCell returns a voronoi diagram cell at random
-	RandomCell() *Cell
+	// RandomCell returns a voronoi diagram cell at random (nil if the diagram can't be built)
+	RandomCell(rng *rand.Rand) *Cell
 
 	// ClosestPoint returns the closest point on the graph to
 	// the given point.
-	// Warning; calculating this could be expensive ..
 	ClosestPoint(image.Point) image.Point
 
+	// KNearest returns the (up to) k closest points on the graph to the
+	// given point, closest first.
+	KNearest(p image.Point, k int) []image.Point
+
+	// PointsWithin returns all points on the graph inside the given area
+	PointsWithin(area image.Rectangle) []image.Point
+
+	// CellAt returns the cell that contains the given point
+	CellAt(p image.Point) *Cell
+
 	// Points returns all points on the graph
 	// These are vertexes that run alongside sites.
 	//
@@ -64,6 +98,13 @@ type Graph interface {
 	// graph. Given points mapped to closest points in graph.
 	Shortest(weight string, a, b image.Point) ([]image.Point, error)
 
+	// Route is Shortest but following the given route. Waypoints must be
+	// points on the graph.
+	Route(r *Route, a, b image.Point) ([]image.Point, error)
+
+	// Scale returns a copy of the graph (with a new name) stretched to a new size
+	Scale(name string, width, height int) (Graph, error)
+
 	// IncrWeights for the given set of points.
 	IncrWeights(pts []image.Point, delta map[string]int) error
 
@@ -74,9 +115,18 @@ type Graph interface {
 	// Tag tags all points in `p` with some name
 	Tag(tag string, p []image.Point)
 
+	// TagAs is Tag but also records what kind of feature the tag is (eg. mountains)
+	TagAs(kind, tag string, p []image.Point)
+
 	// FromTag returns points tagged with `tag`
 	FromTag(tag string) ([]image.Point, bool)
 
+	// TagKind returns the kind of feature given to TagAs (if any)
+	TagKind(tag string) string
+
+	// TagNames returns the names of all tags (sorted)
+	TagNames() []string
+
 	// NeighbouringPoints returns all points that are joined to one of
 	// the given points by an edge(s), but are *not* in `p`
 	NeighbouringPoints(p []image.Point) ([]image.Point, error)
@@ -0,0 +1,90 @@
+package voronoi
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/voidshard/genesis/internal/dijkstra"
+)
+
+var (
+	// ErrScaleCollision is returned if scaling a graph (down) would put two points
+	// in the same place
+	ErrScaleCollision = fmt.Errorf("scaled graph has overlapping points")
+)
+
+// Scale returns a copy of the graph (named `name`) stretched to width x height. Cells,
+// weights & tags are kept, only where they are changes.
+func (g *graph) Scale(name string, width, height int) (Graph, error) {
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("invalid graph size %dx%d", width, height)
+	}
+	at := func(p image.Point) image.Point {
+		return image.Pt(p.X*width/g.Width, p.Y*height/g.Height)
+	}
+
+	out := &graph{
+		GraphName:     name,
+		Width:         width,
+		Height:        height,
+		Seed:          g.Seed,
+		Wrap:          g.Wrap,
+		Sphere:        g.Sphere,
+		DefaultWeight: g.DefaultWeight,
+		WeightNames:   g.WeightNames,
+		SiteCentres:   make([]image.Point, len(g.SiteCentres)),
+		Vertices:      make([]image.Point, len(g.Vertices)),
+		Edges:         make([][2]image.Point, len(g.Edges)),
+		Tags:          map[string][]image.Point{},
+		TagKinds:      map[string]string{},
+	}
+
+	seen := map[image.Point]bool{}
+	for i, v := range g.Vertices {
+		out.Vertices[i] = at(v)
+		if seen[out.Vertices[i]] {
+			return nil, fmt.Errorf("%w vertex %v", ErrScaleCollision, v)
+		}
+		seen[out.Vertices[i]] = true
+	}
+	seen = map[image.Point]bool{}
+	for i, s := range g.SiteCentres {
+		out.SiteCentres[i] = at(s)
+		if seen[out.SiteCentres[i]] {
+			return nil, fmt.Errorf("%w site %v", ErrScaleCollision, s)
+		}
+		seen[out.SiteCentres[i]] = true
+	}
+	for i, e := range g.Edges {
+		out.Edges[i] = [2]image.Point{at(e[0]), at(e[1])}
+	}
+	for tag, pts := range g.Tags {
+		scaled := make([]image.Point, len(pts))
+		for i, p := range pts {
+			scaled[i] = at(p)
+		}
+		out.Tags[tag] = scaled
+	}
+	for tag, kind := range g.TagKinds {
+		out.TagKinds[tag] = kind
+	}
+
+	// weights are by vertex index, which we haven't changed
+	weights := map[string][]int{}
+	for tag, w := range g.dij.Weights() {
+		weights[tag] = append([]int{}, w...)
+	}
+	dij, err := dijkstra.New(out.DefaultWeight, out.WeightNames, out.Vertices, out.Edges)
+	if err != nil {
+		return nil, err
+	}
+	err = dij.SetWeights(weights)
+	if err != nil {
+		return nil, err
+	}
+	out.dij = dij
+	out.wrapGraph()
+	out.buildIndexes()
+
+	return out, nil // the diagram is built when needed, see diagram
+}
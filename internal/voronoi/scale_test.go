@@ -0,0 +1,42 @@
+package voronoi
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScale(t *testing.T) {
+	g, err := newGraph(context.Background(), "g", []string{"a"}, 800, 600, 1, 200, 7, Flat)
+	assert.Nil(t, err)
+
+	path, err := g.Shortest("a", g.Vertices[0], g.Vertices[len(g.Vertices)-1])
+	assert.Nil(t, err)
+	g.TagAs("kind", "tag", path)
+	assert.Nil(t, g.IncrWeights(path[:1], map[string]int{"a": 5}))
+
+	big, err := g.Scale("big", 1600, 1200)
+	assert.Nil(t, err)
+	assert.Equal(t, "big", big.Name())
+	assert.Equal(t, "g", g.Name())
+
+	for i, v := range g.Vertices {
+		assert.Equal(t, image.Pt(v.X*2, v.Y*2), big.Points()[i])
+	}
+	scaled, ok := big.FromTag("tag")
+	assert.True(t, ok)
+	assert.Equal(t, len(path), len(scaled))
+	assert.Equal(t, image.Pt(path[0].X*2, path[0].Y*2), scaled[0])
+	assert.Equal(t, "kind", big.TagKind("tag"))
+	assert.Equal(t, g.dij.Weights(), big.(*graph).dij.Weights())
+
+	// the same route is found across the scaled graph
+	again, err := big.Shortest("a", scaled[0], scaled[len(scaled)-1])
+	assert.Nil(t, err)
+	assert.Equal(t, scaled, again)
+
+	_, err = g.Scale("tiny", 8, 6)
+	assert.ErrorIs(t, err, ErrScaleCollision)
+}
@@ -0,0 +1,180 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+
+	"github.com/voidshard/voronoi"
+)
+
+const (
+	// settleSites is how many sites near a vertex we consider when settling it
+	settleSites = 5
+
+	// settleDist is how far (in pixels) we allow a settled vertex to move
+	settleDist = 1.0
+)
+
+// diagram is a voronoi diagram along with it's sites, so we can settle it's vertices
+// (see settle)
+type diagram struct {
+	*voronoi.Voronoi
+
+	bounds image.Rectangle
+	sites  []image.Point // including any copies (see ghostSites)
+	ids    []int         // index into sites -> the diagram's ID of that site
+	byID   map[int]int   // the diagram's ID of a site -> index into sites
+	index  *index
+}
+
+// settledSite is a site whose edges are settled (see settle)
+type settledSite struct {
+	voronoi.Site
+
+	d     *diagram
+	edges [][2]image.Point
+}
+
+// newDiagram wraps v, ids are the IDs v gave each of the sites when they were added
+func newDiagram(v *voronoi.Voronoi, bounds image.Rectangle, sites []image.Point, ids []int) *diagram {
+	byID := make(map[int]int, len(ids))
+	for i, id := range ids {
+		byID[id] = i
+	}
+	return &diagram{Voronoi: v, bounds: bounds, sites: sites, ids: ids, byID: byID, index: newIndex(bounds, sites, 0)}
+}
+
+// siteAt returns sites[i], with settled edges
+func (d *diagram) siteAt(i int) voronoi.Site {
+	if i < 0 || i >= len(d.ids) {
+		return nil
+	}
+	return d.SiteByID(d.ids[i])
+}
+
+// indexOf returns the index into sites of the site with the diagram's ID `id`
+func (d *diagram) indexOf(id int) (int, bool) {
+	i, ok := d.byID[id]
+	return i, ok
+}
+
+// SiteByID returns the given site (by the diagram's ID), with settled edges
+func (d *diagram) SiteByID(id int) voronoi.Site {
+	s := d.Voronoi.SiteByID(id)
+	if s == nil {
+		return nil
+	}
+	return &settledSite{Site: s, d: d}
+}
+
+// Edges returns the settled edges of the site, starting from the top left most
+func (s *settledSite) Edges() [][2]image.Point {
+	if s.edges != nil {
+		return s.edges
+	}
+
+	edges := [][2]image.Point{}
+	first := 0
+	for _, e := range s.Site.Edges() {
+		a, b := s.d.settle(e[0]), s.d.settle(e[1])
+		if a == b {
+			continue
+		}
+		edges = append(edges, [2]image.Point{a, b})
+		if a.Y < edges[first][0].Y || (a.Y == edges[first][0].Y && a.X < edges[first][0].X) {
+			first = len(edges) - 1
+		}
+	}
+	s.edges = append(edges[first:], edges[:first]...)
+	return s.edges
+}
+
+// settle returns where the vertex `v` of the diagram really is.
+//
+// The diagram is worked out in floats & merges copies of a vertex that are nearly the
+// same in no particular order, so a vertex lying exactly between two pixels can round
+// either way from one build to the next. A vertex is as far from each of the sites
+// whose cells meet there (three or more, or two & it's on the bounds) & further from
+// all others, so we work it out again from those & round it ourselves.
+func (d *diagram) settle(v image.Point) image.Point {
+	onX := v.X == d.bounds.Min.X || v.X == d.bounds.Max.X
+	onY := v.Y == d.bounds.Min.Y || v.Y == d.bounds.Max.Y
+	if onX && onY {
+		return v // a corner
+	}
+
+	near := d.index.nearest(v, settleSites)
+	best, bestDist := v, math.Inf(1)
+	consider := func(x, y float64, from ...int) {
+		dist := math.Hypot(x-float64(v.X), y-float64(v.Y))
+		if dist > settleDist || dist >= bestDist {
+			return
+		}
+		r := siteDist(d.sites[from[0]], x, y)
+		for _, i := range near {
+			if siteDist(d.sites[i], x, y) < r-1e-6 {
+				return // some other cell is here
+			}
+		}
+		best, bestDist = image.Pt(int(math.Round(x)), int(math.Round(y))), dist
+	}
+
+	for i := 0; i < len(near); i++ {
+		for j := i + 1; j < len(near); j++ {
+			a, b := d.sites[near[i]], d.sites[near[j]]
+			if onX {
+				y, ok := bisectAt(a.X, a.Y, b.X, b.Y, v.X)
+				if ok {
+					consider(float64(v.X), y, near[i], near[j])
+				}
+			}
+			if onY {
+				x, ok := bisectAt(a.Y, a.X, b.Y, b.X, v.Y)
+				if ok {
+					consider(x, float64(v.Y), near[i], near[j])
+				}
+			}
+			for k := j + 1; k < len(near); k++ {
+				x, y, ok := circumcentre(a, b, d.sites[near[k]])
+				if ok {
+					consider(x, y, near[i], near[j], near[k])
+				}
+			}
+		}
+	}
+	return best
+}
+
+// bisectAt returns where along the line u = `at` points (u, v) are as far from (au, av)
+// as (bu, bv)
+func bisectAt(au, av, bu, bv, at int) (float64, bool) {
+	den := 2 * (int64(av) - int64(bv))
+	if den == 0 {
+		return 0, false
+	}
+	sq := func(n int64) int64 { return n * n }
+	num := sq(int64(au-at)) - sq(int64(bu-at)) + sq(int64(av)) - sq(int64(bv))
+	return float64(num) / float64(den), true
+}
+
+// circumcentre returns the point as far from a, b & c. We work in integers as long as
+// we can, so which order the sites are given in doesn't change the answer.
+func circumcentre(a, b, c image.Point) (float64, float64, bool) {
+	ax, ay := int64(a.X), int64(a.Y)
+	bx, by := int64(b.X), int64(b.Y)
+	cx, cy := int64(c.X), int64(c.Y)
+
+	den := 2 * (ax*(by-cy) + bx*(cy-ay) + cx*(ay-by))
+	if den == 0 {
+		return 0, 0, false // in a line
+	}
+	a2, b2, c2 := ax*ax+ay*ay, bx*bx+by*by, cx*cx+cy*cy
+	x := a2*(by-cy) + b2*(cy-ay) + c2*(ay-by)
+	y := a2*(cx-bx) + b2*(ax-cx) + c2*(bx-ax)
+	return float64(x) / float64(den), float64(y) / float64(den), true
+}
+
+// siteDist is how far (x, y) is from a site
+func siteDist(s image.Point, x, y float64) float64 {
+	return math.Hypot(float64(s.X)-x, float64(s.Y)-y)
+}
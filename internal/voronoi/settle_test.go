@@ -0,0 +1,42 @@
+package voronoi
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircumcentre(t *testing.T) {
+	a, b, c := image.Pt(0, 0), image.Pt(4, 0), image.Pt(0, 3)
+
+	x, y, ok := circumcentre(a, b, c)
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, x)
+	assert.Equal(t, 1.5, y)
+
+	// the same whichever order we're given the sites in
+	for _, order := range [][3]image.Point{{b, c, a}, {c, a, b}, {c, b, a}} {
+		ox, oy, _ := circumcentre(order[0], order[1], order[2])
+		assert.Equal(t, x, ox)
+		assert.Equal(t, y, oy)
+	}
+
+	_, _, ok = circumcentre(a, image.Pt(1, 1), image.Pt(2, 2))
+	assert.False(t, ok)
+}
+
+func TestSettleIsRepeatable(t *testing.T) {
+	for _, shape := range []Shape{Flat, Cylinder, Sphere} {
+		_, _, sites, verts, edges, err := randomVoronoi(3, 400, 300, 150, shape)
+		assert.Nil(t, err)
+
+		for i := 0; i < 5; i++ {
+			_, _, againSites, againVerts, againEdges, err := randomVoronoi(3, 400, 300, 150, shape)
+			assert.Nil(t, err)
+			assert.Equal(t, sites, againSites, shape)
+			assert.Equal(t, verts, againVerts, shape)
+			assert.Equal(t, edges, againEdges, shape)
+		}
+	}
+}
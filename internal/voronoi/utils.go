@@ -3,6 +3,7 @@ package voronoi
 import (
 	"image"
 	"math"
+	"sort"
 
 	"github.com/voidshard/voronoi"
 	"github.com/voidshard/voronoi/line"
@@ -19,7 +20,7 @@ func PointsBetween(a, b image.Point) []image.Point {
 
 // pythagoras returns the dist between two points
 func pythagoras(a, b image.Point) float64 {
-	return math.Sqrt(math.Pow(float64(a.X+b.X), 2) + math.Pow(float64(a.Y+b.Y), 2))
+	return math.Sqrt(math.Pow(float64(a.X-b.X), 2) + math.Pow(float64(a.Y-b.Y), 2))
 }
 
 // toDecimal turns floats without a decimal component into a number beginning
@@ -47,39 +48,80 @@ func toDecimal(f float64) float64 {
 // implementation. So this one is considered useful for sites & rough calcs
 // but the vertices / edges from the first call of randomVoronoi should be
 // saved to ensure accuracy.
-func rebuildVoronoi(width, height int, pts []image.Point) (*voronoi.Voronoi, error) {
-	b := voronoi.NewBuilder(image.Rect(0, 0, width, height))
-	for _, p := range pts {
-		b.AddSite(p.X, p.Y)
+func rebuildVoronoi(width, height int, pts []image.Point, shape Shape) (*diagram, []int, error) {
+	bounds := image.Rect(0, 0, width, height)
+	var ghosts []image.Point
+	var ghostOf []int
+	if shape != Flat {
+		bounds, ghosts, ghostOf = ghostSites(width, height, pts, shape == Sphere)
+	}
+
+	all := append(append([]image.Point{}, pts...), ghosts...)
+	ids := make([]int, len(all))
+	b := voronoi.NewBuilder(bounds)
+	for i, p := range all {
+		ids[i], _ = b.AddSite(p.X, p.Y) // given the ID of the existing site, if it's a repeat
+	}
+	v, err := b.Voronoi()
+	if err != nil {
+		return nil, nil, err
 	}
-	return b.Voronoi()
+	return newDiagram(v, bounds, all, ids), ghostOf, nil
 }
 
 // randomVoronoi returns a voronoi diagram with approximately `numPoints` Sites.
 // We return the sites, vertices (corners of sites) and edges (edges between
 // vertices).
-func randomVoronoi(seed int64, width, height, points int) (*voronoi.Voronoi, []image.Point, []image.Point, [][2]image.Point, error) {
+//
+// If the world wraps the diagram also has copies of sites near the east & west edges
+// (see ghostSites), the indexes of which sites they copy are returned too.
+func randomVoronoi(seed int64, width, height, points int, shape Shape) (*diagram, []int, []image.Point, []image.Point, [][2]image.Point, error) {
 	b := voronoi.NewBuilder(image.Rect(0, 0, width, height))
 	if seed > 0 {
 		b.SetSeed(seed)
 	}
-	b.SetSiteFilters(b.MinDistance(siteMinDist))
+	if shape == Cylinder {
+		b.SetSiteFilters(wrapMinDistance(width, siteMinDist))
+	} else {
+		b.SetSiteFilters(b.MinDistance(siteMinDist))
+	}
 
 	sites := []image.Point{}
-	for i := 0; i < points*2; i++ {
-		x, y, _, ok := b.AddRandomSite()
-		if ok {
-			sites = append(sites, image.Pt(x, y))
+	ids := []int{}
+	if shape == Sphere {
+		sites = sphereSites(seed, width, height, points)
+	} else {
+		for i := 0; i < points*2; i++ {
+			x, y, id, ok := b.AddRandomSite()
+			if ok {
+				sites = append(sites, image.Pt(x, y))
+				ids = append(ids, id)
+			}
+			if b.SiteCount() >= points {
+				break
+			}
 		}
-		if b.SiteCount() >= points {
-			break
+	}
+
+	if shape != Flat {
+		d, ghostOf, err := rebuildVoronoi(width, height, sites, shape)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+		cells := make([][][2]image.Point, len(sites))
+		for i := range sites {
+			cells[i] = d.siteAt(i).Edges()
 		}
+		vertices, edges := foldEdges(cells, width)
+		sortGraph(vertices, edges)
+		return d, ghostOf, sites, vertices, edges, nil
 	}
 
-	diagram, err := b.Voronoi()
+	v, err := b.Voronoi()
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, err
 	}
+	d := newDiagram(v, image.Rect(0, 0, width, height), sites, ids)
 
 	edgeId := func(a, b image.Point) float64 {
 		// gets a unique ID for each edge regardless of point order
@@ -96,8 +138,8 @@ func randomVoronoi(seed int64, width, height, points int) (*voronoi.Voronoi, []i
 
 	vertices := []image.Point{}
 	edges := [][2]image.Point{}
-	for _, s := range diagram.Sites() {
-		for _, e := range s.Edges() {
+	for i := range sites {
+		for _, e := range d.siteAt(i).Edges() {
 			// save unique verts
 			_, seenZro := vertsSeen[e[0]]
 			_, seenOne := vertsSeen[e[1]]
@@ -120,5 +162,31 @@ func randomVoronoi(seed int64, width, height, points int) (*voronoi.Voronoi, []i
 		}
 	}
 
-	return diagram, sites, vertices, edges, nil
+	sortGraph(vertices, edges)
+	return d, nil, sites, vertices, edges, nil
+}
+
+// sortGraph puts vertices & edges in a fixed order (the diagram gives them to us in
+// any order) so the same sites always make the same graph
+func sortGraph(vertices []image.Point, edges [][2]image.Point) {
+	less := func(a, b image.Point) bool {
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	}
+	sort.Slice(vertices, func(i, j int) bool {
+		return less(vertices[i], vertices[j])
+	})
+	for i, e := range edges {
+		if less(e[1], e[0]) {
+			edges[i] = [2]image.Point{e[1], e[0]}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return less(edges[i][0], edges[j][0])
+		}
+		return less(edges[i][1], edges[j][1])
+	})
 }
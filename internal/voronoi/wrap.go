@@ -0,0 +1,176 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+
+	"github.com/voidshard/genesis/internal/globe"
+)
+
+const (
+	// wrapBand is how many cells wide a band of sites we copy past the east & west
+	// edges of a wrapping world, so cells along the edges are shaped as if the world
+	// carried on around
+	wrapBand = 3
+)
+
+// WrapX returns x on a world `width` wide whose east & west edges meet
+func WrapX(x, width int) int {
+	x %= width
+	if x < 0 {
+		x += width
+	}
+	return x
+}
+
+// Unwrap returns a path on a wrapping world `width` wide as one continuous line.
+// Where the path crosses the east / west edge the points after it are carried on past
+// the edge (ie. x < 0 or x >= width) rather than jumping to the other side.
+func Unwrap(path []image.Point, width int) []image.Point {
+	if len(path) == 0 {
+		return path
+	}
+	out := make([]image.Point, len(path))
+	out[0] = path[0]
+	for i := 1; i < len(path); i++ {
+		p := path[i]
+		prev := out[i-1]
+		// put p in whichever copy of the world is nearest to prev
+		p.X += int(math.Round(float64(prev.X-p.X)/float64(width))) * width
+		out[i] = p
+	}
+	return out
+}
+
+// Distance between two points, the short way around if the world wraps (width > 0)
+func Distance(a, b image.Point, width int) float64 {
+	dx := math.Abs(float64(a.X - b.X))
+	if width > 0 && dx > float64(width)/2 {
+		dx = float64(width) - dx
+	}
+	return math.Hypot(dx, float64(a.Y-b.Y))
+}
+
+// ghostSites returns copies of sites near the east & west edges of a wrapping world
+// placed past the opposite edge (& the index of the site each is a copy of), along
+// with bounds big enough to hold them.
+// Cells of a globe near the poles are very wide, so there we copy half the world.
+func ghostSites(width, height int, sites []image.Point, sphere bool) (image.Rectangle, []image.Point, []int) {
+	band := int(math.Ceil(wrapBand * math.Sqrt(float64(width)*float64(height)/float64(len(sites)+1))))
+	if sphere {
+		band = width / 2
+	}
+	if band > width {
+		band = width
+	}
+
+	ghosts := []image.Point{}
+	of := []int{}
+	for i, s := range sites {
+		if s.X < band {
+			ghosts = append(ghosts, image.Pt(s.X+width, s.Y))
+			of = append(of, i)
+		}
+		if s.X >= width-band {
+			ghosts = append(ghosts, image.Pt(s.X-width, s.Y))
+			of = append(of, i)
+		}
+	}
+	return image.Rect(-band, 0, width+band, height), ghosts, of
+}
+
+// sphereSites returns up to `points` random sites spread evenly over a globe (rather
+// than the image, which is stretched near the poles) at least siteMinDist apart
+func sphereSites(seed int64, width, height, points int) []image.Point {
+	if seed <= 0 {
+		seed = rand.Int63()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	sites := []image.Point{}
+	for i := 0; i < points*2 && len(sites) < points; i++ {
+		p := image.Pt(rng.Intn(width), globe.SiteY(rng.Float64(), height))
+		ok := true
+		for _, s := range sites {
+			if globe.Distance(p, s, width, height) < siteMinDist {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			sites = append(sites, p)
+		}
+	}
+	return sites
+}
+
+// wrapMinDistance is voronoi.MinDistance but measured around a wrapping world
+func wrapMinDistance(width int, dist float64) func(ax, ay, sx, sy int) bool {
+	return func(ax, ay, sx, sy int) bool {
+		return Distance(image.Pt(ax, ay), image.Pt(sx, sy), width) >= dist
+	}
+}
+
+// foldEdges returns the unique vertices & edges of the first `n` sites of a diagram
+// built with ghostSites, with vertices past the east / west edges moved back onto the
+// world. Edges that crossed an edge of the world then join vertices on either side.
+func foldEdges(sites [][][2]image.Point, width int) ([]image.Point, [][2]image.Point) {
+	vertsSeen := map[image.Point]bool{}
+	vertices := []image.Point{}
+
+	// vertices on the world are taken as they are
+	for _, edges := range sites {
+		for _, e := range edges {
+			for _, v := range e {
+				if v.X < 0 || v.X >= width || vertsSeen[v] {
+					continue
+				}
+				vertsSeen[v] = true
+				vertices = append(vertices, v)
+			}
+		}
+	}
+
+	// those past an edge are folded back, snapping to a vertex we already have if it's
+	// within a pixel (copies of the same vertex can round differently)
+	fold := func(v image.Point) image.Point {
+		if v.X >= 0 && v.X < width {
+			return v
+		}
+		v.X = WrapX(v.X, width)
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				near := image.Pt(WrapX(v.X+dx, width), v.Y+dy)
+				if vertsSeen[near] {
+					return near
+				}
+			}
+		}
+		vertsSeen[v] = true
+		vertices = append(vertices, v)
+		return v
+	}
+
+	edgesSeen := map[[2]image.Point]bool{}
+	edges := [][2]image.Point{}
+	for _, es := range sites {
+		for _, e := range es {
+			a, b := fold(e[0]), fold(e[1])
+			if a == b {
+				continue
+			}
+			if b.X < a.X || (b.X == a.X && b.Y < a.Y) {
+				a, b = b, a
+			}
+			key := [2]image.Point{a, b}
+			if edgesSeen[key] {
+				continue
+			}
+			edgesSeen[key] = true
+			edges = append(edges, key)
+		}
+	}
+
+	return vertices, edges
+}
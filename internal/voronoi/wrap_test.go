@@ -0,0 +1,94 @@
+package voronoi
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnwrap(t *testing.T) {
+	cases := []struct {
+		Name   string
+		In     []image.Point
+		Expect []image.Point
+	}{
+		{"empty", []image.Point{}, []image.Point{}},
+		{"no-crossing", []image.Point{{10, 0}, {50, 5}, {90, 9}}, []image.Point{{10, 0}, {50, 5}, {90, 9}}},
+		{"east", []image.Point{{90, 0}, {98, 1}, {3, 2}, {10, 3}}, []image.Point{{90, 0}, {98, 1}, {103, 2}, {110, 3}}},
+		{"west", []image.Point{{5, 0}, {1, 1}, {96, 2}}, []image.Point{{5, 0}, {1, 1}, {-4, 2}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			assert.Equal(t, c.Expect, Unwrap(c.In, 100))
+		})
+	}
+}
+
+func TestWrapGraph(t *testing.T) {
+	g, err := newGraph(context.Background(), "g", []string{"a"}, 800, 600, 1, 200, 7, Cylinder)
+	assert.Nil(t, err)
+
+	crossing := 0
+	for _, v := range g.Vertices {
+		assert.True(t, v.X >= 0 && v.X < g.Width, v)
+	}
+	for _, e := range g.Edges {
+		if Distance(e[0], e[1], 0) > float64(g.Width)/2 {
+			crossing++
+		}
+	}
+	assert.Greater(t, crossing, 0)
+
+	// a path between points near either edge should go the short way, over the edge
+	a, b := g.ClosestPoint(image.Pt(10, 300)), g.ClosestPoint(image.Pt(790, 300))
+	path, err := g.Shortest("a", a, b)
+	assert.Nil(t, err)
+	for _, p := range Unwrap(path, g.Width) {
+		assert.True(t, p.X < 100 || p.X > 700, p)
+	}
+
+	// neighbours of a cell on one edge include cells on the other
+	cell := g.CellAt(image.Pt(1, 300))
+	found, err := g.NeighbouringCells([]*Cell{cell})
+	assert.Nil(t, err)
+	over := false
+	for _, c := range found {
+		assert.True(t, c.Site.X >= 0 && c.Site.X < g.Width, c.Site)
+		over = over || c.Site.X > g.Width/2
+	}
+	assert.True(t, over)
+
+	data, err := g.Marshal()
+	assert.Nil(t, err)
+	result := &graph{}
+	assert.Nil(t, result.Unmarshal(data))
+	assert.True(t, result.Wrap)
+	assert.Equal(t, g.Edges, result.Edges)
+}
+
+func TestSphereGraph(t *testing.T) {
+	g, err := newGraph(context.Background(), "g", []string{"a"}, 800, 400, 1, 300, 7, Sphere)
+	assert.Nil(t, err)
+	assert.True(t, g.Wrap)
+
+	// sites are spread over the globe, so there are fewer in the (stretched) polar rows
+	polar, equator := 0, 0
+	for _, s := range g.SiteCentres {
+		if s.Y < 50 || s.Y >= 350 {
+			polar++
+		} else if s.Y >= 150 && s.Y < 250 {
+			equator++
+		}
+	}
+	assert.Less(t, polar*2, equator)
+
+	data, err := g.Marshal()
+	assert.Nil(t, err)
+	result := &graph{}
+	assert.Nil(t, result.Unmarshal(data))
+	assert.True(t, result.Sphere)
+	assert.Equal(t, g.Edges, result.Edges)
+}
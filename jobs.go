@@ -0,0 +1,70 @@
+package genesis
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/voidshard/genesis/internal/jobs"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+var (
+	// ErrJobFinished is returned when cancelling a job that has already finished
+	ErrJobFinished = jobs.ErrFinished
+)
+
+// JobFunc is work run in the background by SubmitJob. It should return (with ctx.Err())
+// when the context is cancelled. Progress of geography steps called with the
+// given context is recorded on the job.
+type JobFunc = jobs.Func
+
+// SubmitJob queues `fn` to run in the background. Jobs run one at a time, in the
+// order they're submitted.
+func (e *Editor) SubmitJob(proj, kind string, fn JobFunc) (*types.Job, error) {
+	p, err := e.Project(proj)
+	if err != nil {
+		return nil, err
+	}
+	return e.jobs.Submit(p.ID, kind, fn)
+}
+
+// Job returns a job by ID
+func (e *Editor) Job(id string) (*types.Job, error) {
+	j, err := e.jobs.Job(id)
+	return j, jobErr(err)
+}
+
+// ListJobs iterates over jobs (newest first). If proj is given only jobs
+// belonging to the project are returned.
+func (e *Editor) ListJobs(proj, tkn string) ([]*types.Job, string, error) {
+	if proj != "" {
+		p, err := e.Project(proj)
+		if err != nil {
+			return nil, "", err
+		}
+		proj = p.ID
+	}
+	return e.jobs.List(proj, tkn)
+}
+
+// CancelJob stops a queued or running job
+func (e *Editor) CancelJob(id string) (*types.Job, error) {
+	j, err := e.jobs.Cancel(id)
+	return j, jobErr(err)
+}
+
+// WatchJob returns a channel of updates to a job (status, progress) which is closed when
+// the job finishes. The returned func should be called once the caller is no longer
+// interested in updates.
+func (e *Editor) WatchJob(id string) (<-chan *types.Job, func(), error) {
+	ch, done, err := e.jobs.Watch(id)
+	return ch, done, jobErr(err)
+}
+
+// jobErr turns the jobs package not found error into our 404
+func jobErr(err error) error {
+	if errors.Is(err, jobs.ErrNotFound) {
+		return fmt.Errorf("%w %v", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,664 @@
+package genesis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/voidshard/genesis/internal/blob"
+	"github.com/voidshard/genesis/internal/config"
+	"github.com/voidshard/genesis/internal/database"
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/internal/geography"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/progress"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// replayer is an operation (with it's parameters) that can be made again
+type replayer interface {
+	replay(ctx context.Context, geo *geography.Editor, proj string) error
+}
+
+// journalled are the kinds of operation kept in a project's journal
+var journalled = map[string]func() replayer{
+	"tectonics":        func() replayer { return &tectonicsOp{} },
+	"import-heightmap": func() replayer { return &importOp{} },
+	"import-sketch":    func() replayer { return &importOp{Sketch: true} },
+	"mountains":        func() replayer { return &mountainsOp{} },
+	"volcanoes":        func() replayer { return &volcanoesOp{} },
+	"ravines":          func() replayer { return &ravineOp{} },
+	"smooth":           func() replayer { return &smoothOp{} },
+	"flatten":          func() replayer { return &flattenOp{} },
+	"archipelago":      func() replayer { return &archipelagoOp{} },
+	"bay":              func() replayer { return &bayOp{} },
+	"fjords":           func() replayer { return &fjordsOp{} },
+	"raise":            func() replayer { return &editOp{Kind: "raise"} },
+	"lower":            func() replayer { return &editOp{Kind: "lower"} },
+	"flatten-to":       func() replayer { return &editOp{Kind: "flatten-to"} },
+	"smooth-area":      func() replayer { return &editOp{Kind: "smooth-area"} },
+	"noise":            func() replayer { return &editOp{Kind: "noise"} },
+	"undo":             func() replayer { return &undoOp{} },
+	"sea":              func() replayer { return &seaOp{} },
+	"bathymetry":       func() replayer { return &bathymetryOp{} },
+	"wind":             func() replayer { return &windOp{} },
+	"rain":             func() replayer { return &rainOp{} },
+	"seasons":          func() replayer { return &seasonsOp{} },
+	"cryosphere":       func() replayer { return &cryosphereOp{} },
+	"rivers":           func() replayer { return &riversOp{} },
+	"epoch":            func() replayer { return &epochOp{} },
+}
+
+// ListOperations iterates over a project's journal, oldest first
+func (e *Editor) ListOperations(proj, tkn string) ([]*types.Operation, string, error) {
+	p, err := e.Project(proj)
+	if err != nil {
+		return nil, "", err
+	}
+	return e.db.ListOperations(p.ID, tkn)
+}
+
+// Replay throws away the world & rebuilds it from scratch by making the operations in
+// it's journal again (with the same seeds), up to & including `upTo` (a Seq). Going
+// back undoes operations, they're kept until a new operation is made so going forward
+// again redoes them.
+// The world is rebuilt to one side & only replaces the current one if every operation
+// succeeds, so a replay that fails part way leaves the world as it was.
+func (e *Editor) Replay(ctx context.Context, proj string, upTo int) error {
+	p, err := e.Project(proj)
+	if err != nil {
+		return err
+	}
+
+	ops := []*types.Operation{}
+	tkn := ""
+	for {
+		found, next, err := e.db.ListOperations(p.ID, tkn)
+		if err != nil {
+			return err
+		}
+		for _, op := range found {
+			if op.Seq <= upTo {
+				ops = append(ops, op)
+			}
+		}
+		if next == "" {
+			break
+		}
+		tkn = next
+	}
+	if upTo < 0 || upTo > len(ops) {
+		return fmt.Errorf("%w operation %d of project %s", ErrNotFound, upTo, p.ID)
+	}
+
+	// decode everything first, so we don't build a world for nothing
+	todo := make([]replayer, len(ops))
+	for i, op := range ops {
+		mk, ok := journalled[op.Kind]
+		if !ok {
+			return fmt.Errorf("unknown operation %s (%d)", op.Kind, op.Seq)
+		}
+		todo[i] = mk()
+		err = json.Unmarshal(op.Params, todo[i])
+		if err != nil {
+			return fmt.Errorf("operation %s (%d) is corrupt: %w", op.Kind, op.Seq, err)
+		}
+	}
+
+	// start from an empty world, off to one side
+	s, err := e.newScratch(p)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for i, op := range ops {
+		err = progress.Report(ctx, "replay", i, len(ops), "operations")
+		if err != nil {
+			return err
+		}
+		err = todo[i].replay(geography.WithSeed(ctx, op.Seed), s.geo, p.ID)
+		if err != nil {
+			return fmt.Errorf("replaying %s (%d): %w", op.Kind, op.Seq, err)
+		}
+	}
+
+	err = e.swapIn(p, s, upTo)
+	if err != nil {
+		return err
+	}
+	return progress.Report(ctx, "replay", len(ops), len(ops), "operations")
+}
+
+// scratch is a world built apart from the real one (see Replay)
+type scratch struct {
+	root  string // temp folder, if any
+	cfg   *config.Config
+	db    database.Database
+	store blob.Blob
+	geo   *geography.Editor
+}
+
+// newScratch returns a scratch world holding only the project `p` at epoch 0
+func (e *Editor) newScratch(p *types.Project) (*scratch, error) {
+	root, err := os.MkdirTemp(e.cfg.Gen.Root, "replay-")
+	if err != nil {
+		return nil, err
+	}
+	cfg := *e.cfg
+	cfg.Gen.Root = root
+
+	var store blob.Blob = blob.NewMemory()
+	if e.cfg.Storage.Driver != config.StorageDriverMemory {
+		store, err = blob.NewFilesystem(filepath.Join(root, "store"))
+		if err != nil {
+			os.RemoveAll(root)
+			return nil, err
+		}
+	}
+
+	db, err := database.NewSqlite3(&config.Database{
+		Driver: config.DatabaseDriverSQLite,
+		Name:   config.DatabaseNameMemory,
+	})
+	if err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+	s := &scratch{root: root, cfg: &cfg, db: db, store: store, geo: geography.New(&cfg, db, store, e.Geo)}
+
+	start := *p
+	start.Epoch = 0
+	tx, err := db.Begin()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	err = tx.SetProjects([]*types.Project{&start})
+	if err != nil {
+		tx.Rollback()
+		s.Close()
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close throws the scratch world away
+func (s *scratch) Close() error {
+	err := s.db.Close()
+	rerr := os.RemoveAll(s.root)
+	if err != nil {
+		return err
+	}
+	return rerr
+}
+
+// swapIn replaces project `p` (canvases, graphs, landmasses & the project itself) with
+// what's been built in the scratch world & moves the journal head to `head`
+func (e *Editor) swapIn(p *types.Project, s *scratch, head int) error {
+	found, err := s.db.Projects([]string{p.ID})
+	if err != nil {
+		return err
+	}
+	if len(found) != 1 {
+		return fmt.Errorf("%w project %s in replay", ErrNotFound, p.ID)
+	}
+	np := found[0]
+
+	landmasses := []*types.Landmass{}
+	tkn := ""
+	for {
+		lms, next, err := s.db.ListLandmasses(p.ID, tkn)
+		if err != nil {
+			return err
+		}
+		landmasses = append(landmasses, lms...)
+		if next == "" {
+			break
+		}
+		tkn = next
+	}
+
+	// canvases & graphs go in together, copied as they are
+	prefix := p.ID + "-"
+	stage, err := paint.New(e.store, e.cfg.Gen.Root, np.WorldWidth, np.WorldHeight).Begin()
+	if err != nil {
+		return err
+	}
+	defer stage.Rollback() // noop once committed
+
+	keep := map[string]bool{}
+	keys, err := s.store.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		data, err := s.store.Get(k)
+		if err != nil {
+			return err
+		}
+		err = stage.Store().Put(k, data)
+		if err != nil {
+			return err
+		}
+		keep[k] = true
+		keep[strings.SplitN(k, "/", 2)[0]] = true // canvases may be many blobs under their name
+	}
+	err = stage.Commit()
+	if err != nil {
+		return err
+	}
+
+	// drop whatever the old world had that the new one doesn't
+	keys, err = e.store.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		name := strings.SplitN(k, "/", 2)[0]
+		if !keep[name] {
+			k = name // all of it
+		} else if keep[k] {
+			continue
+		}
+		err = e.store.Delete(k)
+		if err != nil && !errors.Is(err, blob.ErrNotFound) {
+			return err
+		}
+	}
+	e.canvasChanged(prefix) // we didn't go through a painter, so no one else heard
+	e.geoEdit.Forget(p.ID)
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	for epoch := 0; epoch <= p.Epoch || epoch <= np.Epoch; epoch++ {
+		err = tx.DeleteLandmassesByProjectEpoch(p.ID, epoch)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if len(landmasses) > 0 {
+		err = tx.SetLandmasses(landmasses)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.SetProjects([]*types.Project{np})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.SetJournalHead(p.ID, head)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return e.tiles.InvalidateProject(p.ID)
+}
+
+// journal makes an operation (by calling fn, or the operation itself if fn is nil) with
+// a fresh seed (see geography.WithSeed) & records it in the project's journal.
+// The operation is recorded first & struck off again if it fails, so the world is never
+// changed by something the journal doesn't know about.
+// Operations that were undone (by Replay) are dropped, they can't be redone after
+// something new has been tried.
+func (e *Editor) journal(ctx context.Context, proj, kind string, op replayer, fn func(ctx context.Context) error) error {
+	p, err := e.Project(proj)
+	if err != nil {
+		return err
+	}
+	params, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+
+	seed := rand.Int63()
+	seq, err := e.record(p, kind, params, seed)
+	if err != nil {
+		return err
+	}
+
+	ctx = geography.WithSeed(ctx, seed)
+	if fn == nil {
+		err = op.replay(ctx, e.geoEdit, proj)
+	} else {
+		err = fn(ctx)
+	}
+	if err != nil {
+		uerr := e.unrecord(p, seq)
+		if uerr != nil {
+			return fmt.Errorf("%w (and failed to remove it from the journal: %v)", err, uerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// record adds an operation to the end of a project's journal, dropping anything that
+// was undone, returning it's Seq
+func (e *Editor) record(p *types.Project, kind string, params []byte, seed int64) (int, error) {
+	tx, err := e.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	head, err := tx.JournalHead(p.ID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.DeleteOperationsAfter(p.ID, head)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.SetOperations([]*types.Operation{{
+		ID:        dbutils.NewID(p.ID, head+1),
+		ProjectID: p.ID,
+		Seq:       head + 1,
+		Epoch:     p.Epoch, // before the operation (which may be NextEpoch)
+		Kind:      kind,
+		Params:    params,
+		Seed:      seed,
+		Created:   time.Now(),
+	}})
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.SetJournalHead(p.ID, head+1)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return head + 1, tx.Commit()
+}
+
+// unrecord removes the operation `seq` (see record) from a project's journal, it's
+// expected to be the last one
+func (e *Editor) unrecord(p *types.Project, seq int) error {
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	head, err := tx.JournalHead(p.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if head != seq {
+		tx.Rollback()
+		return fmt.Errorf("journal of project %s moved on to %d", p.ID, head)
+	}
+	err = tx.DeleteOperationsAfter(p.ID, seq-1)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.SetJournalHead(p.ID, seq-1)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type tectonicsOp struct {
+	Noise  float64 `json:"noise"`
+	Points int     `json:"points"`
+}
+
+func (o *tectonicsOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	return geo.CreateTectonics(ctx, proj, o.Noise, o.Points)
+}
+
+// importOp holds the imported image as PNG
+type importOp struct {
+	Sketch  bool                 `json:"sketch"`
+	PNG     []byte               `json:"png"`
+	Options *types.ImportOptions `json:"options"`
+}
+
+func newImportOp(sketch bool, im image.Image, opts *types.ImportOptions) (*importOp, error) {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, im)
+	return &importOp{Sketch: sketch, PNG: buf.Bytes(), Options: opts}, err
+}
+
+func (o *importOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	im, err := png.Decode(bytes.NewReader(o.PNG))
+	if err != nil {
+		return err
+	}
+	if o.Sketch {
+		return geo.ImportSketch(ctx, proj, im, o.Options)
+	}
+	return geo.ImportHeightmap(ctx, proj, im, o.Options)
+}
+
+type mountainsOp struct {
+	Tag   string          `json:"tag"`
+	Spec  *types.PathSpec `json:"spec"`
+	Scale float64         `json:"scale"`
+}
+
+func (o *mountainsOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, _, err := geo.AddMountainRange(ctx, proj, o.Tag, o.Spec, o.Scale)
+	return err
+}
+
+type volcanoesOp struct {
+	Count int             `json:"count"`
+	Spec  *types.PathSpec `json:"spec"`
+}
+
+func (o *volcanoesOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, _, err := geo.AddVolanoes(ctx, proj, o.Count, o.Spec)
+	return err
+}
+
+type ravineOp struct {
+	Tag        string          `json:"tag"`
+	Spec       *types.PathSpec `json:"spec"`
+	ForkChance float64         `json:"fork_chance"`
+}
+
+func (o *ravineOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.AddRavine(ctx, proj, o.Tag, o.Spec, o.ForkChance)
+	return err
+}
+
+type smoothOp struct {
+	Radius uint32 `json:"radius"`
+}
+
+func (o *smoothOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	return geo.SmoothTerrain(ctx, proj, o.Radius)
+}
+
+type flattenOp struct {
+	Area image.Rectangle `json:"area"`
+}
+
+func (o *flattenOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	return geo.FlattenOutside(ctx, proj, o.Area)
+}
+
+type archipelagoOp struct {
+	Tag     string          `json:"tag"`
+	Spec    *types.PathSpec `json:"spec"`
+	Islands int             `json:"islands"`
+}
+
+func (o *archipelagoOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.AddArchipelago(ctx, proj, o.Tag, o.Spec, o.Islands)
+	return err
+}
+
+type bayOp struct {
+	Centre image.Point `json:"centre"`
+	Radius int         `json:"radius"`
+}
+
+func (o *bayOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	return geo.AddBay(ctx, proj, o.Centre, o.Radius)
+}
+
+type fjordsOp struct {
+	Landmass string `json:"landmass"`
+	Depth    int    `json:"depth"`
+}
+
+func (o *fjordsOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.CarveFjords(ctx, proj, o.Landmass, o.Depth)
+	return err
+}
+
+// editOp is any of the hand edits under a brush, a brush mask is kept as grey pixels
+type editOp struct {
+	Kind     string          `json:"kind"`
+	Polygon  []image.Point   `json:"polygon,omitempty"`
+	Centre   image.Point     `json:"centre"`
+	Radius   int             `json:"radius,omitempty"`
+	MaskArea image.Rectangle `json:"mask_area"`
+	MaskPix  []uint8         `json:"mask_pix,omitempty"`
+	Falloff  int             `json:"falloff,omitempty"`
+
+	Amount     uint8   `json:"amount,omitempty"`
+	Height     uint8   `json:"height,omitempty"`
+	SmoothBy   uint32  `json:"smooth_by,omitempty"`
+	NoiseScale float64 `json:"noise_scale,omitempty"`
+}
+
+func newEditOp(kind string, b *types.Brush) *editOp {
+	op := &editOp{Kind: kind, Polygon: b.Polygon, Centre: b.Centre, Radius: b.Radius, Falloff: b.Falloff}
+	if b.Mask != nil {
+		mask := image.NewGray(b.Mask.Bounds())
+		draw.Draw(mask, mask.Bounds(), b.Mask, mask.Bounds().Min, draw.Src)
+		op.MaskArea = mask.Bounds()
+		op.MaskPix = mask.Pix
+	}
+	return op
+}
+
+func (o *editOp) brush() *types.Brush {
+	b := &types.Brush{Polygon: o.Polygon, Centre: o.Centre, Radius: o.Radius, Falloff: o.Falloff}
+	if len(o.MaskPix) > 0 {
+		b.Mask = &image.Gray{Pix: o.MaskPix, Stride: o.MaskArea.Dx(), Rect: o.MaskArea}
+	}
+	return b
+}
+
+func (o *editOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	b := o.brush()
+	switch o.Kind {
+	case "raise":
+		return geo.RaiseArea(ctx, proj, b, o.Amount)
+	case "lower":
+		return geo.LowerArea(ctx, proj, b, o.Amount)
+	case "flatten-to":
+		return geo.FlattenTo(ctx, proj, b, o.Height)
+	case "smooth-area":
+		return geo.SmoothArea(ctx, proj, b, o.SmoothBy)
+	case "noise":
+		return geo.PaintNoise(ctx, proj, b, o.NoiseScale, o.Amount)
+	}
+	return fmt.Errorf("unknown edit %s", o.Kind)
+}
+
+type undoOp struct{}
+
+func (o *undoOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	return geo.UndoEdit(ctx, proj)
+}
+
+type seaOp struct {
+	SeaLevel     uint8 `json:"sea_level"`
+	EquatorWidth int   `json:"equator_width"`
+	ArcticWidth  int   `json:"arctic_width"`
+	Currents     int   `json:"currents"`
+}
+
+func (o *seaOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, _, err := geo.SeaMap(ctx, proj, o.SeaLevel, o.EquatorWidth, o.ArcticWidth, o.Currents)
+	return err
+}
+
+type bathymetryOp struct{}
+
+func (o *bathymetryOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.Bathymetry(ctx, proj)
+	return err
+}
+
+type windOp struct{}
+
+func (o *windOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.Wind(ctx, proj)
+	return err
+}
+
+type rainOp struct {
+	StormMult float64         `json:"storm_mult"`
+	Winds     []types.Heading `json:"winds"`
+}
+
+func (o *rainOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.Rain(ctx, proj, o.StormMult, o.Winds)
+	return err
+}
+
+type seasonsOp struct {
+	Seasons   int     `json:"seasons"`
+	StormMult float64 `json:"storm_mult"`
+}
+
+func (o *seasonsOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.Seasons(ctx, proj, o.Seasons, o.StormMult)
+	return err
+}
+
+type cryosphereOp struct{}
+
+func (o *cryosphereOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.Cryosphere(ctx, proj)
+	return err
+}
+
+type riversOp struct {
+	Threshold int `json:"threshold"`
+}
+
+func (o *riversOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	_, err := geo.Rivers(ctx, proj, o.Threshold)
+	return err
+}
+
+type epochOp struct{}
+
+func (o *epochOp) replay(ctx context.Context, geo *geography.Editor, proj string) error {
+	return geo.NextEpoch(ctx, proj)
+}
@@ -0,0 +1,178 @@
+package genesis
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func testEditor(t *testing.T) *Editor {
+	e, err := New(&Options{Root: t.TempDir(), InMemory: true})
+	assert.Nil(t, err)
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+// layers returns every layer of the world we're interested in comparing
+func layers(t *testing.T, e *Editor, proj string) map[types.Layer]*image.Gray16 {
+	out := map[types.Layer]*image.Gray16{}
+	for _, l := range []types.Layer{types.LayerHeight, types.LayerSeaTemperature, types.LayerLandmass} {
+		im, err := e.Layer(proj, l, image.Rect(0, 0, minWorldSize, minWorldSize))
+		assert.Nil(t, err)
+		out[l] = im
+	}
+	return out
+}
+
+func TestReplayIsRepeatable(t *testing.T) {
+	ctx := context.Background()
+	e := testEditor(t)
+
+	p := &types.Project{Name: "replay"}
+	assert.Nil(t, e.CreateProject(p))
+
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 40))
+	_, _, err := e.AddMountainRange(ctx, p.ID, "range", &types.PathSpec{
+		From:    &image.Point{X: 100, Y: 100},
+		To:      &image.Point{X: 400, Y: 300},
+		MaxDist: 1000,
+	}, 1)
+	assert.Nil(t, err)
+	_, _, err = e.SeaMap(ctx, p.ID, 100, 50, 50, 2)
+	assert.Nil(t, err)
+	made := layers(t, e, p.ID)
+
+	assert.Nil(t, e.Replay(ctx, p.ID, 3))
+	first := layers(t, e, p.ID)
+
+	assert.Nil(t, e.Replay(ctx, p.ID, 3))
+	second := layers(t, e, p.ID)
+
+	for l, im := range made {
+		assert.Equal(t, im.Pix, first[l].Pix, l)
+		assert.Equal(t, first[l].Pix, second[l].Pix, l)
+	}
+}
+
+func TestReplayKeepsWorldOnFailure(t *testing.T) {
+	ctx := context.Background()
+	e := testEditor(t)
+
+	p := &types.Project{Name: "replay"}
+	assert.Nil(t, e.CreateProject(p))
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 40))
+	made := layers(t, e, p.ID)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.NotNil(t, e.Replay(cancelled, p.ID, 1))
+
+	after := layers(t, e, p.ID)
+	for l, im := range made {
+		assert.Equal(t, im.Pix, after[l].Pix, l)
+	}
+	head, err := e.db.JournalHead(p.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, head)
+}
+
+func TestJournalParamsRoundTrip(t *testing.T) {
+	from, to := image.Pt(10, 20), image.Pt(300, 400)
+	spec := &types.PathSpec{From: &from, To: &to, Via: []image.Point{{X: 50, Y: 60}}, MaxDist: 1000}
+	mask := image.NewGray(image.Rect(5, 5, 8, 7))
+	for i := range mask.Pix {
+		mask.Pix[i] = uint8(i * 40)
+	}
+	masked := newEditOp("smooth-area", &types.Brush{Mask: mask, Falloff: 3})
+	masked.SmoothBy = 4
+
+	ops := map[string]replayer{
+		"tectonics":        &tectonicsOp{Noise: 0.5, Points: 40},
+		"import-heightmap": &importOp{PNG: []byte{1, 2, 3}, Options: &types.ImportOptions{Points: 50, Stretch: true}},
+		"import-sketch":    &importOp{Sketch: true, PNG: []byte{4, 5}, Options: &types.ImportOptions{Points: 20}},
+		"mountains":        &mountainsOp{Tag: "range", Spec: spec, Scale: 1.5},
+		"volcanoes":        &volcanoesOp{Count: 3, Spec: spec},
+		"ravines":          &ravineOp{Tag: "gorge", Spec: spec, ForkChance: 0.2},
+		"smooth":           &smoothOp{Radius: 3},
+		"flatten":          &flattenOp{Area: image.Rect(10, 10, 490, 490)},
+		"archipelago":      &archipelagoOp{Tag: "isles", Spec: spec, Islands: 7},
+		"bay":              &bayOp{Centre: image.Pt(200, 100), Radius: 30},
+		"fjords":           &fjordsOp{Landmass: "lm", Depth: 20},
+		"raise":            &editOp{Kind: "raise", Polygon: []image.Point{{X: 1, Y: 1}, {X: 9, Y: 1}, {X: 5, Y: 9}}, Amount: 10},
+		"lower":            &editOp{Kind: "lower", Centre: image.Pt(50, 50), Radius: 10, Falloff: 2, Amount: 5},
+		"flatten-to":       &editOp{Kind: "flatten-to", Centre: image.Pt(50, 50), Radius: 10, Height: 120},
+		"smooth-area":      masked,
+		"noise":            &editOp{Kind: "noise", Centre: image.Pt(50, 50), Radius: 10, NoiseScale: 0.1, Amount: 8},
+		"undo":             &undoOp{},
+		"sea":              &seaOp{SeaLevel: 100, EquatorWidth: 50, ArcticWidth: 50, Currents: 2},
+		"bathymetry":       &bathymetryOp{},
+		"wind":             &windOp{},
+		"rain":             &rainOp{StormMult: 1.2, Winds: []types.Heading{types.EAST, types.SOUTHWEST}},
+		"seasons":          &seasonsOp{Seasons: 4, StormMult: 0.8},
+		"cryosphere":       &cryosphereOp{},
+		"rivers":           &riversOp{Threshold: 30},
+		"epoch":            &epochOp{},
+	}
+	assert.Equal(t, len(journalled), len(ops))
+
+	for kind, op := range ops {
+		mk, ok := journalled[kind]
+		assert.True(t, ok, kind)
+
+		params, err := json.Marshal(op)
+		assert.Nil(t, err, kind)
+		got := mk()
+		assert.Nil(t, json.Unmarshal(params, got), kind)
+		assert.Equal(t, op, got, kind)
+	}
+
+	// brush masks come back as they went in
+	b := ops["smooth-area"].(*editOp).brush()
+	assert.Equal(t, mask.Bounds(), b.Mask.Bounds())
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			assert.Equal(t, mask.At(x, y), b.Mask.At(x, y))
+		}
+	}
+}
+
+func TestJournalRecordsOperations(t *testing.T) {
+	ctx := context.Background()
+	e := testEditor(t)
+
+	p := &types.Project{Name: "journal"}
+	assert.Nil(t, e.CreateProject(p))
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 40))
+	assert.Nil(t, e.RaiseArea(ctx, p.ID, &types.Brush{Centre: image.Pt(100, 100), Radius: 20}, 10))
+	_, _, err := e.SeaMap(ctx, p.ID, 100, 50, 50, 2)
+	assert.Nil(t, err)
+
+	// failed operations are struck off again
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.NotNil(t, e.SmoothTerrain(cancelled, p.ID, 2))
+
+	expect := []replayer{
+		&tectonicsOp{Noise: 0.5, Points: 40},
+		&editOp{Kind: "raise", Centre: image.Pt(100, 100), Radius: 20, Amount: 10},
+		&seaOp{SeaLevel: 100, EquatorWidth: 50, ArcticWidth: 50, Currents: 2},
+	}
+	found, _, err := e.ListOperations(p.ID, "")
+	assert.Nil(t, err)
+	assert.Equal(t, len(expect), len(found))
+	for i, op := range found {
+		assert.Equal(t, i+1, op.Seq)
+		got := journalled[op.Kind]()
+		assert.Nil(t, json.Unmarshal(op.Params, got))
+		assert.Equal(t, expect[i], got, op.Kind)
+	}
+
+	head, err := e.db.JournalHead(p.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, len(expect), head)
+}
@@ -12,6 +12,11 @@ type Options struct {
 	Root           string
 	DatabaseDriver string
 	SearchDriver   string
+	StorageDriver  string
+
+	// InMemory keeps everything (db, canvases, graphs) in memory, nothing is written to
+	// disk unless asked for (see Snapshot)
+	InMemory bool
 }
 
 // setOpts sets options (from opts) in our config if they're non zero values
@@ -32,6 +37,14 @@ func setOpts(opts *Options, cfg *config.Config) {
 	if opts.SearchDriver != "" {
 		cfg.Search.Driver = opts.SearchDriver
 	}
+	if opts.StorageDriver != "" {
+		cfg.Storage.Driver = opts.StorageDriver
+	}
+	if opts.InMemory {
+		cfg.Database.Driver = config.DatabaseDriverSQLite
+		cfg.Database.Name = config.DatabaseNameMemory
+		cfg.Storage.Driver = config.StorageDriverMemory
+	}
 	if cfg.Database.Driver == config.DatabaseDriverSQLite {
 		if cfg.Database.Location == "" {
 			cfg.Database.Location = cfg.Gen.Root
@@ -42,5 +55,10 @@ func setOpts(opts *Options, cfg *config.Config) {
 			cfg.Search.Location = cfg.Gen.Root
 		}
 	}
+	if cfg.Storage.Driver == config.StorageDriverFilesystem {
+		if cfg.Storage.Location == "" {
+			cfg.Storage.Location = cfg.Gen.Root
+		}
+	}
 	log.Println("using root folder", cfg.Gen.Root)
 }
@@ -0,0 +1,23 @@
+package types
+
+import (
+	"image"
+)
+
+// Brush is the area a manual terrain edit applies to. Give one of Polygon, Radius
+// (a circle around Centre) or Mask.
+type Brush struct {
+	// Polygon outline, in world pixels
+	Polygon []image.Point
+
+	// Centre & Radius of a circle
+	Centre image.Point
+	Radius int
+
+	// Mask is a grayscale image placed at it's own bounds (in world pixels). White
+	// is the full edit, black is none.
+	Mask image.Image
+
+	// Falloff is how many pixels the edit fades out over at the edge of the area
+	Falloff int
+}
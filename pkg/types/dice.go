@@ -43,3 +43,13 @@ func (d *Dice) Roll() int {
 	}
 	return v
 }
+
+// RollWith rolls the dice using the given source of random numbers, so rolls can
+// be repeated by seeding it the same
+func (d *Dice) RollWith(rng *rand.Rand) int {
+	v := d.add
+	for _, x := range d.roll {
+		v += rng.Intn(x)
+	}
+	return v
+}
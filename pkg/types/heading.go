@@ -74,7 +74,6 @@ func (h Heading) IsDiagonal() bool {
 	default:
 		return false
 	}
-	return false
 }
 
 func (h Heading) Dist(a Heading) int {
@@ -0,0 +1,23 @@
+package types
+
+import (
+	"image/color"
+)
+
+var (
+	// Colours understood in a sketch (see ImportSketch). Other colours are taken as
+	// whichever of these they're closest to.
+	SketchSea      = color.RGBA{0, 0, 255, 255}
+	SketchLand     = color.RGBA{0, 255, 0, 255}
+	SketchMountain = color.RGBA{139, 69, 19, 255}
+)
+
+// ImportOptions control how an image is brought in as the starting terrain
+type ImportOptions struct {
+	// Points in the voronoi graph built over the terrain (as CreateTectonics)
+	Points int
+
+	// Stretch heights so the lowest in the image is 0 & the highest the max.
+	// Ignored for sketches.
+	Stretch bool
+}
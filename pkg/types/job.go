@@ -0,0 +1,43 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobStatus is the state of a job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job is some (long running) operation performed in the background
+type Job struct {
+	ID        string    `db:"id" json:"id"`
+	ProjectID string    `db:"project_id" json:"project_id"`
+	Kind      string    `db:"kind" json:"kind"`
+	Status    JobStatus `db:"status" json:"status"`
+	Error     string    `db:"error" json:"error,omitempty"`
+
+	// Progress (0-1) of the current step & a human readable message
+	// eg. "rain: 40% of storm fronts"
+	Progress float64 `db:"progress" json:"progress"`
+	Message  string  `db:"message" json:"message,omitempty"`
+
+	// Result is whatever the operation returned (if anything) as JSON
+	Result json.RawMessage `db:"result" json:"result,omitempty"`
+
+	Created  time.Time  `db:"created" json:"created"`
+	Started  *time.Time `db:"started" json:"started,omitempty"`
+	Finished *time.Time `db:"finished" json:"finished,omitempty"`
+}
+
+// Done returns if the job has finished (one way or another)
+func (j *Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
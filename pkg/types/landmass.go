@@ -1,15 +1,15 @@
 package types
 
 type Landmass struct {
-	ProjectID string `db:"project_id"`
-	ID        string `db:"id"`
-	Epoch     int    `db:"epoch"`
-	Size      int    `db:"size"`
+	ProjectID string `db:"project_id" json:"project_id"`
+	ID        string `db:"id" json:"id"`
+	Epoch     int    `db:"epoch" json:"epoch"`
+	Size      int    `db:"size" json:"size"`
 
-	ColorR int `db:"color_r"`
-	ColorG int `db:"color_g"`
-	ColorB int `db:"color_b"`
+	ColorR int `db:"color_r" json:"color_r"`
+	ColorG int `db:"color_g" json:"color_g"`
+	ColorB int `db:"color_b" json:"color_b"`
 
-	FirstX int `db:"first_x"`
-	FirstY int `db:"first_y"`
+	FirstX int `db:"first_x" json:"first_x"`
+	FirstY int `db:"first_y" json:"first_y"`
 }
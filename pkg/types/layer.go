@@ -0,0 +1,134 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Layer names some data of a project that can be read out as a single band
+// (eg. for export to a game engine).
+type Layer string
+
+const (
+	// LayerHeight is the amalgamated heightmap
+	LayerHeight Layer = "height"
+
+	// LayerRain is rainfall
+	LayerRain Layer = "rain"
+
+	// LayerSeaTemperature is sea water temperature (0 implies land)
+	LayerSeaTemperature Layer = "sea-temperature"
+
+	// LayerLandmass is the number of the landmass a pixel belongs to (0 implies sea)
+	LayerLandmass Layer = "landmass"
+
+	// LayerWindEast & LayerWindNorth are how far east & north the wind blows, 32768
+	// being still air (so less than that is west / south)
+	LayerWindEast  Layer = "wind-east"
+	LayerWindNorth Layer = "wind-north"
+
+	// LayerTemperature is the yearly average air temperature, 0 being -64 celsius &
+	// 65535 about 64 (see Seasons)
+	LayerTemperature Layer = "temperature"
+
+	// LayerTemperatureVariance & LayerRainVariance are how much temperature & rain
+	// change over the year (standard deviation between seasons), in the units of
+	// LayerTemperature & LayerRain
+	LayerTemperatureVariance Layer = "temperature-variance"
+	LayerRainVariance        Layer = "rain-variance"
+
+	// LayerMonsoon is 65535 where there are monsoons, 0 elsewhere
+	LayerMonsoon Layer = "monsoon"
+
+	// LayerIce is 65535 for sea ice, ice caps & glaciers & 32896 for snow above the
+	// snowline (see Cryosphere)
+	LayerIce Layer = "ice"
+
+	// LayerDepth is how deep the sea is, 0 being land (see Bathymetry)
+	LayerDepth Layer = "depth"
+
+	// LayerAll bundles all of the above into one multi-band output (in the order
+	// given by Layers). Only valid for multi-band formats.
+	LayerAll Layer = "all"
+)
+
+// Layers returns the single band layers in the order they're bundled in LayerAll
+func Layers() []Layer {
+	return []Layer{
+		LayerHeight, LayerRain, LayerSeaTemperature, LayerLandmass, LayerWindEast, LayerWindNorth,
+		LayerTemperature, LayerTemperatureVariance, LayerRainVariance, LayerMonsoon, LayerIce,
+		LayerDepth,
+	}
+}
+
+// layerSeason separates a layer from it's season in seasonal layer names
+const layerSeason = "-season-"
+
+// Seasonal returns the layer as it is in one season (eg. "rain-season-1"). Rain,
+// temperature & wind layers have seasons (see Seasons).
+func (l Layer) Seasonal(season int) Layer {
+	return Layer(fmt.Sprintf("%s%s%d", l, layerSeason, season))
+}
+
+// Season returns the layer & season of a seasonal layer (see Seasonal), ok is false if
+// it isn't one
+func (l Layer) Season() (Layer, int, bool) {
+	i := strings.LastIndex(string(l), layerSeason)
+	if i < 0 {
+		return l, 0, false
+	}
+	season, err := strconv.Atoi(string(l)[i+len(layerSeason):])
+	if err != nil || season < 0 {
+		return l, 0, false
+	}
+	return l[:i], season, true
+}
+
+// ExportFormat is a file format we know how to write layers out in
+type ExportFormat string
+
+const (
+	// ExportPNG16 is a 16 bit greyscale PNG
+	ExportPNG16 ExportFormat = "png16"
+
+	// ExportRawR16 is headerless little endian uint16 values, row by row.
+	// This is the format Unity & Unreal expect for terrain heightmaps.
+	ExportRawR16 ExportFormat = "r16"
+
+	// ExportGeoTIFF is a 16 bit greyscale TIFF with GeoTIFF tie-points
+	ExportGeoTIFF ExportFormat = "geotiff"
+
+	// ExportMultiBandTIFF is a GeoTIFF with one 16 bit sample per layer
+	ExportMultiBandTIFF ExportFormat = "tiff-multiband"
+
+	// ExportEXR is an uncompressed OpenEXR with one 32 bit float channel, values
+	// scaled to 0-1
+	ExportEXR ExportFormat = "exr"
+
+	// ExportGeoJSON is a GeoJSON FeatureCollection of vector features
+	// (coastlines, mountain ranges, volcanoes ..)
+	ExportGeoJSON ExportFormat = "geojson"
+
+	// ExportSVG is an SVG with one layer per kind of vector feature
+	ExportSVG ExportFormat = "svg"
+)
+
+// Extension returns the usual file extension for the format
+func (f ExportFormat) Extension() string {
+	switch f {
+	case ExportPNG16:
+		return ".png"
+	case ExportRawR16:
+		return ".r16"
+	case ExportGeoTIFF, ExportMultiBandTIFF:
+		return ".tif"
+	case ExportEXR:
+		return ".exr"
+	case ExportGeoJSON:
+		return ".geojson"
+	case ExportSVG:
+		return ".svg"
+	}
+	return ""
+}
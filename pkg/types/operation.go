@@ -0,0 +1,28 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Operation is an entry in a project's journal; a call that changed the world, with
+// everything needed to make it again.
+type Operation struct {
+	ID        string `db:"id" json:"id"`
+	ProjectID string `db:"project_id" json:"project_id"`
+
+	// Seq is the order of operations within a project, from 1
+	Seq int `db:"seq" json:"seq"`
+
+	// Epoch the operation was made in
+	Epoch int `db:"epoch" json:"epoch"`
+
+	// Kind of operation (eg. "mountains") & it's parameters as JSON
+	Kind   string          `db:"kind" json:"kind"`
+	Params json.RawMessage `db:"params" json:"params"`
+
+	// Seed math/rand was seeded with for the operation
+	Seed int64 `db:"seed" json:"seed"`
+
+	Created time.Time `db:"created" json:"created"`
+}
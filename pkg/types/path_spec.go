@@ -14,6 +14,18 @@ type PathSpec struct {
 	// If not given a point will be randomly chosen.
 	To *image.Point
 
+	// Via are points the path should pass through on the way (in order).
+	// These are snapped to the nearest point on the world's graph.
+	Via []image.Point
+
+	// Avoid are areas the path should not enter
+	Avoid []image.Rectangle
+
+	// Weights, if given, sets which weights are used to find the path & how much
+	// each counts. Eg. {"mountains": 0.7, "rivers": 0.3}
+	// By default only the weight of the kind of feature being added is used.
+	Weights map[string]float64
+
 	// MaxDist is the approximate max distance the path should follow
 	// starting from `From`
 	MaxDist float64
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"image"
+)
+
+// PathTree is a path with branches coming off of it (eg. a ravine & it's forks)
+type PathTree struct {
+	// Tag the path is saved under
+	Tag string
+
+	// Path is the points along this branch. A child's path starts on it's parent's path.
+	Path []image.Point
+
+	// Children are branches that fork from this path
+	Children []*PathTree
+}
+
+// Paths returns this path & the paths of all branches below it
+func (t *PathTree) Paths() [][]image.Point {
+	paths := [][]image.Point{t.Path}
+	for _, c := range t.Children {
+		paths = append(paths, c.Paths()...)
+	}
+	return paths
+}
@@ -4,13 +4,37 @@ import (
 	"fmt"
 )
 
+// Projection is how the surface of a world is laid out on it's (rectangular) canvases
+type Projection string
+
+const (
+	// ProjectionFlat worlds are flat, north at the top & south at the bottom
+	ProjectionFlat Projection = "flat"
+
+	// ProjectionSphere worlds are globes drawn equirectangular; x is longitude & y is
+	// latitude. Generation happens over the sphere so polar regions aren't inflated.
+	// They always Wrap & look best twice as wide as they are high.
+	ProjectionSphere Projection = "sphere"
+)
+
 type Project struct {
-	ID          string `db:"id"`
-	Name        string `db:"name"`
-	Epoch       int    `db:"epoch"`
-	Seed        int    `db:"seed"`
-	WorldWidth  int    `db:"world_width"`
-	WorldHeight int    `db:"world_height"`
+	ID          string `db:"id" json:"id"`
+	Name        string `db:"name" json:"name"`
+	Epoch       int    `db:"epoch" json:"epoch"`
+	Seed        int    `db:"seed" json:"seed"`
+	WorldWidth  int    `db:"world_width" json:"world_width"`
+	WorldHeight int    `db:"world_height" json:"world_height"`
+
+	// Wrap worlds are cylinders, the east & west edges (x=0 & x=WorldWidth-1) meet
+	Wrap bool `db:"wrap" json:"wrap"`
+
+	// Projection of the world onto canvases, flat if not set
+	Projection Projection `db:"projection" json:"projection"`
+}
+
+// Sphere returns if the world is a globe (see ProjectionSphere)
+func (p *Project) Sphere() bool {
+	return p.Projection == ProjectionSphere
 }
 
 func (p *Project) Canvas(name string) string {
@@ -5,6 +5,7 @@ import (
 	"math/rand"
 
 	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/internal/geography"
 	"github.com/voidshard/genesis/pkg/types"
 )
 
@@ -15,6 +16,13 @@ const (
 var (
 	// ErrNotFound our generic 404
 	ErrNotFound = fmt.Errorf("not found")
+
+	// ErrExists is returned when making something that already exists
+	ErrExists = fmt.Errorf("already exists")
+
+	// ErrNoPath is returned when we can't find a path between two points
+	// (eg. for a mountain range)
+	ErrNoPath = geography.ErrNoPath
 )
 
 // Project returns the given project by name or ID.
@@ -24,7 +32,6 @@ func (e *Editor) Project(key string) (*types.Project, error) {
 	if !dbutils.IsValidID(key) {
 		// possible because IDs are deterministic
 		id = dbutils.NewID(key)
-		fmt.Println("SET ID TO", id)
 	}
 	ps, err := e.Projects([]string{id})
 	if err != nil {
@@ -47,6 +54,14 @@ func (e *Editor) ListProjects(tkn string) ([]*types.Project, string, error) {
 func (e *Editor) CreateProject(in *types.Project) error {
 	in.ID = dbutils.NewID(in.Name)
 
+	found, err := e.Projects([]string{in.ID})
+	if err != nil {
+		return err
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("%w: project '%s'", ErrExists, in.Name)
+	}
+
 	// overwrite anything invalid
 	if in.Seed <= 0 {
 		in.Seed = int(rand.Int63())
@@ -57,6 +72,12 @@ func (e *Editor) CreateProject(in *types.Project) error {
 	if in.WorldHeight < minWorldSize {
 		in.WorldHeight = minWorldSize
 	}
+	if in.Projection != types.ProjectionSphere {
+		in.Projection = types.ProjectionFlat
+	}
+	if in.Sphere() {
+		in.Wrap = true // the far side of a globe is always next door
+	}
 
 	txn, err := e.db.Begin()
 	if err != nil {
@@ -70,3 +91,33 @@ func (e *Editor) CreateProject(in *types.Project) error {
 
 	return txn.Commit()
 }
+
+// DeleteProject removes a project, it's database rows and all of it's
+// canvases & graphs (in every epoch).
+func (e *Editor) DeleteProject(key string) error {
+	p, err := e.Project(key)
+	if err != nil {
+		return err
+	}
+
+	txn, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = txn.DeleteProject(p.ID)
+	if err != nil {
+		txn.Rollback()
+		return err
+	}
+	err = txn.Commit()
+	if err != nil {
+		return err
+	}
+
+	err = e.tiles.InvalidateProject(p.ID)
+	if err != nil {
+		return err
+	}
+
+	return e.geoEdit.DeleteProject(p.ID)
+}
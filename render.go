@@ -0,0 +1,73 @@
+package genesis
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"github.com/voidshard/genesis/internal/render"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// Render draws `area` of the current epoch as a coloured map in the named style
+// (eg. atlas, parchment, satellite). See render.Register for adding styles.
+func (e *Editor) Render(proj, style string, area image.Rectangle) (image.Image, error) {
+	st, err := render.GetStyle(style)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := e.Project(proj)
+	if err != nil {
+		return nil, err
+	}
+	area = area.Intersect(image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+
+	height, err := e.geoEdit.Layer(p.ID, types.LayerHeight, area)
+	if err != nil {
+		return nil, err
+	}
+	water, err := e.geoEdit.Layer(p.ID, types.LayerSeaTemperature, area)
+	if err != nil {
+		return nil, err
+	}
+	rain, err := e.geoEdit.Layer(p.ID, types.LayerRain, area)
+	if err != nil {
+		return nil, err
+	}
+
+	features, err := e.geoEdit.TaggedFeatures(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	labels := []*render.Label{}
+	for _, f := range features {
+		if f.Name == "" || strings.HasPrefix(f.Name, f.Kind+"/") {
+			continue // unnamed (or at least, named by us rather than the user)
+		}
+		at, ok := f.Anchor()
+		if !ok {
+			continue
+		}
+		labels = append(labels, &render.Label{
+			Text: f.Name,
+			At:   image.Pt(int(math.Round(at.X)), int(math.Round(at.Y))),
+		})
+	}
+
+	return render.Render(&render.Layers{
+		Height: height,
+		Water:  water,
+		Rain:   rain,
+		Labels: labels,
+	}, st)
+}
+
+// RenderPNG is Render but writes the result to `path` as a PNG
+func (e *Editor) RenderPNG(proj, style string, area image.Rectangle, path string) error {
+	im, err := e.Render(proj, style, area)
+	if err != nil {
+		return err
+	}
+	return render.WritePNG(path, im)
+}
@@ -0,0 +1,58 @@
+package genesis
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+// mean returns the average value of a layer over the whole world
+func mean(t *testing.T, e *Editor, p *types.Project, l types.Layer) float64 {
+	im, err := e.Layer(p.ID, l, image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+	assert.Nil(t, err)
+	total := 0.0
+	for y := 0; y < p.WorldHeight; y++ {
+		for x := 0; x < p.WorldWidth; x++ {
+			total += float64(im.Gray16At(x, y).Y)
+		}
+	}
+	return total / float64(p.WorldWidth*p.WorldHeight)
+}
+
+func TestRescale(t *testing.T) {
+	ctx := context.Background()
+	e := testEditor(t)
+
+	p := &types.Project{Name: "rescale"}
+	assert.Nil(t, e.CreateProject(p))
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 40))
+	_, _, err := e.SeaMap(ctx, p.ID, 100, 50, 50, 2)
+	assert.Nil(t, err)
+	_, err = e.Bathymetry(ctx, p.ID)
+	assert.Nil(t, err)
+
+	scaled, err := e.Rescale(ctx, p.ID, 1000, 600)
+	assert.Nil(t, err)
+	assert.Equal(t, "rescale-1000x600", scaled.Name)
+	assert.Equal(t, 1000, scaled.WorldWidth)
+	assert.Equal(t, 600, scaled.WorldHeight)
+	assert.Equal(t, p.Seed, scaled.Seed)
+
+	found, err := e.Project(scaled.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, scaled.WorldWidth, found.WorldWidth)
+
+	// terrain is redrawn & the sea depth stretched, so both look much the same overall
+	for _, l := range []types.Layer{types.LayerHeight, types.LayerDepth} {
+		want := mean(t, e, p, l)
+		assert.Greater(t, want, 0.0, l)
+		assert.InEpsilon(t, want, mean(t, e, scaled, l), 0.1, l)
+	}
+
+	_, err = e.Rescale(ctx, p.ID, 1000, 600)
+	assert.ErrorIs(t, err, ErrExists)
+}
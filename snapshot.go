@@ -0,0 +1,100 @@
+package genesis
+
+import (
+	"github.com/voidshard/genesis/internal/blob"
+	"github.com/voidshard/genesis/internal/config"
+	"github.com/voidshard/genesis/internal/database"
+	"github.com/voidshard/genesis/internal/paint"
+	"github.com/voidshard/genesis/internal/voronoi"
+)
+
+const (
+	// snapshotDatabase is the default sqlite db name, so Options{Root: dir} finds it
+	snapshotDatabase = "genesis.sqlite"
+)
+
+// Snapshot writes every project (db rows, canvases & graphs) into `dir` laid out as a
+// root folder, so it can be opened later with Options{Root: dir}.
+func (e *Editor) Snapshot(dir string) error {
+	store, err := blob.NewFilesystem(dir)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewSqlite3(&config.Database{
+		Driver:   config.DatabaseDriverSQLite,
+		Name:     snapshotDatabase,
+		Location: dir,
+	})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = database.Copy(db, e.db)
+	if err != nil {
+		return err
+	}
+
+	tkn := ""
+	for {
+		projects, next, err := e.db.ListProjects(tkn)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range projects {
+			src := paint.New(e.store, e.cfg.Gen.Root, p.WorldWidth, p.WorldHeight)
+			dst := paint.New(store, dir, p.WorldWidth, p.WorldHeight)
+
+			// canvases are written out by the painter (they're stored differently
+			// depending on where they live)
+			names, err := paint.Names(e.store, p.ID+"-")
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				cnv, err := src.Canvas(name)
+				if err != nil {
+					return err
+				}
+				im, err := paint.Image(cnv)
+				if err != nil {
+					return err
+				}
+				out, err := dst.NewCanvasFromImage(name, im)
+				if err != nil {
+					return err
+				}
+				err = dst.Save(out)
+				if err != nil {
+					return err
+				}
+			}
+
+			// graphs can be copied as is
+			keys, err := e.store.List(p.ID + "-")
+			if err != nil {
+				return err
+			}
+			for _, k := range keys {
+				if !voronoi.IsKey(k) {
+					continue
+				}
+				data, err := e.store.Get(k)
+				if err != nil {
+					return err
+				}
+				err = store.Put(k, data)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		tkn = next
+	}
+}
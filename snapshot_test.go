@@ -0,0 +1,67 @@
+package genesis
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	e := testEditor(t)
+
+	p := &types.Project{Name: "snapshot"}
+	assert.Nil(t, e.CreateProject(p))
+	assert.Nil(t, e.CreateTectonics(ctx, p.ID, 0.5, 40))
+	_, _, err := e.AddMountainRange(ctx, p.ID, "range", nil, 1)
+	assert.Nil(t, err)
+	_, _, err = e.SeaMap(ctx, p.ID, 100, 50, 50, 2)
+	assert.Nil(t, err)
+
+	dir := t.TempDir()
+	assert.Nil(t, e.Snapshot(dir))
+
+	// the snapshot opens as a root folder, with the world as it was
+	opened, err := New(&Options{Root: dir})
+	assert.Nil(t, err)
+	defer opened.Close()
+
+	reopened, err := opened.Project(p.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, p.Name, reopened.Name)
+	assert.Equal(t, p.WorldWidth, reopened.WorldWidth)
+
+	made := layers(t, e, p.ID)
+	for l, im := range layers(t, opened, p.ID) {
+		assert.Equal(t, made[l].Pix, im.Pix, l)
+	}
+
+	// graphs come too, so features recorded on them are the same (in any order)
+	vector := func(e *Editor) []string {
+		path := filepath.Join(t.TempDir(), "world.geojson")
+		assert.Nil(t, e.ExportVector(p.ID, types.ExportGeoJSON, path))
+		data, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+
+		collection := struct {
+			Features []json.RawMessage `json:"features"`
+		}{}
+		assert.Nil(t, json.Unmarshal(data, &collection))
+		features := []string{}
+		for _, f := range collection.Features {
+			features = append(features, string(f))
+		}
+		sort.Strings(features)
+		return features
+	}
+	want, got := vector(e), vector(opened)
+	assert.Greater(t, len(want), 0)
+	assert.Equal(t, want, got)
+}
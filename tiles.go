@@ -0,0 +1,176 @@
+package genesis
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/voidshard/genesis/internal/dbutils"
+	"github.com/voidshard/genesis/internal/geography"
+	"github.com/voidshard/genesis/internal/render"
+	"github.com/voidshard/genesis/internal/tiles"
+	"github.com/voidshard/genesis/pkg/types"
+)
+
+const (
+	// tilePad is how far past the edge of a tile we read when rendering it, so
+	// smoothing & hillshading line up with neighbouring tiles
+	tilePad = 4
+
+	// idLength is the length of a project ID (a UUID string)
+	idLength = 36
+)
+
+// Tile returns XYZ (slippy map) tile z/x/y as a PNG for the given epoch.
+//
+// Layer is either a data layer (eg. height, rain) which gives 16 bit greyscale tiles,
+// or a render style (eg. atlas, satellite) which gives coloured tiles.
+// Tiles are cached on disk until a canvas of the epoch is saved.
+func (e *Editor) Tile(proj string, epoch int, layer string, z, x, y int) ([]byte, error) {
+	p, err := e.Project(proj)
+	if err != nil {
+		return nil, err
+	}
+	if epoch < 0 || epoch > p.Epoch {
+		return nil, fmt.Errorf("%w epoch %d", ErrNotFound, epoch)
+	}
+	world := image.Rect(0, 0, p.WorldWidth, p.WorldHeight)
+
+	var src tiles.Source
+	style, err := render.GetStyle(layer)
+	if err == nil {
+		src = e.renderTileSource(p, epoch, style)
+	} else if isLayer(types.Layer(layer)) {
+		src = func(area image.Rectangle) (image.Image, error) {
+			return e.geoEdit.LayerFromEpoch(p.ID, epoch, types.Layer(layer), area)
+		}
+	} else {
+		return nil, fmt.Errorf("%w %s", geography.ErrUnknownLayer, layer)
+	}
+
+	data, err := e.tiles.Tile(tiles.Key{Project: p.ID, Epoch: epoch, Layer: layer}, world, z, x, y, src)
+	if errors.Is(err, tiles.ErrOutOfRange) {
+		return nil, fmt.Errorf("%w %v", ErrNotFound, err)
+	}
+	return data, err
+}
+
+// renderTileSource returns a tile source that renders areas of the given epoch.
+//
+// Value ranges are measured over the whole world (once) so tiles are coloured
+// consistently with each other.
+func (e *Editor) renderTileSource(p *types.Project, epoch int, style render.Style) tiles.Source {
+	return func(area image.Rectangle) (image.Image, error) {
+		rng, err := e.tileRanges(p, epoch)
+		if err != nil {
+			return nil, err
+		}
+
+		padded := area.Inset(-tilePad).Intersect(image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+		in, err := e.renderLayers(p, epoch, padded)
+		if err != nil {
+			return nil, err
+		}
+		in.Ranges = rng
+		in.Plain = true
+
+		im, err := render.Render(in, style)
+		if err != nil {
+			return nil, err
+		}
+
+		// render output starts at 0,0
+		return im.(*image.RGBA).SubImage(area.Sub(padded.Min)), nil
+	}
+}
+
+// tileRanges returns the value ranges for a whole epoch, measured a strip at a time
+func (e *Editor) tileRanges(p *types.Project, epoch int) (*render.Ranges, error) {
+	key := fmt.Sprintf("%s-%d", p.ID, epoch)
+
+	e.rangeLock.Lock()
+	defer e.rangeLock.Unlock()
+
+	rng, ok := e.ranges[key]
+	if ok {
+		return rng, nil
+	}
+
+	rng = render.NewRanges()
+	for y := 0; y < p.WorldHeight; y += tiles.Size {
+		in, err := e.renderLayers(p, epoch, image.Rect(0, y, p.WorldWidth, y+tiles.Size))
+		if err != nil {
+			return nil, err
+		}
+		rng.Measure(in)
+	}
+
+	e.ranges[key] = rng
+	return rng, nil
+}
+
+// renderLayers reads the layers needed to render `area` of an epoch
+func (e *Editor) renderLayers(p *types.Project, epoch int, area image.Rectangle) (*render.Layers, error) {
+	area = area.Intersect(image.Rect(0, 0, p.WorldWidth, p.WorldHeight))
+
+	height, err := e.geoEdit.LayerFromEpoch(p.ID, epoch, types.LayerHeight, area)
+	if err != nil {
+		return nil, err
+	}
+	water, err := e.geoEdit.LayerFromEpoch(p.ID, epoch, types.LayerSeaTemperature, area)
+	if err != nil {
+		return nil, err
+	}
+	rain, err := e.geoEdit.LayerFromEpoch(p.ID, epoch, types.LayerRain, area)
+	if err != nil {
+		return nil, err
+	}
+
+	return &render.Layers{Height: height, Water: water, Rain: rain}, nil
+}
+
+// canvasChanged throws away cached tiles built from the named canvas.
+//
+// Canvases are named <project id>-<epoch>-<name> (see types.Project) or
+// <project id>-<name> for those shared by all epochs (eg. noise).
+func (e *Editor) canvasChanged(name string) {
+	if len(name) <= idLength || !dbutils.IsValidID(name[:idLength]) {
+		return
+	}
+	id := name[:idLength]
+
+	var err error
+	epoch, aerr := strconv.Atoi(strings.SplitN(name[idLength+1:], "-", 2)[0])
+
+	e.rangeLock.Lock()
+	for k := range e.ranges {
+		if k == fmt.Sprintf("%s-%d", id, epoch) || (aerr != nil && strings.HasPrefix(k, id)) {
+			delete(e.ranges, k)
+		}
+	}
+	e.rangeLock.Unlock()
+
+	if aerr == nil {
+		err = e.tiles.Invalidate(id, epoch)
+	} else {
+		err = e.tiles.InvalidateProject(id)
+	}
+	if err != nil {
+		log.Println("failed to invalidate tiles for canvas", name, err)
+	}
+}
+
+func isLayer(l types.Layer) bool {
+	if base, _, ok := l.Season(); ok {
+		l = base
+	}
+	for _, known := range types.Layers() {
+		if l == known {
+			return true
+		}
+	}
+	return false
+}